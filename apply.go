@@ -0,0 +1,130 @@
+// apply.go
+
+package janet
+
+/*
+#include <stdlib.h>
+
+#include "janet.h"
+
+static int takesKeywordArgs(JanetFunction *fn) {
+    return (fn->def->flags & JANET_FUNCDEF_FLAG_STRUCTARG) != 0;
+}
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"unsafe"
+)
+
+// Kwargs is the keyword arguments of a janet function call (see VM.Apply).
+//
+// It is converted to a janet struct with keyword keys (eg. Kwargs{"width": 3} to `{:width 3}`),
+// or to keyword/value pairs when it is the last argument of a function with `&keys` or `&named` parameters.
+type Kwargs map[string]any
+
+// pairs returns the keyword/value pairs of the arguments converted to janet, sorted by their keys.
+func (k Kwargs) pairs(e *encoder) ([]C.Janet, error) {
+	keys := make([]string, 0, len(k))
+	for key := range k {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	pairs := make([]C.Janet, 0, len(k)*2)
+	for _, key := range keys {
+		value, err := e.encode(k[key])
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert keyword argument '%s': %w", key, err)
+		}
+		pairs = append(pairs, janetKeyword(strings.TrimPrefix(key, ":")), value)
+	}
+	return pairs, nil
+}
+
+// janet function which calls other callable values (eg. cfunctions, keywords, or tables)
+const applierSource = `(fn [f & args] (f ;args))`
+
+// Apply calls the function bound to `name` (eg. "string/join") with `args` converted to janet values,
+// and returns its result converted to a go value.
+//
+// When the last argument is Kwargs and the function takes `&keys` or `&named` parameters,
+// it is passed as keyword/value pairs (eg. `:width 3`), otherwise as a struct (eg. `{:width 3}`).
+//
+// Unlike Execute, it does not parse or compile any source, so it is faster for calling small functions repeatedly.
+func (vm *VM) Apply(
+	ctx context.Context,
+	name string,
+	args ...any,
+) (result any, err error) {
+	res, err := runOnVM(ctx, vm, func(env *C.JanetTable) valueResult {
+		var callable C.Janet
+		cName := C.CString(name)
+		defer C.free(unsafe.Pointer(cName))
+		if C.janet_resolve(env, C.janet_csymbol(cName), &callable) == C.JANET_BINDING_NONE {
+			return valueResult{err: fmt.Errorf("unknown binding: %s", name)}
+		}
+
+		janetResult, err := vm.call(env, callable, args)
+		if err != nil {
+			return valueResult{err: err}
+		}
+
+		value, err := vm.decoder(ctx).decode(janetResult)
+		return valueResult{value: value, err: err}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.value, res.err
+}
+
+// call calls a janet `callable` (eg. a function, cfunction, or keyword) with `args` converted to janet values.
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) call(
+	env *C.JanetTable,
+	callable C.Janet,
+	args []any,
+) (C.Janet, error) {
+	enc := vm.encoder()
+	argv := make([]C.Janet, 0, len(args)+1)
+	var kwargs Kwargs
+	if C.janet_checktype(callable, C.JANET_FUNCTION) != 0 && len(args) > 0 && C.takesKeywordArgs(C.janet_unwrap_function(callable)) != 0 {
+		if k, ok := args[len(args)-1].(Kwargs); ok {
+			kwargs, args = k, args[:len(args)-1]
+		}
+	}
+	if C.janet_checktype(callable, C.JANET_FUNCTION) == 0 {
+		// call other callables through the applier
+		if vm.applier == nil {
+			helper, err := compileHelper(env, applierSource)
+			if err != nil {
+				return C.janet_wrap_nil(), err
+			}
+			vm.applier = helper
+		}
+		argv = append(argv, callable)
+		callable = C.janet_wrap_function(vm.applier)
+	}
+	for i, arg := range args {
+		converted, err := enc.encode(arg)
+		if err != nil {
+			return C.janet_wrap_nil(), fmt.Errorf("failed to convert argument #%d: %w", i, err)
+		}
+		argv = append(argv, converted)
+	}
+	if kwargs != nil {
+		pairs, err := kwargs.pairs(enc)
+		if err != nil {
+			return C.janet_wrap_nil(), err
+		}
+		argv = append(argv, pairs...)
+	}
+
+	return pcall(env, C.janet_unwrap_function(callable), argv...)
+}
@@ -0,0 +1,95 @@
+// apply_test.go
+
+package janet
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestApply tests calling functions without parsing sources.
+func TestApply(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	if _, _, _, err := vm.Execute(ctx, `(defn add [x y] (+ x y)) (def colors @{"red" "ff0000"})`); err != nil {
+		t.Fatalf("Failed to define functions: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		args []any
+
+		expected any
+	}{
+		{"string/join", []any{[]string{"a", "b", "c"}, ", "}, "a, b, c"},
+		{"+", []any{1, 2, 3.5}, float64(6.5)},
+		{"add", []any{10, 20}, float64(30)},
+		{"reverse", []any{[]int{1, 2, 3}}, []any{float64(3), float64(2), float64(1)}},
+		{"colors", []any{"red"}, "ff0000"},
+	}
+	for _, test := range tests {
+		if result, err := vm.Apply(ctx, test.name, test.args...); err != nil {
+			t.Errorf("Failed to apply '%s': %v", test.name, err)
+		} else if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("Expected %v for '%s', got %v", test.expected, test.name, result)
+		}
+	}
+
+	// errors
+	for _, test := range []struct {
+		name string
+		args []any
+
+		expectedErrPattern string
+	}{
+		{"no-such-function", nil, "unknown binding"},
+		{"add", []any{1}, "arity mismatch"},
+		{"string/join", []any{1}, "expected"},
+		{"error", []any{"oops"}, "oops"},
+		{"add", []any{1, make(chan int)}, "failed to convert argument #1"},
+	} {
+		if _, err := vm.Apply(ctx, test.name, test.args...); err == nil || !strings.Contains(err.Error(), test.expectedErrPattern) {
+			t.Errorf("Expected error with '%s' for '%s', got: %v", test.expectedErrPattern, test.name, err)
+		}
+	}
+}
+
+// TestApplyKwargs tests calling functions with keyword arguments.
+func TestApplyKwargs(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	if _, _, _, err := vm.Execute(ctx, `
+(defn widget-keys [name &keys {:width w :height h}] (string name ":" w "x" h))
+(defn widget-named [name &named width height] (string name ":" width "x" height))
+(defn widget-struct [name opts] (string name ":" (opts :width) "x" (opts :height)))
+`); err != nil {
+		t.Fatalf("Failed to define functions: %v", err)
+	}
+
+	for _, name := range []string{"widget-keys", "widget-named", "widget-struct"} {
+		if result, err := vm.Apply(ctx, name, "box", Kwargs{"width": 3, ":height": 4}); err != nil {
+			t.Errorf("Failed to apply '%s' with kwargs: %v", name, err)
+		} else if result != "box:3x4" {
+			t.Errorf("Expected 'box:3x4' for '%s', got %v", name, result)
+		}
+	}
+
+	// kwargs for other functions are converted to structs
+	if result, err := vm.Apply(ctx, "struct?", Kwargs{"width": 3}); err != nil || result != true {
+		t.Errorf("Expected kwargs to be converted to a struct, got %v (%v)", result, err)
+	}
+}
@@ -0,0 +1,130 @@
+// arena.go
+
+package janet
+
+import (
+	"unsafe"
+)
+
+// number of elements (or bytes) of blocks of arenas
+const arenaBlockSize = 4096
+
+// Arena is a reusable pool of memory for converting janet values to go (see WithArena),
+// for pipelines which convert lots of values without leaving garbage for each of them.
+//
+// Slices and strings of converted values are carved out of blocks of the arena,
+// and maps are reused after they are cleared. Converted values are valid until Release is called,
+// after which the memory is reused by following conversions (so the values should not be used anymore).
+//
+// Numbers are still boxed by go, and *OrderedMap values and huge slices (longer than 4096 elements)
+// are allocated as usual. An Arena should not be used by concurrent conversions.
+type Arena struct {
+	values slab[any]  // elements of slices
+	bytes  slab[byte] // bytes of strings
+
+	maps           []map[any]any    // maps handed out since the last release
+	stringMaps     []map[string]any // maps with string keys handed out since the last release
+	freeMaps       []map[any]any    // cleared maps for reuse
+	freeStringMaps []map[string]any // cleared maps with string keys for reuse
+}
+
+// NewArena returns a new empty arena.
+func NewArena() *Arena {
+	return &Arena{}
+}
+
+// WithArena makes the execution convert its result to go with the memory of `arena`,
+// so that the converted value is valid until `arena` is released.
+//
+// It applies to functions which convert values to go (eg. VM.ParseToValue, VM.EvalValue, and VM.ExecuteInto).
+// Values stored into go values with VM.ExecuteInto are copied, except the ones stored into `any`.
+func WithArena(arena *Arena) ExecOption {
+	return func(o *execOptions) {
+		o.arena = arena
+	}
+}
+
+// Release makes all values converted with the arena invalid, and keeps their memory for following conversions.
+func (a *Arena) Release() {
+	a.values.reset()
+	a.bytes.reset()
+
+	for _, m := range a.maps {
+		clear(m)
+	}
+	for _, m := range a.stringMaps {
+		clear(m)
+	}
+	a.freeMaps, a.maps = append(a.freeMaps, a.maps...), a.maps[:0]
+	a.freeStringMaps, a.stringMaps = append(a.freeStringMaps, a.stringMaps...), a.stringMaps[:0]
+}
+
+// slice returns a slice of `n` elements.
+func (a *Arena) slice(n int) []any {
+	return a.values.alloc(n)
+}
+
+// string returns a copy of `b` (prefixed with `prefix`, if any) in the arena.
+func (a *Arena) string(prefix string, b []byte) string {
+	buf := a.bytes.alloc(len(prefix) + len(b))
+	if len(buf) == 0 {
+		return ""
+	}
+	copy(buf[copy(buf, prefix):], b)
+	return unsafe.String(&buf[0], len(buf))
+}
+
+// anyMap returns an empty map[any]any.
+func (a *Arena) anyMap(count int) map[any]any {
+	var m map[any]any
+	if n := len(a.freeMaps); n > 0 {
+		m, a.freeMaps = a.freeMaps[n-1], a.freeMaps[:n-1]
+	} else {
+		m = make(map[any]any, count)
+	}
+	a.maps = append(a.maps, m)
+	return m
+}
+
+// stringMap returns an empty map[string]any.
+func (a *Arena) stringMap(count int) map[string]any {
+	var m map[string]any
+	if n := len(a.freeStringMaps); n > 0 {
+		m, a.freeStringMaps = a.freeStringMaps[n-1], a.freeStringMaps[:n-1]
+	} else {
+		m = make(map[string]any, count)
+	}
+	a.stringMaps = append(a.stringMaps, m)
+	return m
+}
+
+// slab allocates slices out of blocks, which are reused after reset.
+type slab[T any] struct {
+	blocks  [][]T
+	current int // index of the block being used
+	used    int // number of used items in the current block
+}
+
+// alloc returns a zeroed slice of `n` items (with no spare capacity, not to overwrite the following ones on appending).
+func (s *slab[T]) alloc(n int) []T {
+	if n > arenaBlockSize {
+		return make([]T, n)
+	}
+	if s.current < len(s.blocks) && s.used+n > arenaBlockSize {
+		s.current, s.used = s.current+1, 0
+	}
+	if s.current == len(s.blocks) {
+		s.blocks = append(s.blocks, make([]T, arenaBlockSize))
+	}
+	items := s.blocks[s.current][s.used : s.used+n : s.used+n]
+	s.used += n
+	return items
+}
+
+// reset makes all the blocks reusable, clearing the used items (not to keep references to them).
+func (s *slab[T]) reset() {
+	for i := range min(s.current+1, len(s.blocks)) {
+		clear(s.blocks[i])
+	}
+	s.current, s.used = 0, 0
+}
@@ -0,0 +1,76 @@
+// arena_test.go
+
+package janet
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// TestArena tests converting values with the memory of arenas.
+func TestArena(t *testing.T) {
+	vm, err := NewVM(WithMapKeys(MapKeysTrimmed))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	if _, _, _, err := vm.Execute(ctx, `(def large (seq [i :range [0 1000]] {:id i :name (string "n" i) :tags [:a 'b "c"]}))`); err != nil {
+		t.Fatalf("Failed to define a large collection: %v", err)
+	}
+	expected, err := vm.ParseToValue(ctx, `large`)
+	if err != nil {
+		t.Fatalf("Failed to convert: %v", err)
+	}
+
+	arena := NewArena()
+	for i := range 3 {
+		converted, err := vm.ParseToValue(ctx, `large`, WithArena(arena))
+		if err != nil {
+			t.Fatalf("Failed to convert with the arena: %v", err)
+		}
+		if !reflect.DeepEqual(converted, expected) {
+			t.Errorf("Expected the same value with the arena (#%d)", i)
+		}
+
+		evaluated, _, _, err := vm.EvalValue(ctx, `[:x "yz" {:k []}]`, WithArena(arena))
+		if err != nil {
+			t.Fatalf("Failed to evaluate with the arena: %v", err)
+		}
+		if !reflect.DeepEqual(evaluated, []any{":x", "yz", map[string]any{"k": []any{}}}) {
+			t.Errorf("Expected the evaluated value with the arena, got %v", evaluated)
+		}
+
+		var into struct {
+			Name string
+			Tags []string
+		}
+		if err := vm.ExecuteInto(ctx, `(first large)`, &into, WithArena(arena)); err != nil {
+			t.Fatalf("Failed to execute into a struct with the arena: %v", err)
+		}
+		if into.Name != "n0" || !reflect.DeepEqual(into.Tags, []string{":a", "b", "c"}) {
+			t.Errorf("Expected the stored value with the arena, got %+v", into)
+		}
+
+		// memory is reused after release
+		blocks, maps := len(arena.values.blocks), len(arena.maps)+len(arena.stringMaps)
+		arena.Release()
+		if i > 0 && (len(arena.values.blocks) != blocks || len(arena.freeStringMaps) != maps) {
+			t.Errorf("Expected memory to be reused, got %d blocks and %d maps", len(arena.values.blocks), len(arena.freeStringMaps))
+		}
+	}
+
+	// slices do not overwrite following ones when they are appended to
+	converted, err := vm.ParseToValue(ctx, `[[1 2] [3 4]]`, WithArena(arena))
+	if err != nil {
+		t.Fatalf("Failed to convert with the arena: %v", err)
+	}
+	pairs := converted.([]any)
+	_ = append(pairs[0].([]any), 5.0)
+	if !reflect.DeepEqual(pairs[1], []any{3.0, 4.0}) {
+		t.Errorf("Expected the following slice not to be overwritten, got %v", pairs[1])
+	}
+}
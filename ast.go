@@ -0,0 +1,502 @@
+// ast.go
+
+package janet
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Position is a position in janet source.
+type Position struct {
+	Offset int // byte offset (starting from 0)
+	Line   int // line number (starting from 1)
+	Column int // column number in bytes (starting from 1)
+}
+
+// String returns the position in `line:column` format.
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// NodeKind is the kind of a janet AST node.
+type NodeKind int
+
+// kinds of janet AST nodes
+const (
+	NodeNil          NodeKind = iota // nil
+	NodeBoolean                      // true or false
+	NodeNumber                       // numbers
+	NodeString                       // "string" or `long string`
+	NodeBuffer                       // @"buffer" or @`long buffer`
+	NodeSymbol                       // symbols
+	NodeKeyword                      // :keywords
+	NodeTuple                        // (tuple)
+	NodeBracketTuple                 // [bracket tuple]
+	NodeArray                        // @[array] or @(array)
+	NodeStruct                       // {struct}
+	NodeTable                        // @{table}
+	NodeReaderMacro                  // 'quote, ~quasiquote, ,unquote, ;splice, or |short-fn
+	NodeComment                      // # comment
+)
+
+// names of node kinds
+var nodeKindNames = map[NodeKind]string{
+	NodeNil:          "nil",
+	NodeBoolean:      "boolean",
+	NodeNumber:       "number",
+	NodeString:       "string",
+	NodeBuffer:       "buffer",
+	NodeSymbol:       "symbol",
+	NodeKeyword:      "keyword",
+	NodeTuple:        "tuple",
+	NodeBracketTuple: "bracket-tuple",
+	NodeArray:        "array",
+	NodeStruct:       "struct",
+	NodeTable:        "table",
+	NodeReaderMacro:  "reader-macro",
+	NodeComment:      "comment",
+}
+
+// String returns the name of the node kind.
+func (k NodeKind) String() string {
+	if name, exists := nodeKindNames[k]; exists {
+		return name
+	}
+	return fmt.Sprintf("NodeKind(%d)", int(k))
+}
+
+// IsCollection returns whether the node kind is a collection (tuples, arrays, structs, and tables).
+func (k NodeKind) IsCollection() bool {
+	switch k {
+	case NodeTuple, NodeBracketTuple, NodeArray, NodeStruct, NodeTable:
+		return true
+	}
+	return false
+}
+
+// Node is a node of janet AST.
+type Node struct {
+	Kind NodeKind
+
+	// source text of atoms and comments,
+	// opening delimiters of collections (eg. `(`, `@[`),
+	// or prefixes of reader macros (eg. `'`, `~`)
+	Text string
+
+	// decoded value of literals:
+	// nil, bool, float64, or string (for strings, buffers, symbols, and keywords without leading colons)
+	Value any
+
+	// elements of collections (including comments),
+	// or the form of reader macros
+	Children []*Node
+
+	Start Position // position of the first byte of the node
+	End   Position // position right after the last byte of the node
+}
+
+// Forms returns the children of the node excluding comments.
+func (n *Node) Forms() []*Node {
+	forms := make([]*Node, 0, len(n.Children))
+	for _, child := range n.Children {
+		if child.Kind != NodeComment {
+			forms = append(forms, child)
+		}
+	}
+	return forms
+}
+
+// String returns the source text of the node with normalized whitespaces.
+func (n *Node) String() string {
+	switch {
+	case n.Kind.IsCollection():
+		var sb strings.Builder
+		sb.WriteString(n.Text)
+		for i, child := range n.Forms() {
+			if i > 0 {
+				sb.WriteString(" ")
+			}
+			sb.WriteString(child.String())
+		}
+		sb.WriteString(closingDelimiter(n.Text))
+		return sb.String()
+	case n.Kind == NodeReaderMacro && len(n.Children) > 0:
+		return n.Text + n.Children[0].String()
+	default:
+		return n.Text
+	}
+}
+
+// ParseError is an error from parsing janet source.
+type ParseError struct {
+	Position Position
+	Message  string
+}
+
+// Error returns the error message with its position.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: parse error: %s", e.Position, e.Message)
+}
+
+// ParseAST parses janet source `src` into AST nodes (without evaluating them),
+// and returns the top-level nodes (including comments).
+func ParseAST(src string) (nodes []*Node, err error) {
+	p := &astParser{lexer: newLexer(src)}
+
+	for {
+		node, err := p.parseNode()
+		if err != nil {
+			return nil, err
+		}
+		if node == nil {
+			return nodes, nil
+		}
+		nodes = append(nodes, node)
+	}
+}
+
+// astParser builds AST nodes from lexed tokens.
+type astParser struct {
+	lexer *lexer
+}
+
+// parseNode parses the next node, or returns nil at the end of the source.
+func (p *astParser) parseNode() (*Node, error) {
+	tok, err := p.lexer.next()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok.Kind {
+	case tokenEOF:
+		return nil, nil
+	case TokenClose:
+		return nil, &ParseError{Position: tok.Start, Message: fmt.Sprintf("unexpected closing delimiter %s", tok.Text)}
+	case TokenOpen:
+		return p.parseCollection(tok)
+	case TokenReaderMacro:
+		form, err := p.parseForm(tok)
+		if err != nil {
+			return nil, err
+		}
+		return &Node{
+			Kind:     NodeReaderMacro,
+			Text:     tok.Text,
+			Children: []*Node{form},
+			Start:    tok.Start,
+			End:      form.End,
+		}, nil
+	default:
+		return atomNode(tok)
+	}
+}
+
+// parseForm parses the form following a reader macro, skipping comments.
+func (p *astParser) parseForm(macro Token) (*Node, error) {
+	for {
+		node, err := p.parseNode()
+		if err != nil {
+			return nil, err
+		}
+		if node == nil {
+			return nil, &ParseError{Position: p.lexer.pos(), Message: fmt.Sprintf("unexpected end of source, %s opened at %s", macro.Text, macro.Start)}
+		}
+		if node.Kind != NodeComment {
+			return node, nil
+		}
+	}
+}
+
+// parseCollection parses the elements of a collection until its closing delimiter.
+func (p *astParser) parseCollection(open Token) (*Node, error) {
+	node := &Node{
+		Kind:  collectionKind(open.Text),
+		Text:  open.Text,
+		Start: open.Start,
+	}
+
+	for {
+		tok, err := p.lexer.peek()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok.Kind {
+		case tokenEOF:
+			return nil, &ParseError{Position: tok.Start, Message: fmt.Sprintf("unexpected end of source, %s opened at %s", open.Text, open.Start)}
+		case TokenClose:
+			_, _ = p.lexer.next()
+			if tok.Text != closingDelimiter(open.Text) {
+				return nil, &ParseError{Position: tok.Start, Message: fmt.Sprintf("mismatched delimiter %s, %s opened at %s", tok.Text, open.Text, open.Start)}
+			}
+			node.End = tok.End
+
+			if (node.Kind == NodeStruct || node.Kind == NodeTable) && len(node.Forms())%2 != 0 {
+				return nil, &ParseError{Position: open.Start, Message: "struct and table literals expect even number of arguments"}
+			}
+			return node, nil
+		default:
+			child, err := p.parseNode()
+			if err != nil {
+				return nil, err
+			}
+			node.Children = append(node.Children, child)
+		}
+	}
+}
+
+// collectionKind returns the node kind for an opening delimiter.
+func collectionKind(open string) NodeKind {
+	switch open {
+	case "(":
+		return NodeTuple
+	case "[":
+		return NodeBracketTuple
+	case "{":
+		return NodeStruct
+	case "@{":
+		return NodeTable
+	default: // "@(", "@["
+		return NodeArray
+	}
+}
+
+// closingDelimiter returns the closing delimiter for an opening delimiter.
+func closingDelimiter(open string) string {
+	switch strings.TrimPrefix(open, "@") {
+	case "(":
+		return ")"
+	case "[":
+		return "]"
+	case "{":
+		return "}"
+	}
+	return ""
+}
+
+// atomNode converts a token to an atom (or comment) node.
+func atomNode(tok Token) (*Node, error) {
+	node := &Node{
+		Text:  tok.Text,
+		Start: tok.Start,
+		End:   tok.End,
+	}
+
+	switch tok.Kind {
+	case TokenComment:
+		node.Kind = NodeComment
+	case TokenString, TokenLongString:
+		if strings.HasPrefix(tok.Text, "@") {
+			node.Kind = NodeBuffer
+		} else {
+			node.Kind = NodeString
+		}
+		value, err := decodeStringToken(tok)
+		if err != nil {
+			return nil, err
+		}
+		node.Value = value
+	case TokenKeyword:
+		node.Kind = NodeKeyword
+		node.Value = tok.Text[1:]
+	case TokenNumber:
+		node.Kind = NodeNumber
+		node.Value, _ = scanNumber(tok.Text)
+	case TokenNil:
+		node.Kind = NodeNil
+	case TokenBoolean:
+		node.Kind = NodeBoolean
+		node.Value = tok.Text == "true"
+	default:
+		node.Kind = NodeSymbol
+		node.Value = tok.Text
+	}
+
+	return node, nil
+}
+
+// decodeStringToken decodes the value of a (long) string or buffer token.
+func decodeStringToken(tok Token) (string, error) {
+	text := strings.TrimPrefix(tok.Text, "@")
+
+	if tok.Kind == TokenLongString {
+		delim := len(text) - len(strings.TrimLeft(text, "`"))
+		content := text[delim : len(text)-delim]
+
+		// remove indentation (as janet does) when there are only spaces before it on every line
+		indent := tok.Start.Column - 1
+		if strings.HasPrefix(tok.Text, "@") {
+			indent++
+		}
+		lines := strings.Split(content, "\n")
+		reindent := true
+		for _, line := range lines[1:] {
+			prefix := line[:min(indent, len(line))]
+			if strings.TrimLeft(prefix, " ") != "" && prefix != "\r" {
+				reindent = false
+				break
+			}
+		}
+		if reindent {
+			for i := 1; i < len(lines); i++ {
+				lines[i] = lines[i][min(indent, len(lines[i])):]
+			}
+			content = strings.Join(lines, "\n")
+		}
+
+		// remove leading and trailing EOLs
+		if strings.HasPrefix(content, "\r\n") {
+			content = content[2:]
+		} else if strings.HasPrefix(content, "\n") {
+			content = content[1:]
+		}
+		if strings.HasSuffix(content, "\r\n") {
+			content = content[:len(content)-2]
+		} else if strings.HasSuffix(content, "\n") {
+			content = content[:len(content)-1]
+		}
+		return content, nil
+	}
+
+	var sb strings.Builder
+	content := text[1 : len(text)-1]
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		if c != '\\' {
+			sb.WriteByte(c)
+			continue
+		}
+
+		i++
+		switch content[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case 'r':
+			sb.WriteByte('\r')
+		case '0', 'z':
+			sb.WriteByte(0)
+		case 'f':
+			sb.WriteByte('\f')
+		case 'v':
+			sb.WriteByte('\v')
+		case 'a':
+			sb.WriteByte('\a')
+		case 'b':
+			sb.WriteByte('\b')
+		case 'e':
+			sb.WriteByte(27)
+		case '\'', '?', '"', '\\':
+			sb.WriteByte(content[i])
+		case 'x', 'u', 'U':
+			digits := map[byte]int{'x': 2, 'u': 4, 'U': 6}[content[i]]
+			if i+1+digits > len(content) {
+				return "", &ParseError{Position: tok.Start, Message: "invalid hex digit in hex escape"}
+			}
+			code, err := strconv.ParseUint(content[i+1:i+1+digits], 16, 32)
+			if err != nil {
+				return "", &ParseError{Position: tok.Start, Message: "invalid hex digit in hex escape"}
+			}
+			if content[i] == 'x' {
+				sb.WriteByte(byte(code))
+			} else {
+				sb.WriteRune(rune(code))
+			}
+			i += digits
+		default:
+			return "", &ParseError{Position: tok.Start, Message: "invalid string escape sequence"}
+		}
+	}
+	return sb.String(), nil
+}
+
+// scanNumber scans a janet number literal (eg. `1_000`, `0xff`, `2r1010`, `1e3`, `16rff&2`).
+func scanNumber(text string) (float64, bool) {
+	str := strings.ReplaceAll(text, "_", "")
+
+	negative := false
+	if strings.HasPrefix(str, "-") || strings.HasPrefix(str, "+") {
+		negative = str[0] == '-'
+		str = str[1:]
+	}
+	if str == "" || strings.HasPrefix(str, "_") {
+		return 0, false
+	}
+
+	base := 10
+	if strings.HasPrefix(str, "0x") || strings.HasPrefix(str, "0X") {
+		base, str = 16, str[2:]
+	} else if idx := strings.IndexAny(str, "rR"); idx > 0 && idx <= 2 {
+		b, err := strconv.Atoi(str[:idx])
+		if err != nil || b < 2 || b > 36 {
+			return 0, false
+		}
+		base, str = b, str[idx+1:]
+	}
+
+	if base == 10 {
+		if strings.ContainsAny(str, "&") {
+			str = strings.Replace(str, "&", "e", 1)
+		}
+		if strings.ContainsAny(str, "xXpP") || strings.EqualFold(str, "inf") || strings.EqualFold(str, "infinity") || strings.EqualFold(str, "nan") {
+			return 0, false
+		}
+		value, err := strconv.ParseFloat(str, 64)
+		if err != nil && !errors.Is(err, strconv.ErrRange) {
+			return 0, false
+		}
+		if negative {
+			value = -value
+		}
+		return value, true
+	}
+
+	// mantissa and exponent (with `&`) in the given base
+	mantissa, exponent, hasExponent := strings.Cut(str, "&")
+	if mantissa == "" || (hasExponent && exponent == "") {
+		return 0, false
+	}
+	value, fractionDigits, seenPoint := 0.0, 0, false
+	for _, c := range strings.ToLower(mantissa) {
+		if c == '.' {
+			if seenPoint {
+				return 0, false
+			}
+			seenPoint = true
+			continue
+		}
+		digit := strings.IndexRune("0123456789abcdefghijklmnopqrstuvwxyz", c)
+		if digit < 0 || digit >= base {
+			return 0, false
+		}
+		value = value*float64(base) + float64(digit)
+		if seenPoint {
+			fractionDigits++
+		}
+	}
+	exp := -fractionDigits
+	if hasExponent {
+		expSign := 1
+		if strings.HasPrefix(exponent, "-") || strings.HasPrefix(exponent, "+") {
+			if exponent[0] == '-' {
+				expSign = -1
+			}
+			exponent = exponent[1:]
+		}
+		e, err := strconv.ParseInt(exponent, base, 32)
+		if err != nil {
+			return 0, false
+		}
+		exp += expSign * int(e)
+	}
+	value *= math.Pow(float64(base), float64(exp))
+	if negative {
+		value = -value
+	}
+	return value, true
+}
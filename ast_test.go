@@ -0,0 +1,145 @@
+// ast_test.go
+
+package janet
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseAST tests the ParseAST function.
+func TestParseAST(t *testing.T) {
+	src := `# comment
+(defn add [x y]
+  "Adds two numbers."
+  (+ x y))
+
+@{:a 0x10 :b @[1_000 2r101 -3.5e1]}
+'(nil true false) @"buf" ` + "``long\n  string``"
+
+	nodes, err := ParseAST(src)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	if len(nodes) != 6 {
+		t.Fatalf("Expected 6 top-level nodes, got %d", len(nodes))
+	}
+
+	// comment
+	if nodes[0].Kind != NodeComment || nodes[0].Text != "# comment" {
+		t.Errorf("Expected a comment node, got %s: '%s'", nodes[0].Kind, nodes[0].Text)
+	}
+
+	// function definition
+	defn := nodes[1]
+	if defn.Kind != NodeTuple || len(defn.Children) != 5 {
+		t.Fatalf("Expected a tuple with 5 children, got %s with %d children", defn.Kind, len(defn.Children))
+	}
+	if defn.Start != (Position{Offset: 10, Line: 2, Column: 1}) || defn.End != (Position{Offset: 58, Line: 4, Column: 11}) {
+		t.Errorf("Unexpected positions of tuple: %+v - %+v", defn.Start, defn.End)
+	}
+	if sym := defn.Children[1]; sym.Kind != NodeSymbol || sym.Value != "add" || sym.Start != (Position{Offset: 16, Line: 2, Column: 7}) {
+		t.Errorf("Unexpected symbol node: %+v", sym)
+	}
+	if params := defn.Children[2]; params.Kind != NodeBracketTuple || params.String() != "[x y]" {
+		t.Errorf("Unexpected parameters node: %s '%s'", params.Kind, params)
+	}
+	if doc := defn.Children[3]; doc.Kind != NodeString || doc.Value != "Adds two numbers." || doc.Start.Line != 3 || doc.Start.Column != 3 {
+		t.Errorf("Unexpected docstring node: %+v", doc)
+	}
+
+	// table with numbers
+	table := nodes[2]
+	if table.Kind != NodeTable || len(table.Forms()) != 4 {
+		t.Fatalf("Expected a table with 4 forms, got %s with %d forms", table.Kind, len(table.Forms()))
+	}
+	if key := table.Children[0]; key.Kind != NodeKeyword || key.Value != "a" || key.Text != ":a" {
+		t.Errorf("Unexpected keyword node: %+v", key)
+	}
+	if num := table.Children[1]; num.Kind != NodeNumber || num.Value != float64(16) {
+		t.Errorf("Unexpected number node: %+v", num)
+	}
+	array := table.Children[3]
+	var numbers []any
+	for _, n := range array.Children {
+		numbers = append(numbers, n.Value)
+	}
+	if array.Kind != NodeArray || !reflect.DeepEqual(numbers, []any{float64(1000), float64(5), float64(-35)}) {
+		t.Errorf("Unexpected array node: %s %v", array.Kind, numbers)
+	}
+
+	// quoted tuple
+	quoted := nodes[3]
+	if quoted.Kind != NodeReaderMacro || quoted.Text != "'" || quoted.String() != "'(nil true false)" {
+		t.Errorf("Unexpected reader macro node: %s '%s'", quoted.Kind, quoted)
+	}
+	literals := quoted.Children[0].Children
+	if literals[0].Kind != NodeNil || literals[1].Value != true || literals[2].Value != false {
+		t.Errorf("Unexpected literal nodes: %+v", literals)
+	}
+
+	// buffer and long string
+	if buf := nodes[4]; buf.Kind != NodeBuffer || buf.Value != "buf" {
+		t.Errorf("Unexpected buffer node: %+v", buf)
+	}
+	if long := nodes[5]; long.Kind != NodeString || long.Value != "long\n  string" {
+		t.Errorf("Unexpected long string node: %+v", long)
+	}
+}
+
+// TestParseASTValues tests decoded values of literals.
+func TestParseASTValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{input: `"a\tb\x41é\\"`, expected: "a\tbAé\\"},
+		{input: `16rff`, expected: float64(255)},
+		{input: `-0x10`, expected: float64(-16)},
+		{input: `.5`, expected: float64(0.5)},
+		{input: `1e400`, expected: math.Inf(1)},
+		{input: `-`, expected: "-"},
+		{input: `+x`, expected: "+x"},
+		{input: `:`, expected: ""},
+	}
+
+	for _, test := range tests {
+		nodes, err := ParseAST(test.input)
+		if err != nil {
+			t.Errorf("Failed to parse '%s': %v", test.input, err)
+			continue
+		}
+		if len(nodes) != 1 || !reflect.DeepEqual(nodes[0].Value, test.expected) {
+			t.Errorf("Input: %s\nExpected value: %v, got: %+v", test.input, test.expected, nodes[0])
+		}
+	}
+}
+
+// TestParseASTErrors tests errors from the ParseAST function.
+func TestParseASTErrors(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrPattern string
+	}{
+		{input: `(malformed expression`, expectedErrPattern: `1:22: parse error: unexpected end of source, ( opened at 1:1`},
+		{input: `(a]`, expectedErrPattern: `mismatched delimiter ]`},
+		{input: `)`, expectedErrPattern: `unexpected closing delimiter )`},
+		{input: `{:a}`, expectedErrPattern: `even number of arguments`},
+		{input: `1abc`, expectedErrPattern: `cannot start with a digit`},
+		{input: `"unterminated`, expectedErrPattern: `unexpected end of source`},
+		{input: `"\q"`, expectedErrPattern: `invalid string escape sequence`},
+		{input: `'`, expectedErrPattern: `unexpected end of source`},
+	}
+
+	for _, test := range tests {
+		_, err := ParseAST(test.input)
+		if err == nil {
+			t.Errorf("Expected error containing '%s' for input '%s', but got none", test.expectedErrPattern, test.input)
+		} else if !strings.Contains(err.Error(), test.expectedErrPattern) {
+			t.Errorf("Expected error containing '%s', got '%s'", test.expectedErrPattern, err.Error())
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// asyncfunc.go
+
+package janet
+
+/*
+#include <stdlib.h>
+
+#include "janet.h"
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"runtime/cgo"
+	"unsafe"
+)
+
+// AsyncFunc is a go function which is called from janet asynchronously (see VM.RegisterAsyncFunc),
+// with arguments converted to go values.
+//
+// `ctx` is derived from the context of the execution which called the function (eg. the one passed to VM.Execute),
+// so that the function can respect its deadline and values, and is cancelled along with it.
+// When it is cancelled, the error of the context is raised in the script, even if the function succeeded.
+type AsyncFunc func(ctx context.Context, args ...any) (any, error)
+
+// janet source of the helper function which creates a janet function calling the async function `id`
+const asyncWrapperSource = `(fn [call id] (fn [& args] (call id ;args)))`
+
+// asyncResult is the result of an async function, passed back to the VM handler goroutine.
+type asyncResult struct {
+	vm    *VM
+	value any
+	err   error
+}
+
+// RegisterAsyncFunc defines a janet function `name` in the VM, which calls `fn` on its own goroutine.
+//
+// The janet caller is suspended (like with `ev/sleep`) until `fn` returns, and resumed with its result
+// converted to janet (or an error raised with its error), so that slow operations (eg. http requests)
+// do not block other fibers of the execution (eg. the ones started with `ev/spawn` or `ev/gather`).
+//
+// The definition is cleared with VM.Reset, like other definitions. Async functions are not available
+// without janet's event loop (eg. with `janet_no_ev` build tag).
+func (vm *VM) RegisterAsyncFunc(
+	ctx context.Context,
+	name string,
+	fn AsyncFunc,
+) error {
+	res, err := runOnVM(ctx, vm, func(env *C.JanetTable) error {
+		return vm.defineAsyncFunc(env, name, "", fn)
+	})
+	if err != nil {
+		return err
+	}
+	return res
+}
+
+// defineAsyncFunc defines a janet function `name` (with docstring `doc` if not empty) in `env`,
+// which calls the async function `fn`.
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) defineAsyncFunc(env *C.JanetTable, name, doc string, fn AsyncFunc) error {
+	wrapper, err := compileHelper(env, asyncWrapperSource)
+	if err != nil {
+		return err
+	}
+	defer C.janet_gcunroot(C.janet_wrap_function(wrapper))
+
+	vm.asyncFuncs = append(vm.asyncFuncs, fn)
+	caller, err := pcall(env, wrapper, asyncCaller(), C.janet_wrap_integer(C.int32_t(len(vm.asyncFuncs)-1)))
+	if err != nil {
+		return err
+	}
+
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	var cDoc *C.char
+	if doc != "" {
+		cDoc = C.CString(doc)
+		defer C.free(unsafe.Pointer(cDoc))
+	}
+	C.janet_def(env, cName, caller, cDoc)
+	return nil
+}
+
+// startAsync converts `argc` arguments at `argv`, and calls the async function `id` with them on a new goroutine,
+// whose result is posted to the event loop of `janetVM` for resuming `fiber`.
+//
+// The function's context is cancelled when the context of the request is done, and its result is discarded then
+// (the error of the context is raised instead), so that scripts clean up in the same way as other failures.
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) startAsync(id, argc int, argv unsafe.Pointer, janetVM, fiber unsafe.Pointer) error {
+	if id < 0 || id >= len(vm.asyncFuncs) {
+		return fmt.Errorf("unknown async function: %d", id)
+	}
+	fn := vm.asyncFuncs[id]
+	schedID := fiberSchedID(fiber)
+
+	dec := vm.decoder(vm.requestContext())
+	args := make([]any, argc)
+	for i, arg := range unsafe.Slice((*C.Janet)(argv), argc) {
+		converted, err := dec.decode(arg)
+		if err != nil {
+			return fmt.Errorf("failed to convert argument #%d: %w", i, err)
+		}
+		args[i] = converted
+	}
+
+	ctx, cancel := context.WithCancel(vm.requestContext())
+	go func() {
+		defer cancel()
+
+		result := &asyncResult{vm: vm}
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					result.err = fmt.Errorf("async function panicked: %v", r)
+				}
+			}()
+			result.value, result.err = fn(ctx, args...)
+		}()
+		if err := ctx.Err(); err != nil && result.err == nil {
+			result.value, result.err = nil, err
+		}
+		postAsyncResult(janetVM, fiber, schedID, cgo.NewHandle(result))
+	}()
+	return nil
+}
+
+// finish converts the result into `value`, and returns whether the function succeeded
+// (or stores the error message into `value`).
+// This function should only be called from the VM handler goroutine.
+func (r *asyncResult) finish(value unsafe.Pointer) bool {
+	out := (*C.Janet)(value)
+	if r.err != nil {
+		*out = janetString(r.err.Error())
+		return false
+	}
+	converted, err := r.vm.encoder().encode(r.value)
+	if err != nil {
+		*out = janetString("failed to convert result: " + err.Error())
+		return false
+	}
+	*out = converted
+	return true
+}
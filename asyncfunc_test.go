@@ -0,0 +1,153 @@
+// asyncfunc_test.go
+
+package janet
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRegisterAsyncFunc tests calling go functions asynchronously from janet.
+func TestRegisterAsyncFunc(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	if err := vm.RegisterAsyncFunc(ctx, "slow-double", func(ctx context.Context, args ...any) (any, error) {
+		time.Sleep(100 * time.Millisecond)
+		return args[0].(float64) * 2, nil
+	}); err != nil {
+		t.Fatalf("Failed to register async function: %v", err)
+	}
+	if err := vm.RegisterAsyncFunc(ctx, "fail", func(ctx context.Context, args ...any) (any, error) {
+		return nil, errors.New("failed in go")
+	}); err != nil {
+		t.Fatalf("Failed to register async function: %v", err)
+	}
+
+	// (not available without the event loop)
+	if !Build().EV {
+		if _, _, _, err := vm.Execute(ctx, `(slow-double 2)`); err == nil || !strings.Contains(err.Error(), "without the event loop") {
+			t.Errorf("Expected error without the event loop, got: %v", err)
+		}
+		return
+	}
+
+	// result of a call
+	if result, _, _, err := vm.Execute(ctx, `(+ 1 (slow-double 2))`); err != nil || result != "5" {
+		t.Errorf("Expected 5, got: %s, %v", result, err)
+	}
+
+	// top-level forms are evaluated after the previous ones return
+	if result, _, _, err := vm.Execute(ctx, `(def doubled (slow-double 3)) (+ doubled 1)`); err != nil || result != "7" {
+		t.Errorf("Expected 7, got: %s, %v", result, err)
+	}
+
+	// calls do not block other fibers
+	start := time.Now()
+	gathered, _, _, err := vm.EvalValue(ctx, `(ev/gather (slow-double 1) (slow-double 2) (slow-double 3))`)
+	if err != nil || !reflect.DeepEqual(gathered, []any{float64(2), float64(4), float64(6)}) {
+		t.Errorf("Expected [2 4 6], got: %v, %v", gathered, err)
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("Expected concurrent calls, took: %s", elapsed)
+	}
+
+	// errors are raised in janet
+	if result, _, _, err := vm.Execute(ctx, `(try (fail) ([err] (string "caught: " err)))`); err != nil || result != "caught: failed in go" {
+		t.Errorf("Expected caught error, got: %s, %v", result, err)
+	}
+	if _, _, _, err := vm.Execute(ctx, `(fail)`); err == nil || !strings.Contains(err.Error(), "failed in go") {
+		t.Errorf("Expected error, got: %v", err)
+	}
+}
+
+// TestAsyncFuncContext tests contexts passed to async functions.
+func TestAsyncFuncContext(t *testing.T) {
+	if !Build().EV {
+		t.Skip("async functions are not available without the event loop")
+	}
+
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	type key struct{}
+	if err := vm.RegisterAsyncFunc(context.TODO(), "request-id", func(ctx context.Context, args ...any) (any, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			return nil, errors.New("no deadline")
+		}
+		return ctx.Value(key{}), nil
+	}); err != nil {
+		t.Fatalf("Failed to register async function: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithValue(context.TODO(), key{}, "req-1"), time.Minute)
+	defer cancel()
+
+	// from executions
+	if result, _, _, err := vm.Execute(ctx, `(request-id)`); err != nil || result != "req-1" {
+		t.Errorf("Expected req-1, got: %s, %v", result, err)
+	}
+
+	// from other calls
+	if result, err := vm.Apply(ctx, "request-id"); err != nil || result != "req-1" {
+		t.Errorf("Expected req-1, got: %v, %v", result, err)
+	}
+}
+
+// TestAsyncFuncCancellation tests cancelling async functions with the contexts of executions.
+func TestAsyncFuncCancellation(t *testing.T) {
+	if !Build().EV {
+		t.Skip("async functions are not available without the event loop")
+	}
+
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	cancelled := make(chan error, 1)
+	if err := vm.RegisterAsyncFunc(ctx, "wait", func(ctx context.Context, args ...any) (any, error) {
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return "ignored", nil
+	}); err != nil {
+		t.Fatalf("Failed to register async function: %v", err)
+	}
+	if _, _, _, err := vm.Execute(ctx, `(var cleaned nil)`); err != nil {
+		t.Fatalf("Failed to define var: %v", err)
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, _, _, err := vm.Execute(timeout, `(try (wait) ([err] (set cleaned err)))`); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got: %v", err)
+	}
+	select {
+	case err := <-cancelled:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected deadline exceeded in async function, got: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for the async function to be cancelled")
+	}
+
+	// the error of the context is raised in the script
+	if result, _, _, err := vm.Execute(ctx, `cleaned`); err != nil || result != context.DeadlineExceeded.Error() {
+		t.Errorf("Expected cleanup with the error, got: %s, %v", result, err)
+	}
+}
@@ -0,0 +1,147 @@
+// bench_test.go
+
+package janet
+
+import (
+	"context"
+	"testing"
+)
+
+// BenchmarkExecute benchmarks executions of a small expression.
+func BenchmarkExecute(b *testing.B) {
+	vm, err := NewVM()
+	if err != nil {
+		b.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, _, _, err := vm.Execute(ctx, `(+ 1 2)`); err != nil {
+			b.Fatalf("Failed to execute: %v", err)
+		}
+	}
+}
+
+// BenchmarkExecuteParallel benchmarks concurrent executions of a small expression on a VM.
+func BenchmarkExecuteParallel(b *testing.B) {
+	vm, err := NewVM()
+	if err != nil {
+		b.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, _, _, err := vm.Execute(ctx, `(+ 1 2)`); err != nil {
+				b.Errorf("Failed to execute: %v", err)
+				return
+			}
+		}
+	})
+}
+
+// BenchmarkParseToValue benchmarks conversions of a small expression to a go value.
+func BenchmarkParseToValue(b *testing.B) {
+	vm, err := NewVM()
+	if err != nil {
+		b.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := vm.ParseToValue(ctx, `{:a 1 :b [2 3]}`); err != nil {
+			b.Fatalf("Failed to parse: %v", err)
+		}
+	}
+}
+
+// BenchmarkApply benchmarks calls of a core function without parsing sources.
+func BenchmarkApply(b *testing.B) {
+	vm, err := NewVM()
+	if err != nil {
+		b.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := vm.Apply(ctx, "+", 1, 2); err != nil {
+			b.Fatalf("Failed to apply: %v", err)
+		}
+	}
+}
+
+// BenchmarkConvertLarge benchmarks conversions of a large collection to go.
+func BenchmarkConvertLarge(b *testing.B) {
+	vm, err := NewVM()
+	if err != nil {
+		b.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	if _, _, _, err := vm.Execute(ctx, `(def large (seq [i :range [0 10000]] {:id i :name (string "n" i) :tags [:a :b]}))`); err != nil {
+		b.Fatalf("Failed to define a large collection: %v", err)
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := vm.ParseToValue(ctx, `large`); err != nil {
+			b.Fatalf("Failed to convert: %v", err)
+		}
+	}
+}
+
+// BenchmarkConvertLargeArena benchmarks conversions of a large collection to go with an arena.
+func BenchmarkConvertLargeArena(b *testing.B) {
+	vm, err := NewVM()
+	if err != nil {
+		b.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	if _, _, _, err := vm.Execute(ctx, `(def large (seq [i :range [0 10000]] {:id i :name (string "n" i) :tags [:a :b]}))`); err != nil {
+		b.Fatalf("Failed to define a large collection: %v", err)
+	}
+
+	arena := NewArena()
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := vm.ParseToValue(ctx, `large`, WithArena(arena)); err != nil {
+			b.Fatalf("Failed to convert: %v", err)
+		}
+		arena.Release()
+	}
+}
+
+// BenchmarkCallOverhead benchmarks round trips to the VM goroutine with the smallest janet call.
+func BenchmarkCallOverhead(b *testing.B) {
+	vm, err := NewVM()
+	if err != nil {
+		b.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := vm.Apply(ctx, "identity", nil); err != nil {
+			b.Fatalf("Failed to apply: %v", err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// benchmark.go
+
+package janet
+
+/*
+#include "janet.h"
+
+int getJanetGCCounters(size_t *allocated, size_t *blocks);
+*/
+import "C"
+
+import (
+	"context"
+	"time"
+)
+
+// BenchmarkOptions is the options of VM.Benchmark.
+type BenchmarkOptions struct {
+	Iterations int           // number of iterations (0 for iterating until Duration elapses)
+	Duration   time.Duration // min duration of iterations when Iterations is 0 (default: 1 second)
+}
+
+// BenchmarkResult is the result of VM.Benchmark, measured inside the VM.
+type BenchmarkResult struct {
+	Iterations  int
+	Elapsed     time.Duration
+	NsPerOp     int64
+	AllocsPerOp int64 // approximate number of janet allocations per iteration
+	BytesPerOp  int64 // approximate number of bytes allocated by janet per iteration
+	GCs         int   // number of janet garbage collections while iterating
+
+	// whether AllocsPerOp, BytesPerOp, and GCs are counted
+	// (they are not when linked against system janet, whose counters are internal)
+	Counted bool
+}
+
+// Benchmark compiles `janetExpression` once and evaluates it repeatedly in the VM,
+// measuring the time, janet allocations, and garbage collections per iteration
+// without the overhead of this binding (eg. parsing, compiling, or converting results).
+//
+// The VM does not handle other requests while benchmarking.
+func (vm *VM) Benchmark(
+	ctx context.Context,
+	janetExpression string,
+	opts BenchmarkOptions,
+) (result BenchmarkResult, err error) {
+	if opts.Iterations <= 0 && opts.Duration <= 0 {
+		opts.Duration = time.Second
+	}
+
+	type benchmarkResult struct {
+		result BenchmarkResult
+		err    error
+	}
+	res, err := runOnVM(ctx, vm, func(env *C.JanetTable) benchmarkResult {
+		fn, err := compileHelper(env, "(fn [] "+janetExpression+"\n)")
+		if err != nil {
+			return benchmarkResult{err: err}
+		}
+		defer C.janet_gcunroot(C.janet_wrap_function(fn))
+
+		var result BenchmarkResult
+		var allocated, blocks uint64
+		var prevAllocated, prevBlocks C.size_t
+		result.Counted = C.getJanetGCCounters(&prevAllocated, &prevBlocks) != 0
+
+		start := time.Now()
+		for opts.Iterations <= 0 || result.Iterations < opts.Iterations {
+			if opts.Iterations <= 0 && time.Since(start) >= opts.Duration {
+				break
+			}
+			if ctx.Err() != nil {
+				return benchmarkResult{err: contextError(ctx)}
+			}
+
+			if _, err := pcall(env, fn); err != nil {
+				return benchmarkResult{err: err}
+			}
+			result.Iterations++
+
+			var curAllocated, curBlocks C.size_t
+			C.getJanetGCCounters(&curAllocated, &curBlocks)
+			if curAllocated < prevAllocated {
+				// collected while iterating, so only allocations after the collection are counted
+				result.GCs++
+				allocated += uint64(curAllocated)
+			} else {
+				allocated += uint64(curAllocated - prevAllocated)
+				if curBlocks > prevBlocks {
+					blocks += uint64(curBlocks - prevBlocks)
+				}
+			}
+			prevAllocated, prevBlocks = curAllocated, curBlocks
+		}
+		result.Elapsed = time.Since(start)
+
+		if result.Iterations > 0 {
+			result.NsPerOp = result.Elapsed.Nanoseconds() / int64(result.Iterations)
+			result.BytesPerOp = int64(allocated / uint64(result.Iterations))
+			result.AllocsPerOp = int64(blocks / uint64(result.Iterations))
+		}
+		return benchmarkResult{result: result}
+	})
+	if err != nil {
+		return BenchmarkResult{}, err
+	}
+	return res.result, res.err
+}
@@ -0,0 +1,59 @@
+// benchmark_test.go
+
+package janet
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestBenchmark tests benchmarks of janet expressions inside the VM.
+func TestBenchmark(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	result, err := vm.Benchmark(ctx, `(string/repeat "a" 1000)`, BenchmarkOptions{Iterations: 10000})
+	if err != nil {
+		t.Fatalf("Failed to benchmark: %v", err)
+	}
+	if result.Iterations != 10000 || result.NsPerOp <= 0 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if result.Counted { // not counted when linked against system janet
+		if result.BytesPerOp < 1000 || result.AllocsPerOp < 1 {
+			t.Errorf("Expected allocations to be counted, got: %+v", result)
+		}
+		if result.GCs == 0 {
+			t.Errorf("Expected garbage collections while allocating 10MB, got: %+v", result)
+		}
+	}
+
+	// iterate for a duration
+	if result, err := vm.Benchmark(ctx, `(+ 1 2)`, BenchmarkOptions{Duration: 10 * time.Millisecond}); err != nil {
+		t.Errorf("Failed to benchmark for a duration: %v", err)
+	} else if result.Iterations == 0 || result.Elapsed < 10*time.Millisecond {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	// errors
+	for _, expr := range []string{`(+ 1`, `(error "failed")`} {
+		if _, err := vm.Benchmark(ctx, expr, BenchmarkOptions{Iterations: 1}); err == nil {
+			t.Errorf("Expected error for benchmarking '%s'", expr)
+		}
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if _, err := vm.Benchmark(canceled, `(+ 1 2)`, BenchmarkOptions{Duration: time.Minute}); err == nil || !strings.Contains(err.Error(), "canceled") {
+		t.Errorf("Expected benchmark to be canceled, got: %v", err)
+	}
+}
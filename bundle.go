@@ -0,0 +1,171 @@
+// bundle.go
+
+package janet
+
+/*
+#include "janet.h"
+*/
+import "C"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"unsafe"
+)
+
+// header of bundles created with BundleProject, followed by the manifest in JSON and a newline
+const bundleMagic = "janet-go bundle 1\n"
+
+// BundleManifest is the manifest of a bundle created with BundleProject.
+type BundleManifest struct {
+	Entry        string   `json:"entry"`         // path of the entry file, relative to the project directory
+	Modules      []string `json:"modules"`       // paths of the imported modules, relative to the project directory if in it
+	JanetVersion string   `json:"janet_version"` // version of janet which compiled the bundle
+}
+
+// janet source which evaluates the entry file `(dyn :bundle-entry)` and returns its image,
+// along with the modules imported while evaluating it.
+const bundlerSource = `(do
+  (def cached (keys module/cache))
+  (def env (dofile (dyn :bundle-entry)))
+  {:image (string (make-image env))
+   :modules (sorted (filter |(and (string? $) (not (index-of $ cached))) (keys module/cache)))})`
+
+// BundleProject compiles the janet project in `dir` into a single artifact, which can be run with VM.ExecuteImage.
+//
+// The entry file (`entry`, relative to `dir`) is evaluated with its imports resolved relative to it
+// (or to `dir` for non-relative imports), and its environment is marshaled into an image
+// with all the imported modules, along with a manifest (see ReadBundleManifest).
+//
+// Top-level forms of the files are evaluated while bundling, so the work should be done in
+// the entry's `main` function, like the ones of janet's CLI.
+func BundleProject(dir, entry string) ([]byte, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	vm, err := NewVM(WithSyspath(root))
+	if err != nil {
+		return nil, err
+	}
+	defer vm.Close()
+
+	var bundled struct {
+		Image   string
+		Modules []string
+	}
+	if err := vm.ExecuteInto(context.Background(), bundlerSource, &bundled, WithDyns(map[string]any{
+		"bundle-entry": filepath.Join(root, entry),
+	})); err != nil {
+		return nil, fmt.Errorf("failed to bundle %s: %w", entry, err)
+	}
+
+	manifest := BundleManifest{
+		Entry:        filepath.ToSlash(entry),
+		Modules:      []string{},
+		JanetVersion: Build().JanetVersion,
+	}
+	for _, module := range bundled.Modules {
+		if rel, err := filepath.Rel(root, module); err == nil && filepath.IsLocal(rel) {
+			module = filepath.ToSlash(rel)
+		}
+		manifest.Modules = append(manifest.Modules, module)
+	}
+	header, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.Concat([]byte(bundleMagic), header, []byte("\n"), []byte(bundled.Image)), nil
+}
+
+// ReadBundleManifest returns the manifest of a bundle created with BundleProject.
+func ReadBundleManifest(bundle []byte) (*BundleManifest, error) {
+	manifest, _, err := splitBundle(bundle)
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("not a bundle")
+	}
+	return manifest, nil
+}
+
+// splitBundle splits a bundle into its manifest and image (with nil manifest if `bundle` is a plain image).
+func splitBundle(bundle []byte) (manifest *BundleManifest, image []byte, err error) {
+	rest, ok := bytes.CutPrefix(bundle, []byte(bundleMagic))
+	if !ok {
+		return nil, bundle, nil
+	}
+	header, image, ok := bytes.Cut(rest, []byte("\n"))
+	if !ok {
+		return nil, nil, fmt.Errorf("malformed bundle manifest")
+	}
+	manifest = &BundleManifest{}
+	if err := json.Unmarshal(header, manifest); err != nil {
+		return nil, nil, fmt.Errorf("malformed bundle manifest: %w", err)
+	}
+	return manifest, image, nil
+}
+
+// janet source which loads the image bound to `__janet_go_image` and calls its main function
+// with `(dyn :args)`, like janet's CLI runs images.
+const imageRunnerSource = `(let [env (load-image __janet_go_image)]
+  (when-let [main (get-in env ['main :value])]
+    (main ;(or (dyn :args) []))))`
+
+// ExecuteImage runs a bundle created with BundleProject (or an image created with janet's `make-image`)
+// by calling its `main` function with `(dyn :args)` (see WithArgs), and returns the result
+// in the same way as Execute.
+//
+// Bundles compiled with other versions of janet are not run. The image's environment is
+// discarded after the execution, so it is not visible to other executions.
+func (vm *VM) ExecuteImage(
+	ctx context.Context,
+	image []byte,
+	opts ...ExecOption,
+) (
+	evaluated string,
+	stdout string,
+	stderr string,
+	err error,
+) {
+	manifest, image, err := splitBundle(image)
+	if err != nil {
+		return "", "", "", err
+	}
+	if manifest != nil && manifest.JanetVersion != Build().JanetVersion {
+		return "", "", "", fmt.Errorf("bundle was compiled with janet %s, not %s", manifest.JanetVersion, Build().JanetVersion)
+	}
+	options := newExecOptions(opts)
+
+	res, err := runOnVM(ctx, vm, func(env *C.JanetTable) vmExecResponse {
+		// image is bound in a temporary environment
+		imageEnv := C.janet_table(1)
+		imageEnv.proto = env
+		C.janet_gcroot(C.janet_wrap_table(imageEnv))
+		defer C.janet_gcunroot(C.janet_wrap_table(imageEnv))
+
+		buffer := C.janet_buffer(C.int32_t(len(image)))
+		if len(image) > 0 {
+			C.janet_buffer_push_bytes(buffer, (*C.uint8_t)(unsafe.Pointer(&image[0])), C.int32_t(len(image)))
+		}
+		name := C.CString("__janet_go_image")
+		defer C.free(unsafe.Pointer(name))
+		C.janet_def(imageEnv, name, C.janet_wrap_buffer(buffer), nil)
+
+		return vm.evaluate(imageEnv, imageRunnerSource, options)
+	})
+	if err != nil {
+		return "", "", "", err
+	}
+	if res.err == nil && res.signal != SignalOK {
+		return res.evaluated, res.stdout, res.stderr, &RaisedSignal{Signal: res.signal, Value: res.evaluated}
+	}
+	return res.evaluated, res.stdout, res.stderr, res.err
+}
@@ -0,0 +1,94 @@
+// bundle_test.go
+
+package janet
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// TestBundleProject tests bundling projects into images and running them.
+func TestBundleProject(t *testing.T) {
+	dir := t.TempDir()
+	for name, source := range map[string]string{
+		"main.janet": `(import ./lib/greeting)
+(import shared/util)
+(defn main [& args]
+  (print (greeting/greet (get args 1 "world")))
+  (util/double (length args)))`,
+		"lib/greeting.janet": `(defn greet [name] (string "hello, " name))`,
+		"shared/util.janet":  `(defn double [x] (* 2 x))`,
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	bundle, err := BundleProject(dir, "main.janet")
+	if err != nil {
+		t.Fatalf("Failed to bundle project: %v", err)
+	}
+
+	manifest, err := ReadBundleManifest(bundle)
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	expected := BundleManifest{
+		Entry:        "main.janet",
+		Modules:      []string{"lib/greeting.janet", "shared/util.janet"},
+		JanetVersion: Build().JanetVersion,
+	}
+	if !reflect.DeepEqual(*manifest, expected) {
+		t.Errorf("Expected manifest %+v, got: %+v", expected, *manifest)
+	}
+
+	// run the bundle without the project files
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("Failed to remove project: %v", err)
+	}
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	evaluated, stdout, _, err := vm.ExecuteImage(ctx, bundle, WithArgs([]string{"app", "janet"}))
+	if err != nil {
+		t.Fatalf("Failed to execute image: %v", err)
+	}
+	if evaluated != "4" || stdout != "hello, janet\n" {
+		t.Errorf("Unexpected result from image: %s, %q", evaluated, stdout)
+	}
+
+	// environment of the image is not visible to other executions
+	if evaluated, _, _, err := vm.Execute(ctx, `(dyn 'main)`); err != nil || evaluated != "nil" {
+		t.Errorf("Expected image's environment discarded, got: %s (%v)", evaluated, err)
+	}
+
+	// plain images
+	image, _, _, err := vm.EvalValue(ctx, `(defn main [&] :plain) (string (make-image (curenv)))`)
+	if err != nil {
+		t.Fatalf("Failed to make image: %v", err)
+	}
+	if evaluated, _, _, err := vm.ExecuteImage(ctx, []byte(image.(string))); err != nil || evaluated != ":plain" {
+		t.Errorf("Expected result from plain image, got: %s (%v)", evaluated, err)
+	}
+
+	// bundles of other versions
+	header, _ := json.Marshal(BundleManifest{Entry: "main.janet", JanetVersion: "0.0.1"})
+	_, payload, _ := splitBundle(bundle)
+	if _, _, _, err := vm.ExecuteImage(ctx, slices.Concat([]byte(bundleMagic), header, []byte("\n"), payload)); err == nil {
+		t.Errorf("Expected error for bundles of other versions")
+	}
+}
@@ -0,0 +1,136 @@
+// callbacks.go
+
+package janet
+
+/*
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"runtime/cgo"
+	"unsafe"
+)
+
+// goDeleteHandle is called from janet when a wrapped go value is garbage-collected.
+//
+//export goDeleteHandle
+func goDeleteHandle(handle C.uintptr_t) {
+	cgo.Handle(handle).Delete()
+	liveGoValues.Add(-1)
+}
+
+// goDebugHook is called from janet when a script raises a debug signal on a VM with a debug handler,
+// and returns whether to resume the paused fiber.
+//
+//export goDebugHook
+func goDebugHook(vm C.uintptr_t, fiber, value unsafe.Pointer) C.int {
+	if cgo.Handle(vm).Value().(*VM).handleDebug(fiber, value) == DebugResume {
+		return 1
+	}
+	return 0
+}
+
+// goVerifyModule is called from janet before a module file is loaded on a VM with a module verifier,
+// and returns the error message (to be freed by the caller) if the file should not be loaded.
+//
+//export goVerifyModule
+func goVerifyModule(vm C.uintptr_t, path *C.char) *C.char {
+	if err := cgo.Handle(vm).Value().(*VM).options.moduleVerifier(C.GoString(path)); err != nil {
+		return C.CString(err.Error())
+	}
+	return nil
+}
+
+// goPublish is called from janet when a script publishes a value with `host/publish`,
+// and returns the number of subscribers (or the error message, to be freed by the caller, into `err`).
+//
+//export goPublish
+func goPublish(vm C.uintptr_t, topic *C.uint8_t, length C.int32_t, value unsafe.Pointer, err **C.char) C.int32_t {
+	subscribers, e := cgo.Handle(vm).Value().(*VM).handlePublish(C.GoStringN((*C.char)(unsafe.Pointer(topic)), C.int(length)), value)
+	if e != nil {
+		*err = C.CString(e.Error())
+	}
+	return C.int32_t(subscribers)
+}
+
+// goHandOff is called from janet when a script hands a callback to the host with `host/callback`,
+// and returns the error message (to be freed by the caller) if there is no handler for it.
+//
+//export goHandOff
+func goHandOff(vm C.uintptr_t, name *C.uint8_t, length C.int32_t, value unsafe.Pointer) *C.char {
+	if err := cgo.Handle(vm).Value().(*VM).handleHandOff(C.GoStringN((*C.char)(unsafe.Pointer(name)), C.int(length)), value); err != nil {
+		return C.CString(err.Error())
+	}
+	return nil
+}
+
+// goStartAsync is called from janet when a script calls an async function `id` with `argc` arguments at `argv`,
+// and returns the error message (to be freed by the caller) if it could not be started.
+//
+//export goStartAsync
+func goStartAsync(vm C.uintptr_t, id, argc C.int32_t, argv unsafe.Pointer, janetVM, fiber unsafe.Pointer) *C.char {
+	if err := cgo.Handle(vm).Value().(*VM).startAsync(int(id), int(argc), argv, janetVM, fiber); err != nil {
+		return C.CString(err.Error())
+	}
+	return nil
+}
+
+// goFinishAsync is called from janet when the result of an async function arrives at the VM's event loop,
+// and stores the result (or the error message) into `value`, returning 1 if the function failed.
+//
+//export goFinishAsync
+func goFinishAsync(result C.uintptr_t, value unsafe.Pointer) C.int {
+	handle := cgo.Handle(result)
+	defer handle.Delete()
+	if !handle.Value().(*asyncResult).finish(value) {
+		return 1
+	}
+	return 0
+}
+
+// goLog is called from janet when a script logs `message` with `log/*` functions,
+// and returns the error message (to be freed by the caller) if its fields could not be converted.
+//
+//export goLog
+func goLog(vm C.uintptr_t, level C.int, message *C.uint8_t, length C.int32_t, fields unsafe.Pointer) *C.char {
+	if err := cgo.Handle(vm).Value().(*VM).handleLog(int(level), C.GoStringN((*C.char)(unsafe.Pointer(message)), C.int(length)), fields); err != nil {
+		return C.CString(err.Error())
+	}
+	return nil
+}
+
+// goClockNow is called from janet when a script gets the current time on a VM with a clock,
+// and stores the time into `sec` and `nsec` (since the Unix epoch).
+//
+//export goClockNow
+func goClockNow(vm C.uintptr_t, sec, nsec *C.int64_t) {
+	now := cgo.Handle(vm).Value().(*VM).options.clock.Now()
+	*sec, *nsec = C.int64_t(now.Unix()), C.int64_t(now.Nanosecond())
+}
+
+// goClockSleep is called from janet when a script sleeps with `os/sleep` on a VM with a clock,
+// and returns the error message (to be freed by the caller) if the sleep was interrupted.
+//
+//export goClockSleep
+func goClockSleep(vm C.uintptr_t, seconds C.double) *C.char {
+	v := cgo.Handle(vm).Value().(*VM)
+	if err := v.options.clock.Sleep(v.requestContext(), secondsToDuration(float64(seconds))); err != nil {
+		return C.CString(err.Error())
+	}
+	return nil
+}
+
+// goReadRandom is called from janet when a script needs random data on a VM with a random source,
+// and returns the error message (to be freed by the caller) if `n` bytes could not be read into `buf`.
+//
+//export goReadRandom
+func goReadRandom(vm C.uintptr_t, buf *C.uint8_t, n C.int32_t) *C.char {
+	if n == 0 {
+		return nil
+	}
+	if err := cgo.Handle(vm).Value().(*VM).readRandom(unsafe.Slice((*byte)(unsafe.Pointer(buf)), int(n))); err != nil {
+		return C.CString(err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+// clock.go
+
+package janet
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+
+#include "janet.h"
+
+// defined in callbacks.go
+extern void goClockNow(uintptr_t vm, int64_t *sec, int64_t *nsec);
+extern char *goClockSleep(uintptr_t vm, double seconds);
+
+// defined in janet_bundled.go or janet_system.go
+void *setCrashGuard(void *guard);
+
+// handle of the VM running on the current thread, if it has a clock
+static _Thread_local uintptr_t clockVM = 0;
+
+// original cfunctions of janet, which are called for what the clock does not replace
+static _Thread_local JanetCFunction systemClock = NULL;
+static _Thread_local JanetCFunction systemDate = NULL;
+static _Thread_local JanetCFunction systemStrftime = NULL;
+
+static void setClockVM(uintptr_t vm, JanetCFunction clock, JanetCFunction date, JanetCFunction strftime) {
+    clockVM = vm;
+    systemClock = clock;
+    systemDate = date;
+    systemStrftime = strftime;
+}
+
+// returns the current time of the clock
+static void clockNow(int64_t *sec, int64_t *nsec) {
+    void *guard = setCrashGuard(NULL); // not to jump over go frames (see crash.go)
+    goClockNow(clockVM, sec, nsec);
+    setCrashGuard(guard);
+}
+
+// (os/time)
+static Janet clockTime(int32_t argc, Janet *argv) {
+    (void) argv;
+    janet_fixarity(argc, 0);
+    int64_t sec, nsec;
+    clockNow(&sec, &nsec);
+    return janet_wrap_number((double)sec);
+}
+
+// (os/clock &opt source format), whose :realtime and :monotonic sources are the clock
+static Janet clockClock(int32_t argc, Janet *argv) {
+    janet_sandbox_assert(JANET_SANDBOX_HRTIME);
+    janet_arity(argc, 0, 2);
+    JanetKeyword source = janet_optkeyword(argv, argc, 0, NULL);
+    if (source != NULL && janet_cstrcmp(source, "realtime") != 0 && janet_cstrcmp(source, "monotonic") != 0) {
+        return systemClock(argc, argv); // :cputime, or errors
+    }
+
+    int64_t sec, nsec;
+    clockNow(&sec, &nsec);
+    JanetKeyword format = janet_optkeyword(argv, argc, 1, NULL);
+    if (format == NULL || janet_cstrcmp(format, "double") == 0) {
+        return janet_wrap_number((double)sec + (double)nsec / 1E9);
+    } else if (janet_cstrcmp(format, "int") == 0) {
+        return janet_wrap_number((double)sec);
+    } else if (janet_cstrcmp(format, "tuple") == 0) {
+        Janet tup[2] = {janet_wrap_number((double)sec), janet_wrap_number((double)nsec)};
+        return janet_wrap_tuple(janet_tuple_n(tup, 2));
+    }
+    janet_panicf("expected :double, :int, or :tuple, got %v", argv[1]);
+}
+
+// calls `system` with the time of the clock as the `index`th argument, if it is not given
+static Janet withDefaultTime(JanetCFunction system, int32_t index, int32_t argc, Janet *argv) {
+    if (argc > index && !janet_checktype(argv[index], JANET_NIL)) {
+        return system(argc, argv);
+    }
+    janet_arity(argc, index, index + 2);
+    Janet args[3];
+    for (int32_t i = 0; i < argc; i++) args[i] = argv[i];
+    int64_t sec, nsec;
+    clockNow(&sec, &nsec);
+    args[index] = janet_wrap_number((double)sec);
+    return system(argc > index ? argc : index + 1, args);
+}
+
+// (os/date &opt time local)
+static Janet clockDate(int32_t argc, Janet *argv) {
+    return withDefaultTime(systemDate, 0, argc, argv);
+}
+
+// (os/strftime fmt &opt time local)
+static Janet clockStrftime(int32_t argc, Janet *argv) {
+    return withDefaultTime(systemStrftime, 1, argc, argv);
+}
+
+// (os/sleep n), which blocks until `n` seconds pass on the clock
+static Janet clockSleep(int32_t argc, Janet *argv) {
+    janet_fixarity(argc, 1);
+    double delay = janet_getnumber(argv, 0);
+    if (delay < 0) janet_panic("invalid argument to sleep");
+    void *guard = setCrashGuard(NULL); // not to jump over go frames (see crash.go)
+    char *err = goClockSleep(clockVM, delay);
+    setCrashGuard(guard);
+    if (err != NULL) {
+        Janet message = janet_cstringv(err);
+        free(err);
+        janet_panicv(message);
+    }
+    return janet_wrap_nil();
+}
+
+static const JanetReg clockCfuns[] = {
+    {"os/time", clockTime, "(os/time)\n\nGet the current time of the host's clock expressed as the number of whole seconds since January 1, 1970, the Unix epoch. Returns a real number."},
+    {"os/clock", clockClock, "(os/clock &opt source format)\n\nReturn the current time of the requested clock source. :realtime (default) and :monotonic return the time of the host's clock, and :cputime returns the CPU time consumed by this process. `format` is one of :double (default), :int, and :tuple."},
+    {"os/date", clockDate, "(os/date &opt time local)\n\nReturns the given time (or the current time of the host's clock) as a date struct."},
+    {"os/strftime", clockStrftime, "(os/strftime fmt &opt time local)\n\nFormat the given time (or the current time of the host's clock) as a string."},
+    {"os/sleep", clockSleep, "(os/sleep n)\n\nSuspend the program for `n` seconds on the host's clock. `n` can be a real number. Returns nil."},
+    {NULL, NULL, NULL},
+};
+
+// registers the functions of the clock in `env`, returning 0 if the original ones are not found
+static int registerClockCfuns(JanetTable *env, uintptr_t vm) {
+    Janet clock, date, strftime;
+    if (janet_resolve(env, janet_csymbol("os/clock"), &clock) != JANET_BINDING_DEF || !janet_checktype(clock, JANET_CFUNCTION) ||
+        janet_resolve(env, janet_csymbol("os/date"), &date) != JANET_BINDING_DEF || !janet_checktype(date, JANET_CFUNCTION) ||
+        janet_resolve(env, janet_csymbol("os/strftime"), &strftime) != JANET_BINDING_DEF || !janet_checktype(strftime, JANET_CFUNCTION)) {
+        return 0;
+    }
+    setClockVM(vm, janet_unwrap_cfunction(clock), janet_unwrap_cfunction(date), janet_unwrap_cfunction(strftime));
+    janet_cfuns(env, NULL, clockCfuns);
+    return 1;
+}
+
+static void unregisterClock() {
+    setClockVM(0, NULL, NULL, NULL);
+}
+
+static int hasEventLoop() {
+#ifdef JANET_EV
+    return 1;
+#else
+    return 0;
+#endif
+}
+*/
+import "C"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/cgo"
+	"sync"
+	"time"
+)
+
+// Clock is a source of time for scripts (see WithClock).
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// Sleep blocks until `d` passes on the clock, or returns the error of `ctx` when it is done.
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// WithClock makes scripts get the time from `clock` instead of the system, so that simulations can fast-forward time
+// and tests can freeze it (eg. with ManualClock).
+//
+// `os/time`, `os/clock` (except for :cputime, with :monotonic being the same as :realtime),
+// and the current time of `os/date` and `os/strftime` return the time of the clock,
+// and `os/sleep` and `ev/sleep` wait on it (`ev/sleep` suspends only the current task, like async functions).
+// Other timeouts of the event loop (eg. `ev/deadline`, or the timeouts of channels and streams) still use the system's clock.
+func WithClock(clock Clock) Option {
+	return func(o *vmOptions) {
+		o.clock = clock
+	}
+}
+
+// startClock replaces the time functions in `core` with the ones of the VM's clock (if any).
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) startClock(core *C.JanetTable) (stop func(), err error) {
+	clock := vm.options.clock
+	if clock == nil {
+		return func() {}, nil
+	}
+
+	handle := cgo.NewHandle(vm)
+	if C.registerClockCfuns(core, C.uintptr_t(handle)) == 0 {
+		handle.Delete()
+		return nil, errors.New("failed to set clock: time functions not found")
+	}
+	stop = func() {
+		C.unregisterClock()
+		handle.Delete()
+	}
+
+	if C.hasEventLoop() != 0 {
+		if err := vm.defineAsyncFunc(core, "ev/sleep", "(ev/sleep sec)\n\nSuspend the current fiber for `sec` seconds on the host's clock without blocking the event loop.", func(ctx context.Context, args ...any) (any, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("arity mismatch, expected 1, got %d", len(args))
+			}
+			sec, ok := args[0].(float64)
+			if !ok || sec < 0 {
+				return nil, fmt.Errorf("invalid argument to sleep: %v", args[0])
+			}
+			return nil, clock.Sleep(ctx, secondsToDuration(sec))
+		}); err != nil {
+			stop()
+			return nil, err
+		}
+	}
+	return stop, nil
+}
+
+// secondsToDuration converts janet's seconds to time.Duration.
+func secondsToDuration(sec float64) time.Duration {
+	return time.Duration(sec * float64(time.Second))
+}
+
+// ManualClock is a Clock whose time changes only when it is set or advanced, for freezing time in tests
+// and fast-forwarding it in simulations. It is safe for concurrent use.
+type ManualClock struct {
+	mu       sync.Mutex
+	now      time.Time
+	sleepers map[chan struct{}]time.Time // wake-up times of sleepers, keyed by the channels closed on them
+}
+
+// NewManualClock returns a new ManualClock whose time is `now`.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now, sleepers: map[chan struct{}]time.Time{}}
+}
+
+// Now returns the current time of the clock.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Sleep blocks until the clock is advanced by `d`, or returns the error of `ctx` when it is done.
+func (c *ManualClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.mu.Lock()
+	if d <= 0 {
+		c.mu.Unlock()
+		return nil
+	}
+	wake := make(chan struct{})
+	c.sleepers[wake] = c.now.Add(d)
+	c.mu.Unlock()
+
+	select {
+	case <-wake:
+		return nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.sleepers, wake)
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Advance moves the time of the clock forward by `d`, waking up the sleepers whose time has come.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(c.now.Add(d))
+}
+
+// Set sets the time of the clock to `now`, waking up the sleepers whose time has come.
+func (c *ManualClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(now)
+}
+
+// Sleepers returns the number of sleepers waiting for the clock (eg. for advancing it after scripts start sleeping).
+func (c *ManualClock) Sleepers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.sleepers)
+}
+
+// set sets the time to `now`, and wakes up the sleepers whose time has come. `c.mu` should be locked.
+func (c *ManualClock) set(now time.Time) {
+	c.now = now
+	for wake, until := range c.sleepers {
+		if !until.After(now) {
+			close(wake)
+			delete(c.sleepers, wake)
+		}
+	}
+}
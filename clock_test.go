@@ -0,0 +1,100 @@
+// clock_test.go
+
+package janet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestClock tests getting the time of scripts from a host's clock.
+func TestClock(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, 2, 3, 4, 5, 6, 500_000_000, time.UTC))
+	vm, err := NewVM(WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	for expression, expected := range map[string]string{
+		`(os/time)`:                              "1580702706",
+		`(os/clock)`:                             "1580702706.5",
+		`(os/clock :monotonic :tuple)`:           "(1580702706 500000000)",
+		`(os/clock :realtime :int)`:              "1580702706",
+		`(get (os/date) :year)`:                  "2020",
+		`(os/strftime "%Y-%m-%d %H:%M:%S")`:      "2020-02-03 04:05:06",
+		`(os/strftime "%Y" 0)`:                   "1970",
+		`(number? (os/clock :cputime))`:          "true",
+		`(get (os/date 86400) :month-day)`:       "1",
+		`(- (os/time) (do (os/time) (os/time)))`: "0",
+	} {
+		if evaluated, _, _, err := vm.Execute(ctx, expression); err != nil || evaluated != expected {
+			t.Errorf("Expected %s to be %s, got %q (%v)", expression, expected, evaluated, err)
+		}
+	}
+
+	// sleeps wait for the clock
+	expressions := []string{`(os/sleep 60)`}
+	if Build().EV {
+		expressions = append(expressions, `(ev/sleep 60)`)
+	}
+	for _, expression := range expressions {
+		done := make(chan error, 1)
+		go func() {
+			_, _, _, err := vm.Execute(ctx, expression)
+			done <- err
+		}()
+		for clock.Sleepers() == 0 {
+			select {
+			case err := <-done:
+				t.Fatalf("Expected %s to wait for the clock, got %v", expression, err)
+			case <-time.After(time.Millisecond):
+			}
+		}
+		clock.Advance(59 * time.Second)
+		select {
+		case err := <-done:
+			t.Fatalf("Expected %s to wait for the clock, got %v", expression, err)
+		case <-time.After(50 * time.Millisecond):
+		}
+		clock.Advance(time.Second)
+		if err := <-done; err != nil {
+			t.Errorf("Failed to execute %s: %v", expression, err)
+		}
+	}
+	expected := fmt.Sprint(1580702706 + 60*len(expressions))
+	if evaluated, _, _, err := vm.Execute(ctx, `(os/time)`); err != nil || evaluated != expected {
+		t.Errorf("Expected time to be advanced to %s, got %q (%v)", expected, evaluated, err)
+	}
+
+	// tasks wake up in the order of the clock
+	if Build().EV {
+		go func() {
+			for clock.Sleepers() < 2 {
+				time.Sleep(time.Millisecond)
+			}
+			clock.Advance(time.Second)
+			time.Sleep(50 * time.Millisecond) // (for the woken task to run first)
+			clock.Advance(time.Second)
+		}()
+		if evaluated, _, _, err := vm.Execute(ctx, `(def order @[])
+(ev/gather
+  (do (ev/sleep 2) (array/push order :slow))
+  (do (ev/sleep 1) (array/push order :fast)))
+(string/format "%j" order)`); err != nil || evaluated != "@[:fast :slow]" {
+			t.Errorf("Expected tasks to wake up in order, got %q (%v)", evaluated, err)
+		}
+	}
+
+	// sleeps are interrupted with the context
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, _, _, err := vm.Execute(timeout, `(os/sleep 10)`); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+}
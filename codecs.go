@@ -0,0 +1,88 @@
+// codecs.go
+
+package janet
+
+import (
+	"encoding"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+var (
+	urlType             = reflect.TypeFor[url.URL]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// encodeText returns the string representation of common go types which have no janet counterparts,
+// to be converted to a janet string:
+//
+//   - url.URL, *url.URL => string (eg. "https://janet-lang.org")
+//   - named types of [16]byte (eg. UUIDs) => string (eg. "123e4567-e89b-12d3-a456-426614174000")
+//   - encoding.TextMarshaler (eg. net.IP or netip.Addr) => string returned from its MarshalText method
+//
+// It returns false if `value` is not one of them, or is a nil pointer or slice.
+func encodeText(value any) (text string, ok bool, err error) {
+	rv := reflect.ValueOf(value)
+	if (rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Slice) && rv.IsNil() {
+		return "", false, nil
+	}
+
+	switch v := value.(type) {
+	case url.URL:
+		return v.String(), true, nil
+	case *url.URL:
+		return v.String(), true, nil
+	case encoding.TextMarshaler:
+		marshaled, err := v.MarshalText()
+		if err != nil {
+			return "", true, fmt.Errorf("failed to marshal %T: %w", value, err)
+		}
+		return string(marshaled), true, nil
+	}
+
+	if isUUIDType(rv.Type()) {
+		b := make([]byte, 16)
+		reflect.Copy(reflect.ValueOf(b), rv)
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), true, nil
+	}
+	return "", false, nil
+}
+
+// isUUIDType returns whether `typ` is a named type of [16]byte (eg. UUIDs).
+func isUUIDType(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Array && typ.Len() == 16 && typ.Elem().Kind() == reflect.Uint8 && typ.Name() != ""
+}
+
+// assignText stores a string `src` converted from janet into `dst` of the types encoded by `encodeText`,
+// or of types implementing encoding.TextUnmarshaler (eg. net.IP).
+//
+// It returns false if `src` is not a string, or `dst` is not one of them.
+func assignText(dst reflect.Value, src any) (ok bool, err error) {
+	str, isString := src.(string)
+	if !isString {
+		return false, nil
+	}
+
+	switch {
+	case dst.Type() == urlType:
+		parsed, err := url.Parse(str)
+		if err != nil {
+			return true, err
+		}
+		dst.Set(reflect.ValueOf(*parsed))
+		return true, nil
+	case dst.CanAddr() && dst.Addr().Type().Implements(textUnmarshalerType):
+		return true, dst.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(str))
+	case isUUIDType(dst.Type()):
+		b, err := hex.DecodeString(strings.ReplaceAll(str, "-", ""))
+		if err != nil || len(b) != 16 {
+			return true, fmt.Errorf("invalid UUID: %q", str)
+		}
+		reflect.Copy(dst, reflect.ValueOf(b))
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,75 @@
+// codecs_test.go
+
+package janet
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// uuid is a uuid-like type.
+type uuid [16]byte
+
+// TestCodecs tests conversions of common go types.
+func TestCodecs(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	type config struct {
+		Endpoint url.URL
+		Proxy    *url.URL
+		IP       net.IP
+		Addr     netip.Addr
+		ID       uuid
+		Timeout  time.Duration
+	}
+
+	endpoint, _ := url.Parse("https://janet-lang.org/docs?q=1")
+	original := config{
+		Endpoint: *endpoint,
+		IP:       net.ParseIP("192.168.0.1"),
+		Addr:     netip.MustParseAddr("::1"),
+		ID:       uuid{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00},
+		Timeout:  1500 * time.Millisecond,
+	}
+
+	// go => janet
+	if err := vm.Define(ctx, "config", original); err != nil {
+		t.Fatalf("Failed to define: %v", err)
+	}
+	expected := `@["https://janet-lang.org/docs?q=1" nil "192.168.0.1" "::1" "123e4567-e89b-12d3-a456-426614174000" 1.5]`
+	if evaluated, _, _, err := vm.Execute(ctx, `(map config [:endpoint :proxy :ip :addr :id :timeout])`, WithRender(RenderJDN)); err != nil || evaluated != expected {
+		t.Errorf("Expected %s, got %s (%v)", expected, evaluated, err)
+	}
+
+	// janet => go
+	var decoded config
+	if err := vm.ExecuteInto(ctx, `(merge config {:proxy "http://localhost:8080" :timeout "1m30s"})`, &decoded); err != nil {
+		t.Fatalf("Failed to execute into: %v", err)
+	}
+	if decoded.Endpoint.String() != original.Endpoint.String() ||
+		decoded.Proxy == nil || decoded.Proxy.Host != "localhost:8080" ||
+		!decoded.IP.Equal(original.IP) ||
+		decoded.Addr != original.Addr ||
+		decoded.ID != original.ID ||
+		decoded.Timeout != 90*time.Second {
+		t.Errorf("Expected values converted back, got %+v", decoded)
+	}
+
+	// malformed values
+	for _, input := range []string{`{:ip "not an ip"}`, `{:id "123"}`, `{:timeout "soon"}`, `{:endpoint ":"}`} {
+		if err := vm.ExecuteInto(ctx, input, &decoded); err == nil || !strings.Contains(err.Error(), "result.") {
+			t.Errorf("Expected error with path for '%s', got: %v", input, err)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// conn.go
+
+package janet
+
+/*
+#include <unistd.h>
+#include "janet.h"
+
+#ifdef JANET_EV
+// methods of janet's net streams (`:read`, `:write`, `:close`, ...), borrowed from a stream created with `net/socket`
+// (they are static in janet, and the same for all VMs)
+static const void *netStreamMethods = NULL;
+#endif
+
+// returns nil if the socket could not be wrapped as a janet stream (`fd` is closed then)
+static Janet makeJanetConn(JanetTable *env, int fd) {
+#ifdef JANET_EV
+    if (netStreamMethods == NULL) {
+        Janet socket;
+        if (janet_resolve(env, janet_csymbol("net/socket"), &socket) != JANET_BINDING_NONE &&
+            janet_checktype(socket, JANET_CFUNCTION)) {
+            JanetTryState state;
+            if (janet_try(&state) == JANET_SIGNAL_OK) {
+                Janet created = janet_unwrap_cfunction(socket)(0, NULL);
+                if (janet_checkabstract(created, &janet_stream_type)) {
+                    JanetStream *stream = (JanetStream *)janet_unwrap_abstract(created);
+                    netStreamMethods = stream->methods;
+                    janet_stream_close(stream);
+                }
+            }
+            janet_restore(&state);
+        }
+    }
+    if (netStreamMethods != NULL) {
+        JanetStream *stream = janet_stream(fd, JANET_STREAM_READABLE | JANET_STREAM_WRITABLE | JANET_STREAM_SOCKET, netStreamMethods);
+        return janet_wrap_abstract(stream);
+    }
+#endif
+    close(fd);
+    return janet_wrap_nil();
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// Conn is a go net.Conn wrapped with VM.WrapConn, which is passed into janet (eg. with VM.Define)
+// as a janet stream (`core/stream`), so that scripts can use it with `net/read`, `net/write`, `:read`, `:write`,
+// or in fibers of the event loop (eg. `ev/spawn`) like the ones accepted with `net/server`.
+//
+// Data flows through a unix socket pair, copied by goroutines.
+type Conn struct {
+	mu   sync.Mutex
+	file *os.File // janet's end of the socket pair (nil after passed into janet)
+
+	done chan struct{} // closed when copying is finished
+	err  error         // error from copying
+}
+
+// halfCloser is a connection which can close its writing side only (eg. *net.TCPConn).
+type halfCloser interface {
+	CloseWrite() error
+}
+
+// WrapConn wraps `conn` as a janet stream, so that go can accept connections and hand protocol handling to scripts.
+//
+// Data read from `conn` is readable from the stream, which reaches EOF when `conn` does,
+// and data written to the stream is written to `conn`.
+// When the stream is closed in janet (or shut down with `(net/shutdown stream :w)`), the writing side of `conn`
+// is closed if it supports `CloseWrite` (eg. *net.TCPConn), otherwise `conn` itself is closed.
+// `conn` is closed after copying in both directions is finished.
+//
+// Streams are not available without janet's event loop or networking (eg. with `janet_no_ev` or `janet_no_net` build tag).
+func (vm *VM) WrapConn(conn net.Conn) (*Conn, error) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	file := os.NewFile(uintptr(fds[1]), "janet-conn")
+	local, err := net.FileConn(file)
+	file.Close()
+	if err != nil {
+		syscall.Close(fds[0])
+		return nil, err
+	}
+
+	c := &Conn{
+		file: os.NewFile(uintptr(fds[0]), "janet-stream"),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(c.done)
+
+		var wg sync.WaitGroup
+		var inErr, outErr error
+
+		// go => janet
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, inErr = io.Copy(local, conn)
+			if isClosedConn(inErr) {
+				inErr = nil // janet closed the stream before reading all
+			}
+			if hc, ok := local.(halfCloser); ok {
+				_ = hc.CloseWrite()
+			}
+		}()
+
+		// janet => go
+		_, outErr = io.Copy(conn, local)
+		if hc, ok := conn.(halfCloser); ok {
+			outErr = errors.Join(outErr, ignoreClosedConn(hc.CloseWrite()))
+		} else {
+			outErr = errors.Join(outErr, ignoreClosedConn(conn.Close()))
+		}
+
+		wg.Wait()
+		c.err = errors.Join(inErr, outErr, ignoreClosedConn(conn.Close()), local.Close())
+	}()
+
+	return c, nil
+}
+
+// Wait waits for copying to be finished and returns its error.
+//
+// It returns after both the janet stream and the net.Conn are closed (or reach EOF).
+func (c *Conn) Wait() error {
+	<-c.done
+	return c.err
+}
+
+// toJanet creates a janet stream from the connection.
+// A connection can be passed into janet only once.
+// This function should only be called from the VM handler goroutine.
+func (c *Conn) toJanet(env *C.JanetTable) (C.Janet, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return C.janet_wrap_nil(), errors.New("connection is already passed into janet")
+	}
+
+	// janet's stream will own a duplicated file descriptor
+	fd, err := syscall.Dup(int(c.file.Fd()))
+	if err != nil {
+		return C.janet_wrap_nil(), err
+	}
+	if err := c.file.Close(); err != nil {
+		syscall.Close(fd)
+		return C.janet_wrap_nil(), err
+	}
+	c.file = nil
+
+	syscall.CloseOnExec(fd)
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return C.janet_wrap_nil(), err
+	}
+	stream := C.makeJanetConn(env, C.int(fd))
+	if C.janet_checktype(stream, C.JANET_NIL) != 0 {
+		return stream, errors.New("failed to open connection as a janet stream")
+	}
+	return stream, nil
+}
+
+// isClosedConn returns whether `err` is from a connection closed by the other side (or by itself).
+func isClosedConn(err error) bool {
+	return errors.Is(err, net.ErrClosed) || errors.Is(err, io.ErrClosedPipe) || errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// ignoreClosedConn returns nil if `err` is from a closed connection.
+func ignoreClosedConn(err error) error {
+	if isClosedConn(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,110 @@
+// conn_test.go
+
+package janet
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+)
+
+// TestWrapConn tests the WrapConn function.
+func TestWrapConn(t *testing.T) {
+	if build := Build(); !build.EV || !build.Net {
+		t.Skip("streams are not available without the event loop or networking")
+	}
+
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	// client sends a request, and reads the response until the connection is closed
+	response := make(chan string, 1)
+	go func() {
+		client, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			response <- err.Error()
+			return
+		}
+		defer client.Close()
+		if _, err := client.Write([]byte("ping")); err != nil {
+			response <- err.Error()
+			return
+		}
+		_ = client.(*net.TCPConn).CloseWrite()
+		read, err := io.ReadAll(client)
+		if err != nil {
+			response <- err.Error()
+			return
+		}
+		response <- string(read)
+	}()
+
+	accepted, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+	conn, err := vm.WrapConn(accepted)
+	if err != nil {
+		t.Fatalf("Failed to wrap connection: %v", err)
+	}
+	if err := vm.Define(ctx, "conn", conn); err != nil {
+		t.Fatalf("Failed to define connection: %v", err)
+	}
+
+	// protocol is handled by a fiber of the event loop
+	if _, _, _, err := vm.Execute(ctx, `
+(ev/spawn
+  (def request (buffer))
+  (while (net/read conn 1024 request))
+  (net/write conn (string "pong: " request))
+  (:close conn))
+`); err != nil {
+		t.Fatalf("Failed to handle connection: %v", err)
+	}
+	if err := conn.Wait(); err != nil {
+		t.Errorf("Failed to copy connection: %v", err)
+	}
+	if res := <-response; res != "pong: ping" {
+		t.Errorf("Unexpected response: '%s'", res)
+	}
+
+	// a connection can be passed into janet only once
+	if err := vm.Define(ctx, "again", conn); err == nil {
+		t.Errorf("Expected error when passing a connection twice")
+	}
+
+	// connections without half-closing (closed when the stream is closed)
+	server, client := net.Pipe()
+	conn, err = vm.WrapConn(server)
+	if err != nil {
+		t.Fatalf("Failed to wrap connection: %v", err)
+	}
+	if err := vm.Define(ctx, "piped", conn); err != nil {
+		t.Fatalf("Failed to define connection: %v", err)
+	}
+	go func() {
+		read, _ := io.ReadAll(client)
+		response <- string(read)
+	}()
+	if _, _, _, err := vm.Execute(ctx, `(with [s piped] (:write s "hello"))`); err != nil {
+		t.Fatalf("Failed to write to connection: %v", err)
+	}
+	if res := <-response; res != "hello" {
+		t.Errorf("Unexpected data written: '%s'", res)
+	}
+	if err := conn.Wait(); err != nil {
+		t.Errorf("Failed to copy connection: %v", err)
+	}
+}
@@ -0,0 +1,270 @@
+// conversioncheck.go
+
+package janet
+
+/*
+#include "janet.h"
+
+static void *shapePointer(Janet x) {
+    return janet_unwrap_pointer(x);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// max number of bytes of values in mismatch reports
+const mismatchValueBytes = 80
+
+// ConversionMismatch is a discrepancy between a janet value and the go value converted from it,
+// found with WithConversionCheck.
+type ConversionMismatch struct {
+	Path   string // location of the value in the converted value (eg. `value[2][":name"]`)
+	Janet  string // summary of the janet value (eg. `tuple of 3 elements`)
+	Go     string // the converted go value (truncated)
+	Reason string // what is mismatched (eg. "expected 3 elements, got 2")
+}
+
+// String returns the mismatch formatted as a human-readable report.
+func (m ConversionMismatch) String() string {
+	return fmt.Sprintf("%s: %s (janet: %s, go: %s)", m.Path, m.Reason, m.Janet, m.Go)
+}
+
+// WithConversionCheck makes the VM cross-check every conversion of janet values to go (eg. VM.ParseToValue)
+// against the janet value walked again through the janet API, asserting type tags, lengths of collections,
+// and contents of strings and numbers, and report discrepancies to `handler` with their locations.
+//
+// It is for catching bugs of walking the memory of janet values (eg. tables and structs) in tests and debugging,
+// before they corrupt data in production, as it slows conversions down.
+// The handler is called on the VM handler goroutine, so it should not call methods of the VM (which would block forever).
+func WithConversionCheck(handler func(mismatch ConversionMismatch)) Option {
+	return func(o *vmOptions) {
+		o.conversionCheck = handler
+	}
+}
+
+// janetShape is a janet value walked through the janet API, for cross-checking conversions.
+type janetShape struct {
+	kind    string  // type name of janet (eg. "table"), or "cycle" for collections which contain themselves
+	boolean bool    // for booleans
+	number  float64 // for numbers
+	text    string  // bytes of strings, buffers, symbols, and keywords (without leading colons)
+
+	count  int          // length of collections reported by janet
+	elems  []janetShape // elements of tuples and arrays
+	keys   []any        // converted keys of tables and structs (nil for keys which failed to be converted)
+	values []janetShape // values of tables and structs
+}
+
+// String returns a summary of the value.
+func (s *janetShape) String() string {
+	switch s.kind {
+	case "boolean":
+		return fmt.Sprintf("boolean %t", s.boolean)
+	case "number":
+		return fmt.Sprintf("number %v", s.number)
+	case "string", "buffer", "symbol", "keyword":
+		return fmt.Sprintf("%s of %d bytes %s", s.kind, len(s.text), truncate(fmt.Sprintf("%q", s.text), mismatchValueBytes))
+	case "tuple", "array":
+		return fmt.Sprintf("%s of %d elements", s.kind, s.count)
+	case "table", "struct":
+		return fmt.Sprintf("%s of %d entries", s.kind, s.count)
+	}
+	return s.kind
+}
+
+// checkConversion cross-checks `converted` from `value`, and reports discrepancies to the handler.
+// This function should only be called from the VM handler goroutine.
+func (d *decoder) checkConversion(value C.Janet, converted any) {
+	// keys are converted again with the same policies, without the limits and the state of `d`
+	keys := &decoder{
+		ctx:     d.ctx,
+		cycles:  d.cycles,
+		keys:    d.keys,
+		ordered: d.ordered,
+		utf8:    d.utf8,
+		key:     true,
+	}
+	shape := keys.shapeOf(value, map[unsafe.Pointer]bool{})
+
+	checker := conversionChecker{utf8: d.utf8, report: d.check}
+	checker.compare(&shape, converted, "value")
+}
+
+// shapeOf walks a janet value through the janet API (instead of the memory walking of conversions).
+// `d` converts keys of tables and structs.
+func (d *decoder) shapeOf(value C.Janet, visiting map[unsafe.Pointer]bool) janetShape {
+	shape := janetShape{kind: janetTypeName(value)}
+
+	switch C.janet_type(value) {
+	case C.JANET_BOOLEAN:
+		shape.boolean = C.janet_unwrap_boolean(value) != 0
+	case C.JANET_NUMBER:
+		shape.number = float64(C.janet_unwrap_number(value))
+	case C.JANET_STRING, C.JANET_SYMBOL, C.JANET_KEYWORD:
+		shape.text = string(janetBytes(C.janet_unwrap_string(value), C.janet_length(value)))
+	case C.JANET_BUFFER:
+		buffer := C.janet_unwrap_buffer(value)
+		shape.text = string(janetBytes(buffer.data, C.janet_length(value)))
+	case C.JANET_TUPLE, C.JANET_ARRAY, C.JANET_TABLE, C.JANET_STRUCT:
+		ptr := C.shapePointer(value)
+		if visiting[ptr] {
+			return janetShape{kind: "cycle"}
+		}
+		visiting[ptr] = true
+		defer delete(visiting, ptr)
+
+		shape.count = int(C.janet_length(value))
+		if C.janet_checktypes(value, C.JANET_TFLAG_INDEXED) != 0 {
+			for i := range C.int32_t(shape.count) {
+				shape.elems = append(shape.elems, d.shapeOf(C.janet_getindex(value, i), visiting))
+			}
+			break
+		}
+
+		var kvs *C.JanetKV
+		var length, capacity C.int32_t
+		C.janet_dictionary_view(value, &kvs, &length, &capacity)
+		for kv := C.janet_dictionary_next(kvs, capacity, nil); kv != nil; kv = C.janet_dictionary_next(kvs, capacity, kv) {
+			shape.keys = append(shape.keys, d.convertedKey(kv.key))
+			shape.values = append(shape.values, d.shapeOf(kv.value, visiting))
+		}
+	}
+	return shape
+}
+
+// convertedKey converts a key of a janet table or struct in the same way as conversions (nil if it fails).
+func (d *decoder) convertedKey(key C.Janet) any {
+	converted, err := d.decodeValue(key)
+	if err != nil {
+		return nil
+	}
+	if converted != nil && !reflect.TypeOf(converted).Comparable() {
+		converted = janetValueToString(key)
+	}
+	if d.keys != MapKeysAny {
+		if converted, err = d.stringKey(converted, janetKeyKind(key)); err != nil {
+			return nil
+		}
+	}
+	return converted
+}
+
+// conversionChecker compares converted go values with janet values walked through the janet API.
+type conversionChecker struct {
+	utf8   InvalidUTF8
+	report func(mismatch ConversionMismatch)
+}
+
+// compare compares a converted go value at `path` with `shape`, reporting discrepancies.
+func (c conversionChecker) compare(shape *janetShape, converted any, path string) {
+	mismatch := func(format string, args ...any) {
+		c.report(ConversionMismatch{
+			Path:   path,
+			Janet:  shape.String(),
+			Go:     truncate(fmt.Sprintf("%#v", converted), mismatchValueBytes),
+			Reason: fmt.Sprintf(format, args...),
+		})
+	}
+
+	switch shape.kind {
+	case "nil":
+		if converted != nil {
+			mismatch("expected nil, got %T", converted)
+		}
+	case "boolean":
+		if b, ok := converted.(bool); !ok || b != shape.boolean {
+			mismatch("expected %t", shape.boolean)
+		}
+	case "number":
+		if n, ok := converted.(float64); !ok || (n != shape.number && !(math.IsNaN(n) && math.IsNaN(shape.number))) {
+			mismatch("expected %v", shape.number)
+		}
+	case "string", "buffer", "symbol", "keyword":
+		expected := shape.text
+		if shape.kind == "keyword" {
+			expected = ":" + expected
+		}
+		if c.utf8 == InvalidUTF8Replaced {
+			expected = strings.ToValidUTF8(expected, string(utf8.RuneError))
+		}
+		var text string
+		switch v := converted.(type) {
+		case string:
+			text = v
+		case []byte:
+			text = string(v)
+		default:
+			mismatch("expected a string, got %T", converted)
+			return
+		}
+		if text != expected {
+			mismatch("expected %d bytes %s, got %d bytes", len(expected), truncate(fmt.Sprintf("%q", expected), mismatchValueBytes), len(text))
+		}
+	case "tuple", "array":
+		slice, ok := converted.([]any)
+		if !ok {
+			mismatch("expected []any, got %T", converted)
+			return
+		}
+		if len(shape.elems) != shape.count || len(slice) != shape.count {
+			mismatch("expected %d elements, walked %d, got %d", shape.count, len(shape.elems), len(slice))
+			return
+		}
+		for i := range shape.elems {
+			c.compare(&shape.elems[i], slice[i], fmt.Sprintf("%s[%d]", path, i))
+		}
+	case "table", "struct":
+		var length int
+		var lookup func(key any) (any, bool)
+		switch m := converted.(type) {
+		case map[any]any:
+			length = len(m)
+			lookup = func(key any) (any, bool) { v, ok := m[key]; return v, ok }
+		case map[string]any:
+			length = len(m)
+			lookup = func(key any) (any, bool) {
+				name, ok := key.(string)
+				if !ok {
+					return nil, false
+				}
+				v, ok := m[name]
+				return v, ok
+			}
+		case *OrderedMap:
+			length = m.Len()
+			lookup = m.Get
+		default:
+			mismatch("expected a map, got %T", converted)
+			return
+		}
+		if len(shape.keys) != shape.count || length != shape.count {
+			mismatch("expected %d entries, walked %d, got %d", shape.count, len(shape.keys), length)
+			return
+		}
+		for i, key := range shape.keys {
+			value, ok := lookup(key)
+			if key == nil || !ok {
+				mismatch("missing key %#v", key)
+				continue
+			}
+			c.compare(&shape.values[i], value, fmt.Sprintf("%s[%#v]", path, key))
+		}
+	}
+	// (others, eg. abstract types and functions, are converted in many ways)
+}
+
+// truncate truncates `s` to `n` bytes (marking it with an ellipsis).
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,91 @@
+// conversioncheck_test.go
+
+package janet
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestConversionCheck tests cross-checking conversions of janet values to go.
+func TestConversionCheck(t *testing.T) {
+	ctx := context.TODO()
+
+	// conversions with various policies have no discrepancies
+	for _, opts := range [][]Option{
+		nil,
+		{WithMapKeys(MapKeysTrimmed), WithOrderedMaps()},
+		{WithInvalidUTF8(InvalidUTF8Replaced)},
+		{WithInvalidUTF8(InvalidUTF8Bytes)},
+		{WithCyclePolicy(CycleReference)},
+	} {
+		var mismatches []ConversionMismatch
+		vm, err := NewVM(append(opts, WithConversionCheck(func(mismatch ConversionMismatch) {
+			mismatches = append(mismatches, mismatch)
+		}))...)
+		if err != nil {
+			t.Fatalf("Failed to create Janet VM: %v", err)
+		}
+		defer vm.Close()
+
+		for _, expression := range []string{
+			`[nil true 1.5 "str" @"buf" 'sym :kw (int/s64 1) math/nan]`,
+			`{:a [1 2 {:b @{"c" [3]}}] "d" {:e "\xff"}}`,
+			`(seq [i :range [0 2000]] {:id i :name (string "n" i)})`,
+		} {
+			if !Build().IntTypes && strings.Contains(expression, "(int/") {
+				continue // (`int/*` is not available)
+			}
+			if _, err := vm.ParseToValue(ctx, expression); err != nil {
+				t.Fatalf("Failed to convert %s: %v", expression, err)
+			}
+			if _, err := vm.ParseToValue(ctx, expression, WithArena(NewArena())); err != nil {
+				t.Fatalf("Failed to convert %s with an arena: %v", expression, err)
+			}
+		}
+		// (fails without CycleReference)
+		_, _ = vm.ParseToValue(ctx, `(do (def t @{:x 1}) (put t :self t))`)
+
+		if len(mismatches) > 0 {
+			t.Errorf("Expected no mismatches, got %v", mismatches)
+		}
+	}
+
+	// discrepancies are reported with their locations
+	var mismatches []ConversionMismatch
+	checker := conversionChecker{report: func(mismatch ConversionMismatch) {
+		mismatches = append(mismatches, mismatch)
+	}}
+	shape := janetShape{kind: "tuple", count: 3, elems: []janetShape{
+		{kind: "number", number: 1},
+		{kind: "keyword", text: "kw"},
+		{kind: "table", count: 2, keys: []any{":a", ":b"}, values: []janetShape{
+			{kind: "string", text: "hello"},
+			{kind: "array", count: 2, elems: []janetShape{{kind: "nil"}, {kind: "boolean", boolean: true}}},
+		}},
+	}}
+	checker.compare(&shape, []any{1.0, ":kw", map[any]any{":a": "hello", ":b": []any{nil, true}}}, "value")
+	if len(mismatches) > 0 {
+		t.Errorf("Expected no mismatches, got %v", mismatches)
+	}
+
+	for _, tc := range []struct {
+		converted any
+		path      string
+		reason    string
+	}{
+		{[]any{1.0, ":kw"}, "value", "expected 3 elements"},
+		{[]any{2.0, ":kw", map[any]any{":a": "hello", ":b": []any{nil, true}}}, "value[0]", "expected 1"},
+		{[]any{1.0, "kw", map[any]any{":a": "hello", ":b": []any{nil, true}}}, "value[1]", "expected 3 bytes"},
+		{[]any{1.0, ":kw", map[any]any{":a": "hello", ":c": []any{nil, true}}}, "value[2]", `missing key ":b"`},
+		{[]any{1.0, ":kw", map[any]any{":a": "hello", ":b": []any{nil, 1.0}}}, `value[2][":b"][1]`, "expected true"},
+		{[]any{1.0, ":kw", map[string]any{":a": 1.0, ":b": []any{nil, true}}}, `value[2][":a"]`, "expected a string"},
+	} {
+		mismatches = nil
+		checker.compare(&shape, tc.converted, "value")
+		if len(mismatches) != 1 || mismatches[0].Path != tc.path || !strings.Contains(mismatches[0].Reason, tc.reason) {
+			t.Errorf("Expected a mismatch at %s (%s), got %v", tc.path, tc.reason, mismatches)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// crash.go
+
+package janet
+
+/*
+#include <stdlib.h>
+
+// defined in janet_bundled.go or janet_system.go
+const char *getCrashMessage();
+*/
+import "C"
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// Fatal errors of the janet runtime (eg. failed internal assertions, which abort the process by default)
+// raised while evaluating janet code are caught with the bundled janet. The runtime is discarded then,
+// and the failed request returns ErrVMCrashed while the VM continues with a new runtime
+// (created with the same options, including WithPrelude).
+//
+// Definitions made on the VM, value handles, watchers of vars, and async functions are lost with the runtime.
+// Fatal errors raised while go callbacks are running (eg. in functions registered with VM.RegisterAsyncFunc),
+// or with `system_janet`, still abort the process.
+
+// vmCrash is the panic value raised on the VM handler goroutine when the janet runtime fails fatally,
+// which unwinds the request being handled without running any other janet code.
+type vmCrash struct {
+	message string
+}
+
+// err returns the crash as an error wrapping ErrVMCrashed.
+func (c *vmCrash) err() error {
+	return fmt.Errorf("%w: %s", ErrVMCrashed, c.message)
+}
+
+// checkCrash panics with *vmCrash if the janet runtime of the current thread failed fatally.
+// This function should only be called from the VM handler goroutine.
+func checkCrash() {
+	if message := C.getCrashMessage(); message != nil {
+		panic(&vmCrash{message: strings.TrimSpace(C.GoString(message))})
+	}
+}
+
+// recoverCrash runs `fn` and returns the fatal failure of the janet runtime in it (if any).
+// This function should only be called from the VM handler goroutine.
+func recoverCrash(fn func()) (crash *vmCrash) {
+	defer func() {
+		if r := recover(); r != nil {
+			c, ok := r.(*vmCrash)
+			if !ok {
+				panic(r)
+			}
+			crash = c
+		}
+	}()
+	fn()
+	return nil
+}
+
+// discardRuntime drops the states of the VM which belong to its crashed janet runtime,
+// without touching the runtime.
+// This function should only be called from the VM handler goroutine, after the runtime crashed.
+func (vm *VM) discardRuntime() {
+	for _, watcher := range vm.watchers {
+		C.free(unsafe.Pointer(watcher.name))
+		close(watcher.ch)
+	}
+	vm.watchers = nil
+
+	for h := range vm.handles {
+		h.roots = 0
+	}
+	vm.handles = nil
+	vm.liveRoots.Store(0)
+
+	for _, helper := range vm.helpers() {
+		*helper.fn = nil
+	}
+	vm.asyncFuncs = nil
+	vm.env = nil
+	vm.results.clear()
+}
+
+// restart discards the crashed janet runtime of the VM and starts a new one.
+// The VM is closed if the new runtime fails to be initialized.
+//
+// This function should only be called from the VM handler goroutine which is exiting after the crash.
+func (vm *VM) restart() {
+	vm.discardRuntime()
+	vm.stats.crashes.Add(1)
+
+	if err := <-vm.spawn(); err != nil {
+		vm.closeOnce.Do(func() {
+			close(vm.shutdownChan)
+		})
+		vm.rejectRequests(vm.queue.close(), ErrClosed)
+		vm.subscriptions.close()
+	}
+}
@@ -0,0 +1,94 @@
+// crash_test.go
+
+package janet
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// janet expression which fails an internal assertion of the runtime
+const crashingExpression = `(range 0 1 math/nan)`
+
+// TestCrashRecovery tests recovering VMs from fatal errors of the runtime.
+func TestCrashRecovery(t *testing.T) {
+	if !recoversCrashes {
+		t.Skip("fatal errors are not recovered with system janet")
+	}
+
+	vm, err := NewVM(WithPrelude(`(defn double [x] (* 2 x))`))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	if _, _, _, err := vm.Execute(ctx, `(def answer 42)`); err != nil {
+		t.Fatalf("Failed to define: %v", err)
+	}
+	handle, err := vm.EvalHandle(ctx, `@[1 2 3]`)
+	if err != nil {
+		t.Fatalf("Failed to create handle: %v", err)
+	}
+
+	// crashes while executing
+	if _, _, _, err := vm.Execute(ctx, crashingExpression); !errors.Is(err, ErrVMCrashed) {
+		t.Fatalf("Expected ErrVMCrashed, got %v", err)
+	}
+
+	// the VM continues with a new runtime and the prelude
+	if evaluated, _, _, err := vm.Execute(ctx, `(double 21)`); err != nil || evaluated != "42" {
+		t.Errorf("Expected prelude to be evaluated again, got %q (%v)", evaluated, err)
+	}
+	if _, _, _, err := vm.Execute(ctx, `answer`); err == nil {
+		t.Errorf("Expected definitions to be lost with the crashed runtime")
+	}
+	if _, err := handle.Get(ctx, 0); err == nil {
+		t.Errorf("Expected handles to be released with the crashed runtime")
+	}
+	if roots := vm.LiveRoots(); roots != 0 {
+		t.Errorf("Expected no live roots, got %d", roots)
+	}
+
+	// crashes while parsing, and in other requests
+	if _, err := vm.ParseToValue(ctx, crashingExpression); !errors.Is(err, ErrVMCrashed) {
+		t.Errorf("Expected ErrVMCrashed from ParseToValue, got %v", err)
+	}
+	if _, err := vm.EvalHandle(ctx, crashingExpression); !errors.Is(err, ErrVMCrashed) {
+		t.Errorf("Expected ErrVMCrashed from EvalHandle, got %v", err)
+	}
+
+	if evaluated, _, _, err := vm.Execute(ctx, `(double 2)`); err != nil || evaluated != "4" {
+		t.Errorf("Expected VM to work after crashes, got %q (%v)", evaluated, err)
+	}
+	if crashes := vm.Stats().Crashes; crashes != 3 {
+		t.Errorf("Expected 3 crashes, got %d", crashes)
+	}
+}
+
+// TestPrelude tests evaluating preludes on creation and reset of VMs.
+func TestPrelude(t *testing.T) {
+	vm, err := NewVM(WithPrelude(`(def greeting "hello")`))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	if _, _, _, err := vm.Execute(ctx, `(def greeting "bye")`); err != nil {
+		t.Fatalf("Failed to redefine: %v", err)
+	}
+	if err := vm.Reset(ctx); err != nil {
+		t.Fatalf("Failed to reset: %v", err)
+	}
+	if evaluated, _, _, err := vm.Execute(ctx, `greeting`); err != nil || evaluated != "hello" {
+		t.Errorf("Expected prelude to be evaluated on reset, got %q (%v)", evaluated, err)
+	}
+
+	if _, err := NewVM(WithPrelude(`(error "oops")`)); err == nil {
+		t.Errorf("Expected creation to fail with a failing prelude")
+	}
+}
@@ -0,0 +1,93 @@
+// debug.go
+
+package janet
+
+/*
+#include "janet.h"
+*/
+import "C"
+
+import (
+	"context"
+	"reflect"
+	"runtime/cgo"
+	"unsafe"
+)
+
+// DebugFrame is a stack frame of a fiber paused by a debug signal.
+type DebugFrame struct {
+	Name   string         // name of the function (empty for anonymous functions)
+	Source string         // source path of the function (empty if unknown)
+	Line   int            // current source line (0 if unknown)
+	Column int            // current source column (0 if unknown)
+	Locals map[string]any // local bindings in the frame, converted to go
+}
+
+// DebugEvent is a debug signal raised by a script (eg. with `(debug)`).
+type DebugEvent struct {
+	Value  any          // payload of the signal converted to go (nil for `(debug)`)
+	Frames []DebugFrame // stack frames of the paused fiber (the current frame first)
+}
+
+// DebugAction is what to do with a fiber paused by a debug signal.
+type DebugAction int
+
+// DebugAction constants
+const (
+	DebugAbort  DebugAction = iota // fail the evaluation with an error
+	DebugResume                    // resume the paused fiber
+)
+
+// janet source of the helper function which lists the stack frames of a fiber.
+const debugInspectorSource = `(fn [fiber]
+  (map (fn [frame]
+         {:name (frame :name)
+          :source (frame :source)
+          :line (frame :source-line)
+          :column (frame :source-column)
+          :locals (frame :locals)})
+       (debug/stack fiber)))`
+
+// startDebugger makes the VM's debug handler (if any) receive debug signals raised on the current thread.
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) startDebugger() (stop func()) {
+	if vm.options.debugHandler == nil {
+		return func() {}
+	}
+
+	handle := cgo.NewHandle(vm)
+	setDebugHook(handle)
+	return func() {
+		setDebugHook(0)
+		handle.Delete()
+	}
+}
+
+// handleDebug calls the debug handler for `fiber` paused by a debug signal with `value`.
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) handleDebug(fiber, value unsafe.Pointer) DebugAction {
+	paused := (*C.JanetFiber)(fiber)
+	env := paused.env
+	if env == nil {
+		env = vm.env
+	}
+	dec := vm.decoder(context.Background())
+
+	var event DebugEvent
+	event.Value, _ = dec.decode(*(*C.Janet)(value))
+
+	if vm.debugInspector == nil {
+		if helper, err := compileHelper(env, debugInspectorSource); err == nil {
+			vm.debugInspector = helper
+		}
+	}
+	if vm.debugInspector != nil {
+		if frames, err := pcall(env, vm.debugInspector, C.janet_wrap_fiber(paused)); err == nil {
+			if converted, err := dec.decode(frames); err == nil {
+				_ = assign(reflect.ValueOf(&event.Frames).Elem(), converted, "frames", false)
+			}
+		}
+	}
+
+	return vm.options.debugHandler(event)
+}
@@ -0,0 +1,61 @@
+// debug_test.go
+
+package janet
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestDebugHandler tests handling debug signals raised by scripts.
+func TestDebugHandler(t *testing.T) {
+	var events []DebugEvent
+	vm, err := NewVM(WithDebugHandler(func(event DebugEvent) DebugAction {
+		events = append(events, event)
+		if event.Value == ":abort" {
+			return DebugAbort
+		}
+		return DebugResume
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	// resumed
+	evaluated, _, _, err := vm.Execute(ctx, `(defn add [a b] (let [sum (+ a b)] (debug) sum)) (add 1 2)`)
+	if err != nil || evaluated != "3" {
+		t.Errorf("Expected execution to be resumed, got: %s (%v)", evaluated, err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 debug event, got %d", len(events))
+	}
+	frame := events[0].Frames[0]
+	if frame.Name != "add" || frame.Locals["sum"] != float64(3) || frame.Locals["a"] != float64(1) {
+		t.Errorf("Unexpected frame: %+v", frame)
+	}
+
+	// raised in a child fiber
+	evaluated, _, _, err = vm.Execute(ctx, `(resume (fiber/new (fn [] (signal :debug :child) :done)))`)
+	if err != nil || evaluated != ":done" || len(events) != 2 || events[1].Value != ":child" {
+		t.Errorf("Expected child fiber to be resumed, got: %s (%v)", evaluated, err)
+	}
+
+	// aborted
+	if _, _, _, err := vm.Execute(ctx, `(signal :debug :abort) :unreachable`); err == nil || !strings.Contains(err.Error(), "aborted") {
+		t.Errorf("Expected execution to be aborted, got: %v", err)
+	}
+
+	// without handlers, debug signals fail executions
+	plain, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer plain.Close()
+	if _, _, _, err := plain.Execute(ctx, `(debug) :unreachable`); err == nil {
+		t.Errorf("Expected execution to fail without debug handlers")
+	}
+}
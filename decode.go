@@ -0,0 +1,457 @@
+// decode.go
+
+package janet
+
+/*
+#include "janet.h"
+
+static int32_t structCapacity(JanetStruct st) {
+    return janet_struct_capacity(st);
+}
+
+static int32_t structLength(JanetStruct st) {
+    return janet_struct_length(st);
+}
+
+static int32_t stringLength(const uint8_t *str) {
+    return janet_string_length(str);
+}
+
+static void *heapPointer(Janet x) {
+    return janet_unwrap_pointer(x);
+}
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// CyclePolicy is the policy for converting janet values which contain themselves
+// (eg. a table which contains itself) to go.
+type CyclePolicy int
+
+// CyclePolicy constants
+const (
+	CycleError     CyclePolicy = iota // conversion fails with an error
+	CycleReference                    // the same go map or slice is reused for the same table or array
+)
+
+// MapKeys is the policy for converting keys of janet tables and structs to go.
+type MapKeys int
+
+// MapKeys constants
+const (
+	MapKeysAny     MapKeys = iota // keys are converted as other values (tables and structs => map[any]any)
+	MapKeysString                 // string, symbol, and keyword keys are converted to strings, keywords with a leading colon (=> map[string]any)
+	MapKeysTrimmed                // same as MapKeysString, but keywords are converted without a leading colon (eg. `:name` => "name")
+)
+
+// InvalidUTF8 is the policy for converting janet strings, buffers, symbols, and keywords which are not valid UTF-8
+// (janet strings are arbitrary bytes) to go.
+type InvalidUTF8 int
+
+// InvalidUTF8 constants
+const (
+	InvalidUTF8Kept     InvalidUTF8 = iota // converted to go strings as they are
+	InvalidUTF8Error                       // conversion fails with an error
+	InvalidUTF8Replaced                    // invalid bytes are replaced with the replacement character (U+FFFD)
+	InvalidUTF8Bytes                       // strings and buffers are converted to []byte (symbols, keywords, and keys of tables and structs fail with an error)
+)
+
+// keyKind is the kind of a key of a janet table or struct, for converting it with MapKeys.
+type keyKind int
+
+// keyKind constants
+const (
+	keyOther   keyKind = iota // not convertible to a string
+	keyString                 // string or symbol
+	keyKeyword                // keyword
+)
+
+// DecodeLimits is the limits for converting (possibly untrusted) janet values to go.
+//
+// Zero values mean no limits.
+type DecodeLimits struct {
+	MaxDepth    int // max nesting depth of collections (tuples, arrays, tables, and structs)
+	MaxElements int // max number of elements (including keys and values of tables) in total
+	MaxBytes    int // max number of bytes of strings, symbols, and keywords in total
+}
+
+// number of values between checks of context cancellation while converting
+const decodeCancelCheckInterval = 1024
+
+// decoder converts janet values to go values.
+type decoder struct {
+	ctx     context.Context // checked periodically, for aborting conversions of huge values
+	cycles  CyclePolicy
+	limits  DecodeLimits
+	keys    MapKeys
+	ordered bool // whether tables and structs are converted to *OrderedMap instead of map[any]any
+	utf8    InvalidUTF8
+	arena   *Arena // where converted values are allocated (see WithArena)
+
+	check func(mismatch ConversionMismatch) // handler of mismatched conversions (see WithConversionCheck)
+
+	key bool // whether a key of a table or struct is being converted
+
+	depth    int // current nesting depth
+	elements int // number of elements converted so far
+	bytes    int // number of bytes converted so far
+
+	uncheckedValues int // number of values converted since the last check of context cancellation
+
+	visiting map[unsafe.Pointer]bool // collections being converted (for detecting cycles)
+	visited  map[unsafe.Pointer]any  // converted collections (for reusing references)
+}
+
+// decoder returns a new decoder with the VM's options,
+// which aborts conversions when `ctx` is done.
+func (vm *VM) decoder(ctx context.Context) *decoder {
+	return &decoder{
+		ctx:     ctx,
+		cycles:  vm.options.cycles,
+		limits:  vm.options.limits,
+		keys:    vm.options.mapKeys,
+		ordered: vm.options.orderedMaps,
+		utf8:    vm.options.invalidUTF8,
+		check:   vm.options.conversionCheck,
+	}
+}
+
+// decode converts a janet value to a go value.
+//
+//   - nil => nil
+//   - boolean => bool
+//   - number => float64 (including NaN and infinities)
+//   - int/s64, int/u64 => int64, uint64
+//   - string, buffer, symbol => string (or []byte for strings and buffers which are not valid UTF-8, with WithInvalidUTF8)
+//   - keyword => string (with a leading colon)
+//   - tuple, array => []any
+//   - table, struct => map[any]any (or map[string]any with WithMapKeys, or *OrderedMap with WithOrderedMaps)
+//   - go/value => *GoValue
+//   - others => string representation
+//
+// This function should only be called from the VM handler goroutine.
+func (d *decoder) decode(value C.Janet) (converted any, err error) {
+	if d.check != nil {
+		defer func() {
+			if err == nil {
+				d.checkConversion(value, converted)
+			}
+		}()
+	}
+
+	// convert collections at once, unless the same go values should be reused for the same collections
+	if d.depth == 0 && d.cycles == CycleError && C.janet_checktypes(value, C.JANET_TFLAG_INDEXED|C.JANET_TFLAG_DICTIONARY) != 0 {
+		if converted, ok, err := d.decodeSerialized(value); ok {
+			return converted, err
+		}
+	}
+	return d.decodeValue(value)
+}
+
+// decodeValue converts a janet value to a go value, converting the elements of collections one by one.
+// This function should only be called from the VM handler goroutine.
+func (d *decoder) decodeValue(value C.Janet) (any, error) {
+	d.uncheckedValues++
+	if d.uncheckedValues >= decodeCancelCheckInterval {
+		d.uncheckedValues = 0
+		if d.ctx.Err() != nil {
+			return nil, fmt.Errorf("conversion aborted: %w", contextError(d.ctx))
+		}
+	}
+
+	switch C.janet_type(value) {
+	case C.JANET_NIL:
+		return nil, nil
+	case C.JANET_BOOLEAN:
+		return C.janet_unwrap_boolean(value) != 0, nil
+	case C.JANET_NUMBER:
+		number := float64(C.janet_unwrap_number(value))
+		if math.IsNaN(number) {
+			return math.NaN(), nil // normalize NaN payloads
+		}
+		return number, nil // including math.Inf(1) and math.Inf(-1)
+	case C.JANET_STRING:
+		str := C.janet_unwrap_string(value)
+		if err := d.addBytes(int(C.stringLength(str))); err != nil {
+			return nil, err
+		}
+		return d.decodeText(d.text("", janetBytes(str, C.stringLength(str))), "string")
+	case C.JANET_SYMBOL:
+		sym := C.janet_unwrap_symbol(value)
+		if err := d.addBytes(int(C.stringLength(sym))); err != nil {
+			return nil, err
+		}
+		return d.decodeText(d.text("", janetBytes(sym, C.stringLength(sym))), "symbol")
+	case C.JANET_KEYWORD:
+		kw := C.janet_unwrap_keyword(value)
+		if err := d.addBytes(int(C.stringLength(kw)) + 1); err != nil {
+			return nil, err
+		}
+		return d.decodeText(d.text(":", janetBytes(kw, C.stringLength(kw))), "keyword")
+	case C.JANET_BUFFER:
+		str, err := d.decodeAsString(value)
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeText(str.(string), "buffer")
+	case C.JANET_TUPLE, C.JANET_ARRAY, C.JANET_TABLE, C.JANET_STRUCT:
+		return d.decodeCollection(value)
+	case C.JANET_ABSTRACT:
+		if wrapped := unwrapGoValue(value); wrapped != nil {
+			return wrapped, nil
+		}
+		if converted, ok := janetIntToGo(value); ok {
+			return converted, nil
+		}
+		return d.decodeAsString(value)
+	default:
+		// For other complex types, fallback to string representation
+		return d.decodeAsString(value)
+	}
+}
+
+// janetStringToGo converts the bytes of a janet string, symbol, or keyword to go,
+// including NULs (janet strings are length-prefixed, not NUL-terminated).
+func janetStringToGo(str *C.uint8_t) string {
+	return C.GoStringN((*C.char)(unsafe.Pointer(str)), C.int(C.stringLength(str)))
+}
+
+// text converts bytes of a janet string, symbol, or keyword (prefixed with `prefix`) to a go string.
+func (d *decoder) text(prefix string, b []byte) string {
+	if d.arena != nil {
+		return d.arena.string(prefix, b)
+	}
+	return prefix + string(b)
+}
+
+// newSlice returns a go slice of `n` elements for a converted collection.
+func (d *decoder) newSlice(n int) []any {
+	if d.arena != nil {
+		return d.arena.slice(n)
+	}
+	return make([]any, n)
+}
+
+// decodeText applies the policy for invalid UTF-8 to `text` converted from a janet value of `kind`
+// ("string", "buffer", "symbol", or "keyword").
+func (d *decoder) decodeText(text string, kind string) (any, error) {
+	if d.utf8 == InvalidUTF8Kept || utf8.ValidString(text) {
+		return text, nil
+	}
+	switch d.utf8 {
+	case InvalidUTF8Replaced:
+		return strings.ToValidUTF8(text, string(utf8.RuneError)), nil
+	case InvalidUTF8Bytes:
+		if (kind == "string" || kind == "buffer") && !d.key {
+			return []byte(text), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot convert %s %q which is not valid UTF-8", kind, text)
+}
+
+// decodeAsString converts a janet value to its string representation.
+func (d *decoder) decodeAsString(value C.Janet) (any, error) {
+	str := janetValueToString(value)
+	if err := d.addBytes(len(str)); err != nil {
+		return nil, err
+	}
+	return str, nil
+}
+
+// addBytes counts `n` bytes converted, and returns an error if it exceeds the limit.
+func (d *decoder) addBytes(n int) error {
+	d.bytes += n
+	if d.limits.MaxBytes > 0 && d.bytes > d.limits.MaxBytes {
+		return &QuotaError{Quota: "MaxBytes", Limit: d.limits.MaxBytes}
+	}
+	return nil
+}
+
+// addElements counts `n` elements converted, and returns an error if it exceeds the limit.
+func (d *decoder) addElements(n int) error {
+	d.elements += n
+	if d.limits.MaxElements > 0 && d.elements > d.limits.MaxElements {
+		return &QuotaError{Quota: "MaxElements", Limit: d.limits.MaxElements}
+	}
+	return nil
+}
+
+// decodeCollection converts a janet tuple, array, table, or struct to a go slice or map,
+// detecting cycles.
+func (d *decoder) decodeCollection(value C.Janet) (any, error) {
+	if d.visiting == nil {
+		d.visiting, d.visited = map[unsafe.Pointer]bool{}, map[unsafe.Pointer]any{}
+	}
+	ptr := C.heapPointer(value)
+	if d.visiting[ptr] {
+		if d.cycles == CycleReference {
+			return d.visited[ptr], nil
+		}
+		return nil, fmt.Errorf("cannot convert %s which contains itself", janetTypeName(value))
+	}
+	if converted, exists := d.visited[ptr]; exists && d.cycles == CycleReference {
+		return converted, nil
+	}
+	d.visiting[ptr] = true
+	defer delete(d.visiting, ptr)
+
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.limits.MaxDepth > 0 && d.depth > d.limits.MaxDepth {
+		return nil, &QuotaError{Quota: "MaxDepth", Limit: d.limits.MaxDepth}
+	}
+
+	switch C.janet_type(value) {
+	case C.JANET_TUPLE, C.JANET_ARRAY:
+		elems := janetIndexed(value)
+		if err := d.addElements(len(elems)); err != nil {
+			return nil, err
+		}
+		slice := d.newSlice(len(elems))
+		d.visited[ptr] = slice
+		for i, elem := range elems {
+			converted, err := d.decodeValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			slice[i] = converted
+		}
+		return slice, nil
+	default:
+		var kvs []C.JanetKV
+		var count int
+		if C.janet_checktype(value, C.JANET_TABLE) != 0 {
+			table := C.janet_unwrap_table(value)
+			kvs = unsafe.Slice(table.data, int(table.capacity))
+			count = int(table.count)
+		} else {
+			st := C.janet_unwrap_struct(value)
+			kvs = unsafe.Slice(st, int(C.structCapacity(st)))
+			count = int(C.structLength(st))
+		}
+		if err := d.addElements(count * 2); err != nil {
+			return nil, err
+		}
+		result, put := d.newDictionary(count)
+		d.visited[ptr] = result
+		for _, kv := range kvs {
+			if C.janet_checktype(kv.key, C.JANET_NIL) != 0 {
+				continue
+			}
+			inKey := d.key
+			d.key = true
+			key, err := d.decodeValue(kv.key)
+			d.key = inKey
+			if err != nil {
+				return nil, err
+			}
+			val, err := d.decodeValue(kv.value)
+			if err != nil {
+				return nil, err
+			}
+			if key != nil && !reflect.TypeOf(key).Comparable() {
+				// collections cannot be go map keys, so use their string representations instead
+				key = janetValueToString(kv.key)
+			}
+			if err := put(key, janetKeyKind(kv.key), val); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+}
+
+// newDictionary returns an empty go value for a janet table or struct with `count` entries,
+// and a function for adding entries to it (with the converted key, and the kind of the janet key).
+func (d *decoder) newDictionary(count int) (dict any, put func(key any, kind keyKind, value any) error) {
+	if d.keys == MapKeysAny {
+		if d.ordered {
+			m := newOrderedMap(count)
+			return m, func(key any, _ keyKind, value any) error {
+				m.Set(key, value)
+				return nil
+			}
+		}
+		var m map[any]any
+		if d.arena != nil {
+			m = d.arena.anyMap(count)
+		} else {
+			m = make(map[any]any, count)
+		}
+		return m, func(key any, _ keyKind, value any) error {
+			m[key] = value
+			return nil
+		}
+	}
+
+	var ordered *OrderedMap
+	var m map[string]any
+	if d.ordered {
+		ordered = newOrderedMap(count)
+		dict = ordered
+	} else {
+		if d.arena != nil {
+			m = d.arena.stringMap(count)
+		} else {
+			m = make(map[string]any, count)
+		}
+		dict = m
+	}
+	return dict, func(key any, kind keyKind, value any) error {
+		name, err := d.stringKey(key, kind)
+		if err != nil {
+			return err
+		}
+		if ordered != nil {
+			if _, exists := ordered.Get(name); exists {
+				return fmt.Errorf("duplicate key %q", name)
+			}
+			ordered.Set(name, value)
+		} else {
+			if _, exists := m[name]; exists {
+				return fmt.Errorf("duplicate key %q", name)
+			}
+			m[name] = value
+		}
+		return nil
+	}
+}
+
+// stringKey converts a converted key of a janet table or struct to a string, with the policy for keys.
+func (d *decoder) stringKey(key any, kind keyKind) (string, error) {
+	switch kind {
+	case keyString:
+		return key.(string), nil
+	case keyKeyword:
+		if d.keys == MapKeysTrimmed {
+			return strings.TrimPrefix(key.(string), ":"), nil
+		}
+		return key.(string), nil
+	}
+	return "", fmt.Errorf("cannot convert key %v (%T) to a string", key, key)
+}
+
+// janetKeyKind returns the kind of a janet key.
+func janetKeyKind(key C.Janet) keyKind {
+	switch C.janet_type(key) {
+	case C.JANET_STRING, C.JANET_SYMBOL:
+		return keyString
+	case C.JANET_KEYWORD:
+		return keyKeyword
+	}
+	return keyOther
+}
+
+// janetTypeName returns the type name of a janet value (eg. "table").
+func janetTypeName(value C.Janet) string {
+	return C.GoString(C.janet_type_names[C.janet_type(value)])
+}
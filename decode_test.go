@@ -0,0 +1,413 @@
+// decode_test.go
+
+package janet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// TestCycles tests conversions of janet values which contain themselves.
+func TestCycles(t *testing.T) {
+	ctx := context.TODO()
+
+	const cyclicTable = `(do (def t @{:name "t"}) (put t :self t))`
+	const cyclicArray = `(do (def a @[1]) (array/push a a))`
+	const sharedArray = `(do (def a @[1]) [a {:a a}])`
+
+	// CycleError (default)
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	for _, input := range []string{cyclicTable, cyclicArray} {
+		if _, err := vm.ParseToValue(ctx, input); err == nil || !strings.Contains(err.Error(), "contains itself") {
+			t.Errorf("Expected error for '%s', got: %v", input, err)
+		}
+	}
+	if value, err := vm.ParseToValue(ctx, sharedArray); err != nil {
+		t.Errorf("Failed to parse shared (but not cyclic) values: %v", err)
+	} else if expected := []any{[]any{float64(1)}, map[any]any{":a": []any{float64(1)}}}; !reflect.DeepEqual(value, expected) {
+		t.Errorf("Expected %v, got %v", expected, value)
+	}
+
+	// CycleReference
+	refVM, err := NewVM(WithCyclePolicy(CycleReference))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer refVM.Close()
+
+	value, err := refVM.ParseToValue(ctx, cyclicTable)
+	if err != nil {
+		t.Fatalf("Failed to parse cyclic table: %v", err)
+	}
+	table := value.(map[any]any)
+	if self, ok := table[":self"].(map[any]any); !ok || reflect.ValueOf(self).Pointer() != reflect.ValueOf(table).Pointer() {
+		t.Errorf("Expected the table to reference itself, got %v", table[":self"])
+	}
+
+	value, err = refVM.ParseToValue(ctx, cyclicArray)
+	if err != nil {
+		t.Fatalf("Failed to parse cyclic array: %v", err)
+	}
+	array := value.([]any)
+	if self, ok := array[1].([]any); !ok || &self[0] != &array[0] {
+		t.Errorf("Expected the array to reference itself, got %v", array[1])
+	}
+}
+
+// TestDecodeLimits tests the limits for converting janet values.
+func TestDecodeLimits(t *testing.T) {
+	ctx := context.TODO()
+
+	vm, err := NewVM(WithDecodeLimits(DecodeLimits{
+		MaxDepth:    3,
+		MaxElements: 100,
+		MaxBytes:    1000,
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	tests := []struct {
+		input              string
+		expectedErrPattern string
+	}{
+		{`[[[1]]]`, ""},
+		{`[[[[1]]]]`, "nesting depth"},
+		{`(range 100)`, ""},
+		{`(range 101)`, "elements"},
+		{`(table ;(range 100))`, ""},
+		{`(table ;(range 102))`, "elements"},
+		{`[(range 50) (range 50)]`, "elements"},
+		{`(string/repeat "a" 1000)`, ""},
+		{`(string/repeat "a" 1001)`, "bytes"},
+		{`[(string/repeat "a" 600) (keyword (string/repeat "b" 600))]`, "bytes"},
+	}
+	for _, test := range tests {
+		_, err := vm.ParseToValue(ctx, test.input)
+		if test.expectedErrPattern == "" {
+			if err != nil {
+				t.Errorf("Expected no error for '%s', got: %v", test.input, err)
+			}
+		} else if quotaErr := (*QuotaError)(nil); !errors.As(err, &quotaErr) || !strings.Contains(err.Error(), test.expectedErrPattern) {
+			t.Errorf("Expected quota error with '%s' for '%s', got: %v", test.expectedErrPattern, test.input, err)
+		}
+	}
+
+	// limits stop serializing huge values at once, before they are serialized in memory
+	serialized := 0
+	onSerialized = func(bytes int) { serialized += bytes }
+	defer func() { onSerialized = nil }()
+	if _, err := vm.ParseToValue(ctx, `(seq [i :range [0 100000]] (string/repeat "x" 100))`); !errors.As(err, new(*QuotaError)) {
+		t.Errorf("Expected a quota error, got: %v", err)
+	}
+	if serialized > 0 {
+		t.Errorf("Expected the value not to be serialized, got %d bytes", serialized)
+	}
+}
+
+// TestCollectionKeys tests conversions of tables with collections as keys.
+func TestCollectionKeys(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	value, err := vm.ParseToValue(context.TODO(), `{[1 2] :tuple @{} :table}`)
+	if err != nil {
+		t.Fatalf("Failed to parse table with collection keys: %v", err)
+	}
+	if table := value.(map[any]any); table["(1 2)"] != ":tuple" || len(table) != 2 {
+		t.Errorf("Expected collection keys as their string representations, got %v", table)
+	}
+}
+
+// cancelAfterContext is a context which is canceled after its Err is called `n` times.
+type cancelAfterContext struct {
+	context.Context
+	n atomic.Int32
+}
+
+// Err returns context.Canceled after it is called `n` times.
+func (c *cancelAfterContext) Err() error {
+	if c.n.Add(-1) < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+// TestDecodeCancellation tests cancellation of conversions of huge values.
+func TestDecodeCancellation(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := &cancelAfterContext{Context: context.Background()}
+	ctx.n.Store(10)
+
+	if _, err := vm.ParseToValue(ctx, "(range 100000)"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected conversion to be canceled, got: %v", err)
+	}
+	if remaining := ctx.n.Load(); remaining >= 0 {
+		t.Errorf("Expected context to be checked while converting")
+	}
+}
+
+// TestBinaryStrings tests conversions of janet strings which contain NULs.
+func TestBinaryStrings(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`"a\0b"`, "a\x00b"},
+		{`(string "\0" "\xff" "c")`, "\x00\xffc"},
+		{`(keyword "k\0v")`, ":k\x00v"},
+		{`(symbol "s\0")`, "s\x00"},
+		{`@"buf\0fer"`, "buf\x00fer"},
+		{`["x\0y"]`, []any{"x\x00y"}},
+	}
+	for _, test := range tests {
+		if value, err := vm.ParseToValue(ctx, test.input); err != nil {
+			t.Errorf("Failed to parse '%s': %v", test.input, err)
+		} else if !reflect.DeepEqual(value, test.expected) {
+			t.Errorf("Expected %q for '%s', got %q", test.expected, test.input, value)
+		}
+	}
+
+	if evaluated, _, _, err := vm.Execute(ctx, `"a\0b"`); err != nil {
+		t.Errorf("Failed to execute binary string: %v", err)
+	} else if evaluated != "a\x00b" {
+		t.Errorf("Expected binary string to be evaluated as %q, got %q", "a\x00b", evaluated)
+	}
+}
+
+// TestSerializedDecoding tests that collections converted at once are the same as the ones converted one by one.
+func TestSerializedDecoding(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	// collections are converted one by one when the same go values should be reused
+	oneByOne, err := NewVM(WithCyclePolicy(CycleReference))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer oneByOne.Close()
+
+	ctx := context.TODO()
+
+	for _, expr := range []string{
+		`[nil true false 1.5 -0 math/inf "str\0ing" 'sym :kw]`,
+		`@[[1 [2 [3 @[4]]]] {:a {:b @{:c "d"}}}]`,
+		`{[1 2] :tuple-key 3 :number-key "s" :string-key}`,
+		`[(int/s64 "9007199254740993") (int/u64 1) @"buffer" print]`,
+		`[math/nan]`,
+		`(do (var x @[]) (for i 0 2000 (set x @[x])) x)`, // deeper than the c serializer converts at once
+	} {
+		if !Build().IntTypes && strings.Contains(expr, "(int/") {
+			continue // (`int/*` is not available)
+		}
+		serialized, err1 := vm.ParseToValue(ctx, expr)
+		converted, err2 := oneByOne.ParseToValue(ctx, expr)
+		if err1 != nil || err2 != nil {
+			t.Errorf("Failed to convert '%s': %v, %v", expr, err1, err2)
+		} else if fmt.Sprintf("%v", serialized) != fmt.Sprintf("%v", converted) {
+			t.Errorf("Expected the same values for '%s', got: %v and %v", expr, serialized, converted)
+		}
+	}
+
+	// collections which contain themselves
+	if _, err := vm.ParseToValue(ctx, `(do (def t @{}) (put t :self t) [t])`); err == nil || !strings.Contains(err.Error(), "contains itself") {
+		t.Errorf("Expected error for table which contains itself, got: %v", err)
+	}
+}
+
+// TestOrderedMaps tests conversions of tables and structs to OrderedMap.
+func TestOrderedMaps(t *testing.T) {
+	ctx := context.TODO()
+
+	vm, err := NewVM(WithOrderedMaps(), WithCyclePolicy(CycleReference))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	if _, _, _, err := vm.Execute(ctx, `(def config {:name "janet" :version 1 :tags [:a :b] :nested @{:x 1 :y 2 :z 3}})`); err != nil {
+		t.Fatalf("Failed to define: %v", err)
+	}
+	expectedKeys, _, _, err := vm.EvalValue(ctx, `(keys config)`)
+	if err != nil {
+		t.Fatalf("Failed to evaluate keys: %v", err)
+	}
+
+	value, _, _, err := vm.EvalValue(ctx, `config`)
+	if err != nil {
+		t.Fatalf("Failed to evaluate: %v", err)
+	}
+	config, ok := value.(*OrderedMap)
+	if !ok {
+		t.Fatalf("Expected *OrderedMap, got %T", value)
+	}
+	if !reflect.DeepEqual(config.Keys(), expectedKeys) {
+		t.Errorf("Expected keys in janet's order %v, got %v", expectedKeys, config.Keys())
+	}
+	if name, ok := config.Get(":name"); !ok || name != "janet" {
+		t.Errorf("Expected value for :name, got %v (%v)", name, ok)
+	}
+	if _, ok := config.Get(":nonexistent"); ok {
+		t.Errorf("Expected no value for unknown key")
+	}
+	if nested, _ := config.Get(":nested"); reflect.TypeOf(nested) != reflect.TypeOf(config) {
+		t.Errorf("Expected nested *OrderedMap, got %T", nested)
+	}
+
+	// values converted one by one (with cycles)
+	value, err = vm.ParseToValue(ctx, `(do (def t @{:name "t"}) (put t :self t))`)
+	if err != nil {
+		t.Fatalf("Failed to parse cyclic table: %v", err)
+	}
+	if table := value.(*OrderedMap); table.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %v", table.Entries())
+	} else if self, _ := table.Get(":self"); self != table {
+		t.Errorf("Expected the table to reference itself, got %v", self)
+	}
+
+	// ordered maps are stored into go values and converted back to tables
+	var out struct {
+		Name   string
+		Nested map[string]int
+	}
+	if err := vm.ExecuteInto(ctx, `config`, &out); err != nil || out.Name != "janet" || out.Nested[":y"] != 2 {
+		t.Errorf("Expected ordered maps stored into a struct, got %+v (%v)", out, err)
+	}
+	if err := vm.Define(ctx, "again", NewOrderedMap(Entry{"a", 1}, Entry{"b", 2}, Entry{"a", 3})); err != nil {
+		t.Fatalf("Failed to define: %v", err)
+	}
+	if evaluated, _, _, err := vm.Execute(ctx, `(string (again "a") (again "b") (length again))`); err != nil || evaluated != "322" {
+		t.Errorf("Expected ordered map converted to a table, got %s (%v)", evaluated, err)
+	}
+}
+
+// TestMapKeys tests conversions of tables and structs with string keys.
+func TestMapKeys(t *testing.T) {
+	ctx := context.TODO()
+
+	tests := []struct {
+		policy             MapKeys
+		ordered            bool
+		input              string
+		expected           any
+		expectedErrPattern string
+	}{
+		{MapKeysString, false, `{:name "janet" "tags" [{:a 1}] 'sym true}`, map[string]any{":name": "janet", "tags": []any{map[string]any{":a": float64(1)}}, "sym": true}, ""},
+		{MapKeysTrimmed, false, `{:name "janet" "tags" @{:a 1}}`, map[string]any{"name": "janet", "tags": map[string]any{"a": float64(1)}}, ""},
+		{MapKeysTrimmed, true, `{:name "janet"}`, NewOrderedMap(Entry{"name", "janet"}), ""},
+		{MapKeysString, false, `{1 :one}`, nil, "cannot convert key 1"},
+		{MapKeysString, false, `{[1] :tuple}`, nil, "cannot convert key"},
+		{MapKeysTrimmed, false, `{:a 1 "a" 2}`, nil, `duplicate key "a"`},
+		{MapKeysTrimmed, true, `{:a 1 "a" 2}`, nil, `duplicate key "a"`},
+	}
+	for _, test := range tests {
+		// values are converted at once with CycleError, and one by one with CycleReference
+		for _, cycles := range []CyclePolicy{CycleError, CycleReference} {
+			opts := []Option{WithMapKeys(test.policy), WithCyclePolicy(cycles)}
+			if test.ordered {
+				opts = append(opts, WithOrderedMaps())
+			}
+			vm, err := NewVM(opts...)
+			if err != nil {
+				t.Fatalf("Failed to create Janet VM: %v", err)
+			}
+
+			value, err := vm.ParseToValue(ctx, test.input)
+			if test.expectedErrPattern == "" {
+				if err != nil {
+					t.Errorf("Failed to parse '%s': %v", test.input, err)
+				} else if !reflect.DeepEqual(value, test.expected) {
+					t.Errorf("Expected %v for '%s', got %v", test.expected, test.input, value)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), test.expectedErrPattern) {
+				t.Errorf("Expected error with '%s' for '%s', got: %v", test.expectedErrPattern, test.input, err)
+			}
+			vm.Close()
+		}
+	}
+}
+
+// TestInvalidUTF8 tests conversions of janet strings which are not valid UTF-8.
+func TestInvalidUTF8(t *testing.T) {
+	ctx := context.TODO()
+
+	tests := []struct {
+		policy             InvalidUTF8
+		input              string
+		expected           any
+		expectedErrPattern string
+	}{
+		{InvalidUTF8Kept, `["a\xffb" (keyword "k\xff")]`, []any{"a\xffb", ":k\xff"}, ""},
+		{InvalidUTF8Error, `["ok" "héllo"]`, []any{"ok", "héllo"}, ""},
+		{InvalidUTF8Error, `["a\xffb"]`, nil, "not valid UTF-8"},
+		{InvalidUTF8Error, `(symbol "s\xff")`, nil, "cannot convert symbol"},
+		{InvalidUTF8Replaced, `["a\xff\xfeb" {(keyword "k\xff") 1}]`, []any{"a�b", map[any]any{":k�": float64(1)}}, ""},
+		{InvalidUTF8Bytes, `["a\xffb" "ok" @"\xfe"]`, []any{[]byte("a\xffb"), "ok", []byte("\xfe")}, ""},
+		{InvalidUTF8Bytes, `{"k\xff" 1}`, nil, "cannot convert string"},
+		{InvalidUTF8Bytes, `(keyword "k\xff")`, nil, "cannot convert keyword"},
+	}
+	for _, test := range tests {
+		// values are converted at once with CycleError, and one by one with CycleReference
+		for _, cycles := range []CyclePolicy{CycleError, CycleReference} {
+			vm, err := NewVM(WithInvalidUTF8(test.policy), WithCyclePolicy(cycles))
+			if err != nil {
+				t.Fatalf("Failed to create Janet VM: %v", err)
+			}
+
+			value, err := vm.ParseToValue(ctx, test.input)
+			if test.expectedErrPattern == "" {
+				if err != nil {
+					t.Errorf("Failed to parse '%s': %v", test.input, err)
+				} else if !reflect.DeepEqual(value, test.expected) {
+					t.Errorf("Expected %q for '%s', got %q", test.expected, test.input, value)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), test.expectedErrPattern) {
+				t.Errorf("Expected error with '%s' for '%s', got: %v", test.expectedErrPattern, test.input, err)
+			}
+			vm.Close()
+		}
+	}
+
+	// stored into []byte losslessly
+	vm, err := NewVM(WithInvalidUTF8(InvalidUTF8Bytes))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+	var out struct {
+		Data []byte `janet:"data"`
+	}
+	if err := vm.ExecuteInto(ctx, `{:data "\xff\x00"}`, &out); err != nil || string(out.Data) != "\xff\x00" {
+		t.Errorf("Expected bytes to be stored, got %q (%v)", out.Data, err)
+	}
+}
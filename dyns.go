@@ -0,0 +1,92 @@
+// dyns.go
+
+package janet
+
+/*
+#include "janet.h"
+
+// binds dynamic bindings in `dyns` in `env`, and returns the previous bindings (as tuples of them,
+// as nil values are not stored in tables), which should be restored with `unbindDyns`
+//
+// (the ones outside fibers are not bound, as they are not marked by the gc of janet)
+static JanetTable *bindDyns(JanetTable *env, JanetTable *dyns) {
+    JanetTable *prev = janet_table(dyns->count);
+    janet_gcroot(janet_wrap_table(prev));
+
+    for (int32_t i = 0; i < dyns->capacity; i++) {
+        JanetKV kv = dyns->data[i];
+        if (!janet_checktype(kv.key, JANET_KEYWORD)) continue;
+
+        Janet old = janet_table_rawget(env, kv.key);
+        janet_table_put(prev, kv.key, janet_wrap_tuple(janet_tuple_n(&old, 1)));
+
+        janet_table_put(env, kv.key, kv.value);
+    }
+    return prev;
+}
+
+// restores the bindings stored with `bindDyns`
+static void unbindDyns(JanetTable *env, JanetTable *prev) {
+    for (int32_t i = 0; i < prev->capacity; i++) {
+        JanetKV kv = prev->data[i];
+        if (!janet_checktype(kv.key, JANET_KEYWORD)) continue;
+
+        janet_table_put(env, kv.key, janet_unwrap_tuple(kv.value)[0]);
+    }
+    janet_gcunroot(janet_wrap_table(prev));
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WithDyns sets dynamic bindings (eg. `:pretty-format`, `:err-color`, or application-specific ones)
+// for the execution, with or without leading `:` in their names.
+//
+// Values are converted to janet in the same way as VM.Define, and the bindings are restored
+// after the execution (like janet's `with-dyns`), so they are not visible to other executions.
+// Bindings of `:out` and `:err` are overridden by the captured outputs.
+func WithDyns(dyns map[string]any) ExecOption {
+	return func(o *execOptions) {
+		if o.dyns == nil {
+			o.dyns = map[string]any{}
+		}
+		for name, value := range dyns {
+			o.dyns[strings.TrimPrefix(name, ":")] = value
+		}
+	}
+}
+
+// withDyns runs `fn` with dynamic bindings `dyns` bound in `env`.
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) withDyns(env *C.JanetTable, dyns map[string]any, fn func()) error {
+	if len(dyns) == 0 {
+		fn()
+		return nil
+	}
+
+	table := C.janet_table(C.int32_t(len(dyns)))
+	enc := vm.encoder()
+	for name, value := range dyns {
+		converted, err := enc.encode(value)
+		if err != nil {
+			return fmt.Errorf("dynamic binding `%s`: %w", name, err)
+		}
+		C.janet_table_put(table, janetKeyword(name), converted)
+	}
+
+	prev := C.bindDyns(env, table)
+	defer C.unbindDyns(env, prev)
+
+	fn()
+	return nil
+}
+
+// WithArgs sets `(dyn :args)` of the execution to `args`, like the command-line arguments
+// of scripts run with janet's CLI (where the first one is the script's path, eg. `["script.janet" "-v"]`).
+func WithArgs(args []string) ExecOption {
+	return WithDyns(map[string]any{"args": append([]string{}, args...)})
+}
@@ -0,0 +1,94 @@
+// dyns_test.go
+
+package janet
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// TestWithDyns tests dynamic bindings of executions.
+func TestWithDyns(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	// custom dyns
+	evaluated, _, _, err := vm.Execute(ctx, `[(dyn :app-user) (dyn :retries)]`, WithDyns(map[string]any{
+		":app-user": "alice",
+		"retries":   3,
+	}))
+	if err != nil {
+		t.Fatalf("Failed to execute with dyns: %v", err)
+	}
+	if evaluated != `(alice 3)` {
+		t.Errorf("Expected dyns in the execution, got: %s", evaluated)
+	}
+
+	// not leaked into other executions
+	if evaluated, _, _, err = vm.Execute(ctx, `(dyn :app-user)`); err != nil || evaluated != "nil" {
+		t.Errorf("Expected dyns not leaked, got: %s (%v)", evaluated, err)
+	}
+
+	// overriding existing dyns, which are restored
+	if _, _, _, err = vm.Execute(ctx, `(setdyn :pretty-format "%q")`); err != nil {
+		t.Fatalf("Failed to set dyn: %v", err)
+	}
+	_, stdout, _, err := vm.Execute(ctx, `(pp @[1 2])`, WithDyns(map[string]any{"pretty-format": "<%j>"}))
+	if err != nil || stdout != "<@[1 2]>\n" {
+		t.Errorf("Expected output with overridden format, got: %q (%v)", stdout, err)
+	}
+	if evaluated, _, _, err = vm.Execute(ctx, `(dyn :pretty-format)`); err != nil || evaluated != "%q" {
+		t.Errorf("Expected dyn restored, got: %s (%v)", evaluated, err)
+	}
+
+	// with other functions
+	var user string
+	if err := vm.ExecuteInto(ctx, `(dyn :app-user)`, &user, WithDyns(map[string]any{"app-user": "bob"})); err != nil || user != "bob" {
+		t.Errorf("Expected dyn in ExecuteInto, got: %s (%v)", user, err)
+	}
+	results, _, _, err := vm.ExecuteAllForms(ctx, `(dyn :app-user)`, WithDyns(map[string]any{"app-user": "carol"}))
+	if err != nil || len(results) != 1 || results[0].Evaluated != "carol" {
+		t.Errorf("Expected dyn in ExecuteAllForms, got: %+v (%v)", results, err)
+	}
+
+	// values which cannot be converted
+	if _, _, _, err = vm.Execute(ctx, `1`, WithDyns(map[string]any{"bad": make(chan int)})); err == nil {
+		t.Errorf("Expected error for dyns which cannot be converted")
+	}
+}
+
+// TestWithArgs tests command-line style arguments of executions.
+func TestWithArgs(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	var args []string
+	if err := vm.ExecuteInto(ctx, `(dyn :args)`, &args, WithArgs([]string{"script.janet", "-v", "input.txt"})); err != nil {
+		t.Fatalf("Failed to execute with args: %v", err)
+	}
+	if !reflect.DeepEqual(args, []string{"script.janet", "-v", "input.txt"}) {
+		t.Errorf("Unexpected args: %v", args)
+	}
+
+	// scripts which parse args as they do in janet's CLI
+	evaluated, _, _, err := vm.Execute(ctx, `(let [[_ & rest] (dyn :args)] (string/join rest ","))`, WithArgs([]string{"main.janet", "a", "b"}))
+	if err != nil || evaluated != "a,b" {
+		t.Errorf("Expected joined args, got: %s (%v)", evaluated, err)
+	}
+
+	// empty args
+	if evaluated, _, _, err = vm.Execute(ctx, `(length (dyn :args))`, WithArgs(nil)); err != nil || evaluated != "0" {
+		t.Errorf("Expected empty args, got: %s (%v)", evaluated, err)
+	}
+}
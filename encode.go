@@ -0,0 +1,292 @@
+// encode.go
+
+package janet
+
+/*
+#include "janet.h"
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+	"unsafe"
+)
+
+// NonFinite is the policy for encoding NaN and infinities into janet.
+type NonFinite int
+
+// NonFinite constants
+const (
+	NonFiniteLiteral NonFinite = iota // encoded as they are (janet numbers `nan`, `inf`, and `-inf`)
+	NonFiniteNil                      // encoded as nil
+	NonFiniteError                    // encoding fails with an error
+)
+
+// NilCollections is the policy for encoding nil go slices and maps into janet.
+type NilCollections int
+
+// NilCollections constants
+const (
+	NilCollectionsNil       NilCollections = iota // encoded as nil
+	NilCollectionsMutable                         // encoded as empty mutable collections (`@[]` and `@{}`), like empty ones
+	NilCollectionsImmutable                       // encoded as empty immutable collections (`()` and `{}`)
+)
+
+// encoder converts go values to janet values.
+type encoder struct {
+	vm             *VM
+	nonFinite      NonFinite
+	nilCollections NilCollections
+	omitZero       bool // whether zero-valued fields of structs are omitted
+}
+
+// encoder returns a new encoder with the VM's options.
+func (vm *VM) encoder() *encoder {
+	return &encoder{
+		vm:             vm,
+		nonFinite:      vm.options.nonFinite,
+		nilCollections: vm.options.nilCollections,
+		omitZero:       vm.options.omitZero,
+	}
+}
+
+// encode converts a go value to a janet value.
+//
+//   - nil (and nil pointers) => nil
+//   - bool => boolean
+//   - integers and floats => number (integers out of the exact range of numbers => int/s64 or int/u64)
+//   - string => string
+//   - []byte => buffer
+//   - slices and arrays => array (nil slices => nil, or empty collections with WithNilCollections)
+//   - maps, *OrderedMap => table (nil maps => nil, or empty collections with WithNilCollections)
+//   - structs => struct with keyword keys of their exported fields (eg. `:max-depth` for `MaxDepth`, see structField)
+//   - *GoValue => go/value (abstract)
+//   - *Stream => core/file
+//   - *Conn => core/stream
+//   - time.Time => number (epoch seconds)
+//   - time.Duration => number (seconds)
+//   - Date => struct (same as `os/date`)
+//   - Marshaler => the value returned from its MarshalJanet method
+//   - url.URL, UUIDs, encoding.TextMarshaler => string (see encodeText)
+//
+// This function should only be called from the VM handler goroutine.
+func (e *encoder) encode(value any) (C.Janet, error) {
+	if m, ok := value.(Marshaler); ok {
+		marshaled, err := marshal(m)
+		if err != nil {
+			return C.janet_wrap_nil(), err
+		}
+		return e.encode(marshaled)
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return C.janet_wrap_nil(), nil
+	case *GoValue:
+		if v == nil {
+			return C.janet_wrap_nil(), nil
+		}
+		return wrapGoValue(v), nil
+	case *Value:
+		if v == nil {
+			return C.janet_wrap_nil(), nil
+		}
+		return v.toJanet(e.vm)
+	case *Stream:
+		if v == nil {
+			return C.janet_wrap_nil(), nil
+		}
+		return v.toJanet()
+	case *Conn:
+		if v == nil {
+			return C.janet_wrap_nil(), nil
+		}
+		return v.toJanet(e.vm.env)
+	case time.Time:
+		return C.janet_wrap_number(C.double(float64(v.Unix()) + float64(v.Nanosecond())/float64(time.Second))), nil
+	case time.Duration:
+		return C.janet_wrap_number(C.double(v.Seconds())), nil
+	case Date:
+		return dateToJanet(v), nil
+	case Kwargs:
+		pairs, err := v.pairs(e)
+		if err != nil {
+			return C.janet_wrap_nil(), err
+		}
+		st := C.janet_struct_begin(C.int32_t(len(pairs) / 2))
+		for i := 0; i < len(pairs); i += 2 {
+			C.janet_struct_put(st, pairs[i], pairs[i+1])
+		}
+		return C.janet_wrap_struct(C.janet_struct_end(st)), nil
+	case *OrderedMap:
+		if v == nil {
+			return C.janet_wrap_nil(), nil
+		}
+		table := C.janet_table(C.int32_t(v.Len()))
+		for _, entry := range v.entries {
+			key, err := e.encode(entry.Key)
+			if err != nil {
+				return C.janet_wrap_nil(), err
+			}
+			val, err := e.encode(entry.Value)
+			if err != nil {
+				return C.janet_wrap_nil(), err
+			}
+			C.janet_table_put(table, key, val)
+		}
+		return C.janet_wrap_table(table), nil
+	case []byte:
+		buffer := C.janet_buffer(C.int32_t(len(v)))
+		if len(v) > 0 {
+			C.janet_buffer_push_bytes(buffer, (*C.uint8_t)(unsafe.Pointer(&v[0])), C.int32_t(len(v)))
+		}
+		return C.janet_wrap_buffer(buffer), nil
+	}
+
+	if text, ok, err := encodeText(value); ok {
+		if err != nil {
+			return C.janet_wrap_nil(), err
+		}
+		return janetString(text), nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Bool:
+		if rv.Bool() {
+			return C.janet_wrap_true(), nil
+		}
+		return C.janet_wrap_false(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return goIntToJanet(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return goUintToJanet(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return e.encodeFloat(rv.Float())
+	case reflect.String:
+		return janetString(rv.String()), nil
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return e.encodeNil(C.janet_wrap_tuple(C.janet_tuple_n(nil, 0)), C.janet_wrap_array(C.janet_array(0))), nil
+		}
+		array := C.janet_array(C.int32_t(rv.Len()))
+		for i := range rv.Len() {
+			elem, err := e.encode(rv.Index(i).Interface())
+			if err != nil {
+				return C.janet_wrap_nil(), err
+			}
+			C.janet_array_push(array, elem)
+		}
+		return C.janet_wrap_array(array), nil
+	case reflect.Map:
+		if rv.IsNil() {
+			return e.encodeNil(C.janet_wrap_struct(C.janet_struct_end(C.janet_struct_begin(0))), C.janet_wrap_table(C.janet_table(0))), nil
+		}
+		table := C.janet_table(C.int32_t(rv.Len()))
+		iter := rv.MapRange()
+		for iter.Next() {
+			key, err := e.encode(iter.Key().Interface())
+			if err != nil {
+				return C.janet_wrap_nil(), err
+			}
+			val, err := e.encode(iter.Value().Interface())
+			if err != nil {
+				return C.janet_wrap_nil(), err
+			}
+			C.janet_table_put(table, key, val)
+		}
+		return C.janet_wrap_table(table), nil
+	case reflect.Struct:
+		return e.encodeStruct(rv)
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return C.janet_wrap_nil(), nil
+		}
+		return e.encode(rv.Elem().Interface())
+	}
+
+	return C.janet_wrap_nil(), fmt.Errorf("cannot convert go value of type %T to janet", value)
+}
+
+// encodeNil converts a nil slice or map to nil, or to the `immutable` or `mutable` empty collection,
+// applying the policy for nil collections.
+func (e *encoder) encodeNil(immutable, mutable C.Janet) C.Janet {
+	switch e.nilCollections {
+	case NilCollectionsMutable:
+		return mutable
+	case NilCollectionsImmutable:
+		return immutable
+	}
+	return C.janet_wrap_nil()
+}
+
+// encodeStruct converts the exported fields of a go struct to a janet struct (see structField),
+// omitting empty ones tagged with `omitempty`, or zero-valued ones if the encoder is created with WithOmitZero.
+func (e *encoder) encodeStruct(rv reflect.Value) (C.Janet, error) {
+	var pairs []C.Janet
+	for _, field := range structFields(rv.Type()) {
+		value, err := rv.FieldByIndexErr(field.index)
+		if err != nil {
+			continue // in a nil embedded struct
+		}
+		if (field.omitEmpty && isEmptyValue(value)) || (e.omitZero && value.IsZero()) {
+			continue
+		}
+		converted, err := e.encode(value.Interface())
+		if err != nil {
+			return C.janet_wrap_nil(), fmt.Errorf("%s.%s: %w", rv.Type(), field.goName, err)
+		}
+		pairs = append(pairs, janetKeyword(field.name), converted)
+	}
+
+	st := C.janet_struct_begin(C.int32_t(len(pairs) / 2))
+	for i := 0; i < len(pairs); i += 2 {
+		C.janet_struct_put(st, pairs[i], pairs[i+1])
+	}
+	return C.janet_wrap_struct(C.janet_struct_end(st)), nil
+}
+
+// encodeFloat converts a float to a janet number, applying the policy for NaN and infinities.
+func (e *encoder) encodeFloat(f float64) (C.Janet, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		switch e.nonFinite {
+		case NonFiniteNil:
+			return C.janet_wrap_nil(), nil
+		case NonFiniteError:
+			return C.janet_wrap_nil(), fmt.Errorf("cannot encode non-finite number %v", f)
+		}
+	}
+	return C.janet_wrap_number(C.double(f)), nil
+}
+
+// Define converts a go `value` to janet and binds it to `name` in the VM's environment.
+//
+// Values which cannot be converted (eg. functions or channels) can be
+// wrapped with VM.Wrap and passed as opaque janet values.
+func (vm *VM) Define(
+	ctx context.Context,
+	name string,
+	value any,
+) (err error) {
+	res, err := runOnVM(ctx, vm, func(env *C.JanetTable) error {
+		converted, err := vm.encoder().encode(value)
+		if err != nil {
+			return err
+		}
+
+		cName := C.CString(name)
+		defer C.free(unsafe.Pointer(cName))
+		C.janet_def(env, cName, converted, nil)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return res
+}
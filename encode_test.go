@@ -0,0 +1,148 @@
+// encode_test.go
+
+package janet
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// TestDefine tests the Define function.
+func TestDefine(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	tests := []struct {
+		name  string
+		value any
+
+		expression string
+		expected   any
+	}{
+		{"n", nil, "(nil? n)", true},
+		{"b", true, "b", true},
+		{"i", 42, "(+ i 1)", float64(43)},
+		{"u", uint8(7), "u", float64(7)},
+		{"f", 3.5, "f", 3.5},
+		{"s", "hello", "(string s \" world\")", "hello world"},
+		{"buf", []byte("bytes"), "(string (type buf) \" \" buf)", "buffer bytes"},
+		{"arr", []int{1, 2, 3}, "(tuple (type arr) ;arr)", []any{":array", float64(1), float64(2), float64(3)}},
+		{"m", map[string]any{"a": 1, "b": []string{"x"}}, "[(type m) (m \"a\") (m \"b\")]", []any{":table", float64(1), []any{"x"}}},
+		{"p", &[]string{"ptr"}, "p", []any{"ptr"}},
+		{"st", struct {
+			Name    string
+			Tags    []string
+			private int
+		}{Name: "janet", private: 1}, "[(type st) (st :name) (st :tags) (length st)]", []any{":struct", "janet", nil, float64(1)}},
+	}
+	for _, test := range tests {
+		if err := vm.Define(ctx, test.name, test.value); err != nil {
+			t.Errorf("Failed to define '%s': %v", test.name, err)
+			continue
+		}
+		if value, err := vm.ParseToValue(ctx, test.expression); err != nil {
+			t.Errorf("Failed to evaluate '%s': %v", test.expression, err)
+		} else if !reflect.DeepEqual(value, test.expected) {
+			t.Errorf("Expected %#v for '%s', got %#v", test.expected, test.expression, value)
+		}
+	}
+
+	// values which cannot be converted
+	for _, value := range []any{func() {}, make(chan int), struct{ F func() }{}} {
+		if err := vm.Define(ctx, "x", value); err == nil {
+			t.Errorf("Expected error for value of type %T", value)
+		}
+	}
+}
+
+// TestNonFinite tests conversions of NaN and infinities.
+func TestNonFinite(t *testing.T) {
+	ctx := context.TODO()
+
+	// janet => go
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	if value, err := vm.ParseToValue(ctx, "[math/nan math/inf (- math/inf)]"); err != nil {
+		t.Errorf("Failed to parse non-finite numbers: %v", err)
+	} else if numbers := value.([]any); !math.IsNaN(numbers[0].(float64)) || !math.IsInf(numbers[1].(float64), 1) || !math.IsInf(numbers[2].(float64), -1) {
+		t.Errorf("Expected NaN, +Inf, and -Inf, got %v", numbers)
+	}
+
+	// go => janet
+	tests := []struct {
+		policy NonFinite
+
+		expected    string
+		expectError bool
+	}{
+		{NonFiniteLiteral, "(nan inf -inf)", false},
+		{NonFiniteNil, "(nil nil nil)", false},
+		{NonFiniteError, "", true},
+	}
+	for _, test := range tests {
+		vm, err := NewVM(WithNonFinite(test.policy))
+		if err != nil {
+			t.Fatalf("Failed to create Janet VM: %v", err)
+		}
+
+		err = vm.Define(ctx, "numbers", []float64{math.NaN(), math.Inf(1), math.Inf(-1)})
+		if test.expectError {
+			if err == nil {
+				t.Errorf("Expected error with policy %d", test.policy)
+			}
+		} else if err != nil {
+			t.Errorf("Failed to define non-finite numbers with policy %d: %v", test.policy, err)
+		} else if evaluated, _, _, err := vm.Execute(ctx, "(tuple ;numbers)"); err != nil || evaluated != test.expected {
+			t.Errorf("Expected '%s' with policy %d, got '%s' (error: %v)", test.expected, test.policy, evaluated, err)
+		}
+
+		vm.Close()
+	}
+}
+
+// TestNilCollections tests encoding nil slices and maps, and zero-valued fields of structs.
+func TestNilCollections(t *testing.T) {
+	ctx := context.TODO()
+
+	type record struct {
+		Name  string
+		Tags  []string
+		Attrs map[string]string
+	}
+
+	tests := []struct {
+		opts []Option
+
+		expected string
+	}{
+		{nil, `(nil nil nil nil 1)`},
+		{[]Option{WithNilCollections(NilCollectionsMutable)}, `(@[] @{} @[] @{} 3)`},
+		{[]Option{WithNilCollections(NilCollectionsImmutable)}, `(() {} () {} 3)`},
+		{[]Option{WithOmitZero()}, `(nil nil nil nil 0)`},
+	}
+	for _, test := range tests {
+		vm, err := NewVM(test.opts...)
+		if err != nil {
+			t.Fatalf("Failed to create Janet VM: %v", err)
+		}
+
+		if err := vm.Define(ctx, "values", []any{[]int(nil), map[string]int(nil), record{}}); err != nil {
+			t.Errorf("Failed to define nil collections: %v", err)
+		} else if evaluated, _, _, err := vm.Execute(ctx, "(let [[s m r] values] [s m (r :tags) (r :attrs) (length r)])", WithRender(RenderJDN)); err != nil || evaluated != test.expected {
+			t.Errorf("Expected '%s', got '%s' (error: %v)", test.expected, evaluated, err)
+		}
+
+		vm.Close()
+	}
+}
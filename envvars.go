@@ -0,0 +1,120 @@
+// envvars.go
+
+package janet
+
+/*
+#include "janet.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"strings"
+)
+
+// envVars is the policy for environment variables visible to scripts.
+type envVars struct {
+	vars  map[string]string // synthetic environment (host's environment variables are not visible if set)
+	allow []string          // names of host's environment variables which are visible (all if empty)
+	deny  []string          // names of host's environment variables which are not visible
+}
+
+// WithEnvVars makes scripts see only `vars` as environment variables, instead of the host's.
+func WithEnvVars(vars map[string]string) Option {
+	return func(o *vmOptions) {
+		o.envVars.vars = make(map[string]string, len(vars))
+		for name, value := range vars {
+			o.envVars.vars[name] = value
+		}
+	}
+}
+
+// WithEnvAllowlist makes scripts see only the host's environment variables with `names`.
+func WithEnvAllowlist(names ...string) Option {
+	return func(o *vmOptions) {
+		o.envVars.allow = append(o.envVars.allow, names...)
+	}
+}
+
+// WithEnvDenylist hides the host's environment variables with `names` from scripts.
+func WithEnvDenylist(names ...string) Option {
+	return func(o *vmOptions) {
+		o.envVars.deny = append(o.envVars.deny, names...)
+	}
+}
+
+// restricted returns whether scripts should not see the host's environment as it is.
+func (e envVars) restricted() bool {
+	return e.vars != nil || len(e.allow) > 0 || len(e.deny) > 0
+}
+
+// visible returns the environment variables visible to scripts.
+func (e envVars) visible() map[string]string {
+	if e.vars != nil {
+		return e.vars
+	}
+
+	visible := map[string]string{}
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if len(e.allow) > 0 && !slices.Contains(e.allow, name) {
+			continue
+		}
+		if slices.Contains(e.deny, name) {
+			continue
+		}
+		visible[name] = value
+	}
+	return visible
+}
+
+// janet source of the helper function which replaces functions for environment variables
+// with the ones which access the given table instead of the host's environment.
+//
+// Subprocesses are also given the table as their environment, unless one is passed explicitly with `:e` flag
+// (other keys of the passed dictionary, eg. `:out`, are kept).
+// The functions are also replaced in the image dictionaries, so that the original ones cannot be reached
+// (eg. with `(get load-image-dict 'os/getenv)`) or unmarshalled back.
+const envVarsReplacerSource = `(fn [vars]
+  (def env (curenv))
+  (defn replace [name f]
+    (when-let [entry (get env name)]
+      (put make-image-dict (entry :value) nil)
+      (put make-image-dict f name)
+      (put load-image-dict name f)
+      (put env name (merge entry {:value f}))))
+  (replace 'os/getenv (fn getenv [name &opt dflt] (get vars name dflt)))
+  (replace 'os/environ (fn environ [] (table/clone vars)))
+  (replace 'os/setenv (fn setenv [name value] (put vars name value) nil))
+  (each name ['os/execute 'os/spawn 'os/posix-exec]
+    (when-let [original (get-in env [name :value])]
+      (replace name (fn [args &opt flags environ]
+                      (if (string/find "e" (string (or flags "")))
+                        (original args flags environ)
+                        (original args (keyword (or flags "") "e") (merge vars (or environ {})))))))))`
+
+// apply replaces functions for environment variables in `core` if they are restricted.
+// This function should only be called from the VM handler goroutine.
+func (e envVars) apply(core *C.JanetTable) error {
+	if !e.restricted() {
+		return nil
+	}
+
+	replacer, err := compileHelper(core, envVarsReplacerSource)
+	if err != nil {
+		return err
+	}
+	defer C.janet_gcunroot(C.janet_wrap_function(replacer))
+
+	vars := e.visible()
+	table := C.janet_table(C.int32_t(len(vars)))
+	for name, value := range vars {
+		C.janet_table_put(table, janetString(name), janetString(value))
+	}
+	if _, err := pcall(core, replacer, C.janet_wrap_table(table)); err != nil {
+		return errors.New("failed to restrict environment variables: " + err.Error())
+	}
+	return nil
+}
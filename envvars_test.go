@@ -0,0 +1,80 @@
+// envvars_test.go
+
+package janet
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+// TestEnvVars tests environment variables visible to scripts.
+func TestEnvVars(t *testing.T) {
+	t.Setenv("JANET_GO_TEST_SECRET", "s3cr3t")
+	t.Setenv("JANET_GO_TEST_PUBLIC", "hello")
+
+	ctx := context.TODO()
+
+	tests := []struct {
+		name string
+		opts []Option
+
+		expectedSecret string
+		expectedPublic string
+	}{
+		{"unrestricted", nil, "s3cr3t", "hello"},
+		{"allowlist", []Option{WithEnvAllowlist("JANET_GO_TEST_PUBLIC")}, "nil", "hello"},
+		{"denylist", []Option{WithEnvDenylist("JANET_GO_TEST_SECRET")}, "nil", "hello"},
+		{"synthetic", []Option{WithEnvVars(map[string]string{"JANET_GO_TEST_PUBLIC": "synthetic"})}, "nil", "synthetic"},
+	}
+	for _, test := range tests {
+		vm, err := NewVM(test.opts...)
+		if err != nil {
+			t.Fatalf("[%s] Failed to create Janet VM: %v", test.name, err)
+		}
+
+		if evaluated, _, _, err := vm.Execute(ctx, `(os/getenv "JANET_GO_TEST_SECRET")`); err != nil || evaluated != test.expectedSecret {
+			t.Errorf("[%s] Expected secret '%s', got: %s (%v)", test.name, test.expectedSecret, evaluated, err)
+		}
+		if evaluated, _, _, err := vm.Execute(ctx, `(get (os/environ) "JANET_GO_TEST_PUBLIC")`); err != nil || evaluated != test.expectedPublic {
+			t.Errorf("[%s] Expected public '%s', got: %s (%v)", test.name, test.expectedPublic, evaluated, err)
+		}
+		// (the original functions are not reachable through the image dictionaries)
+		if evaluated, _, _, err := vm.Execute(ctx, `((get load-image-dict 'os/getenv) "JANET_GO_TEST_SECRET")`); err != nil || evaluated != test.expectedSecret {
+			t.Errorf("[%s] Expected secret '%s' from the image dictionary, got: %s (%v)", test.name, test.expectedSecret, evaluated, err)
+		}
+		if evaluated, _, _, err := vm.Execute(ctx, `(get ((get load-image-dict 'os/environ)) "JANET_GO_TEST_SECRET")`); err != nil || evaluated != test.expectedSecret {
+			t.Errorf("[%s] Expected secret '%s' from the image dictionary, got: %s (%v)", test.name, test.expectedSecret, evaluated, err)
+		}
+		if evaluated, _, _, err := vm.Execute(ctx, `((unmarshal (marshal os/getenv make-image-dict) load-image-dict) "JANET_GO_TEST_SECRET")`); err != nil || evaluated != test.expectedSecret {
+			t.Errorf("[%s] Expected secret '%s' from an unmarshalled function, got: %s (%v)", test.name, test.expectedSecret, evaluated, err)
+		}
+
+		vm.Close()
+	}
+
+	// setting variables does not affect the host
+	vm, err := NewVM(WithEnvDenylist("JANET_GO_TEST_SECRET"))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	evaluated, _, _, err := vm.Execute(ctx, `(os/setenv "JANET_GO_TEST_PUBLIC" "changed") (os/getenv "JANET_GO_TEST_PUBLIC")`)
+	if err != nil || evaluated != "changed" {
+		t.Errorf("Expected changed variable, got: %s (%v)", evaluated, err)
+	}
+	if value := os.Getenv("JANET_GO_TEST_PUBLIC"); value != "hello" {
+		t.Errorf("Expected host's variable unchanged, got: %s", value)
+	}
+
+	// subprocesses see the same environment
+	if build := Build(); build.Processes && build.EV {
+		_, stdout, _, err := vm.Execute(ctx, `(def out (os/spawn ["/bin/sh" "-c" "echo \"[$JANET_GO_TEST_SECRET][$JANET_GO_TEST_PUBLIC]\""] :p {:out :pipe}))
+(prin (ev/read (out :out) :all))
+(os/proc-wait out)`)
+		if err != nil || stdout != "[][changed]\n" {
+			t.Errorf("Expected restricted environment in subprocesses, got: %q (%v)", stdout, err)
+		}
+	}
+}
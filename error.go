@@ -0,0 +1,291 @@
+// error.go
+
+package janet
+
+/*
+#include "janet.h"
+
+// defined in janet_bundled.go or janet_system.go
+int getCFunctionInfo(JanetCFunction cfun, const char **prefix, const char **name, const char **file, int32_t *line);
+
+// returns a stack frame as a table (with the same keys as the ones of `debug/stack`)
+static Janet stackFrame(JanetStackFrame *frame) {
+    JanetTable *t = janet_table(0);
+    if (frame->func) {
+        JanetFuncDef *def = frame->func->def;
+        if (def->name) {
+            janet_table_put(t, janet_ckeywordv("name"), janet_wrap_string(def->name));
+        }
+        if (def->source) {
+            janet_table_put(t, janet_ckeywordv("source"), janet_wrap_string(def->source));
+        }
+        if (frame->pc) {
+            int32_t off = (int32_t)(frame->pc - def->bytecode);
+            janet_table_put(t, janet_ckeywordv("pc"), janet_wrap_integer(off));
+            if (def->sourcemap) {
+                JanetSourceMapping mapping = def->sourcemap[off];
+                janet_table_put(t, janet_ckeywordv("source-line"), janet_wrap_integer(mapping.line));
+                janet_table_put(t, janet_ckeywordv("source-column"), janet_wrap_integer(mapping.column));
+            }
+        }
+    } else {
+        JanetCFunction cfun = (JanetCFunction)(frame->pc);
+        const char *prefix = NULL, *name = NULL, *file = NULL;
+        int32_t line = 0;
+        if (cfun && getCFunctionInfo(cfun, &prefix, &name, &file, &line)) {
+            janet_table_put(t, janet_ckeywordv("name"), prefix
+                            ? janet_wrap_string(janet_formatc("%s/%s", prefix, name))
+                            : janet_cstringv(name));
+            if (file) {
+                janet_table_put(t, janet_ckeywordv("source"), janet_cstringv(file));
+            }
+            if (line > 0) {
+                janet_table_put(t, janet_ckeywordv("source-line"), janet_wrap_integer(line));
+            }
+        } else if (cfun) {
+            // registered name is only in the string representation, eg. "<cfunction os/sleep>"
+            const uint8_t *str = janet_to_string(janet_wrap_cfunction(cfun));
+            int32_t len = janet_string_length(str);
+            if (len > 12 && str[len - 1] == '>' && !(str[11] == '0' && str[12] == 'x')) {
+                janet_table_put(t, janet_ckeywordv("name"), janet_stringv(str + 11, len - 12));
+            }
+        }
+        janet_table_put(t, janet_ckeywordv("c"), janet_wrap_true());
+    }
+    if (frame->flags & JANET_STACKFRAME_TAILCALL) {
+        janet_table_put(t, janet_ckeywordv("tail"), janet_wrap_true());
+    }
+    return janet_wrap_table(t);
+}
+
+// returns the stack frames of `fiber` and its child fibers, the innermost fiber's current frame first
+static JanetArray *stackFrames(JanetFiber *fiber) {
+    JanetArray *frames = janet_array(0);
+
+    JanetFiber **fibers = NULL;
+    int32_t count = 0;
+    for (JanetFiber *f = fiber; f != NULL; f = f->child) count++;
+    fibers = janet_smalloc(sizeof(JanetFiber *) * (size_t) count);
+    count = 0;
+    for (JanetFiber *f = fiber; f != NULL; f = f->child) fibers[count++] = f;
+
+    for (int32_t i = count - 1; i >= 0; i--) {
+        int32_t index = fibers[i]->frame;
+        while (index > 0) {
+            JanetStackFrame *frame = (JanetStackFrame *)(fibers[i]->data + index - JANET_FRAME_SIZE);
+            janet_array_push(frames, stackFrame(frame));
+            index = frame->prevframe;
+        }
+    }
+    janet_sfree(fibers);
+    return frames;
+}
+*/
+import "C"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// Error is an error raised by janet code (eg. with `(error {:code 404})`),
+// or from parsing or compiling it, which is returned wrapped in a *RuntimeError or a *CompileError.
+type Error struct {
+	Message string       // string representation of the raised value
+	Value   any          // raised value converted to go (in the same way as ParseToValue)
+	Handle  *Value       // handle of the raised value, only with WithErrorHandle (should be released after use)
+	Frames  []StackFrame // stack frames of the fiber which raised the error (the innermost frame first, empty for parse errors)
+}
+
+// CompileError is an error from parsing or compiling janet source (including errors raised by macros).
+//
+// The *Error can be retrieved with errors.As.
+type CompileError struct {
+	Err *Error
+}
+
+// Error returns the error message.
+func (e *CompileError) Error() string {
+	return e.Err.Message
+}
+
+// Unwrap returns the *Error.
+func (e *CompileError) Unwrap() error {
+	return e.Err
+}
+
+// RuntimeError is an error raised while running janet code (eg. with `error`, or from a failed function).
+//
+// The *Error can be retrieved with errors.As.
+type RuntimeError struct {
+	Err *Error
+}
+
+// Error returns the error message.
+func (e *RuntimeError) Error() string {
+	return e.Err.Message
+}
+
+// Unwrap returns the *Error.
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+// QuotaError is returned when a value exceeds a quota of the VM (eg. one of DecodeLimits).
+type QuotaError struct {
+	Quota string // name of the exceeded quota (eg. "MaxBytes" of DecodeLimits)
+	Limit int    // value of the quota
+}
+
+// Error returns the error message.
+func (e *QuotaError) Error() string {
+	switch e.Quota {
+	case "MaxDepth":
+		return fmt.Sprintf("exceeded the limit of nesting depth %d", e.Limit)
+	case "MaxElements":
+		return fmt.Sprintf("exceeded the limit of %d elements", e.Limit)
+	case "MaxBytes":
+		return fmt.Sprintf("exceeded the limit of %d bytes", e.Limit)
+	default:
+		return fmt.Sprintf("exceeded the limit of %s %d", e.Quota, e.Limit)
+	}
+}
+
+// contextError returns the error of the done `ctx`, which is also ErrTimeout if its deadline is exceeded.
+func contextError(ctx context.Context) error {
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return err
+}
+
+// evalFailure is the failure of an evaluation of janet source.
+type evalFailure struct {
+	fiber   *C.JanetFiber // fiber which raised the error (nil for parse and compile errors other than the ones from macros)
+	compile bool          // whether the source failed to be parsed or compiled
+}
+
+// wrap returns `err` as a *CompileError or a *RuntimeError.
+func (f evalFailure) wrap(err *Error) error {
+	if f.compile {
+		return &CompileError{Err: err}
+	}
+	return &RuntimeError{Err: err}
+}
+
+// StackFrame is a stack frame of a fiber which raised an error.
+type StackFrame struct {
+	Name      string // name of the function (empty for anonymous functions)
+	Source    string // source path of the function (empty if unknown)
+	Line      int    // source line (0 if unknown)
+	Column    int    // source column (0 if unknown)
+	PC        int    // program counter in the function's bytecode (0 for c functions)
+	TailCall  bool   // whether the frame was a tail call
+	CFunction bool   // whether the function is a c function
+}
+
+// Error returns the string representation of the raised value.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Stacktrace returns the error and its stack frames, formatted in the same way as janet's CLI, eg.
+//
+//	error: oops
+//	  in fail [script.janet] on line 2, column 3
+//	  in thunk [script.janet] (tail call) on line 4, column 1
+func (e *Error) Stacktrace() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "error: %s\n", e.Message)
+	for _, frame := range e.Frames {
+		sb.WriteString(frame.String())
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// String returns the frame formatted in the same way as janet's CLI (eg. "  in fail [script.janet] on line 2, column 3").
+func (f StackFrame) String() string {
+	var sb strings.Builder
+	sb.WriteString("  in")
+	switch {
+	case f.Name != "":
+		sb.WriteString(" " + f.Name)
+	case f.CFunction:
+		sb.WriteString(" <cfunction>")
+	default:
+		sb.WriteString(" <anonymous>")
+	}
+	if f.Source != "" {
+		fmt.Fprintf(&sb, " [%s]", f.Source)
+	}
+	if f.TailCall {
+		sb.WriteString(" (tail call)")
+	}
+	switch {
+	case f.CFunction:
+		if f.Line > 0 {
+			fmt.Fprintf(&sb, " on line %d", f.Line)
+		}
+	case f.Line > 0:
+		fmt.Fprintf(&sb, " on line %d, column %d", f.Line, f.Column)
+	default:
+		fmt.Fprintf(&sb, " pc=%d", f.PC)
+	}
+	return sb.String()
+}
+
+// newError creates an error from a raised janet `value` and its string representation,
+// with the stack frames of `fiber` (nil if the error was not raised from a fiber).
+//
+// Values which cannot be converted to go (eg. the ones which contain themselves) are kept only as the message.
+// This function should only be called from the VM handler goroutine.
+func newError(fiber *C.JanetFiber, value C.Janet, message string) *Error {
+	converted, err := (&decoder{ctx: context.Background()}).decode(value)
+	if err != nil {
+		converted = message
+	}
+	return &Error{Message: message, Value: converted, Frames: stackFrames(fiber)}
+}
+
+// stackFrames returns the stack frames of `fiber` and its child fibers.
+// This function should only be called from the VM handler goroutine.
+func stackFrames(fiber *C.JanetFiber) (frames []StackFrame) {
+	if fiber == nil {
+		return nil
+	}
+
+	array := C.stackFrames(fiber)
+	for _, frame := range unsafe.Slice(array.data, int(array.count)) {
+		table := C.janet_unwrap_table(frame)
+		str := func(key string) string {
+			if value := C.janet_table_get(table, janetKeyword(key)); C.janet_checktype(value, C.JANET_STRING) != 0 {
+				return janetStringToGo(C.janet_unwrap_string(value))
+			}
+			return ""
+		}
+		num := func(key string) int {
+			if value := C.janet_table_get(table, janetKeyword(key)); C.janet_checktype(value, C.JANET_NUMBER) != 0 {
+				return int(C.janet_unwrap_number(value))
+			}
+			return 0
+		}
+		flag := func(key string) bool {
+			return C.janet_truthy(C.janet_table_get(table, janetKeyword(key))) != 0
+		}
+		frames = append(frames, StackFrame{
+			Name:      str("name"),
+			Source:    str("source"),
+			Line:      num("source-line"),
+			Column:    num("source-column"),
+			PC:        num("pc"),
+			TailCall:  flag("tail"),
+			CFunction: flag("c"),
+		})
+	}
+	return frames
+}
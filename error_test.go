@@ -0,0 +1,234 @@
+// error_test.go
+
+package janet
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestErrorValues tests values raised by janet code.
+func TestErrorValues(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	tests := []struct {
+		input string
+
+		expectedValue any
+	}{
+		{`(error "failed")`, "failed"},
+		{`(error {:code 404 :msg "not found"})`, map[any]any{":code": float64(404), ":msg": "not found"}},
+		{`(error [:bad-input 3])`, []any{":bad-input", float64(3)}},
+		{`(+ 1`, "<unknown>:1:4: parse error: unexpected end of source, ( opened at line 1, column 1"},
+	}
+	for _, test := range tests {
+		_, _, _, err := vm.Execute(ctx, test.input)
+		var janetErr *Error
+		if !errors.As(err, &janetErr) {
+			t.Errorf("Expected *Error from '%s', got: %v", test.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(janetErr.Value, test.expectedValue) {
+			t.Errorf("Expected raised value %v from '%s', got: %v", test.expectedValue, test.input, janetErr.Value)
+		}
+		if janetErr.Handle != nil {
+			t.Errorf("Expected no handle without WithErrorHandle")
+		}
+	}
+
+	// from other functions
+	if _, err := vm.Apply(ctx, "error", map[string]any{"code": 500}); err == nil {
+		t.Errorf("Expected error from Apply")
+	} else if janetErr := (*Error)(nil); !errors.As(err, &janetErr) || !reflect.DeepEqual(janetErr.Value, map[any]any{"code": float64(500)}) {
+		t.Errorf("Unexpected error from Apply: %v", err)
+	}
+	if _, _, _, err := vm.EvalValue(ctx, `(error :oops)`); err == nil {
+		t.Errorf("Expected error from EvalValue")
+	} else if janetErr := (*Error)(nil); !errors.As(err, &janetErr) || janetErr.Value != ":oops" {
+		t.Errorf("Unexpected error from EvalValue: %v", err)
+	}
+
+	// with handles
+	_, _, _, err = vm.Execute(ctx, `(error @{"retry" true})`, WithErrorHandle())
+	var janetErr *Error
+	if !errors.As(err, &janetErr) || janetErr.Handle == nil {
+		t.Fatalf("Expected *Error with handle, got: %v", err)
+	}
+	defer janetErr.Handle.Release(ctx)
+	if janetErr.Handle.Type() != TypeTable {
+		t.Errorf("Expected table handle, got %s", janetErr.Handle.Type())
+	}
+	if retry, err := janetErr.Handle.Get(ctx, "retry"); err != nil || retry != true {
+		t.Errorf("Expected retry of the raised value, got %v (%v)", retry, err)
+	}
+}
+
+// TestErrorStacktrace tests stack frames of errors.
+func TestErrorStacktrace(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	_, _, stderr, err := vm.Execute(ctx, `(defn fail [x] (error x))
+(defn outer [] (fail :oops) 1)
+(outer)`)
+	var janetErr *Error
+	if !errors.As(err, &janetErr) {
+		t.Fatalf("Expected *Error, got: %v", err)
+	}
+	expected := []StackFrame{
+		{Name: "fail", Source: "<unknown>", Line: 1, Column: 16},
+		{Name: "outer", Source: "<unknown>", Line: 2, Column: 16},
+		{Name: "thunk", Source: "<unknown>", Line: 3, Column: 1},
+	}
+	if len(janetErr.Frames) != len(expected) {
+		t.Fatalf("Expected frames %+v, got: %+v", expected, janetErr.Frames)
+	}
+	for i, frame := range janetErr.Frames {
+		frame.PC = 0
+		if frame != expected[i] {
+			t.Errorf("Expected frame #%d %+v, got: %+v", i, expected[i], frame)
+		}
+	}
+	if janetErr.Stacktrace() != stderr {
+		t.Errorf("Expected stacktrace same as janet's: '%s', got: '%s'", stderr, janetErr.Stacktrace())
+	}
+
+	// frames of child fibers come first
+	_, _, stderr, err = vm.Execute(ctx, `(resume (fiber/new (fn [] (error "inner"))))`)
+	if !errors.As(err, &janetErr) {
+		t.Fatalf("Expected *Error, got: %v", err)
+	}
+	if len(janetErr.Frames) != 2 || janetErr.Frames[0].Name != "" || janetErr.Frames[1].Name != "thunk" {
+		t.Errorf("Unexpected frames: %+v", janetErr.Frames)
+	}
+	if janetErr.Stacktrace() != stderr {
+		t.Errorf("Expected stacktrace same as janet's: '%s', got: '%s'", stderr, janetErr.Stacktrace())
+	}
+
+	// no frames for parse errors
+	if _, _, _, err = vm.Execute(ctx, `(+ 1`); !errors.As(err, &janetErr) || len(janetErr.Frames) != 0 {
+		t.Errorf("Expected no frames for parse errors, got: %v", err)
+	}
+}
+
+// TestSourceMap tests source maps of evaluated source.
+func TestSourceMap(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	// stack traces
+	_, _, stderr, err := vm.Execute(ctx, `(defn fail [] (error "oops"))
+(fail)`, WithSourceMap("scripts/fail.janet", 10))
+	var janetErr *Error
+	if !errors.As(err, &janetErr) {
+		t.Fatalf("Expected *Error, got: %v", err)
+	}
+	if len(janetErr.Frames) != 2 ||
+		janetErr.Frames[0] != (StackFrame{Name: "fail", Source: "scripts/fail.janet", Line: 11, Column: 15, PC: janetErr.Frames[0].PC}) ||
+		janetErr.Frames[1].Source != "scripts/fail.janet" || janetErr.Frames[1].Line != 12 {
+		t.Errorf("Unexpected frames: %+v", janetErr.Frames)
+	}
+	if janetErr.Stacktrace() != stderr {
+		t.Errorf("Expected stacktrace same as janet's: '%s', got: '%s'", stderr, janetErr.Stacktrace())
+	}
+
+	// parse and compile errors
+	if _, _, _, err := vm.Execute(ctx, "\n(+ 1", WithSourceMap("embedded.janet", 4)); err == nil ||
+		err.Error() != "embedded.janet:6:4: parse error: unexpected end of source, ( opened at line 6, column 1" {
+		t.Errorf("Unexpected parse error: %v", err)
+	}
+	if results, _, _, _ := vm.ExecuteAllForms(ctx, "(+ 1 2)\n(undefined-fn)", WithSourceMap("forms.janet", 1)); len(results) != 2 ||
+		results[1].Err == nil || results[1].Err.Error() != "forms.janet:3:1: compile error: unknown symbol undefined-fn" {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+}
+
+// TestErrorCategories tests branching on categories of errors with errors.Is and errors.As.
+func TestErrorCategories(t *testing.T) {
+	vm, err := NewVM(WithDecodeLimits(DecodeLimits{MaxElements: 10}), WithQueueLimit(1))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	// compile errors and runtime errors (which wrap *Error)
+	for _, test := range []struct {
+		input   string
+		compile bool
+	}{
+		{`(+ 1`, true},
+		{`(undefined-function 1)`, true},
+		{`(defmacro bad [] (error "in macro")) (bad)`, true},
+		{`(error "failed")`, false},
+		{`(+ 1 :a)`, false},
+	} {
+		_, _, _, err := vm.Execute(ctx, test.input)
+		compileErr, runtimeErr, janetErr := (*CompileError)(nil), (*RuntimeError)(nil), (*Error)(nil)
+		if test.compile && !errors.As(err, &compileErr) {
+			t.Errorf("Expected compile error for '%s', got: %v", test.input, err)
+		} else if !test.compile && !errors.As(err, &runtimeErr) {
+			t.Errorf("Expected runtime error for '%s', got: %v", test.input, err)
+		}
+		if !errors.As(err, &janetErr) || janetErr.Message != err.Error() {
+			t.Errorf("Expected *Error for '%s', got: %v", test.input, err)
+		}
+	}
+	if _, err := vm.Apply(ctx, "error", "failed"); !errors.As(err, new(*RuntimeError)) {
+		t.Errorf("Expected runtime error from Apply, got: %v", err)
+	}
+	if results, _, _, err := vm.ExecuteAllForms(ctx, `(+ 1 2) (undefined-function)`); err != nil || len(results) != 2 || !errors.As(results[1].Err, new(*CompileError)) {
+		t.Errorf("Expected compile error from ExecuteAllForms, got: %v, %v", results, err)
+	}
+
+	// quotas
+	if _, err := vm.ParseToValue(ctx, `(range 11)`); !errors.As(err, new(*QuotaError)) {
+		t.Errorf("Expected quota error, got: %v", err)
+	} else if quotaErr := (*QuotaError)(nil); errors.As(err, &quotaErr) && (quotaErr.Quota != "MaxElements" || quotaErr.Limit != 10) {
+		t.Errorf("Unexpected quota error: %+v", quotaErr)
+	}
+
+	// timeouts
+	timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, _, _, err := vm.Execute(timeout, `(while true)`); !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected timeout, got: %v", err)
+	}
+
+	// full queue (one execution running, and one waiting)
+	go func() { _, _, _, _ = vm.Execute(ctx, `(os/sleep 0.5)`) }()
+	time.Sleep(100 * time.Millisecond)
+	go func() { _, _, _, _ = vm.Execute(ctx, `(+ 1 2)`) }()
+	for vm.Stats().PendingExec < 1 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, _, _, err := vm.Execute(ctx, `(+ 1 2)`); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected full queue, got: %v", err)
+	}
+
+	// closed VMs
+	vm.Close()
+	if _, _, _, err := vm.Execute(ctx, `(+ 1 2)`); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected closed VM, got: %v", err)
+	}
+}
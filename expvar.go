@@ -0,0 +1,102 @@
+// expvar.go
+
+package janet
+
+import (
+	"expvar"
+	"sync"
+)
+
+// expvarVMs is the "janet" expvar map of VMs created with WithExpvar, published on the first use.
+var expvarVMs = sync.OnceValue(func() *expvar.Map {
+	return expvar.NewMap("janet")
+})
+
+// expvarMu serializes publishing and unpublishing VMs.
+var expvarMu sync.Mutex
+
+// WithExpvar publishes the statistics of the VM (see VM.Stats) as `name` in the "janet" expvar map
+// (eg. `janet.default.executions` with name "default", served at `/debug/vars` with expvar's handler),
+// so that existing dashboards of expvar pick them up without extra code.
+//
+// Published values are:
+//
+//   - uptime_seconds, busy_fraction
+//   - served, executions, errors, crashes
+//   - queue_depth (number of pending requests), avg_wait_seconds, p99_wait_seconds
+//   - heap_blocks, heap_allocated_bytes, gc_collections (not available with system janet)
+//   - cache_hits, cache_misses, cache_hit_rate (of the result cache, see WithResultCache)
+//
+// A VM published with the same name replaces the previous one, and the VM is unpublished when it is closed.
+// The "janet" expvar map should not be published by others.
+func WithExpvar(name string) Option {
+	return func(o *vmOptions) {
+		o.expvar = name
+	}
+}
+
+// expvarVM is a VM published in the "janet" expvar map.
+type expvarVM struct {
+	vm *VM
+}
+
+// String returns the statistics of the VM in JSON.
+func (v *expvarVM) String() string {
+	stats := v.vm.Stats()
+
+	values := new(expvar.Map)
+	setFloat := func(key string, value float64) {
+		f := new(expvar.Float)
+		f.Set(value)
+		values.Set(key, f)
+	}
+	setInt := func(key string, value uint64) {
+		i := new(expvar.Int)
+		i.Set(int64(value))
+		values.Set(key, i)
+	}
+
+	setFloat("uptime_seconds", stats.Uptime.Seconds())
+	setFloat("busy_fraction", stats.BusyFraction)
+	setInt("served", stats.Served)
+	setInt("executions", stats.Executions)
+	setInt("errors", stats.Errors)
+	setInt("crashes", stats.Crashes)
+	setInt("queue_depth", uint64(stats.PendingExec+stats.PendingParse+stats.PendingCall))
+	setFloat("avg_wait_seconds", stats.AvgWait.Seconds())
+	setFloat("p99_wait_seconds", stats.P99Wait.Seconds())
+	setInt("heap_blocks", stats.HeapBlocks)
+	setInt("heap_allocated_bytes", stats.HeapAllocated)
+	setInt("gc_collections", stats.Collections)
+	setInt("cache_hits", stats.CacheHits)
+	setInt("cache_misses", stats.CacheMisses)
+	var hitRate float64
+	if lookups := stats.CacheHits + stats.CacheMisses; lookups > 0 {
+		hitRate = float64(stats.CacheHits) / float64(lookups)
+	}
+	setFloat("cache_hit_rate", hitRate)
+
+	return values.String()
+}
+
+// publishExpvar publishes the VM in the "janet" expvar map, if it is created with WithExpvar.
+func (vm *VM) publishExpvar() {
+	if vm.options.expvar == "" {
+		return
+	}
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+	expvarVMs().Set(vm.options.expvar, &expvarVM{vm: vm})
+}
+
+// unpublishExpvar removes the VM from the "janet" expvar map, unless it is replaced with another VM.
+func (vm *VM) unpublishExpvar() {
+	if vm.options.expvar == "" {
+		return
+	}
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+	if published, ok := expvarVMs().Get(vm.options.expvar).(*expvarVM); ok && published.vm == vm {
+		expvarVMs().Delete(vm.options.expvar)
+	}
+}
@@ -0,0 +1,80 @@
+// expvar_test.go
+
+package janet
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"testing"
+)
+
+// TestExpvar tests publishing statistics of VMs with expvar.
+func TestExpvar(t *testing.T) {
+	vm, err := NewVM(WithExpvar("expvar-test"), WithResultCache(4))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	for range 2 {
+		if _, _, _, err := vm.Execute(ctx, `(+ 1 2)`, WithPure()); err != nil {
+			t.Fatalf("Failed to execute: %v", err)
+		}
+	}
+	if _, _, _, err := vm.Execute(ctx, `(error "failed")`); err == nil {
+		t.Fatalf("Expected an error")
+	}
+	if _, _, _, err := vm.Execute(ctx, `(do (def a @[]) (for i 0 100000 (array/push a @"x")) (length a))`); err != nil {
+		t.Fatalf("Failed to execute: %v", err)
+	}
+	if _, _, _, err := vm.Execute(ctx, `(gccollect)`); err != nil {
+		t.Fatalf("Failed to execute: %v", err)
+	}
+
+	published := expvar.Get("janet").(*expvar.Map).Get("expvar-test")
+	if published == nil {
+		t.Fatalf("Expected the VM to be published")
+	}
+	var values map[string]float64
+	if err := json.Unmarshal([]byte(published.String()), &values); err != nil {
+		t.Fatalf("Failed to unmarshal %s: %v", published.String(), err)
+	}
+	for key, expected := range map[string]float64{
+		"executions":     5,
+		"errors":         1,
+		"served":         4,
+		"queue_depth":    0,
+		"cache_hits":     1,
+		"cache_misses":   1,
+		"cache_hit_rate": 0.5,
+	} {
+		if values[key] != expected {
+			t.Errorf("Expected %s to be %v, got %v", key, expected, values[key])
+		}
+	}
+	if values["uptime_seconds"] <= 0 {
+		t.Errorf("Expected uptime, got %v", values)
+	}
+	if values["heap_blocks"] > 0 { // not sampled when linked against system janet
+		if values["gc_collections"] < 1 {
+			t.Errorf("Expected collections, got %v", values)
+		}
+	}
+
+	// VMs are unpublished when they are closed, unless replaced with others
+	other, err := NewVM(WithExpvar("expvar-test"))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	vm.Close()
+	if expvar.Get("janet").(*expvar.Map).Get("expvar-test") == nil {
+		t.Errorf("Expected the replacing VM to be kept published")
+	}
+	other.Close()
+	if published := expvar.Get("janet").(*expvar.Map).Get("expvar-test"); published != nil {
+		t.Errorf("Expected the VM to be unpublished, got %v", published)
+	}
+}
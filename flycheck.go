@@ -0,0 +1,129 @@
+// flycheck.go
+
+package janet
+
+/*
+#include "janet.h"
+*/
+import "C"
+
+import (
+	"context"
+	"strings"
+)
+
+// janet source of the helper function which checks janet source for errors without running it.
+//
+// Like janet's `flycheck`, it parses, compiles, and expands macros in the source
+// with an environment derived from the VM's, but only evaluates function and macro definitions.
+// Other definitions are declared with nil values, and other forms are not evaluated at all.
+const flycheckerSource = `(fn [source]
+  (def env (make-env))
+  (def diagnostics @[])
+  (var pending source)
+  (defn declare [pattern ref?]
+    (cond
+      (symbol? pattern) (put env pattern (if ref? @{:ref @[nil]} @{:value nil}))
+      (indexed? pattern) (each p pattern (declare p ref?))
+      (dictionary? pattern) (eachp [_ p] pattern (declare p ref?))))
+  (defn evaluator [thunk form _ _]
+    (when (and (tuple? form) (= (tuple/type form) :parens))
+      (def head (first form))
+      (cond
+        (index-of head '[defn defn- defmacro defmacro- varfn]) (thunk)
+        (index-of head '[def def- defglobal]) (declare (get form 1) false)
+        (index-of head '[var var- varglobal]) (declare (get form 1) true))))
+  (run-context
+    {:env env
+     :source :flycheck
+     :chunks (fn [buf _] (when pending (buffer/push buf pending) (set pending nil)))
+     :evaluator evaluator
+     :on-parse-error (fn [p _] (array/push diagnostics [:error ;(parser/where p) (parser/error p)]))
+     :on-compile-error (fn [msg _ _ &opt line col] (array/push diagnostics [:error (or line 0) (or col 0) msg]))
+     :on-compile-warning (fn [msg _ _ &opt line col] (array/push diagnostics [:warning (or line 0) (or col 0) msg]))
+     :on-status (fn [f x] (when (= :error (fiber/status f)) (array/push diagnostics [:error 0 0 (string x)])))})
+  diagnostics)`
+
+// name of the rule for diagnostics from Flycheck
+const LintRuleFlycheck = "flycheck"
+
+// flycheckResult is used to receive the diagnostics from the VM handler.
+type flycheckResult struct {
+	diagnostics []Diagnostic
+	err         error
+}
+
+// Flycheck checks janet source `src` for parse and compile errors (eg. unknown symbols)
+// against the VM's environment, without running it.
+//
+// Only function and macro definitions in `src` are evaluated (in a separate environment),
+// so definitions in `src` do not leak into the VM's environment.
+func (vm *VM) Flycheck(
+	ctx context.Context,
+	src string,
+) (
+	diagnostics []Diagnostic,
+	err error,
+) {
+	res, err := runOnVM(ctx, vm, func(env *C.JanetTable) flycheckResult {
+		if vm.flychecker == nil {
+			helper, err := compileHelper(env, flycheckerSource)
+			if err != nil {
+				return flycheckResult{err: err}
+			}
+			vm.flychecker = helper
+		}
+
+		checked, err := pcall(env, vm.flychecker, janetString(src))
+		if err != nil {
+			return flycheckResult{err: err}
+		}
+
+		// [severity line column message]
+		lineOffsets := lineOffsets(src)
+		for _, elem := range janetIndexed(checked) {
+			fields := janetIndexed(elem)
+
+			severity := SeverityError
+			if janetValueToString(fields[0]) == ":warning" {
+				severity = SeverityWarning
+			}
+			pos := positionAt(lineOffsets, int(C.janet_unwrap_number(fields[1])), int(C.janet_unwrap_number(fields[2])))
+			diagnostics = append(diagnostics, Diagnostic{
+				Rule:     LintRuleFlycheck,
+				Severity: severity,
+				Message:  strings.TrimSpace(janetValueToString(fields[3])),
+				Start:    pos,
+				End:      pos,
+			})
+		}
+		return flycheckResult{diagnostics: diagnostics}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.diagnostics, res.err
+}
+
+// lineOffsets returns the byte offsets of the beginning of each line in `src`.
+func lineOffsets(src string) []int {
+	offsets := []int{0}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			offsets = append(offsets, i+1)
+		}
+	}
+	return offsets
+}
+
+// positionAt returns the position at `line` and `column` (both starting from 1, or 0 if unknown).
+func positionAt(lineOffsets []int, line, column int) Position {
+	line = min(max(line, 1), len(lineOffsets))
+	column = max(column, 1)
+	return Position{
+		Offset: lineOffsets[line-1] + column - 1,
+		Line:   line,
+		Column: column,
+	}
+}
@@ -0,0 +1,79 @@
+// flycheck_test.go
+
+package janet
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestFlycheck tests the Flycheck function.
+func TestFlycheck(t *testing.T) {
+	vm, err := SharedVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	// valid source is not evaluated
+	diagnostics, err := vm.Flycheck(context.TODO(), `(defn flychecked [x] (* x 2))
+(def value (flychecked 21))
+(print "not printed")
+(error "not raised")`)
+	if err != nil {
+		t.Fatalf("Failed to flycheck: %v", err)
+	}
+	if len(diagnostics) != 0 {
+		t.Errorf("Expected no diagnostics, got: %v", diagnostics)
+	}
+	if _, _, _, err := vm.Execute(context.TODO(), `flychecked`); err == nil {
+		t.Errorf("Definitions from flychecked source should not leak into the VM's environment")
+	}
+
+	// unknown symbols
+	diagnostics, err = vm.Flycheck(context.TODO(), `(def a 1)
+(+ a no-such-symbol)`)
+	if err != nil {
+		t.Fatalf("Failed to flycheck: %v", err)
+	}
+	if len(diagnostics) != 1 ||
+		diagnostics[0].Severity != SeverityError ||
+		diagnostics[0].Start.Line != 2 ||
+		!strings.Contains(diagnostics[0].Message, "unknown symbol no-such-symbol") {
+		t.Errorf("Expected an unknown symbol error on line 2, got: %v", diagnostics)
+	}
+
+	// parse errors
+	diagnostics, err = vm.Flycheck(context.TODO(), `(print "unterminated`)
+	if err != nil {
+		t.Fatalf("Failed to flycheck: %v", err)
+	}
+	if len(diagnostics) != 1 || !strings.Contains(diagnostics[0].Message, "unexpected end of source") {
+		t.Errorf("Expected a parse error, got: %v", diagnostics)
+	}
+}
+
+// TestDoc tests the Doc function.
+func TestDoc(t *testing.T) {
+	vm, err := SharedVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	if doc, err := vm.Doc(context.TODO(), "map"); err != nil || !strings.HasPrefix(doc, "(map f ind") {
+		t.Errorf("Expected docstring of `map`, got '%s' (error: %v)", doc, err)
+	}
+
+	if _, _, _, err := vm.Execute(context.TODO(), `(defn documented "Documented function." [] nil)`); err != nil {
+		t.Fatalf("Failed to define a function: %v", err)
+	}
+	if doc, err := vm.Doc(context.TODO(), "documented"); err != nil || !strings.Contains(doc, "Documented function.") {
+		t.Errorf("Expected docstring of `documented`, got '%s' (error: %v)", doc, err)
+	}
+
+	if doc, err := vm.Doc(context.TODO(), "no-such-symbol"); err != nil || doc != "" {
+		t.Errorf("Expected empty docstring, got '%s' (error: %v)", doc, err)
+	}
+}
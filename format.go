@@ -0,0 +1,166 @@
+// format.go
+
+package janet
+
+import (
+	"strings"
+)
+
+// forms whose bodies are indented by 2 spaces (same as spork/fmt)
+var indent2Forms = map[string]bool{
+	"fn": true, "match": true, "with": true, "with-dyns": true, "def": true, "def-": true,
+	"defglobal": true, "varglobal": true, "defer": true, "var": true, "var-": true, "let": true,
+	"case": true, "if": true, "if-let": true, "if-not": true, "if-with": true, "when": true,
+	"when-let": true, "when-with": true, "when-not": true, "while": true, "loop": true,
+	"seq": true, "generate": true, "catseq": true, "tabseq": true, "for": true, "forv": true,
+	"each": true, "eachp": true, "eachk": true, "eachy": true, "each-y": true, "prompt": true,
+	"label": true, "repeat": true, "try": true, "unless": true, "defmacro": true,
+	"defmacro-": true, "defn": true, "defn-": true, "edefer": true, "ev/spawn": true,
+	"ev/do-thread": true, "ev/with-deadline": true, "forever": true, "compwhen": true,
+	"compif": true, "ev/spawn-thread": true, "ev/go": true, "coro": true, "defdyn": true,
+	"varfn": true, "do": true, "upscope": true, "assert": true, "comment": true, "cond": true,
+	"with-vars": true, "with-syms": true,
+}
+
+// prefixes of forms whose bodies are indented by 2 spaces (same as spork/fmt)
+var indent2Prefixes = []string{"with-", "def", "if-", "when-"}
+
+// FormatOptions is the options for formatting janet source.
+type FormatOptions struct {
+	// additional forms (eg. user-defined macros) whose bodies are indented by 2 spaces, like `defn`
+	IndentForms []string
+}
+
+// Format formats janet source `src` into canonically indented code,
+// in the same way as spork/fmt does:
+//
+//   - line breaks between forms are kept (consecutive blank lines are collapsed into one),
+//   - bodies of control forms (eg. `defn`, `let`, `when`) are indented by 2 spaces,
+//   - other arguments are aligned with the first argument when it is on the same line as the function,
+//   - elements of other collections are aligned after their opening delimiters,
+//   - whitespaces between forms on the same line and at the end of lines are normalized,
+//   - closing delimiters are placed right after the last elements.
+func Format(src string, opts FormatOptions) (string, error) {
+	nodes, err := ParseAST(src)
+	if err != nil {
+		return "", err
+	}
+
+	f := &formatter{
+		indentForms: map[string]bool{},
+	}
+	for _, form := range opts.IndentForms {
+		f.indentForms[form] = true
+	}
+
+	f.writeNodes(nodes, 0)
+	if f.sb.Len() > 0 {
+		f.sb.WriteString("\n")
+	}
+
+	return f.sb.String(), nil
+}
+
+// formatter writes formatted janet source.
+type formatter struct {
+	sb          strings.Builder
+	column      int // current column (starting from 0)
+	indentForms map[string]bool
+}
+
+// write writes `str`, keeping track of the current column.
+func (f *formatter) write(str string) {
+	f.sb.WriteString(str)
+	if idx := strings.LastIndexByte(str, '\n'); idx >= 0 {
+		f.column = len(str) - idx - 1
+	} else {
+		f.column += len(str)
+	}
+}
+
+// newlines writes `n` line breaks and indents the next line.
+func (f *formatter) newlines(n, indent int) {
+	f.write(strings.Repeat("\n", n) + strings.Repeat(" ", indent))
+}
+
+// separate writes the separator between two consecutive nodes.
+func (f *formatter) separate(prev, next *Node, indent int) {
+	if prev.Kind == NodeComment || next.Start.Line > prev.End.Line {
+		f.newlines(min(max(next.Start.Line-prev.End.Line, 1), 2), indent)
+	} else {
+		f.write(" ")
+	}
+}
+
+// writeNodes writes consecutive nodes with `indent` for the following lines.
+func (f *formatter) writeNodes(nodes []*Node, indent int) {
+	for i, node := range nodes {
+		if i > 0 {
+			f.separate(nodes[i-1], node, indent)
+		}
+		f.writeNode(node)
+	}
+}
+
+// writeNode writes a node at the current column.
+func (f *formatter) writeNode(node *Node) {
+	switch {
+	case node.Kind.IsCollection():
+		f.writeCollection(node)
+	case node.Kind == NodeReaderMacro:
+		f.write(node.Text)
+		for _, child := range node.Children {
+			f.writeNode(child)
+		}
+	case node.Kind == NodeComment:
+		f.write(strings.TrimRight(node.Text, " \t\r"))
+	default:
+		f.write(node.Text)
+	}
+}
+
+// writeCollection writes a collection node with its children indented.
+func (f *formatter) writeCollection(node *Node) {
+	start := f.column
+	f.write(node.Text)
+
+	indent := f.column
+	var firstArgument *Node // set when following arguments should be aligned with it
+	if node.Kind == NodeTuple {
+		forms := node.Forms()
+		if len(forms) > 0 && forms[0].Kind == NodeSymbol && f.isIndent2Form(forms[0].Text) {
+			indent = start + 2
+		} else if len(forms) > 1 && !forms[0].Kind.IsCollection() && forms[1].Start.Line == forms[0].End.Line {
+			firstArgument = forms[1]
+		}
+	}
+
+	children := node.Children
+	for i, child := range children {
+		if i > 0 {
+			f.separate(children[i-1], child, indent)
+		}
+		if child == firstArgument {
+			indent = f.column
+		}
+		f.writeNode(child)
+	}
+
+	if len(children) > 0 && children[len(children)-1].Kind == NodeComment {
+		f.newlines(1, indent)
+	}
+	f.write(closingDelimiter(node.Text))
+}
+
+// isIndent2Form returns whether the body of the form named `name` should be indented by 2 spaces.
+func (f *formatter) isIndent2Form(name string) bool {
+	if indent2Forms[name] || f.indentForms[name] {
+		return true
+	}
+	for _, prefix := range indent2Prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
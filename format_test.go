@@ -0,0 +1,109 @@
+// format_test.go
+
+package janet
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestFormat tests the Format function.
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		opts     FormatOptions
+		expected string
+	}{
+		{
+			input: `(defn add [x y]
+(+ x y)
+    )`,
+			expected: `(defn add [x y]
+  (+ x y))
+`,
+		},
+		{
+			input: `(print   "a"
+"b"   "c")   `,
+			expected: `(print "a"
+       "b" "c")
+`,
+		},
+		{
+			input: `(let [a 1
+b 2]
+(+ a
+b))
+
+
+
+(def x @{:a 1
+:b 2})`,
+			expected: `(let [a 1
+      b 2]
+  (+ a
+     b))
+
+(def x @{:a 1
+         :b 2})
+`,
+		},
+		{
+			input: `(
+foo
+bar)
+(with-custom-macro a
+b)
+(my-macro a
+b)`,
+			opts: FormatOptions{IndentForms: []string{"my-macro"}},
+			expected: `(foo
+ bar)
+(with-custom-macro a
+  b)
+(my-macro a
+  b)
+`,
+		},
+		{
+			input: `(do # comment   
+  (print "x") # trailing
+)
+'(1
+2)`,
+			expected: `(do # comment
+  (print "x") # trailing
+  )
+'(1
+  2)
+`,
+		},
+		{
+			input:    "(def s ``\n  long\n  string``)",
+			expected: "(def s ``\n  long\n  string``)\n",
+		},
+		{
+			input:    ``,
+			expected: ``,
+		},
+	}
+
+	for _, test := range tests {
+		formatted, err := Format(test.input, test.opts)
+		if err != nil {
+			t.Errorf("Failed to format '%s': %v", test.input, err)
+		} else if formatted != test.expected {
+			t.Errorf("Input: %s\nExpected:\n%s\ngot:\n%s", test.input, test.expected, formatted)
+		}
+
+		// formatting should be idempotent
+		if again, err := Format(formatted, test.opts); err != nil || again != formatted {
+			t.Errorf("Formatting is not idempotent for '%s':\n%s", formatted, again)
+		}
+	}
+
+	// malformed source
+	if _, err := Format(`(malformed expression`, FormatOptions{}); err == nil || !strings.Contains(err.Error(), "unexpected end of source") {
+		t.Errorf("Expected parse error, got '%v'", err)
+	}
+}
@@ -0,0 +1,224 @@
+// forms.go
+
+package janet
+
+/*
+#include <setjmp.h>
+
+#include "janet.h"
+
+// defined in janet_bundled.go or janet_system.go
+void *setCrashGuard(void *guard);
+
+// defined in signal.go
+void reportError(JanetTable *env, JanetString where, JanetString message, JanetFiber *fiber, Janet err);
+
+// continues `fiber` in the same way as `janet_continue`, but returns JANET_SIGNAL_ERROR
+// when the janet runtime fails fatally, instead of aborting the process (see crash.go)
+static int continueFiber(JanetFiber *fiber, Janet *out) {
+    jmp_buf guard;
+    void *prev = setCrashGuard(&guard);
+    if (setjmp(guard)) {
+        setCrashGuard(prev);
+        return JANET_SIGNAL_ERROR;
+    }
+    int signal = janet_continue(fiber, janet_wrap_nil(), out);
+    setCrashGuard(prev);
+    return signal;
+}
+
+static void runEventLoop() {
+#ifdef JANET_EV
+    janet_loop();
+#endif
+}
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// FormResult is the result of evaluating a top-level form.
+type FormResult struct {
+	Source    string // source text of the form
+	Evaluated string // evaluated result
+	Err       error  // error from parsing, compiling, or running the form
+}
+
+// formsResult is used to receive the results of forms from the VM handler.
+type formsResult struct {
+	results []FormResult
+	stdout  string
+	stderr  string
+	err     error
+}
+
+// ExecuteAllForms executes top-level forms in `janetExpression` one by one, and returns the result of each form,
+// along with any output to stdout and stderr.
+//
+// Evaluation stops at the first form which fails, so the last result has the error (`err` is only for
+// errors which are not from the forms, eg. cancellation).
+func (vm *VM) ExecuteAllForms(
+	ctx context.Context,
+	janetExpression string,
+	opts ...ExecOption,
+) (
+	results []FormResult,
+	stdout string,
+	stderr string,
+	err error,
+) {
+	options := newExecOptions(opts)
+
+	res, err := runOnVM(ctx, vm, func(env *C.JanetTable) formsResult {
+		var results []FormResult
+		var stdout, stderr string
+		if err := vm.withExecDyns(env, options, func() {
+			stdout, stderr = captureExecOutput(env, options, func() {
+				results = vm.evaluateForms(env, janetExpression, options)
+			})
+		}); err != nil {
+			return formsResult{err: err}
+		}
+		return formsResult{
+			results: results,
+			stdout:  stdout,
+			stderr:  stderr,
+		}
+	})
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return res.results, res.stdout, res.stderr, res.err
+}
+
+// evaluateForms parses and evaluates top-level forms in `src` one by one, in the same way as `janet_dobytes`.
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) evaluateForms(
+	env *C.JanetTable,
+	src string,
+	options execOptions,
+) (results []FormResult) {
+	sourcePath := options.source.name
+	if sourcePath == "" {
+		sourcePath = "<unknown>"
+	}
+
+	// source path is needed for compiling functions with source maps
+	where := janetString(sourcePath)
+	C.janet_gcroot(where)
+	defer C.janet_gcunroot(where)
+
+	parser := (*C.JanetParser)(C.janet_abstract(&C.janet_parser_type, C.sizeof_JanetParser))
+	C.janet_parser_init(parser)
+	parser.line += C.size_t(options.source.lineOffset)
+	C.janet_gcroot(C.janet_wrap_abstract(C.JanetAbstract(unsafe.Pointer(parser))))
+	defer C.janet_gcunroot(C.janet_wrap_abstract(C.JanetAbstract(unsafe.Pointer(parser))))
+
+	index := 0     // index of the next byte to be consumed
+	eof := false   // whether the end of source is consumed
+	formStart := 0 // where the source of the next form starts (before trimming)
+
+	// returns the source of the form which was produced after consuming the last byte
+	formSource := func(status C.enum_JanetParserStatus) string {
+		end := index
+		if !eof {
+			// the last byte closed the form, or terminated it (by starting a new one, or being a whitespace)
+			if last := src[index-1]; status != C.JANET_PARSE_ROOT || !strings.ContainsRune(")]}\"`", rune(last)) {
+				end = index - 1
+			}
+		}
+		source := trimSpacesAndComments(src[formStart:end])
+		formStart = end
+		return source
+	}
+
+	for {
+		status := C.janet_parser_status(parser)
+
+		// evaluate parsed values
+		for C.janet_parser_has_more(parser) != 0 {
+			form := C.janet_parser_produce(parser)
+			result := FormResult{Source: formSource(status)}
+
+			var ret C.Janet
+			cres := C.janet_compile(form, env, C.janet_unwrap_string(where))
+			if cres.status == C.JANET_COMPILE_OK {
+				fn := C.janet_thunk(cres.funcdef)
+				fiber := C.janet_fiber(fn, 64, 0, nil)
+				fiber.env = env
+				signal := C.JanetSignal(C.continueFiber(fiber, &ret))
+				checkCrash()
+				if signal != C.JANET_SIGNAL_OK && signal != C.JANET_SIGNAL_EVENT {
+					C.reportError(env, C.janet_unwrap_string(where), nil, fiber, ret)
+					result.Err = &RuntimeError{Err: newError(fiber, ret, janetValueToString(ret))}
+				} else {
+					result.Evaluated, result.Err = vm.render(env, ret, options.render, options.numbers)
+				}
+			} else {
+				line, col := int(parser.line), int(parser.column)
+				if cres.error_mapping.line > 0 && cres.error_mapping.column > 0 {
+					line, col = int(cres.error_mapping.line), int(cres.error_mapping.column)
+				}
+				location := fmt.Sprintf("%s:%d:%d: compile error", sourcePath, line, col)
+				message := fmt.Sprintf("%s: %s", location, C.GoString((*C.char)(unsafe.Pointer(cres.error))))
+				if cres.macrofiber != nil {
+					C.reportError(env, C.janet_unwrap_string(where), C.janet_unwrap_string(janetString(location)), cres.macrofiber, janetString(message))
+				} else {
+					C.reportError(env, C.janet_unwrap_string(where), C.janet_unwrap_string(janetString(message+"\n")), nil, C.janet_wrap_nil())
+				}
+				result.Err = &CompileError{Err: newError(cres.macrofiber, janetString(message), message)}
+			}
+
+			results = append(results, result)
+			if result.Err != nil {
+				C.runEventLoop()
+				return results
+			}
+		}
+
+		// dispatch based on parse state
+		switch status {
+		case C.JANET_PARSE_DEAD:
+			C.runEventLoop()
+			return results
+		case C.JANET_PARSE_ERROR:
+			message := fmt.Sprintf("%s:%d:%d: parse error: %s", sourcePath, int(parser.line), int(parser.column), C.GoString(C.janet_parser_error(parser)))
+			C.reportError(env, C.janet_unwrap_string(where), C.janet_unwrap_string(janetString(message+"\n")), nil, C.janet_wrap_nil())
+			results = append(results, FormResult{
+				Source: trimSpacesAndComments(src[formStart:]),
+				Err:    &CompileError{Err: newError(nil, janetString(message), message)},
+			})
+			C.runEventLoop()
+			return results
+		default:
+			if index >= len(src) {
+				C.janet_parser_eof(parser)
+				eof = true
+			} else {
+				C.janet_parser_consume(parser, C.uint8_t(src[index]))
+				index++
+			}
+		}
+	}
+}
+
+// trimSpacesAndComments trims leading and trailing whitespaces, and leading comments from `src`.
+func trimSpacesAndComments(src string) string {
+	for {
+		src = strings.TrimSpace(src)
+		if !strings.HasPrefix(src, "#") {
+			return src
+		}
+		if i := strings.IndexByte(src, '\n'); i >= 0 {
+			src = src[i+1:]
+		} else {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// forms_test.go
+
+package janet
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestExecuteAllForms tests the ExecuteAllForms function.
+func TestExecuteAllForms(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	type form struct {
+		source    string
+		evaluated string
+		errored   bool
+	}
+	tests := []struct {
+		input          string
+		expected       []form
+		expectedStdout string
+		expectedStderr string
+	}{
+		{
+			input: "(def a 1) (def b 2)\n# sum\n(+ a b)",
+			expected: []form{
+				{"(def a 1)", "1", false},
+				{"(def b 2)", "2", false},
+				{"(+ a b)", "3", false},
+			},
+		},
+		{
+			input: `a"str"[1 2]:kw 'q ` + "``long``" + `(print "out")`,
+			expected: []form{
+				{"a", "1", false},
+				{`"str"`, "str", false},
+				{"[1 2]", "(1 2)", false},
+				{":kw", ":kw", false},
+				{"'q", "q", false},
+				{"``long``", "long", false},
+				{`(print "out")`, "nil", false},
+			},
+			expectedStdout: "out\n",
+		},
+		{
+			input: `(def c 3) (error "oops") (def d 4)`,
+			expected: []form{
+				{"(def c 3)", "3", false},
+				{`(error "oops")`, "", true},
+			},
+			expectedStderr: "error: oops",
+		},
+		{
+			input: `(def e 5) (undefined-symbol) (def f 6)`,
+			expected: []form{
+				{"(def e 5)", "5", false},
+				{"(undefined-symbol)", "", true},
+			},
+			expectedStderr: "compile error: unknown symbol undefined-symbol",
+		},
+		{
+			input: `(def g 7) (def h`,
+			expected: []form{
+				{"(def g 7)", "7", false},
+				{"(def h", "", true},
+			},
+			expectedStderr: "parse error",
+		},
+	}
+	for _, test := range tests {
+		results, stdout, stderr, err := vm.ExecuteAllForms(ctx, test.input)
+		if err != nil {
+			t.Errorf("Failed to execute '%s': %v", test.input, err)
+			continue
+		}
+
+		var forms []form
+		for _, result := range results {
+			forms = append(forms, form{result.Source, result.Evaluated, result.Err != nil})
+		}
+		if !reflect.DeepEqual(forms, test.expected) {
+			t.Errorf("Expected %+v for '%s', got %+v", test.expected, test.input, forms)
+		}
+		if stdout != test.expectedStdout {
+			t.Errorf("Expected stdout '%s' for '%s', got '%s'", test.expectedStdout, test.input, stdout)
+		}
+		if !strings.Contains(stderr, test.expectedStderr) {
+			t.Errorf("Expected stderr containing '%s' for '%s', got '%s'", test.expectedStderr, test.input, stderr)
+		}
+	}
+}
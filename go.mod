@@ -1,3 +1,5 @@
 module github.com/meinside/janet-go
 
 go 1.24.5
+
+require google.golang.org/protobuf v1.36.9
@@ -0,0 +1,43 @@
+// gob.go
+
+package janet
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// register the concrete types of converted values (which are not registered by encoding/gob),
+// so that they can be encoded as interface values (eg. for caching results in gob-based stores, or net/rpc)
+//
+// (gob decodes empty slices as nil ones, so VMs which are passed decoded values
+// should be created with WithNilCollections not to convert them to nil)
+func init() {
+	gob.Register([]any{})
+	gob.Register(map[any]any{})
+	gob.Register(map[string]any{})
+	gob.Register(&OrderedMap{})
+	gob.Register(Kwargs{})
+}
+
+// GobEncode encodes the entries in order with encoding/gob.
+//
+// Keys and values should be of the types of converted janet values (eg. nil, float64, string, []any, or map[any]any),
+// or the types registered with gob.Register.
+func (m *OrderedMap) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(m.entries); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode decodes the entries encoded with GobEncode.
+func (m *OrderedMap) GobDecode(data []byte) error {
+	var entries []Entry
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entries); err != nil {
+		return err
+	}
+	*m = *NewOrderedMap(entries...)
+	return nil
+}
@@ -0,0 +1,97 @@
+// gob_test.go
+
+package janet
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// TestGob tests encoding converted values with encoding/gob and back.
+func TestGob(t *testing.T) {
+	ctx := context.TODO()
+
+	ints := `(int/s64 -7) (int/u64 "18446744073709551615")`
+	if !Build().IntTypes {
+		ints = `-7 18446744073709551615` // (`int/*` is not available)
+	}
+	expression := `[nil true 1.5 "str" @"\xff" 'sym :kw ` + ints + ` @[{:a [1 2]} @{"b" {}}]]`
+	for _, opts := range [][]Option{
+		nil,
+		{WithInvalidUTF8(InvalidUTF8Bytes)},
+		{WithMapKeys(MapKeysTrimmed)},
+		{WithOrderedMaps()},
+	} {
+		vm, err := NewVM(append(opts, WithNilCollections(NilCollectionsMutable))...)
+		if err != nil {
+			t.Fatalf("Failed to create Janet VM: %v", err)
+		}
+		defer vm.Close()
+
+		parsed, err := vm.ParseToValue(ctx, expression)
+		if err != nil {
+			t.Fatalf("Failed to parse: %v", err)
+		}
+
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(&parsed); err != nil {
+			t.Fatalf("Failed to encode %#v: %v", parsed, err)
+		}
+		var decoded any
+		if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+			t.Fatalf("Failed to decode: %v", err)
+		}
+		if !reflect.DeepEqual(decoded, parsed) {
+			t.Errorf("Expected %#v, got %#v", parsed, decoded)
+		}
+		if elems := decoded.([]any); Build().IntTypes && (elems[7] != int64(-7) || elems[8] != uint64(math.MaxUint64)) {
+			t.Errorf("Expected integers to be kept, got %#v and %#v", elems[7], elems[8])
+		}
+
+		// and back to janet
+		result, err := vm.Apply(ctx, "length", decoded)
+		if err != nil {
+			t.Fatalf("Failed to apply: %v", err)
+		}
+		if result != 10.0 {
+			t.Errorf("Expected 10, got %v", result)
+		}
+
+		// (empty collections are decoded as nil slices)
+		empty, err := vm.ParseToValue(ctx, `[]`)
+		if err != nil {
+			t.Fatalf("Failed to parse: %v", err)
+		}
+		buf.Reset()
+		if err := gob.NewEncoder(&buf).Encode(&empty); err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+		if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+			t.Fatalf("Failed to decode: %v", err)
+		}
+		if result, err := vm.Apply(ctx, "type", decoded); err != nil || result != ":array" {
+			t.Errorf("Expected :array, got %v (%v)", result, err)
+		}
+	}
+
+	// entries of ordered maps are kept in order
+	ordered := NewOrderedMap(Entry{Key: ":z", Value: 1.0}, Entry{Key: ":a", Value: []any{nil}}, Entry{Key: 2.0, Value: NewOrderedMap()})
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(ordered); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	var decoded OrderedMap
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Entries(), ordered.Entries()) {
+		t.Errorf("Expected %v, got %v", ordered.Entries(), decoded.Entries())
+	}
+	if value, ok := decoded.Get(":a"); !ok || !reflect.DeepEqual(value, []any{nil}) {
+		t.Errorf("Expected [nil] for :a, got %v", value)
+	}
+}
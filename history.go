@@ -0,0 +1,199 @@
+// history.go
+
+package janet
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// default max number of lines kept in a History
+const defaultHistorySize = 1000
+
+// History is a history of lines (eg. janet codes entered into the prompt of a CLI tool, which are evaluated with VM.Execute),
+// with the latest `size` lines kept in memory, which can be loaded from and saved to a file.
+//
+// Blank lines are not added, and a line which is already in the history is moved to the latest one (de-duplicated).
+// It is safe to be used concurrently.
+type History struct {
+	mu    sync.Mutex
+	size  int
+	lines []string // oldest first
+}
+
+// NewHistory returns a new History which keeps the latest `size` lines (1000 if not positive).
+func NewHistory(size int) *History {
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	return &History{size: size}
+}
+
+// Add adds `line` (which may contain newlines, eg. a multi-line form) as the latest line, and returns whether it is added.
+func (h *History) Add(line string) bool {
+	line = strings.TrimRight(line, " \t\r\n")
+	if strings.TrimSpace(line) == "" {
+		return false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.add(line)
+	return true
+}
+
+// add adds `line` as the latest one, evicting the oldest ones over the size.
+func (h *History) add(line string) {
+	for i, existing := range h.lines {
+		if existing == line {
+			h.lines = append(h.lines[:i], h.lines[i+1:]...)
+			break
+		}
+	}
+	h.lines = append(h.lines, line)
+	if over := len(h.lines) - h.size; over > 0 {
+		h.lines = append(h.lines[:0], h.lines[over:]...)
+	}
+}
+
+// Len returns the number of lines.
+func (h *History) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.lines)
+}
+
+// Lines returns the lines, oldest first.
+func (h *History) Lines() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.lines...)
+}
+
+// At returns the `n`th latest line (0 for the latest one, eg. for navigating with arrow keys), and whether it exists.
+func (h *History) At(n int) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if n < 0 || n >= len(h.lines) {
+		return "", false
+	}
+	return h.lines[len(h.lines)-1-n], true
+}
+
+// Search returns the lines which contain `query`, latest first (eg. for reverse searches like Ctrl-R of shells).
+func (h *History) Search(query string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var found []string
+	for i := len(h.lines) - 1; i >= 0; i-- {
+		if strings.Contains(h.lines[i], query) {
+			found = append(found, h.lines[i])
+		}
+	}
+	return found
+}
+
+// Clear removes all the lines.
+func (h *History) Clear() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lines = nil
+}
+
+// Load adds the lines saved in the file at `path` with Save, as older than the lines added so far.
+// A file which does not exist is not an error (eg. on the first run of a CLI tool).
+func (h *History) Load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	var loaded []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		if line := unescapeHistoryLine(scanner.Text()); strings.TrimSpace(line) != "" {
+			loaded = append(loaded, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	added := h.lines
+	h.lines = nil
+	for _, line := range append(loaded, added...) {
+		h.add(line)
+	}
+	return nil
+}
+
+// Save saves the lines to the file at `path` (readable only by the user, as they may contain secrets),
+// one per line with newlines and backslashes escaped.
+//
+// The file is replaced at once, so that it is not corrupted by failures or concurrent saves (eg. of other processes).
+func (h *History) Save(path string) error {
+	var sb strings.Builder
+	for _, line := range h.Lines() {
+		sb.WriteString(escapeHistoryLine(line))
+		sb.WriteByte('\n')
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name()) // (fails after renamed)
+	if _, err := temp.WriteString(sb.String()); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+// historyEscaper escapes lines of a History for saving them one per line.
+var historyEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+
+// escapeHistoryLine escapes `line` to be saved in a file.
+func escapeHistoryLine(line string) string {
+	return historyEscaper.Replace(line)
+}
+
+// unescapeHistoryLine unescapes a line saved with escapeHistoryLine.
+func unescapeHistoryLine(line string) string {
+	if !strings.Contains(line, `\`) {
+		return line
+	}
+	var sb strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] != '\\' || i+1 == len(line) {
+			sb.WriteByte(line[i])
+			continue
+		}
+		i++
+		switch line[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		default:
+			sb.WriteByte(line[i])
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,63 @@
+// history_test.go
+
+package janet
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// TestHistory tests histories of lines with persistence.
+func TestHistory(t *testing.T) {
+	history := NewHistory(3)
+	for _, line := range []string{"(+ 1 2)", "  ", "(def x 1)", "(+ 1 2)\n", "(defn f []\n  \"a\\\\b\")", "x"} {
+		history.Add(line)
+	}
+
+	// de-duplicated, and only the latest ones are kept
+	expected := []string{"(+ 1 2)", "(defn f []\n  \"a\\\\b\")", "x"}
+	if lines := history.Lines(); !slices.Equal(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+	if line, ok := history.At(0); !ok || line != "x" {
+		t.Errorf("Expected the latest line x, got %q", line)
+	}
+	if _, ok := history.At(3); ok {
+		t.Errorf("Expected no line beyond the oldest one")
+	}
+	if found := history.Search("("); !slices.Equal(found, []string{"(defn f []\n  \"a\\\\b\")", "(+ 1 2)"}) {
+		t.Errorf("Expected lines latest first, got %q", found)
+	}
+
+	// saved and loaded as they are, older than the lines added before loading
+	path := filepath.Join(t.TempDir(), "history")
+	if err := history.Save(path); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected the file to be readable only by the user, got %v (%v)", info, err)
+	}
+
+	loaded := NewHistory(10)
+	loaded.Add("x")
+	loaded.Add("(print 1)")
+	if err := loaded.Load(path); err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	expected = []string{"(+ 1 2)", "(defn f []\n  \"a\\\\b\")", "x", "(print 1)"}
+	if lines := loaded.Lines(); !slices.Equal(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+
+	// missing files are not errors
+	empty := NewHistory(0)
+	if err := empty.Load(filepath.Join(t.TempDir(), "none")); err != nil || empty.Len() != 0 {
+		t.Errorf("Expected no lines loaded from a missing file, got %d (%v)", empty.Len(), err)
+	}
+	loaded.Clear()
+	if loaded.Len() != 0 {
+		t.Errorf("Expected no lines after clearing, got %d", loaded.Len())
+	}
+}
@@ -0,0 +1,219 @@
+// host.go
+
+package janet
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+#include <string.h>
+
+#include "janet.h"
+
+// defined in callbacks.go
+extern int32_t goPublish(uintptr_t vm, uint8_t *topic, int32_t length, Janet *value, char **err);
+extern char *goHandOff(uintptr_t vm, uint8_t *name, int32_t length, Janet *value);
+extern char *goStartAsync(uintptr_t vm, int32_t id, int32_t argc, Janet *argv, void *janetVM, JanetFiber *fiber);
+extern int goFinishAsync(uintptr_t result, Janet *value);
+extern char *goLog(uintptr_t vm, int level, uint8_t *message, int32_t length, Janet *fields);
+
+// defined in janet_bundled.go or janet_system.go
+void *setCrashGuard(void *guard);
+
+// fatal errors of janet raised while go callbacks are running are not caught (see crash.go),
+// not to jump over go frames
+#define WITHOUT_CRASH_GUARD(stmt) do { \
+    void *guard = setCrashGuard(NULL); \
+    stmt; \
+    setCrashGuard(guard); \
+} while (0)
+
+// handle of the VM running on the current thread
+static _Thread_local uintptr_t hostVM = 0;
+
+static void setHostVM(uintptr_t vm) {
+    hostVM = vm;
+}
+
+// panics with the error message `err` (freed here) from go
+static void panicWith(char *err) {
+    Janet message = janet_cstringv(err);
+    free(err);
+    janet_panicv(message);
+}
+
+// (host/publish topic value), which sends `value` to the go subscribers of `topic`
+static Janet publish(int32_t argc, Janet *argv) {
+    janet_fixarity(argc, 2);
+    JanetByteView topic = janet_getbytes(argv, 0);
+    if (hostVM == 0) return janet_wrap_integer(0);
+    char *err = NULL;
+    int32_t subscribers = 0;
+    WITHOUT_CRASH_GUARD(subscribers = goPublish(hostVM, (uint8_t *)topic.bytes, topic.len, &argv[1], &err));
+    if (err != NULL) panicWith(err);
+    return janet_wrap_integer(subscribers);
+}
+
+// (host/callback name f), which hands the function (or fiber) `f` to the go handler of `name`
+static Janet handOff(int32_t argc, Janet *argv) {
+    janet_fixarity(argc, 2);
+    JanetByteView name = janet_getbytes(argv, 0);
+    if (!janet_checktypes(argv[1], JANET_TFLAG_FUNCTION | JANET_TFLAG_CFUNCTION | JANET_TFLAG_FIBER)) {
+        janet_panic_type(argv[1], 1, JANET_TFLAG_FUNCTION | JANET_TFLAG_CFUNCTION | JANET_TFLAG_FIBER);
+    }
+    if (hostVM == 0) janet_panic("no host");
+    char *err = NULL;
+    WITHOUT_CRASH_GUARD(err = goHandOff(hostVM, (uint8_t *)name.bytes, name.len, &argv[1]));
+    if (err != NULL) panicWith(err);
+    return janet_wrap_nil();
+}
+
+// (log/<level> message &opt fields), which logs `message` with `fields` (a struct or table) at `level` in the host
+static Janet logAt(int level, int32_t argc, Janet *argv) {
+    janet_arity(argc, 1, 2);
+    JanetByteView message = janet_getbytes(argv, 0);
+    Janet *fields = NULL;
+    if (argc > 1 && !janet_checktype(argv[1], JANET_NIL)) {
+        if (!janet_checktypes(argv[1], JANET_TFLAG_DICTIONARY)) {
+            janet_panic_type(argv[1], 1, JANET_TFLAG_DICTIONARY | JANET_TFLAG_NIL);
+        }
+        fields = &argv[1];
+    }
+    if (hostVM == 0) return janet_wrap_nil();
+    char *err = NULL;
+    WITHOUT_CRASH_GUARD(err = goLog(hostVM, level, (uint8_t *)message.bytes, message.len, fields));
+    if (err != NULL) panicWith(err);
+    return janet_wrap_nil();
+}
+
+// (levels are the same as go's slog.Level)
+static Janet logDebug(int32_t argc, Janet *argv) { return logAt(-4, argc, argv); }
+static Janet logInfo(int32_t argc, Janet *argv) { return logAt(0, argc, argv); }
+static Janet logWarn(int32_t argc, Janet *argv) { return logAt(4, argc, argv); }
+static Janet logError(int32_t argc, Janet *argv) { return logAt(8, argc, argv); }
+
+static const JanetReg logCfuns[] = {
+    {"log/debug", logDebug, "(log/debug message &opt fields)\n\nLogs `message` with `fields` (a struct or table) at debug level in the host. Returns nil."},
+    {"log/info", logInfo, "(log/info message &opt fields)\n\nLogs `message` with `fields` (a struct or table) at info level in the host. Returns nil."},
+    {"log/warn", logWarn, "(log/warn message &opt fields)\n\nLogs `message` with `fields` (a struct or table) at warn level in the host. Returns nil."},
+    {"log/error", logError, "(log/error message &opt fields)\n\nLogs `message` with `fields` (a struct or table) at error level in the host. Returns nil."},
+    {NULL, NULL, NULL},
+};
+
+static void registerLogCfuns(JanetTable *env) {
+    janet_cfuns(env, NULL, logCfuns);
+}
+
+#ifdef JANET_EV
+// resumes the fiber waiting for the result of an async function, on the VM's thread
+static void finishAsync(JanetEVGenericMessage msg) {
+    Janet value;
+    int failed = 0;
+    WITHOUT_CRASH_GUARD(failed = goFinishAsync((uintptr_t)msg.argp, &value));
+    // (the fiber may have been cancelled and scheduled for something else, eg. by interrupts)
+    if (janet_fiber_can_resume(msg.fiber) && msg.fiber->sched_id == (uint32_t)msg.argi) {
+        if (failed) {
+            janet_cancel(msg.fiber, value);
+        } else {
+            janet_schedule(msg.fiber, value);
+        }
+    }
+    janet_gcunroot(janet_wrap_fiber(msg.fiber));
+    janet_ev_dec_refcount();
+}
+#endif
+
+// returns the id with which `fiber` is waiting to be scheduled (0 without the event loop)
+static uint32_t fiberSchedID(JanetFiber *fiber) {
+#ifdef JANET_EV
+    return fiber->sched_id;
+#else
+    return 0;
+#endif
+}
+
+// posts the `result` of an async function for `fiber` (waiting with `schedID`) to the event loop of `janetVM`,
+// from any thread
+static void postAsyncResult(void *janetVM, JanetFiber *fiber, uint32_t schedID, uintptr_t result) {
+#ifdef JANET_EV
+    JanetEVGenericMessage msg;
+    memset(&msg, 0, sizeof(msg));
+    msg.fiber = fiber;
+    msg.argi = (int)schedID;
+    msg.argp = (void *)result;
+    janet_ev_post_event((JanetVM *)janetVM, finishAsync, msg);
+#endif
+}
+
+// (call-async id & args), which starts the async function `id` with `args`,
+// and suspends the current task until it returns
+static Janet callAsync(int32_t argc, Janet *argv) {
+    janet_arity(argc, 1, -1);
+#ifdef JANET_EV
+    int32_t id = janet_getinteger(argv, 0);
+    if (hostVM == 0) janet_panic("no host");
+    JanetFiber *fiber = janet_root_fiber();
+    char *err = NULL;
+    WITHOUT_CRASH_GUARD(err = goStartAsync(hostVM, id, argc - 1, argv + 1, janet_local_vm(), fiber));
+    if (err != NULL) panicWith(err);
+    janet_gcroot(janet_wrap_fiber(fiber));
+    janet_ev_inc_refcount();
+    janet_await();
+#else
+    janet_panic("async functions are not available without the event loop");
+#endif
+}
+
+static Janet callAsyncCfun() {
+    return janet_wrap_cfunction(callAsync);
+}
+
+static const JanetReg hostCfuns[] = {
+    {"host/publish", publish, "(host/publish topic value)\n\nSends `value` to the subscribers of `topic` (a string or a keyword) in the host. Returns the number of the subscribers."},
+    {"host/callback", handOff, "(host/callback name f)\n\nHands the function (or fiber) `f` to the handler of `name` (a string or a keyword) in the host, which can call it later. Returns nil."},
+    {NULL, NULL, NULL},
+};
+
+static void registerHostCfuns(JanetTable *env) {
+    janet_cfuns(env, NULL, hostCfuns);
+}
+*/
+import "C"
+
+import (
+	"runtime/cgo"
+	"unsafe"
+)
+
+// startHost registers `host/*` (and `log/*` with WithLogger) functions in `core`, which pass values
+// from scripts to the VM's go side (see VM.Subscribe and VM.OnCallback).
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) startHost(core *C.JanetTable) (stop func()) {
+	C.registerHostCfuns(core)
+
+	if vm.options.logger != nil {
+		C.registerLogCfuns(core)
+	}
+
+	handle := cgo.NewHandle(vm)
+	C.setHostVM(C.uintptr_t(handle))
+	return func() {
+		C.setHostVM(0)
+		handle.Delete()
+	}
+}
+
+// asyncCaller returns the cfunction which calls async functions (see VM.RegisterAsyncFunc).
+func asyncCaller() C.Janet {
+	return C.callAsyncCfun()
+}
+
+// fiberSchedID returns the id with which `fiber` is waiting to be scheduled.
+func fiberSchedID(fiber unsafe.Pointer) uint32 {
+	return uint32(C.fiberSchedID((*C.JanetFiber)(fiber)))
+}
+
+// postAsyncResult posts the result of an async function (a handle of *asyncResult) for `fiber`
+// waiting with `schedID` to the event loop of `janetVM`. It can be called from any goroutine.
+func postAsyncResult(janetVM, fiber unsafe.Pointer, schedID uint32, result cgo.Handle) {
+	C.postAsyncResult(janetVM, (*C.JanetFiber)(fiber), C.uint32_t(schedID), C.uintptr_t(result))
+}
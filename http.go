@@ -0,0 +1,197 @@
+// http.go
+
+package janet
+
+/*
+#include "janet.h"
+*/
+import "C"
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// WithHTTPClient makes `http/get`, `http/post`, and `http/request` functions available in the VM,
+// which send requests with `client` (http.DefaultClient if nil), so that scripts can access the network
+// under the host's policy (eg. proxies, TLS, timeouts, or restricted transports) without janet's sockets.
+//
+// Requests are sent asynchronously like the functions registered with VM.RegisterAsyncFunc,
+// with the context of the calling execution, and responses are returned as structs like:
+//
+//	{:status 200 :headers @{"content-type" "text/plain"} :body "..."}
+//
+// (header names are lowercased, and multiple values of a header are joined with ", ")
+//
+// Response bodies larger than 10MiB fail to be read, unless the limit is changed with WithHTTPMaxBody.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *vmOptions) {
+		if client == nil {
+			client = http.DefaultClient
+		}
+		o.httpClient = client
+	}
+}
+
+// default max size of response bodies read by `http/*` functions
+const defaultHTTPMaxBody = 10 << 20
+
+// WithHTTPMaxBody sets the max size of response bodies read by `http/*` functions (see WithHTTPClient) to `size` bytes,
+// so that scripts cannot exhaust the memory of the host with large responses.
+//
+// Requests whose response bodies are larger than `size` fail with an error.
+func WithHTTPMaxBody(size int64) Option {
+	return func(o *vmOptions) {
+		o.httpMaxBody = size
+	}
+}
+
+// registerHTTP defines `http/*` functions in `core`, which send requests with `client`
+// and read response bodies up to `maxBody` bytes (defaultHTTPMaxBody if not positive).
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) registerHTTP(core *C.JanetTable, client *http.Client, maxBody int64) error {
+	if maxBody <= 0 {
+		maxBody = defaultHTTPMaxBody
+	}
+
+	for _, fn := range []struct {
+		name string
+		doc  string
+		fn   AsyncFunc
+	}{
+		{
+			name: "http/get",
+			doc:  "(http/get url &opt headers)\n\nSends a GET request to `url` with `headers` (a table or struct), and returns the response as a struct with :status, :headers, and :body.",
+			fn: func(ctx context.Context, args ...any) (any, error) {
+				if len(args) < 1 || len(args) > 2 {
+					return nil, fmt.Errorf("arity mismatch, expected 1 to 2, got %d", len(args))
+				}
+				return sendHTTP(ctx, client, maxBody, http.MethodGet, args[0], nil, optionalArg(args, 1))
+			},
+		},
+		{
+			name: "http/post",
+			doc:  "(http/post url body &opt headers)\n\nSends a POST request to `url` with `body` (a string or buffer) and `headers` (a table or struct), and returns the response as a struct with :status, :headers, and :body.",
+			fn: func(ctx context.Context, args ...any) (any, error) {
+				if len(args) < 2 || len(args) > 3 {
+					return nil, fmt.Errorf("arity mismatch, expected 2 to 3, got %d", len(args))
+				}
+				return sendHTTP(ctx, client, maxBody, http.MethodPost, args[0], args[1], optionalArg(args, 2))
+			},
+		},
+		{
+			name: "http/request",
+			doc:  "(http/request method url &opt options)\n\nSends a request of `method` (eg. \"PUT\" or :put) to `url` with `options` (a table or struct of :body and :headers), and returns the response as a struct with :status, :headers, and :body.",
+			fn: func(ctx context.Context, args ...any) (any, error) {
+				if len(args) < 2 || len(args) > 3 {
+					return nil, fmt.Errorf("arity mismatch, expected 2 to 3, got %d", len(args))
+				}
+				method, ok := args[0].(string)
+				if !ok {
+					return nil, fmt.Errorf("bad method: %v", args[0])
+				}
+				var body, headers any
+				if options := optionalArg(args, 2); options != nil {
+					opts, ok := asMap(options)
+					if !ok {
+						return nil, fmt.Errorf("bad options: %v", options)
+					}
+					body, headers = keywordField(opts, "body"), keywordField(opts, "headers")
+				}
+				return sendHTTP(ctx, client, maxBody, strings.ToUpper(strings.TrimPrefix(method, ":")), args[1], body, headers)
+			},
+		},
+	} {
+		if err := vm.defineAsyncFunc(core, fn.name, fn.doc, fn.fn); err != nil {
+			return fmt.Errorf("failed to define %s: %w", fn.name, err)
+		}
+	}
+	return nil
+}
+
+// optionalArg returns the `index`th argument in `args`, or nil if it is not given.
+func optionalArg(args []any, index int) any {
+	if index < len(args) {
+		return args[index]
+	}
+	return nil
+}
+
+// sendHTTP sends a request with arguments converted from janet, and returns its response to be converted to janet.
+//
+// Response bodies larger than `maxBody` bytes are not read, and an error is returned instead.
+func sendHTTP(ctx context.Context, client *http.Client, maxBody int64, method string, url, body, headers any) (any, error) {
+	target, ok := url.(string)
+	if !ok {
+		return nil, fmt.Errorf("bad url: %v", url)
+	}
+	var reader io.Reader
+	switch content := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(content)
+	case []byte: // (eg. buffers converted with InvalidUTF8Bytes)
+		reader = bytes.NewReader(content)
+	default:
+		return nil, fmt.Errorf("bad body: %v", body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	if headers != nil {
+		hs, ok := asMap(headers)
+		if !ok {
+			return nil, fmt.Errorf("bad headers: %v", headers)
+		}
+		for name, value := range hs {
+			key, ok := name.(string)
+			if !ok {
+				return nil, fmt.Errorf("bad header name: %v", name)
+			}
+			key = strings.TrimPrefix(key, ":")
+			switch v := value.(type) {
+			case string:
+				req.Header.Add(key, v)
+			case []any:
+				for _, elem := range v {
+					str, ok := elem.(string)
+					if !ok {
+						return nil, fmt.Errorf("bad value of header %s: %v", key, elem)
+					}
+					req.Header.Add(key, str)
+				}
+			default:
+				return nil, fmt.Errorf("bad value of header %s: %v", key, value)
+			}
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxBody+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if int64(len(content)) > maxBody {
+		return nil, fmt.Errorf("response body is larger than %d bytes", maxBody)
+	}
+
+	respHeaders := make(map[string]any, len(resp.Header))
+	for name, values := range resp.Header {
+		respHeaders[strings.ToLower(name)] = strings.Join(values, ", ")
+	}
+	return Kwargs{
+		"status":  resp.StatusCode,
+		"headers": respHeaders,
+		"body":    string(content),
+	}, nil
+}
@@ -0,0 +1,124 @@
+// http_test.go
+
+package janet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// blockingTransport is a http.RoundTripper which refuses all requests.
+type blockingTransport struct{}
+
+func (blockingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, errors.New("blocked by policy")
+}
+
+// TestHTTPClient tests the `http/*` functions enabled with WithHTTPClient.
+func TestHTTPClient(t *testing.T) {
+	if !Build().EV {
+		t.Skip("http functions are not available without the event loop")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/large" {
+			fmt.Fprint(w, strings.Repeat("x", 1024))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Echo", r.Header.Get("X-Greeting"))
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "%s %s", r.Method, body)
+	}))
+	defer server.Close()
+
+	vm, err := NewVM(WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	if err := vm.Define(ctx, "url", server.URL); err != nil {
+		t.Fatalf("Failed to define url: %v", err)
+	}
+
+	for _, test := range []struct {
+		src      string
+		expected string
+	}{
+		{`(def res (http/get url {"x-greeting" "hello"})) (string/format "%d %s %s" (res :status) ((res :headers) "x-echo") (res :body))`, "201 hello GET "},
+		{`((http/post url @"posted") :body)`, "POST posted"},
+		{`((http/request :put url {:body "put" :headers {:x-greeting "hi"}}) :body)`, "PUT put"},
+		{`(string/join (ev/gather ((http/get url) :body) ((http/post url "x") :body)) "|")`, "GET |POST x"},
+	} {
+		if result, _, _, err := vm.Execute(ctx, test.src); err != nil {
+			t.Errorf("Failed to execute '%s': %v", test.src, err)
+		} else if result != test.expected {
+			t.Errorf("Expected '%s' for '%s', got '%s'", test.expected, test.src, result)
+		}
+	}
+
+	// definitions remain after reset
+	if err := vm.Reset(ctx); err != nil {
+		t.Fatalf("Failed to reset VM: %v", err)
+	}
+	if _, _, _, err := vm.Execute(ctx, `(http/get "`+server.URL+`")`); err != nil {
+		t.Errorf("Failed to send request after reset: %v", err)
+	}
+
+	// requests are sent with the host's client
+	blocked, err := NewVM(WithHTTPClient(&http.Client{Transport: blockingTransport{}}))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer blocked.Close()
+	if _, _, _, err := blocked.Execute(ctx, `(http/get "`+server.URL+`")`); err == nil || !strings.Contains(err.Error(), "blocked by policy") {
+		t.Errorf("Expected error from the client's policy, got: %v", err)
+	}
+
+	// binary bodies (buffers converted to []byte)
+	binary, err := NewVM(WithHTTPClient(server.Client()), WithInvalidUTF8(InvalidUTF8Bytes))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer binary.Close()
+	if result, _, _, err := binary.Execute(ctx, `(length ((http/post "`+server.URL+`" @"\xff\x00") :body))`); err != nil || result != "7" {
+		t.Errorf("Expected response of 7 bytes for binary body, got: %s (%v)", result, err)
+	}
+
+	// response bodies are limited
+	limited, err := NewVM(WithHTTPClient(server.Client()), WithHTTPMaxBody(1024))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer limited.Close()
+	if result, _, _, err := limited.Execute(ctx, `(length ((http/get "`+server.URL+`/large") :body))`); err != nil || result != "1024" {
+		t.Errorf("Expected body of 1024 bytes, got: %s (%v)", result, err)
+	}
+	limited, err = NewVM(WithHTTPClient(server.Client()), WithHTTPMaxBody(1023))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer limited.Close()
+	if _, _, _, err := limited.Execute(ctx, `(http/get "`+server.URL+`/large")`); err == nil || !strings.Contains(err.Error(), "larger than 1023 bytes") {
+		t.Errorf("Expected error for large body, got: %v", err)
+	}
+
+	// not available without the option
+	plain, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer plain.Close()
+	if _, _, _, err := plain.Execute(ctx, `(http/get "`+server.URL+`")`); err == nil {
+		t.Errorf("Expected error without WithHTTPClient")
+	}
+}
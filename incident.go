@@ -0,0 +1,143 @@
+// incident.go
+
+package janet
+
+/*
+#include "janet.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// IncidentKind is the kind of a catastrophic failure of a request.
+type IncidentKind int
+
+// IncidentKind constants
+const (
+	IncidentCrash     IncidentKind = iota // the janet runtime crashed (see ErrVMCrashed)
+	IncidentInterrupt                     // the evaluation was interrupted when the context of its request was done (eg. timed out)
+)
+
+// String returns the name of the kind.
+func (k IncidentKind) String() string {
+	switch k {
+	case IncidentCrash:
+		return "crash"
+	case IncidentInterrupt:
+		return "interrupt"
+	}
+	return fmt.Sprintf("IncidentKind(%d)", int(k))
+}
+
+// number of recently handled janet sources kept for incidents
+const incidentRecentSources = 16
+
+// max number of bytes of each recently handled janet source kept for incidents
+const incidentSourceBytes = 256
+
+// Incident is a diagnostic bundle of a request which failed catastrophically, for reproducing the failure.
+type Incident struct {
+	Time       time.Time
+	Kind       IncidentKind
+	Err        error    // error of the request (with the stack frames of the interrupted fiber, if any)
+	Expression string   // janet source of the request (empty for requests other than executions and parsing)
+	Bindings   []string // sorted names of the bindings defined by user codes (empty for crashes, as the runtime cannot be inspected)
+	Recent     []string // janet sources of recently handled requests, the oldest first (truncated)
+	Stats      Stats    // statistics of the VM at the time of the incident
+}
+
+// String returns the incident formatted as a human-readable report.
+func (i Incident) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "incident: %s at %s\n", i.Kind, i.Time.Format(time.RFC3339Nano))
+
+	var janetErr *Error
+	if errors.As(i.Err, &janetErr) {
+		sb.WriteString(janetErr.Stacktrace())
+	} else if i.Err != nil {
+		fmt.Fprintf(&sb, "error: %s\n", i.Err)
+	}
+
+	if i.Expression != "" {
+		fmt.Fprintf(&sb, "expression:\n%s\n", indent(i.Expression))
+	}
+	if len(i.Bindings) > 0 {
+		fmt.Fprintf(&sb, "bindings: %s\n", strings.Join(i.Bindings, " "))
+	}
+	if len(i.Recent) > 0 {
+		sb.WriteString("recent:\n")
+		for _, source := range i.Recent {
+			sb.WriteString(indent(source) + "\n")
+		}
+	}
+	fmt.Fprintf(&sb, "stats: served %d, crashes %d, pending %d, uptime %s, busy %.1f%%\n",
+		i.Stats.Served, i.Stats.Crashes, i.Stats.PendingExec+i.Stats.PendingParse+i.Stats.PendingCall,
+		i.Stats.Uptime, i.Stats.BusyFraction*100)
+	return sb.String()
+}
+
+// indent indents all lines of `str`.
+func indent(str string) string {
+	return "  " + strings.ReplaceAll(strings.TrimRight(str, "\n"), "\n", "\n  ")
+}
+
+// recordSource records the janet `source` of a request being handled, for incidents.
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) recordSource(source string) {
+	if vm.options.incidentSink == nil {
+		return
+	}
+	if len(source) > incidentSourceBytes {
+		source = source[:incidentSourceBytes] + "..."
+	}
+	// (sources of ExecuteBytes may be modified after their requests)
+	source = strings.Clone(source)
+
+	if len(vm.recentSources) < incidentRecentSources {
+		vm.recentSources = append(vm.recentSources, source)
+	} else {
+		copy(vm.recentSources, vm.recentSources[1:])
+		vm.recentSources[len(vm.recentSources)-1] = source
+	}
+}
+
+// reportIncident sends an incident of `kind` for the request of `expression` which failed with `err`
+// to the sink of the VM (see WithIncidentSink).
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) reportIncident(kind IncidentKind, expression string, err error) {
+	if vm.options.incidentSink == nil {
+		return
+	}
+
+	incident := Incident{
+		Time:       time.Now(),
+		Kind:       kind,
+		Err:        err,
+		Expression: strings.Clone(expression),
+		Recent:     slices.Clone(vm.recentSources),
+		Stats:      vm.Stats(),
+	}
+	if kind != IncidentCrash {
+		incident.Bindings = userBindings(vm.env)
+	}
+	vm.options.incidentSink(incident)
+}
+
+// userBindings returns the sorted names of the bindings defined directly in `env` (not in its prototypes).
+// This function should only be called from the VM handler goroutine.
+func userBindings(env *C.JanetTable) (names []string) {
+	for _, kv := range unsafe.Slice(env.data, int(env.capacity)) {
+		if C.janet_checktype(kv.key, C.JANET_SYMBOL) != 0 {
+			names = append(names, janetStringToGo(C.janet_unwrap_symbol(kv.key)))
+		}
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,80 @@
+// incident_test.go
+
+package janet
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestIncidents tests reporting incidents of requests which failed catastrophically.
+func TestIncidents(t *testing.T) {
+	incidents := make(chan Incident, 2)
+	vm, err := NewVM(WithIncidentSink(func(incident Incident) {
+		incidents <- incident
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	if _, _, _, err := vm.Execute(context.TODO(), `(defn spin [] (spin))`); err != nil {
+		t.Fatalf("Failed to define: %v", err)
+	}
+
+	// interrupted
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	_, _, _, err = vm.Execute(ctx, `(spin)`)
+	cancel()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded, got: %v", err)
+	}
+	select {
+	case incident := <-incidents:
+		if incident.Kind != IncidentInterrupt || incident.Expression != `(spin)` {
+			t.Errorf("Expected an interrupt of `(spin)`, got %s of %q", incident.Kind, incident.Expression)
+		}
+		if !slices.Contains(incident.Bindings, "spin") {
+			t.Errorf("Expected bindings to contain spin, got %v", incident.Bindings)
+		}
+		if !slices.Equal(incident.Recent, []string{`(defn spin [] (spin))`, `(spin)`}) {
+			t.Errorf("Expected recent sources, got %q", incident.Recent)
+		}
+		if report := incident.String(); !strings.Contains(report, "incident: interrupt") || !strings.Contains(report, "in spin") {
+			t.Errorf("Expected report with the stack trace, got:\n%s", report)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected an incident of the interrupt")
+	}
+
+	// crashed
+	if !recoversCrashes {
+		return
+	}
+	if _, _, _, err := vm.Execute(context.TODO(), crashingExpression); !errors.Is(err, ErrVMCrashed) {
+		t.Fatalf("Expected ErrVMCrashed, got %v", err)
+	}
+	select {
+	case incident := <-incidents:
+		if incident.Kind != IncidentCrash || !errors.Is(incident.Err, ErrVMCrashed) {
+			t.Errorf("Expected a crash, got %s (%v)", incident.Kind, incident.Err)
+		}
+		if incident.Expression != crashingExpression || incident.Bindings != nil {
+			t.Errorf("Expected the crashing expression without bindings, got %q (%v)", incident.Expression, incident.Bindings)
+		}
+	default:
+		t.Fatalf("Expected an incident of the crash")
+	}
+
+	// failures of requests are not incidents
+	if _, _, _, err := vm.Execute(context.TODO(), `(error "oops")`); err == nil {
+		t.Fatalf("Expected an error")
+	}
+	if len(incidents) > 0 {
+		t.Errorf("Expected no incidents for errors, got %v", <-incidents)
+	}
+}
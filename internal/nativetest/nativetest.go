@@ -0,0 +1,45 @@
+// nativetest.go
+
+// Package nativetest provides a janet native module for testing.
+package nativetest
+
+/*
+#cgo CFLAGS: -I${SRCDIR}/../../amalgamated
+#include "janet.h"
+
+static Janet greet(int32_t argc, Janet *argv) {
+    janet_fixarity(argc, 1);
+    const uint8_t *name = janet_getstring(argv, 0);
+    return janet_wrap_string(janet_formatc("hello, %S", name));
+}
+
+static const JanetReg cfuns[] = {
+    {"greet", greet, "(greet name)\n\nReturns a greeting for `name`."},
+    {NULL, NULL, NULL}
+};
+
+static void moduleEntry(JanetTable *env) {
+    janet_cfuns(env, "nativetest", cfuns);
+}
+
+static void *getEntry() {
+    return moduleEntry;
+}
+
+static const void *getCFunctions() {
+    return cfuns;
+}
+*/
+import "C"
+
+import "unsafe"
+
+// Entry returns the entry function (`void (*)(JanetTable *env)`) of the module.
+func Entry() unsafe.Pointer {
+	return C.getEntry()
+}
+
+// CFunctions returns the NULL-terminated `JanetReg` array of the module.
+func CFunctions() unsafe.Pointer {
+	return unsafe.Pointer(C.getCFunctions())
+}
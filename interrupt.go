@@ -0,0 +1,247 @@
+// interrupt.go
+
+package janet
+
+/*
+#include <pthread.h>
+#include <stdint.h>
+#include <stdlib.h>
+#include <time.h>
+
+#include "janet.h"
+
+// defined in janet_bundled.go or janet_system.go
+int cancelJanetFibers(Janet reason);
+
+// state for interrupting requests handled by a VM
+typedef struct {
+    JanetVM *vm;
+    pthread_mutex_t lock;
+    uint64_t running; // id of the request being handled (0 for none)
+    int pending;      // number of interrupts not handled yet
+    int posted;       // number of events posted to the event loop, but not received yet
+    int interrupted;  // whether the request being handled was interrupted
+} Interrupter;
+
+// interrupter of the VM running on the current thread (for interruptible sleeps)
+static _Thread_local Interrupter *localInterrupter = NULL;
+
+static Interrupter *newInterrupter(void) {
+    Interrupter *it = calloc(1, sizeof(Interrupter));
+    it->vm = janet_local_vm();
+    pthread_mutex_init(&it->lock, NULL);
+    localInterrupter = it;
+    return it;
+}
+
+static void freeInterrupter(Interrupter *it) {
+    localInterrupter = NULL;
+    pthread_mutex_destroy(&it->lock);
+    free(it);
+}
+
+// marks pending interrupts as handled, and returns whether there were any
+static int handleInterrupts(Interrupter *it) {
+    pthread_mutex_lock(&it->lock);
+    int pending = it->pending;
+    it->pending = 0;
+    pthread_mutex_unlock(&it->lock);
+
+    for (int i = 0; i < pending; i++) {
+        janet_interpreter_interrupt_handled(it->vm);
+    }
+    return pending > 0;
+}
+
+static int isInterrupted(Interrupter *it) {
+    pthread_mutex_lock(&it->lock);
+    int pending = it->pending;
+    pthread_mutex_unlock(&it->lock);
+    return pending > 0;
+}
+
+#ifdef JANET_EV
+// called from the event loop of the VM after an interrupt
+static void interruptCallback(JanetEVGenericMessage msg) {
+    Interrupter *it = (Interrupter *)msg.argp;
+    pthread_mutex_lock(&it->lock);
+    it->posted--;
+    int interrupted = it->interrupted;
+    pthread_mutex_unlock(&it->lock);
+
+    handleInterrupts(it);
+    if (interrupted) {
+        // fibers waiting in the event loop (eg. with ev/sleep) are not stopped by interpreter interrupts
+        cancelJanetFibers(janet_cstringv("interrupted"));
+    }
+}
+#endif
+
+static void beginRequest(Interrupter *it, uint64_t id) {
+    pthread_mutex_lock(&it->lock);
+    it->running = id;
+    it->interrupted = 0;
+    pthread_mutex_unlock(&it->lock);
+}
+
+static void endRequest(Interrupter *it) {
+    pthread_mutex_lock(&it->lock);
+    it->running = 0;
+    int posted = it->posted;
+    pthread_mutex_unlock(&it->lock);
+
+    handleInterrupts(it);
+#ifdef JANET_EV
+    // receive the posted events which are still counted as listeners of the event loop,
+    // otherwise later event loops (even of other VMs on this thread) would wait for them
+    if (posted > 0) {
+        janet_loop();
+    }
+#else
+    (void) posted;
+#endif
+}
+
+// interrupts the request with `id`, if it is still being handled
+static void interruptRequest(Interrupter *it, uint64_t id) {
+    pthread_mutex_lock(&it->lock);
+    if (id != 0 && it->running == id) {
+        it->pending++;
+        it->interrupted = 1;
+        janet_interpreter_interrupt(it->vm);
+#ifdef JANET_EV
+        // wake up the event loop, if it is waiting
+        it->posted++;
+        JanetEVGenericMessage msg = {0};
+        msg.argp = it;
+        janet_ev_post_event(it->vm, interruptCallback, msg);
+#endif
+    }
+    pthread_mutex_unlock(&it->lock);
+}
+
+// interrupts the request being handled (if any), and returns its id (0 for none)
+static uint64_t interruptRunning(Interrupter *it) {
+    pthread_mutex_lock(&it->lock);
+    uint64_t id = it->running;
+    pthread_mutex_unlock(&it->lock);
+
+    interruptRequest(it, id);
+    return id;
+}
+
+// os/sleep which wakes up when interrupted
+static Janet interruptibleSleep(int32_t argc, Janet *argv) {
+    janet_fixarity(argc, 1);
+    double delay = janet_getnumber(argv, 0);
+    if (delay < 0) janet_panic("invalid argument to sleep");
+
+    struct timespec now, until;
+    clock_gettime(CLOCK_MONOTONIC, &until);
+    until.tv_sec += (time_t)delay;
+    until.tv_nsec += (long)((delay - (double)(time_t)delay) * 1000000000);
+    if (until.tv_nsec >= 1000000000) {
+        until.tv_sec++;
+        until.tv_nsec -= 1000000000;
+    }
+
+    for (;;) {
+        if (localInterrupter != NULL && isInterrupted(localInterrupter)) {
+            janet_panic("interrupted");
+        }
+        clock_gettime(CLOCK_MONOTONIC, &now);
+        double left = (double)(until.tv_sec - now.tv_sec) + (double)(until.tv_nsec - now.tv_nsec) / 1e9;
+        if (left <= 0) {
+            break;
+        }
+        // sleep in slices for checking interrupts
+        double slice = left < 0.01 ? left : 0.01;
+        struct timespec ts = {(time_t)slice, (long)(slice * 1000000000)};
+        nanosleep(&ts, NULL);
+    }
+    return janet_wrap_nil();
+}
+
+static const JanetReg interruptibleCfuns[] = {
+    {"os/sleep", interruptibleSleep, "(os/sleep n)\n\nSuspend the program for `n` seconds. `n` can be a real number. Returns nil."},
+    {NULL, NULL, NULL},
+};
+
+static void registerInterruptibleCfuns(JanetTable *env) {
+    janet_cfuns(env, NULL, interruptibleCfuns);
+}
+*/
+import "C"
+
+import (
+	"sync"
+)
+
+// interrupter interrupts requests handled by a VM.
+type interrupter struct {
+	mu sync.Mutex // guards `it` against being freed while interrupting
+	it *C.Interrupter
+}
+
+// start creates the interrupter for the VM running on the current thread,
+// and makes `os/sleep` of `core` interruptible.
+//
+// This function should only be called from the VM handler goroutine.
+func (i *interrupter) start(core *C.JanetTable) {
+	C.registerInterruptibleCfuns(core)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.it = C.newInterrupter()
+}
+
+// stop frees the interrupter, after which interrupts are ignored.
+//
+// This function should only be called from the VM handler goroutine.
+func (i *interrupter) stop() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.it != nil {
+		C.freeInterrupter(i.it)
+		i.it = nil
+	}
+}
+
+// begin marks the request with `id` as being handled.
+//
+// This function should only be called from the VM handler goroutine.
+func (i *interrupter) begin(id uint64) {
+	C.beginRequest(i.it, C.uint64_t(id))
+}
+
+// end marks the request as handled, and handles interrupts which were not handled yet.
+//
+// This function should only be called from the VM handler goroutine.
+func (i *interrupter) end() {
+	C.endRequest(i.it)
+}
+
+// interrupt interrupts the request with `id` if it is still being handled by the VM
+// (eg. when the caller's context is done), so that the VM is freed for other requests.
+//
+// Interpreter loops are stopped, and fibers sleeping with `os/sleep` are woken up with an error.
+// Fibers waiting in the event loop (eg. with `ev/sleep`) are also cancelled with the bundled janet,
+// but other blocking calls (eg. reading from stdin) are not interrupted.
+func (i *interrupter) interrupt(id uint64) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.it != nil {
+		C.interruptRequest(i.it, C.uint64_t(id))
+	}
+}
+
+// interruptRunning interrupts the request being handled by the VM (if any) in the same way as interrupt,
+// and returns its id (0 if no request is being handled).
+func (i *interrupter) interruptRunning() uint64 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.it == nil {
+		return 0
+	}
+	return uint64(C.interruptRunning(i.it))
+}
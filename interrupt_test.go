@@ -0,0 +1,52 @@
+// interrupt_test.go
+
+package janet
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestInterrupt tests interrupting executions when their contexts are done.
+func TestInterrupt(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	exprs := []string{
+		`(while true)`,
+		`(os/sleep 10)`,
+		`(defn spin [] (spin)) (spin)`,
+	}
+	if cancelsEventFibers && Build().EV {
+		exprs = append(exprs,
+			`(ev/sleep 10)`,
+			`(ev/go (fn [] (ev/sleep 10))) :spawned`,
+		)
+	}
+	for _, expr := range exprs {
+		ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+		_, _, _, err := vm.Execute(ctx, expr)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected deadline exceeded for '%s', got: %v", expr, err)
+		}
+
+		// the VM should be freed soon
+		ctx, cancel = context.WithTimeout(context.TODO(), time.Second)
+		evaluated, _, _, err := vm.Execute(ctx, `(+ 1 2)`)
+		cancel()
+		if err != nil || evaluated != "3" {
+			t.Errorf("Expected the VM to be freed after interrupting '%s', got: %v (%v)", expr, evaluated, err)
+		}
+	}
+
+	// interrupts do not affect later executions
+	if _, _, _, err := vm.Execute(context.TODO(), `(os/sleep 0.01) (for i 0 100000 i)`); err != nil {
+		t.Errorf("Failed to execute after interrupts: %v", err)
+	}
+}
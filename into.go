@@ -0,0 +1,388 @@
+// into.go
+
+package janet
+
+/*
+#include "janet.h"
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// valueResult is used to receive an evaluated value from the VM handler.
+type valueResult struct {
+	value  any
+	stdout string
+	stderr string
+	err    error
+}
+
+// evaluateValue executes a `janetExpression` and converts the evaluated result to a go value.
+func (vm *VM) evaluateValue(
+	ctx context.Context,
+	janetExpression string,
+	options execOptions,
+) (valueResult, error) {
+	return runOnVM(ctx, vm, func(env *C.JanetTable) valueResult {
+		var janetResult C.Janet
+		var failure evalFailure
+		var ret C.int
+
+		var stdout, stderr string
+		if err := vm.withExecDyns(env, options, func() {
+			stdout, stderr = captureExecOutput(env, options, func() {
+				vm.checkLeaks(janetExpression, func() {
+					ret = dobytesAt(env, janetExpression, options.source, &janetResult, &failure)
+				})
+			})
+		}); err != nil {
+			return valueResult{err: err}
+		}
+		if ret != C.JANET_SIGNAL_OK {
+			return valueResult{stdout: stdout, stderr: stderr, err: failure.wrap(newError(failure.fiber, janetResult, janetValueToString(janetResult)))}
+		}
+
+		dec := vm.decoder(ctx)
+		dec.arena = options.arena
+		value, err := dec.decode(janetResult)
+		return valueResult{value: value, stdout: stdout, stderr: stderr, err: err}
+	})
+}
+
+// EvalValue executes a `janetExpression` and returns the evaluated result converted to a go value
+// (in the same way as ParseToValue), along with any output to stdout and stderr.
+func (vm *VM) EvalValue(
+	ctx context.Context,
+	janetExpression string,
+	opts ...ExecOption,
+) (
+	value any,
+	stdout string,
+	stderr string,
+	err error,
+) {
+	res, err := vm.evaluateValue(ctx, janetExpression, newExecOptions(opts))
+	if err != nil {
+		return nil, "", "", err
+	}
+	return res.value, res.stdout, res.stderr, res.err
+}
+
+// ExecuteInto executes a `janetExpression` and stores the evaluated result into `out`,
+// which should be a non-nil pointer (eg. to a struct, slice, map, or number).
+//
+// Keys of janet tables and structs (with or without leading `:`) are matched with
+// the names of exported fields of structs (`janet` struct tags, or their go names in kebab-case),
+// or their go names case-insensitively, and unknown keys are ignored.
+// Values of types implementing Unmarshaler are stored with their UnmarshalJanet methods.
+// Outputs to stdout and stderr can be received with WithOutput.
+func (vm *VM) ExecuteInto(
+	ctx context.Context,
+	janetExpression string,
+	out any,
+	opts ...ExecOption,
+) error {
+	dst := reflect.ValueOf(out)
+	if dst.Kind() != reflect.Pointer || dst.IsNil() {
+		return fmt.Errorf("out should be a non-nil pointer, got %T", out)
+	}
+
+	options := newExecOptions(opts)
+	res, err := vm.evaluateValue(ctx, janetExpression, options)
+	if err != nil {
+		return err
+	}
+	if options.stdout != nil {
+		*options.stdout = res.stdout
+	}
+	if options.stderr != nil {
+		*options.stderr = res.stderr
+	}
+	if res.err != nil {
+		return res.err
+	}
+
+	return assign(dst.Elem(), res.value, "result", options.strict)
+}
+
+var (
+	timeType     = reflect.TypeFor[time.Time]()
+	durationType = reflect.TypeFor[time.Duration]()
+)
+
+// assign stores a go value `src` converted from janet into `dst`.
+//
+// `path` is the location of `src` in the converted value, used in error messages.
+// With `strict`, nil cannot be stored into values which cannot be nil (see WithStrict).
+func assign(dst reflect.Value, src any, path string, strict bool) error {
+	if src == nil {
+		if strict && !isNillable(dst.Kind()) {
+			return fmt.Errorf("%s: cannot store nil into %s", path, dst.Type())
+		}
+		dst.SetZero()
+		return nil
+	}
+	if ok, err := unmarshal(dst, src); ok {
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return nil
+	}
+
+	switch dst.Type() {
+	case timeType:
+		t, err := DecodeTime(src, nil)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		dst.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		d, err := DecodeDuration(src)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		dst.Set(reflect.ValueOf(d))
+		return nil
+	}
+
+	if ok, err := assignText(dst, src); ok {
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return nil
+	}
+
+	value := reflect.ValueOf(src)
+	if value.Type().AssignableTo(dst.Type()) {
+		dst.Set(value)
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Pointer:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return assign(dst.Elem(), src, path, strict)
+	case reflect.Bool:
+		if b, ok := src.(bool); ok {
+			dst.SetBool(b)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch n := src.(type) {
+		case float64:
+			if n == math.Trunc(n) && n >= math.MinInt64 && n < math.MaxInt64 && !dst.OverflowInt(int64(n)) {
+				dst.SetInt(int64(n))
+				return nil
+			}
+			return fmt.Errorf("%s: %v does not fit in %s", path, n, dst.Type())
+		case int64:
+			if !dst.OverflowInt(n) {
+				dst.SetInt(n)
+				return nil
+			}
+			return fmt.Errorf("%s: %v does not fit in %s", path, n, dst.Type())
+		case uint64:
+			if n <= math.MaxInt64 && !dst.OverflowInt(int64(n)) {
+				dst.SetInt(int64(n))
+				return nil
+			}
+			return fmt.Errorf("%s: %v does not fit in %s", path, n, dst.Type())
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		switch n := src.(type) {
+		case float64:
+			if n == math.Trunc(n) && n >= 0 && n < math.MaxUint64 && !dst.OverflowUint(uint64(n)) {
+				dst.SetUint(uint64(n))
+				return nil
+			}
+			return fmt.Errorf("%s: %v does not fit in %s", path, n, dst.Type())
+		case int64:
+			if n >= 0 && !dst.OverflowUint(uint64(n)) {
+				dst.SetUint(uint64(n))
+				return nil
+			}
+			return fmt.Errorf("%s: %v does not fit in %s", path, n, dst.Type())
+		case uint64:
+			if !dst.OverflowUint(n) {
+				dst.SetUint(n)
+				return nil
+			}
+			return fmt.Errorf("%s: %v does not fit in %s", path, n, dst.Type())
+		}
+	case reflect.Float32, reflect.Float64:
+		switch n := src.(type) {
+		case float64:
+			dst.SetFloat(n)
+			return nil
+		case int64:
+			dst.SetFloat(float64(n))
+			return nil
+		case uint64:
+			dst.SetFloat(float64(n))
+			return nil
+		}
+	case reflect.String:
+		if str, ok := src.(string); ok {
+			dst.SetString(str)
+			return nil
+		}
+	case reflect.Slice:
+		if str, ok := src.(string); ok && dst.Type().Elem().Kind() == reflect.Uint8 {
+			dst.SetBytes([]byte(str))
+			return nil
+		}
+		if bytes, ok := src.([]byte); ok && dst.Type().Elem().Kind() == reflect.Uint8 {
+			dst.SetBytes(bytes)
+			return nil
+		}
+		if elems, ok := src.([]any); ok {
+			slice := reflect.MakeSlice(dst.Type(), len(elems), len(elems))
+			for i, elem := range elems {
+				if err := assign(slice.Index(i), elem, fmt.Sprintf("%s[%d]", path, i), strict); err != nil {
+					return err
+				}
+			}
+			dst.Set(slice)
+			return nil
+		}
+	case reflect.Array:
+		if elems, ok := src.([]any); ok {
+			if len(elems) > dst.Len() {
+				return fmt.Errorf("%s: %d elements do not fit in %s", path, len(elems), dst.Type())
+			}
+			dst.SetZero()
+			for i, elem := range elems {
+				if err := assign(dst.Index(i), elem, fmt.Sprintf("%s[%d]", path, i), strict); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case reflect.Map:
+		if table, ok := asMap(src); ok {
+			m := reflect.MakeMapWithSize(dst.Type(), len(table))
+			for k, v := range table {
+				key := reflect.New(dst.Type().Key()).Elem()
+				if err := assign(key, k, fmt.Sprintf("%s[%v]", path, k), strict); err != nil {
+					return err
+				}
+				elem := reflect.New(dst.Type().Elem()).Elem()
+				if err := assign(elem, v, fmt.Sprintf("%s[%v]", path, k), strict); err != nil {
+					return err
+				}
+				m.SetMapIndex(key, elem)
+			}
+			dst.Set(m)
+			return nil
+		}
+	case reflect.Struct:
+		if table, ok := asMap(src); ok {
+			return assignStruct(dst, table, path, strict)
+		}
+	}
+
+	return fmt.Errorf("%s: cannot store %T into %s", path, src, dst.Type())
+}
+
+// assignStruct stores the values of a converted janet table or struct into the fields of struct `dst` (see structField).
+//
+// With `strict`, keys which do not match any fields are not ignored,
+// and fields tagged with `required` should be given (see WithStrict).
+func assignStruct(dst reflect.Value, table map[any]any, path string, strict bool) error {
+	fields := structFields(dst.Type())
+	assigned := map[string]bool{}
+	for k, v := range table {
+		name, ok := k.(string)
+		if !ok {
+			if strict {
+				return fmt.Errorf("%s: unknown key %v", path, k)
+			}
+			continue
+		}
+		name = strings.TrimPrefix(name, ":")
+
+		f, ok := lookupField(fields, name)
+		if !ok {
+			if strict {
+				return fmt.Errorf("%s: unknown key %s", path, k)
+			}
+			continue
+		}
+		field := fieldByIndexAlloc(dst, f.index)
+		if !field.CanSet() {
+			continue
+		}
+		if err := assign(field, v, path+"."+name, strict); err != nil {
+			return err
+		}
+		assigned[f.name] = true
+	}
+	if strict {
+		for _, f := range fields {
+			if f.required && !assigned[f.name] {
+				return fmt.Errorf("%s: missing required key :%s", path, f.name)
+			}
+		}
+	}
+	return nil
+}
+
+// isNillable returns whether values of `kind` can be nil.
+func isNillable(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map, reflect.Func, reflect.Chan:
+		return true
+	}
+	return false
+}
+
+// fieldByIndexAlloc returns the nested field of struct `v` by `index`, allocating nil embedded structs on the way.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// Eval executes a `janetExpression` on `vm` and returns the evaluated result stored into a value of type T
+// (in the same way as VM.ExecuteInto), eg. `janet.Eval[[]string](ctx, vm, "(keys config)")`.
+func Eval[T any](
+	ctx context.Context,
+	vm *VM,
+	janetExpression string,
+	opts ...ExecOption,
+) (result T, err error) {
+	err = vm.ExecuteInto(ctx, janetExpression, &result, opts...)
+	return result, err
+}
+
+// Parse parses a `janetExpression` containing janet data on `vm` (in the same way as VM.ParseToValue),
+// and returns the parsed value stored into a value of type T (in the same way as VM.ExecuteInto).
+func Parse[T any](
+	ctx context.Context,
+	vm *VM,
+	janetExpression string,
+) (result T, err error) {
+	value, err := vm.ParseToValue(ctx, janetExpression)
+	if err != nil {
+		return result, err
+	}
+	err = assign(reflect.ValueOf(&result).Elem(), value, "result", false)
+	return result, err
+}
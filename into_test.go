@@ -0,0 +1,202 @@
+// into_test.go
+
+package janet
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestExecuteInto tests executions with results stored into go values.
+func TestExecuteInto(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	type item struct {
+		Name  string
+		Count int
+		Tags  []string
+		Price *float64
+	}
+
+	var it item
+	var stdout string
+	if err := vm.ExecuteInto(ctx, `(print "hello") {:name "apple" :count 3 :tags ["red" "fruit"] :price 1.5 :unknown true}`, &it, WithOutput(&stdout, nil)); err != nil {
+		t.Fatalf("Failed to execute into struct: %v", err)
+	}
+	if it.Name != "apple" || it.Count != 3 || !reflect.DeepEqual(it.Tags, []string{"red", "fruit"}) || it.Price == nil || *it.Price != 1.5 {
+		t.Errorf("Unexpected struct: %+v", it)
+	}
+	if stdout != "hello\n" {
+		t.Errorf("Expected stdout 'hello\\n', got '%s'", stdout)
+	}
+
+	var items []item
+	if err := vm.ExecuteInto(ctx, `(map |{:Name $} ["a" "b"])`, &items); err != nil {
+		t.Fatalf("Failed to execute into slice: %v", err)
+	} else if len(items) != 2 || items[0].Name != "a" || items[1].Name != "b" {
+		t.Errorf("Unexpected slice: %+v", items)
+	}
+
+	var counts map[string]uint8
+	if err := vm.ExecuteInto(ctx, `@{"a" 1 "b" 2}`, &counts); err != nil {
+		t.Fatalf("Failed to execute into map: %v", err)
+	} else if !reflect.DeepEqual(counts, map[string]uint8{"a": 1, "b": 2}) {
+		t.Errorf("Unexpected map: %v", counts)
+	}
+
+	// (`int/*` is not available without int types)
+	if Build().IntTypes {
+		var big int64
+		if err := vm.ExecuteInto(ctx, `(int/s64 "9007199254740993")`, &big); err != nil {
+			t.Fatalf("Failed to execute into int64: %v", err)
+		} else if big != 9007199254740993 {
+			t.Errorf("Unexpected int64: %d", big)
+		}
+	}
+
+	var timeout time.Duration
+	if err := vm.ExecuteInto(ctx, `1.5`, &timeout); err != nil {
+		t.Fatalf("Failed to execute into duration: %v", err)
+	} else if timeout != 1500*time.Millisecond {
+		t.Errorf("Unexpected duration: %v", timeout)
+	}
+
+	var anything any
+	if err := vm.ExecuteInto(ctx, `[1 "two"]`, &anything); err != nil {
+		t.Fatalf("Failed to execute into any: %v", err)
+	} else if !reflect.DeepEqual(anything, []any{float64(1), "two"}) {
+		t.Errorf("Unexpected value: %v", anything)
+	}
+
+	// errors
+	for _, test := range []struct {
+		input              string
+		out                any
+		expectedErrPattern string
+	}{
+		{`1.5`, new(int), "does not fit in int"},
+		{`300`, new(uint8), "does not fit in uint8"},
+		{`{:count "three"}`, new(item), "result.count: cannot store string into int"},
+		{`[1 2 3]`, new([2]int), "do not fit in [2]int"},
+		{`(error "oops")`, new(int), "oops"},
+		{`1`, item{}, "non-nil pointer"},
+	} {
+		if err := vm.ExecuteInto(ctx, test.input, test.out); err == nil || !strings.Contains(err.Error(), test.expectedErrPattern) {
+			t.Errorf("Expected error with '%s' for '%s', got: %v", test.expectedErrPattern, test.input, err)
+		}
+	}
+}
+
+// TestEvalValue tests executions with results converted to go values.
+func TestEvalValue(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	value, stdout, stderr, err := vm.EvalValue(ctx, `(print "out") (eprint "err") @{:sum (+ 1 2) :list [1 "two"]}`)
+	if err != nil {
+		t.Fatalf("Failed to evaluate value: %v", err)
+	}
+	if !reflect.DeepEqual(value, map[any]any{":sum": float64(3), ":list": []any{float64(1), "two"}}) {
+		t.Errorf("Unexpected value: %v", value)
+	}
+	if stdout != "out\n" || stderr != "err\n" {
+		t.Errorf("Unexpected outputs: '%s', '%s'", stdout, stderr)
+	}
+
+	// outputs are returned even when the evaluation fails
+	if _, stdout, _, err := vm.EvalValue(ctx, `(print "before") (error "failed")`); err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("Expected error for failed evaluation, got: %v", err)
+	} else if stdout != "before\n" {
+		t.Errorf("Expected stdout 'before\\n', got '%s'", stdout)
+	}
+}
+
+// TestStrictInto tests executions with results stored into go values strictly.
+func TestStrictInto(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	type tenant struct {
+		Name    string `janet:",required"`
+		Limit   int
+		Aliases []string
+		Scores  []int
+		Owner   *struct {
+			Email string `janet:",required"`
+		}
+	}
+
+	var out tenant
+	if err := vm.ExecuteInto(ctx, `{:name "acme" :limit 3 :aliases nil :owner {:email "a@b.c"}}`, &out, WithStrict()); err != nil {
+		t.Errorf("Failed to execute into strictly: %v", err)
+	} else if out.Name != "acme" || out.Limit != 3 || out.Owner == nil || out.Owner.Email != "a@b.c" {
+		t.Errorf("Unexpected value: %+v", out)
+	}
+
+	for _, test := range []struct {
+		input              string
+		expectedErrPattern string
+	}{
+		{`{:name "acme" :limti 3}`, "result: unknown key :limti"},
+		{`{:name "acme" 1 2}`, "result: unknown key 1"},
+		{`{:limit 3}`, "result: missing required key :name"},
+		{`{:name "acme" :owner {}}`, "result.owner: missing required key :email"},
+		{`{:name "acme" :scores [1 nil]}`, "result.scores[1]: cannot store nil into int"},
+		{`{:name "acme" :aliases [1]}`, "result.aliases[0]: cannot store float64 into string"},
+	} {
+		var out tenant
+		if err := vm.ExecuteInto(ctx, test.input, &out, WithStrict()); err == nil || !strings.Contains(err.Error(), test.expectedErrPattern) {
+			t.Errorf("Expected error with '%s' for '%s', got: %v", test.expectedErrPattern, test.input, err)
+		}
+		if err := vm.ExecuteInto(ctx, test.input, &out); err != nil && !strings.Contains(test.expectedErrPattern, "cannot store float64") {
+			t.Errorf("Expected no error without WithStrict for '%s', got: %v", test.input, err)
+		}
+	}
+}
+
+// TestTypedEval tests the Eval and Parse functions.
+func TestTypedEval(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	if sum, err := Eval[int](ctx, vm, `(+ 1 2 3)`); err != nil || sum != 6 {
+		t.Errorf("Expected 6, got %d (%v)", sum, err)
+	}
+	if names, err := Eval[[]string](ctx, vm, `(map string [:a :b])`); err != nil || !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Errorf("Expected [a b], got %v (%v)", names, err)
+	}
+	type point struct{ X, Y float64 }
+	if p, err := Parse[point](ctx, vm, `{:x 1 :y 2.5}`); err != nil || p != (point{1, 2.5}) {
+		t.Errorf("Expected {1 2.5}, got %+v (%v)", p, err)
+	}
+	if _, err := Eval[point](ctx, vm, `{:x 1 :z 2}`, WithStrict()); err == nil || !strings.Contains(err.Error(), "unknown key :z") {
+		t.Errorf("Expected error with options applied, got: %v", err)
+	}
+	if _, err := Parse[int](ctx, vm, `"one"`); err == nil || !strings.Contains(err.Error(), "cannot store string into int") {
+		t.Errorf("Expected type mismatch error, got: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+// inttypes.go
+
+package janet
+
+/*
+#include "janet.h"
+
+enum {
+    intTypeNone = 0,
+    intTypeS64 = 1,
+    intTypeU64 = 2,
+};
+
+static int intTypeOf(Janet x) {
+#ifdef JANET_INT_TYPES
+    switch (janet_is_int(x)) {
+    case JANET_INT_S64:
+        return intTypeS64;
+    case JANET_INT_U64:
+        return intTypeU64;
+    default:
+        return intTypeNone;
+    }
+#else
+    (void)x;
+    return intTypeNone;
+#endif
+}
+
+static int64_t unwrapS64(Janet x) {
+#ifdef JANET_INT_TYPES
+    return janet_unwrap_s64(x);
+#else
+    (void)x;
+    return 0;
+#endif
+}
+
+static uint64_t unwrapU64(Janet x) {
+#ifdef JANET_INT_TYPES
+    return janet_unwrap_u64(x);
+#else
+    (void)x;
+    return 0;
+#endif
+}
+
+// falls back to a (possibly inexact) number when int types are not available
+static Janet wrapS64(int64_t x) {
+#ifdef JANET_INT_TYPES
+    return janet_wrap_s64(x);
+#else
+    return janet_wrap_number((double)x);
+#endif
+}
+
+static Janet wrapU64(uint64_t x) {
+#ifdef JANET_INT_TYPES
+    return janet_wrap_u64(x);
+#else
+    return janet_wrap_number((double)x);
+#endif
+}
+*/
+import "C"
+
+// largest integer which can be represented exactly as a janet number (2^53)
+const maxExactInt = 1 << 53
+
+// janetIntToGo converts a janet `int/s64` or `int/u64` to go int64 or uint64.
+//
+// `ok` is false if `value` is not a 64-bit integer.
+func janetIntToGo(value C.Janet) (converted any, ok bool) {
+	switch C.intTypeOf(value) {
+	case C.intTypeS64:
+		return int64(C.unwrapS64(value)), true
+	case C.intTypeU64:
+		return uint64(C.unwrapU64(value)), true
+	}
+	return nil, false
+}
+
+// goIntToJanet converts a go integer to a janet number, or to `int/s64` if it is out of the exact range of numbers.
+func goIntToJanet(i int64) C.Janet {
+	if i > maxExactInt || i < -maxExactInt {
+		return C.wrapS64(C.int64_t(i))
+	}
+	return C.janet_wrap_number(C.double(i))
+}
+
+// goUintToJanet converts a go unsigned integer to a janet number, or to `int/u64` if it is out of the exact range of numbers.
+func goUintToJanet(u uint64) C.Janet {
+	if u > maxExactInt {
+		return C.wrapU64(C.uint64_t(u))
+	}
+	return C.janet_wrap_number(C.double(u))
+}
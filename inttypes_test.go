@@ -0,0 +1,82 @@
+// inttypes_test.go
+
+package janet
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+)
+
+// TestIntTypes tests conversions of 64-bit integers.
+func TestIntTypes(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	// janet => go
+	parseTests := []struct {
+		input    string
+		expected any
+	}{
+		{`(int/s64 "-9007199254740993")`, int64(-9007199254740993)},
+		{`(int/u64 "18446744073709551615")`, uint64(math.MaxUint64)},
+		{`(int/s64 42)`, int64(42)},
+		{`42`, float64(42)},
+	}
+	for _, test := range parseTests {
+		if !Build().IntTypes && strings.HasPrefix(test.input, "(int/") {
+			continue // (`int/*` is not available)
+		}
+		if value, err := vm.ParseToValue(ctx, test.input); err != nil {
+			t.Errorf("Failed to parse '%s': %v", test.input, err)
+		} else if value != test.expected {
+			t.Errorf("Expected %#v for '%s', got %#v", test.expected, test.input, value)
+		}
+	}
+
+	// go => janet => go (round trips)
+	roundTripTests := []struct {
+		value    any
+		expected any
+		typ      string
+	}{
+		{int64(math.MaxInt64), int64(math.MaxInt64), ":core/s64"},
+		{int64(math.MinInt64), int64(math.MinInt64), ":core/s64"},
+		{uint64(math.MaxUint64), uint64(math.MaxUint64), ":core/u64"},
+		{1 << 53, float64(1 << 53), ":number"},
+		{-(1 << 53), float64(-(1 << 53)), ":number"},
+	}
+	for _, test := range roundTripTests {
+		if !Build().IntTypes {
+			// (converted to possibly inexact numbers)
+			test.typ = ":number"
+			test.expected = toFloat64(test.expected)
+		}
+		if err := vm.Define(ctx, "i", test.value); err != nil {
+			t.Errorf("Failed to define %v: %v", test.value, err)
+			continue
+		}
+		if value, err := vm.ParseToValue(ctx, "[(type i) i]"); err != nil {
+			t.Errorf("Failed to evaluate %v: %v", test.value, err)
+		} else if pair := value.([]any); pair[0] != test.typ || pair[1] != test.expected {
+			t.Errorf("Expected %s %#v for %v, got %v %#v", test.typ, test.expected, test.value, pair[0], pair[1])
+		}
+	}
+}
+
+// toFloat64 converts an integer `value` to float64.
+func toFloat64(value any) float64 {
+	switch v := value.(type) {
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	}
+	return value.(float64)
+}
@@ -4,61 +4,142 @@
 package janet
 
 /*
-#cgo CFLAGS: -I./amalgamated
 #cgo LDFLAGS: -lm -lpthread -ldl
-#include "amalgamated/janet.c"
-#include <stdio.h>
-#include <unistd.h>
-#include <fcntl.h>
+#include <setjmp.h>
 
-static int redirectStdout(int pipe_fd) {
-	fflush(stdout);
-	int original_fd = dup(STDOUT_FILENO);
-	dup2(pipe_fd, STDOUT_FILENO);
-	close(pipe_fd);
-	return original_fd;
-}
+#include "janet.h"
+
+// defined in janet_bundled.go or janet_system.go
+void *setCrashGuard(void *guard);
+
+// dynamic bindings of outputs in the environment, which are replaced while capturing
+//
+// (the ones outside fibers are not used, as they are not marked by the gc of janet,
+// and errors printed outside fibers are reported in the environment instead, see `reportError`)
+typedef struct {
+    Janet out, err;
+} Outputs;
 
-static int redirectStderr(int pipe_fd) {
-	fflush(stderr);
-	int original_fd = dup(STDERR_FILENO);
-	dup2(pipe_fd, STDERR_FILENO);
-	close(pipe_fd);
-	return original_fd;
+// redirects outputs of janet codes in `env` to `out` and `err`, and stores the previous bindings to `prev`
+static void captureOutputs(JanetTable *env, JanetBuffer *out, JanetBuffer *err, Outputs *prev) {
+    prev->out = janet_table_rawget(env, janet_ckeywordv("out"));
+    prev->err = janet_table_rawget(env, janet_ckeywordv("err"));
+
+    janet_table_put(env, janet_ckeywordv("out"), janet_wrap_buffer(out));
+    janet_table_put(env, janet_ckeywordv("err"), janet_wrap_buffer(err));
 }
 
-static void restoreStdout(int original_fd) {
-	fflush(stdout);
-	if (original_fd != -1) {
-		dup2(original_fd, STDOUT_FILENO);
-		close(original_fd);
-	}
+// restores the bindings of outputs stored with `captureOutputs`
+static void restoreOutputs(JanetTable *env, Outputs *prev) {
+    janet_table_put(env, janet_ckeywordv("out"), prev->out);
+    janet_table_put(env, janet_ckeywordv("err"), prev->err);
 }
 
-static int32_t janet_struct_len(JanetStruct st) {
-    return janet_struct_head(st)->length;
+// waits for `fiber` suspended by events (eg. in async functions) in the event loop,
+// and returns its signal with its result stored into `out`
+static int waitFiber(JanetFiber *fiber, Janet *out) {
+#ifdef JANET_EV
+    janet_gcroot(janet_wrap_fiber(fiber));
+    janet_loop();
+    janet_gcunroot(janet_wrap_fiber(fiber));
+#endif
+    *out = fiber->last_value;
+    switch (janet_fiber_status(fiber)) {
+    case JANET_STATUS_DEAD:
+        return JANET_SIGNAL_OK;
+    case JANET_STATUS_ERROR:
+        return JANET_SIGNAL_ERROR;
+    default:
+        *out = janet_cstringv("fiber did not finish in the event loop");
+        return JANET_SIGNAL_ERROR;
+    }
 }
 
-static void restoreStderr(int original_fd) {
-    fflush(stderr);
-    if (original_fd != -1) {
-        dup2(original_fd, STDERR_FILENO);
-        close(original_fd);
+// continues `fiber` and returns its signal with its result stored into `out`, after it finishes in the event loop if suspended,
+// or returns JANET_SIGNAL_ERROR when the janet runtime fails fatally, instead of aborting the process (see crash.go)
+static int callFiber(JanetFiber *fiber, Janet *out) {
+    jmp_buf guard;
+    void *prev = setCrashGuard(&guard);
+    if (setjmp(guard)) {
+        setCrashGuard(prev);
+        return JANET_SIGNAL_ERROR;
+    }
+    int signal = janet_continue(fiber, janet_wrap_nil(), out);
+    if (signal == JANET_SIGNAL_EVENT) {
+        signal = waitFiber(fiber, out);
     }
+    setCrashGuard(prev);
+    return signal;
 }
 
 static char* getJanetVersionString() {
     return JANET_VERSION;
 }
+
+// defined in janet_bundled.go or janet_system.go
+const char *getJanetBuildString();
+
+// bit flags of the enabled build features
+enum {
+    janetFeatureNanbox = 1 << 0,
+    janetFeatureEv = 1 << 1,
+    janetFeatureFfi = 1 << 2,
+    janetFeatureNet = 1 << 3,
+    janetFeatureProcesses = 1 << 4,
+    janetFeatureAssembler = 1 << 5,
+    janetFeaturePeg = 1 << 6,
+    janetFeatureIntTypes = 1 << 7,
+    janetFeatureThreads = 1 << 8,
+    janetFeatureDynamicModules = 1 << 9,
+};
+
+static int getJanetBuildFeatures() {
+    int features = 0;
+#ifdef JANET_NANBOX_64
+    features |= janetFeatureNanbox;
+#endif
+#ifdef JANET_EV
+    features |= janetFeatureEv;
+#endif
+#ifdef JANET_FFI
+    features |= janetFeatureFfi;
+#endif
+#ifdef JANET_NET
+    features |= janetFeatureNet;
+#endif
+#ifndef JANET_NO_PROCESSES
+    features |= janetFeatureProcesses;
+#endif
+#ifdef JANET_ASSEMBLER
+    features |= janetFeatureAssembler;
+#endif
+#ifdef JANET_PEG
+    features |= janetFeaturePeg;
+#endif
+#ifdef JANET_INT_TYPES
+    features |= janetFeatureIntTypes;
+#endif
+#if defined(JANET_EV) && !defined(JANET_SINGLE_THREADED)
+    features |= janetFeatureThreads;
+#endif
+#ifdef JANET_DYNAMIC_MODULES
+    features |= janetFeatureDynamicModules;
+#endif
+    return features;
+}
 */
 import "C"
 
 import (
-	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"runtime"
+	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
@@ -70,26 +151,153 @@ func Version() string {
 	return C.GoString(C.getJanetVersionString())
 }
 
+// module path of this binding
+const modulePath = "github.com/meinside/janet-go"
+
+// BuildInfo is the build information of the embedded Janet and this binding.
+type BuildInfo struct {
+	JanetVersion   string // version of the Janet language (eg. "1.40.1")
+	JanetBuild     string // git hash of the Janet amalgamation
+	BindingVersion string // module version of this binding ("(devel)" or empty when unknown)
+
+	// enabled build features
+	NaNBoxing      bool // values are NaN-boxed
+	EV             bool // event loop (`ev/*`)
+	FFI            bool // foreign function interface (`ffi/*`)
+	Net            bool // networking (`net/*`)
+	Processes      bool // subprocesses (`os/spawn`, `os/execute`, ...)
+	Assembler      bool // assembler (`asm`, `disasm`)
+	PEG            bool // PEG (`peg/*`)
+	IntTypes       bool // 64-bit integer types (`int/s64`, `int/u64`)
+	Threads        bool // threads (`ev/thread`, `ev/do-thread`, ...)
+	DynamicModules bool // native modules loaded dynamically
+}
+
+// Build returns the build information of the embedded Janet and this binding,
+// for bug reports and feature detection at runtime.
+func Build() BuildInfo {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	features := C.getJanetBuildFeatures()
+	has := func(feature C.int) bool {
+		return features&feature != 0
+	}
+
+	return BuildInfo{
+		JanetVersion:   C.GoString(C.getJanetVersionString()),
+		JanetBuild:     C.GoString(C.getJanetBuildString()),
+		BindingVersion: bindingVersion(),
+
+		NaNBoxing:      has(C.janetFeatureNanbox),
+		EV:             has(C.janetFeatureEv),
+		FFI:            has(C.janetFeatureFfi),
+		Net:            has(C.janetFeatureNet),
+		Processes:      has(C.janetFeatureProcesses),
+		Assembler:      has(C.janetFeatureAssembler),
+		PEG:            has(C.janetFeaturePeg),
+		IntTypes:       has(C.janetFeatureIntTypes),
+		Threads:        has(C.janetFeatureThreads),
+		DynamicModules: has(C.janetFeatureDynamicModules),
+	}
+}
+
+// Features returns the names of enabled build features (eg. "ev", "ffi").
+func (b BuildInfo) Features() (features []string) {
+	for _, feature := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"nanbox", b.NaNBoxing},
+		{"ev", b.EV},
+		{"ffi", b.FFI},
+		{"net", b.Net},
+		{"processes", b.Processes},
+		{"assembler", b.Assembler},
+		{"peg", b.PEG},
+		{"int-types", b.IntTypes},
+		{"threads", b.Threads},
+		{"dynamic-modules", b.DynamicModules},
+	} {
+		if feature.enabled {
+			features = append(features, feature.name)
+		}
+	}
+	return features
+}
+
+// String returns the build information in a single line, eg.
+// "janet 1.40.1-34ab114 (janet-go v0.1.0) [nanbox ev ffi ...]".
+func (b BuildInfo) String() string {
+	str := fmt.Sprintf("janet %s-%s", b.JanetVersion, b.JanetBuild)
+	if b.BindingVersion != "" {
+		str += fmt.Sprintf(" (janet-go %s)", b.BindingVersion)
+	}
+	return str + " [" + strings.Join(b.Features(), " ") + "]"
+}
+
+// bindingVersion returns the module version of this binding from the build information of the binary.
+func bindingVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return ""
+}
+
 // shared VM
-var _sharedVM *VM
+var (
+	_sharedVM     *VM
+	_sharedVMLock sync.Mutex
+)
+
+// errors returned from VMs
+var (
+	ErrClosed    = errors.New("vm is closed")                  // returned when a closed VM is used
+	ErrTimeout   = errors.New("timed out")                     // returned (with context.DeadlineExceeded) when the deadline of a context is exceeded
+	ErrQueueFull = errors.New("too many requests are waiting") // returned when the queue of a VM created with WithQueueLimit is full
+	ErrVMCrashed = errors.New("vm crashed")                    // returned when the janet runtime fails fatally while handling a request (the VM continues with a new runtime)
+)
 
 // vmExecRequest is used to send a execution job to the VM handler goroutine.
 type vmExecRequest struct {
+	id           uint64 // for interrupting
+	enqueued     time.Time
+	ctx          context.Context
 	expression   string // janet expression
+	options      execOptions
 	responseChan chan vmExecResponse
 }
 
 // vmExecResponse is used to receive the execution result from the VM handler.
 type vmExecResponse struct {
-	evaluated string // evaluated janet expression
+	evaluated string // evaluated janet expression (or the payload of a raised signal)
+	typ       Type   // type of the evaluated value
+	signal    Signal // signal raised by the expression (a yield or a user signal, if any)
 	stdout    string
 	stderr    string
+	timing    Timing // time spent waiting for and running on the VM
 	err       error
 }
 
 // vmParseRequest is used to send a parse job to the VM handler goroutine.
 type vmParseRequest struct {
+	id           uint64 // for interrupting
+	enqueued     time.Time
+	ctx          context.Context
 	expression   string // janet expression
+	arena        *Arena // where the parsed value is allocated (see WithArena)
 	responseChan chan vmParseResponse
 }
 
@@ -99,170 +307,472 @@ type vmParseResponse struct {
 	err   error
 }
 
+// vmCallRequest is used to run a go function on the VM handler goroutine.
+type vmCallRequest struct {
+	id       uint64 // for interrupting
+	enqueued time.Time
+	ctx      context.Context         // context of the caller (nil for internal requests)
+	fn       func(env *C.JanetTable) // function to be run with the janet environment
+	fail     func(err error)         // called instead of returning from `fn` when the runtime crashes in it (nil for internal requests)
+}
+
 // VM represents a Janet virtual machine instance.
 type VM struct {
-	execChan     chan vmExecRequest  // for executing janet expression
-	parseChan    chan vmParseRequest // for parsing janet expression
+	queue        *requestQueue // requests to the VM handler goroutine
 	shutdownChan chan struct{}
+	options      vmOptions
+	closeOnce    sync.Once
 	wg           sync.WaitGroup
+
+	initOnce sync.Once // for initializing the runtime (on creation, or on the first use with WithLazyInit)
+	initErr  error     // error of the initialization
+
+	interrupter interrupter   // for interrupting requests when their contexts are done
+	requestIDs  atomic.Uint64 // for identifying requests to interrupt
+
+	env *C.JanetTable // environment of user codes, derived from the core environment (only accessed from the VM handler goroutine)
+
+	// janet functions used internally (only accessed from the VM handler goroutine)
+	pegCompiler    *C.JanetFunction
+	pegMatcher     *C.JanetFunction
+	bindingsLister *C.JanetFunction
+	docLookup      *C.JanetFunction
+	flychecker     *C.JanetFunction
+	jdnRenderer    *C.JanetFunction
+	applier        *C.JanetFunction
+	getter         *C.JanetFunction
+	debugInspector *C.JanetFunction
+
+	handles   map[*handle]struct{} // live value handles (only accessed from the VM handler goroutine)
+	liveRoots atomic.Int64         // number of roots held by live value handles
+
+	watchers   []*varWatcher   // watchers of vars (only accessed from the VM handler goroutine)
+	asyncFuncs []AsyncFunc     // async functions, indexed by their ids (only accessed from the VM handler goroutine)
+	requestCtx context.Context // context of the request being handled (only accessed from the VM handler goroutine)
+
+	recentSources []string // janet sources of recently handled requests, for incidents (only accessed from the VM handler goroutine)
+
+	subscriptions    subscriptions    // subscribers of values published by scripts
+	callbackHandlers callbackHandlers // handlers of callbacks handed off by scripts
+	results          *resultCache     // cached results of pure expressions (nil if not cached)
+
+	stats vmStats
 }
 
 // SharedVM initializes and returns a new shared Janet VM.
 // It starts a dedicated OS-thread-locked goroutine to handle all CGo calls
 // sequentially, ensuring thread safety.
-func SharedVM() (vm *VM, err error) {
-	if _sharedVM != nil {
+//
+// `opts` are applied only when the shared VM is newly created
+// (eg. on the first call, or after the shared VM is closed).
+// With WithLazyInit, the goroutine is started on the first use of the shared VM instead.
+func SharedVM(opts ...Option) (vm *VM, err error) {
+	_sharedVMLock.Lock()
+	defer _sharedVMLock.Unlock()
+
+	if _sharedVM != nil && !_sharedVM.closed() {
 		return _sharedVM, nil
 	}
 
-	initDone := make(chan error, 1)
+	if vm, err = NewVM(opts...); err != nil {
+		return nil, err
+	}
 
-	execChan := make(chan vmExecRequest)
-	parseChan := make(chan vmParseRequest)
-	shutdownChan := make(chan struct{})
+	_sharedVM = vm
+	return _sharedVM, nil
+}
+
+// NewVM initializes and returns a new Janet VM with given options,
+// independent of the shared one.
+// It starts a dedicated OS-thread-locked goroutine to handle all CGo calls
+// sequentially, ensuring thread safety.
+//
+// Returned VM should be closed with VM.Close when it is no longer needed.
+func NewVM(opts ...Option) (vm *VM, err error) {
+	options := vmOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
 
 	vm = &VM{
-		execChan:     execChan,
-		parseChan:    parseChan,
-		shutdownChan: shutdownChan,
+		queue:        newRequestQueue(),
+		shutdownChan: make(chan struct{}),
+		options:      options,
+		results:      newResultCache(options.resultCache),
 	}
+	vm.stats.started = time.Now()
+
+	if !options.lazy {
+		if err = vm.Preinit(); err != nil {
+			return nil, err
+		}
+	}
+	vm.publishExpvar()
+
+	return vm, nil
+}
+
+// Preinit initializes the runtime (the OS thread and janet environment) of the VM
+// if it is not initialized yet, and returns the error of the initialization, if any.
+//
+// It is useful for VMs created with WithLazyInit, which should fail on boot (eg. of a server)
+// rather than on their first use. Calling it on other VMs does nothing.
+func (vm *VM) Preinit() error {
+	vm.initOnce.Do(func() {
+		if vm.closed() {
+			vm.initErr = ErrClosed
+			return
+		}
+		vm.initErr = vm.start()
+	})
+	return vm.initErr
+}
+
+// start starts the VM handler goroutine and waits for the runtime to be initialized.
+func (vm *VM) start() error {
+	if err := <-vm.spawn(); err != nil {
+		vm.wg.Wait() // Ensure the goroutine has exited
+		return err
+	}
+
+	return nil
+}
+
+// spawn starts the VM handler goroutine with a new runtime,
+// and returns a channel which receives the error of the initialization (or is closed on success).
+func (vm *VM) spawn() <-chan error {
+	options := vm.options
+	queue, shutdownChan := vm.queue, vm.shutdownChan
+	initDone := make(chan error, 1)
+
 	vm.wg.Add(1)
 
 	// The dedicated VM handler goroutine
 	go func() {
 		runtime.LockOSThread()
-		defer runtime.UnlockOSThread()
+		isolated := false  // whether the thread is tainted and should not be reused
+		var crash *vmCrash // fatal failure of the runtime (if any)
+		defer func() {
+			if !isolated && crash == nil {
+				runtime.UnlockOSThread()
+			}
+		}()
 		defer vm.wg.Done()
+		initialized := false
+		defer func() {
+			if crash != nil && initialized {
+				vm.restart()
+			}
+		}()
+
+		if options.workdir != "" {
+			var err error
+			if isolated, err = enterWorkdir(options.workdir); err != nil {
+				initDone <- err
+				return
+			}
+		}
 
 		C.janet_init()
-		defer C.janet_deinit()
+		var release func() // for releasing resources of options
+		defer func() {
+			vm.interrupter.stop()
+			if crash == nil {
+				// (crashed runtimes are abandoned, as they may fail again while being deinitialized)
+				C.janet_deinit()
+				vm.subscriptions.close()
+			}
+			if release != nil {
+				release()
+			}
+		}()
+		defer func() {
+			// (crashes while handling requests are recovered in the main loop)
+			if r := recover(); r != nil {
+				c, ok := r.(*vmCrash)
+				if !ok {
+					panic(r)
+				}
+				crash = c
+				initDone <- c.err()
+			}
+		}()
 
-		env := C.janet_core_env(nil)
-		if env == nil {
+		core := C.janet_core_env(nil)
+		if core == nil {
 			initDone <- errors.New("failed to create janet environment")
 			return
 		}
+		var err error
+		if release, err = options.apply(core); err != nil {
+			initDone <- err
+			return
+		}
+		vm.interrupter.start(core)
+		stopDebugger := vm.startDebugger()
+		defer stopDebugger()
+		stopVerifier, err := vm.startModuleVerifier(core)
+		if err != nil {
+			initDone <- err
+			return
+		}
+		defer stopVerifier()
+		stopHost := vm.startHost(core)
+		defer stopHost()
+		stopClock, err := vm.startClock(core)
+		if err != nil {
+			initDone <- err
+			return
+		}
+		defer stopClock()
+		stopRandom, err := vm.startRandom(core)
+		if err != nil {
+			initDone <- err
+			return
+		}
+		defer stopRandom()
+		if options.httpClient != nil {
+			if err := vm.registerHTTP(core, options.httpClient, options.httpMaxBody); err != nil {
+				initDone <- err
+				return
+			}
+		}
+		vm.env = newEnv(core)
+		if err := options.evalPrelude(vm.env); err != nil {
+			initDone <- err
+			return
+		}
+		initialized = true
 		close(initDone) // Signal successful initialization
 
-		// Main loop to process requests
-		for {
+		// Main loop to process requests, in batches of the ones queued at each wakeup
+		var batch []vmRequest
+		for crash == nil {
 			select {
-			case req := <-execChan:
-				handleExecRequest(env, req)
-			case req := <-parseChan:
-				handleParseRequest(env, req)
+			case <-queue.wake:
+				batch = queue.take(batch[:0])
+				for i := range batch {
+					if vm.closed() {
+						// (requests already queued are not handled after the VM is closed)
+						vm.rejectRequests(batch[i:], ErrClosed)
+						break
+					}
+					if crash = vm.handleRequest(&batch[i]); crash != nil {
+						// (the rest are handled with the new runtime)
+						if !queue.requeue(batch[i+1:]) {
+							vm.rejectRequests(batch[i+1:], ErrClosed)
+						}
+						break
+					}
+				}
+				clear(batch)
 			case <-shutdownChan:
+				for len(vm.watchers) > 0 {
+					vm.removeWatcher(vm.watchers[0])
+				}
+				vm.rejectRequests(queue.close(), ErrClosed)
 				return
 			}
 		}
 	}()
 
-	// Wait for initialization to complete
-	err = <-initDone
-	if err != nil {
-		vm.wg.Wait() // Ensure the goroutine has exited
-		return nil, err
+	return initDone
+}
+
+// handleRequest handles a queued request, and returns the fatal failure of the runtime in it (if any).
+// Requests whose contexts are already done are not handled.
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) handleRequest(req *vmRequest) (crash *vmCrash) {
+	if ctx := req.context(); ctx != nil && ctx.Err() != nil {
+		req.pending(&vm.stats).Add(-1)
+		req.reject(contextError(ctx))
+		return nil
 	}
+	req.pending(&vm.stats).Add(-1)
 
-	_sharedVM = vm
-	return _sharedVM, nil
+	start := time.Now()
+	switch req.kind {
+	case requestExec:
+		exec := &req.exec
+		vm.recordSource(exec.expression)
+		if crash = vm.handle(exec.id, exec.ctx, func() {
+			vm.handleExecRequest(vm.env, *exec)
+		}); crash != nil {
+			err := crash.err()
+			vm.reportIncident(IncidentCrash, exec.expression, err)
+			exec.responseChan <- vmExecResponse{err: err}
+		}
+		vm.stats.record(start.Sub(exec.enqueued), time.Since(start))
+	case requestParse:
+		parse := &req.parse
+		vm.recordSource(parse.expression)
+		if crash = vm.handle(parse.id, parse.ctx, func() {
+			dec := vm.decoder(parse.ctx)
+			dec.arena = parse.arena
+			handleParseRequest(vm.env, *parse, dec)
+		}); crash != nil {
+			err := crash.err()
+			vm.reportIncident(IncidentCrash, parse.expression, err)
+			parse.responseChan <- vmParseResponse{err: err}
+		}
+		vm.stats.record(start.Sub(parse.enqueued), time.Since(start))
+	default:
+		call := &req.call
+		if crash = vm.handle(call.id, call.ctx, func() {
+			call.fn(vm.env)
+		}); crash != nil {
+			err := crash.err()
+			vm.reportIncident(IncidentCrash, "", err)
+			if call.fail != nil {
+				call.fail(err)
+			}
+		}
+		vm.stats.record(start.Sub(call.enqueued), time.Since(start))
+	}
+	if crash == nil {
+		vm.stats.sampleHeap()
+	}
+	return crash
+}
+
+// rejectRequests responds to queued `reqs` with `err` without handling them.
+func (vm *VM) rejectRequests(reqs []vmRequest, err error) {
+	for i := range reqs {
+		reqs[i].pending(&vm.stats).Add(-1)
+		reqs[i].reject(err)
+	}
+}
+
+// handle handles the request with `id` and `ctx` by running `fn`,
+// and returns the fatal failure of the runtime in it (if any), after which the runtime should not be used.
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) handle(id uint64, ctx context.Context, fn func()) (crash *vmCrash) {
+	vm.interrupter.begin(id)
+	vm.requestCtx = ctx
+	crash = recoverCrash(fn)
+	vm.requestCtx = nil
+	if crash != nil {
+		return crash
+	}
+	vm.interrupter.end()
+	vm.notifyWatchers(vm.env)
+	return nil
 }
 
 // handleExecRequest executes the janet expression within the dedicated VM thread.
 // This function should only be called from the VM handler goroutine.
-func handleExecRequest(
+func (vm *VM) handleExecRequest(
 	env *C.JanetTable,
 	req vmExecRequest,
 ) {
-	var janetResult C.Janet
-	var ret C.int
-
-	cCode := C.CString(req.expression)
-	defer C.free(unsafe.Pointer(cCode))
-
-	// create pipes for stdout and stderr
-	var stdoutPipe [2]C.int
-	var stderrPipe [2]C.int
-	if C.pipe(&stdoutPipe[0]) != 0 {
-		req.responseChan <- vmExecResponse{err: errors.New("failed to create stdout pipe")}
-		return
-	}
-	if C.pipe(&stderrPipe[0]) != 0 {
-		// close opened pipes that were opened above
-		C.close(stdoutPipe[0])
-		C.close(stdoutPipe[1])
-
-		req.responseChan <- vmExecResponse{err: errors.New("failed to create stderr pipe")}
-		return
+	start := time.Now()
+	res := vm.evaluate(env, req.expression, req.options)
+	res.timing = Timing{Wait: start.Sub(req.enqueued), Run: time.Since(start)}
+	if res.err != nil && req.ctx.Err() != nil {
+		vm.reportIncident(IncidentInterrupt, req.expression, res.err)
 	}
+	req.responseChan <- res
+}
 
-	// redirect stdout and stderr
-	originalStdoutFd := C.redirectStdout(stdoutPipe[1])
-	originalStderrFd := C.redirectStderr(stderrPipe[1])
+// evaluate executes `expression` in `env` and returns the result.
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) evaluate(
+	env *C.JanetTable,
+	expression string,
+	options execOptions,
+) vmExecResponse {
+	var janetResult C.Janet
+	var failure evalFailure
+	var ret C.int
 
 	// run janet code
-	ret = C.janet_dostring(env, cCode, nil, &janetResult)
-
-	// restore stdout and stderr
-	C.restoreStdout(originalStdoutFd)
-	C.restoreStderr(originalStderrFd)
-
-	// read all output from pipes
-	var outBuf, errBuf bytes.Buffer
-	buf := make([]byte, 1024)
-	for {
-		n, _ := C.read(stdoutPipe[0], unsafe.Pointer(&buf[0]), 1024)
-		if n <= 0 {
-			break
-		}
-		outBuf.Write(buf[:n])
+	var stdout, stderr string
+	if err := vm.withExecDyns(env, options, func() {
+		stdout, stderr = captureExecOutput(env, options, func() {
+			vm.checkLeaks(expression, func() {
+				ret = dobytesAt(env, expression, options.source, &janetResult, &failure)
+			})
+		})
+	}); err != nil {
+		return vmExecResponse{err: err}
 	}
-	for {
-		n, _ := C.read(stderrPipe[0], unsafe.Pointer(&buf[0]), 1024)
-		if n <= 0 {
-			break
-		}
-		errBuf.Write(buf[:n])
-	}
-	C.close(stdoutPipe[0])
-	C.close(stderrPipe[0])
 
 	// and return the result
-	if ret != C.JANET_SIGNAL_OK {
+	if signal := Signal(ret); signal != SignalOK && !signal.isValueSignal() {
 		var buffer C.JanetBuffer
 		C.janet_buffer_init(&buffer, 0)
 		C.janet_to_string_b(&buffer, janetResult)
 		errOutput := C.GoStringN((*C.char)(unsafe.Pointer(buffer.data)), C.int(buffer.count))
 		C.janet_buffer_deinit(&buffer)
-		req.responseChan <- vmExecResponse{
-			stdout: outBuf.String(),
-			stderr: errBuf.String(),
-			err:    errors.New(errOutput),
+		janetErr := newError(failure.fiber, janetResult, errOutput)
+		if options.errorHandle {
+			janetErr.Handle = vm.newHandle(janetResult, "")
 		}
-		return
+		return vmExecResponse{
+			stdout: stdout,
+			stderr: stderr,
+			err:    failure.wrap(janetErr),
+		}
+	}
+
+	var evaluated string
+	var err error
+	if options.result != nil {
+		err = vm.renderTo(env, janetResult, options.render, options.numbers, options.result)
+	} else {
+		evaluated, err = vm.render(env, janetResult, options.render, options.numbers)
 	}
+	return vmExecResponse{
+		evaluated: evaluated,
+		typ:       Type(C.janet_type(janetResult)),
+		signal:    Signal(ret),
+		stdout:    stdout,
+		stderr:    stderr,
+		err:       err,
+	}
+}
+
+// captureOutput runs `fn` with the outputs of janet codes in `env` redirected to buffers, and returns the outputs.
+//
+// Only the dynamic bindings of the VM are redirected, so outputs of other VMs and go are not affected
+// (outputs written directly to files, eg. with `(file/write stdout ...)`, and errors of fibers
+// printed by the event loop of janet, eg. the ones spawned with `ev/go`, are not captured).
+// This function should only be called from the VM handler goroutine.
+func captureOutput(env *C.JanetTable, fn func()) (stdout, stderr string) {
+	// buffers are reachable from the bindings while capturing
+	out, err := C.janet_buffer(0), C.janet_buffer(0)
+
+	var prev C.Outputs
+	C.captureOutputs(env, out, err, &prev)
+	fn()
+	C.restoreOutputs(env, &prev)
+
+	return C.GoStringN((*C.char)(unsafe.Pointer(out.data)), C.int(out.count)),
+		C.GoStringN((*C.char)(unsafe.Pointer(err.data)), C.int(err.count))
+}
 
-	req.responseChan <- vmExecResponse{
-		evaluated: janetValueToString(janetResult),
-		stdout:    outBuf.String(),
-		stderr:    errBuf.String(),
-		err:       nil,
+// captureExecOutput runs `fn` with outputs captured with captureOutput, unless capturing is skipped in `options`.
+// This function should only be called from the VM handler goroutine.
+func captureExecOutput(env *C.JanetTable, options execOptions, fn func()) (stdout, stderr string) {
+	if options.noCapture {
+		fn()
+		return "", ""
 	}
+	return captureOutput(env, fn)
 }
 
 // handleParseRequest parses the janet string within the dedicated VM thread.
 func handleParseRequest(
 	env *C.JanetTable,
 	req vmParseRequest,
+	dec *decoder,
 ) {
 	var janetResult C.Janet
+	var failure evalFailure
 	var ret C.int
 
-	cCode := C.CString(req.expression)
-	defer C.free(unsafe.Pointer(cCode))
-
 	// run janet code
-	ret = C.janet_dostring(env, cCode, nil, &janetResult)
+	ret = dobytes(env, req.expression, &janetResult, &failure)
 
 	if ret != C.JANET_SIGNAL_OK {
 		var buffer C.JanetBuffer
@@ -271,28 +781,174 @@ func handleParseRequest(
 		errOutput := C.GoStringN((*C.char)(unsafe.Pointer(buffer.data)), C.int(buffer.count))
 		C.janet_buffer_deinit(&buffer)
 		req.responseChan <- vmParseResponse{
-			err: errors.New(errOutput),
+			err: failure.wrap(newError(failure.fiber, janetResult, errOutput)),
 		}
 		return
 	}
 
+	value, err := dec.decode(janetResult)
 	req.responseChan <- vmParseResponse{
-		value: parseJanetValueToGo(janetResult),
-		err:   nil,
+		value: value,
+		err:   err,
+	}
+}
+
+// janet source of the helper function which lists all bindings in the environment.
+const bindingsListerSource = `(fn [] (sort (map string (all-bindings))))`
+
+// janet source of the helper function which looks up the docstring of a binding.
+const docLookupSource = `(fn [name]
+  (def binding (get (curenv) (symbol name)))
+  (when (dictionary? binding) (get binding :doc)))`
+
+// enqueue counts a request waiting for the VM handler goroutine with `pending`,
+// or returns ErrQueueFull if the VM is created with WithQueueLimit and the number of waiting requests reaches it.
+//
+// VMs created with WithLazyInit are initialized here on their first use, and the error of the initialization is returned if it fails.
+func (vm *VM) enqueue(pending *atomic.Int64) error {
+	if err := vm.Preinit(); err != nil {
+		return err
+	}
+	pending.Add(1)
+	if limit := vm.options.queueLimit; limit > 0 && vm.stats.pending() > int64(limit) {
+		pending.Add(-1)
+		return ErrQueueFull
+	}
+	return nil
+}
+
+// runOnVM runs `fn` on the VM handler goroutine and returns its result.
+func runOnVM[T any](
+	ctx context.Context,
+	vm *VM,
+	fn func(env *C.JanetTable) T,
+) (
+	result T,
+	err error,
+) {
+	responseChan := make(chan T, 1)
+	failChan := make(chan error, 1)
+	req := vmCallRequest{
+		id:       vm.requestIDs.Add(1),
+		enqueued: time.Now(),
+		ctx:      ctx,
+		fn: func(env *C.JanetTable) {
+			responseChan <- fn(env)
+		},
+		fail: func(err error) {
+			failChan <- err
+		},
+	}
+
+	if err := vm.enqueue(&vm.stats.pendingCall); err != nil {
+		return result, err
+	}
+	if err := vm.queue.push(vmRequest{kind: requestCall, call: req}); err != nil {
+		vm.stats.pendingCall.Add(-1)
+		return result, err
+	}
+
+	select {
+	case result = <-responseChan:
+		return result, nil
+	case err = <-failChan:
+		return result, err
+	case <-ctx.Done():
+		vm.interrupter.interrupt(req.id)
+		return result, contextError(ctx)
+	}
+}
+
+// compileHelper evaluates `source` (which should evaluate to a janet function)
+// and returns the function, rooted so that it is not garbage collected.
+// This function should only be called from the VM handler goroutine.
+func compileHelper(
+	env *C.JanetTable,
+	source string,
+) (*C.JanetFunction, error) {
+	var janetResult C.Janet
+
+	if ret := dobytes(env, source, &janetResult, nil); ret != C.JANET_SIGNAL_OK {
+		return nil, errors.New(janetValueToString(janetResult))
+	}
+	if C.janet_checktype(janetResult, C.JANET_FUNCTION) == 0 {
+		return nil, errors.New("helper source did not evaluate to a function")
+	}
+	C.janet_gcroot(janetResult)
+
+	return C.janet_unwrap_function(janetResult), nil
+}
+
+// pcall calls the janet function `fn` with `args` in a new fiber
+// (with `env` as its environment) and returns its result, after the fiber finishes in the event loop if suspended.
+//
+// It panics with *vmCrash if the janet runtime fails fatally (see recoverCrash).
+// This function should only be called from the VM handler goroutine.
+func pcall(
+	env *C.JanetTable,
+	fn *C.JanetFunction,
+	args ...C.Janet,
+) (C.Janet, error) {
+	var janetResult C.Janet
+
+	var argv *C.Janet
+	if len(args) > 0 {
+		argv = &args[0]
 	}
+	fiber := C.janet_fiber(fn, 64, C.int32_t(len(args)), argv)
+	if fiber == nil {
+		return C.janet_wrap_nil(), errors.New("arity mismatch")
+	}
+	fiber.env = env
+
+	signal := C.callFiber(fiber, &janetResult)
+	checkCrash()
+	if signal != C.JANET_SIGNAL_OK {
+		return janetResult, &RuntimeError{Err: newError(fiber, janetResult, janetValueToString(janetResult))}
+	}
+	return janetResult, nil
+}
+
+// janetString creates a janet string from a go string.
+func janetString(str string) C.Janet {
+	return C.janet_wrap_string(C.janet_string((*C.uint8_t)(unsafe.Pointer(unsafe.StringData(str))), C.int32_t(len(str))))
+}
+
+// janetKeyword creates a janet keyword from a go string (without the leading `:`).
+func janetKeyword(str string) C.Janet {
+	return C.janet_wrap_keyword(C.janet_symbol((*C.uint8_t)(unsafe.Pointer(unsafe.StringData(str))), C.int32_t(len(str))))
 }
 
 // Close deinitializes the Janet VM.
+//
+// It is safe to call Close multiple times, or concurrently with other calls.
+// Requests already being handled are completed, and any other calls fail with ErrClosed.
 func (vm *VM) Close() {
-	if _sharedVM != nil {
+	vm.closeOnce.Do(func() {
 		close(vm.shutdownChan)
-		vm.wg.Wait()
-		_sharedVM = nil
+	})
+	vm.wg.Wait()
+	vm.unpublishExpvar()
+}
+
+// closed returns whether the VM is closed.
+func (vm *VM) closed() bool {
+	select {
+	case <-vm.shutdownChan:
+		return true
+	default:
+		return false
 	}
 }
 
 // janetValueToString converts a Janet value to its string representation.
 func janetValueToString(value C.Janet) string {
+	return janetValueToFormattedString(value, nil)
+}
+
+// janetValueToFormattedString converts a Janet value to its string representation,
+// with numbers formatted in `numbers` (janet's default format if nil).
+func janetValueToFormattedString(value C.Janet, numbers *NumberFormat) string {
 	switch C.janet_type(value) {
 	case C.JANET_NIL:
 		return "nil"
@@ -302,6 +958,9 @@ func janetValueToString(value C.Janet) string {
 		}
 		return "false"
 	case C.JANET_NUMBER:
+		if numbers != nil {
+			return numbers.format(float64(C.janet_unwrap_number(value)))
+		}
 		var buffer C.JanetBuffer
 		C.janet_buffer_init(&buffer, 0)
 		C.janet_to_string_b(&buffer, value)
@@ -309,11 +968,11 @@ func janetValueToString(value C.Janet) string {
 		C.janet_buffer_deinit(&buffer)
 		return output
 	case C.JANET_STRING:
-		return C.GoString((*C.char)(unsafe.Pointer(C.janet_unwrap_string(value))))
+		return janetStringToGo(C.janet_unwrap_string(value))
 	case C.JANET_SYMBOL:
-		return C.GoString((*C.char)(unsafe.Pointer(C.janet_unwrap_symbol(value))))
+		return janetStringToGo(C.janet_unwrap_symbol(value))
 	case C.JANET_KEYWORD:
-		return ":" + C.GoString((*C.char)(unsafe.Pointer(C.janet_unwrap_keyword(value))))
+		return ":" + janetStringToGo(C.janet_unwrap_keyword(value))
 	case C.JANET_TUPLE:
 		var data *C.Janet
 		var length C.int32_t
@@ -322,7 +981,7 @@ func janetValueToString(value C.Janet) string {
 		var result string
 		for i := C.int32_t(0); i < length; i++ {
 			elem := *(*C.Janet)(unsafe.Pointer(uintptr(unsafe.Pointer(data)) + uintptr(i)*unsafe.Sizeof(*data)))
-			result += janetValueToString(elem)
+			result += janetValueToFormattedString(elem, numbers)
 			if i < length-1 {
 				result += " "
 			}
@@ -338,118 +997,215 @@ func janetValueToString(value C.Janet) string {
 	}
 }
 
-// parseJanetValueToGo converts a Janet value to its Go value.
-func parseJanetValueToGo(value C.Janet) any {
-	switch C.janet_type(value) {
-	case C.JANET_NIL:
-		return nil
-	case C.JANET_BOOLEAN:
-		return C.janet_unwrap_boolean(value) != 0
-	case C.JANET_NUMBER:
-		return float64(C.janet_unwrap_number(value))
-	case C.JANET_STRING:
-		return C.GoString((*C.char)(unsafe.Pointer(C.janet_unwrap_string(value))))
-	case C.JANET_SYMBOL:
-		return C.GoString((*C.char)(unsafe.Pointer(C.janet_unwrap_symbol(value))))
-	case C.JANET_KEYWORD:
-		return ":" + C.GoString((*C.char)(unsafe.Pointer(C.janet_unwrap_keyword(value))))
-	case C.JANET_TUPLE, C.JANET_ARRAY:
-		var data *C.Janet
-		var length C.int32_t
-		C.janet_indexed_view(value, &data, &length)
-		slice := make([]any, length)
-		for i := C.int32_t(0); i < length; i++ {
-			elem := *(*C.Janet)(unsafe.Pointer(uintptr(unsafe.Pointer(data)) + uintptr(i)*unsafe.Sizeof(*data)))
-			slice[i] = parseJanetValueToGo(elem)
-		}
-		return slice
-	case C.JANET_TABLE:
-		table := C.janet_unwrap_table(value)
-		result := make(map[any]any)
-		for i := C.int32_t(0); i < table.capacity; i++ {
-			currentKV := (*C.JanetKV)(unsafe.Pointer(uintptr(unsafe.Pointer(table.data)) + uintptr(i)*unsafe.Sizeof(*table.data)))
-			if C.janet_checktype(currentKV.key, C.JANET_NIL) == 0 {
-				key := parseJanetValueToGo(currentKV.key)
-				val := parseJanetValueToGo(currentKV.value)
-				result[key] = val
-			}
-		}
-		return result
-	case C.JANET_STRUCT:
-		kv := C.janet_unwrap_struct(value)
-		length := C.janet_struct_len(kv)
-		result := make(map[any]any)
-		for i := range length {
-			currentKV := (*C.JanetKV)(unsafe.Pointer(uintptr(unsafe.Pointer(kv)) + uintptr(i)*unsafe.Sizeof(*kv)))
-			if C.janet_checktype(currentKV.key, C.JANET_NIL) == 0 {
-				key := parseJanetValueToGo(currentKV.key)
-				val := parseJanetValueToGo(currentKV.value)
-				result[key] = val
-			}
-		}
-		return result
-	default:
-		// For other complex types, fallback to string representation
-		return janetValueToString(value)
-	}
-}
-
 // Execute executes a `janetExpression` and returns the evaluated result, along with any output to stdout and stderr.
+//
+// When a top-level form raises a yield or a user signal (eg. `(yield 1)`), the execution stops there
+// and a *RaisedSignal is returned with the rendered payload as the evaluated result.
 func (vm *VM) Execute(
 	ctx context.Context,
 	janetExpression string,
+	opts ...ExecOption,
+) (
+	evaluated string,
+	stdout string,
+	stderr string,
+	err error,
+) {
+	res, err := vm.execute(ctx, janetExpression, opts)
+	if err != nil {
+		return "", "", "", err
+	}
+	if res.err == nil && res.signal != SignalOK {
+		return res.evaluated, res.stdout, res.stderr, &RaisedSignal{Signal: res.signal, Value: res.evaluated}
+	}
+	return res.evaluated, res.stdout, res.stderr, res.err
+}
+
+// ExecuteBytes executes janet source `src` in the same way as Execute, without copying it.
+//
+// `src` should not be modified while it is being executed (even after `ctx` is done).
+func (vm *VM) ExecuteBytes(
+	ctx context.Context,
+	src []byte,
+	opts ...ExecOption,
 ) (
 	evaluated string,
 	stdout string,
 	stderr string,
 	err error,
 ) {
-	responseChan := make(chan vmExecResponse, 1)
+	return vm.Execute(ctx, unsafe.String(unsafe.SliceData(src), len(src)), opts...)
+}
+
+// execute sends an execution request to the VM handler and waits for its response.
+func (vm *VM) execute(
+	ctx context.Context,
+	janetExpression string,
+	opts []ExecOption,
+) (vmExecResponse, error) {
+	options := newExecOptions(opts)
+	key, cacheable := vm.results.key(janetExpression, options)
+	if cacheable {
+		if res, ok := vm.results.get(key); ok {
+			vm.stats.cacheHits.Add(1)
+			vm.stats.recordExecution(nil)
+			return res, nil
+		}
+		vm.stats.cacheMisses.Add(1)
+	}
+
+	responseChan := execResponseChans.get()
 	req := vmExecRequest{
+		id:           vm.requestIDs.Add(1),
+		enqueued:     time.Now(),
+		ctx:          ctx,
 		expression:   janetExpression,
+		options:      options,
 		responseChan: responseChan,
 	}
 
-	select {
-	case vm.execChan <- req:
-		// request sent
-	case <-ctx.Done():
-		return "", "", "", ctx.Err()
+	if err := vm.enqueue(&vm.stats.pendingExec); err != nil {
+		execResponseChans.put(responseChan)
+		return vmExecResponse{}, err
+	}
+	if err := vm.queue.push(vmRequest{kind: requestExec, exec: req}); err != nil {
+		vm.stats.pendingExec.Add(-1)
+		execResponseChans.put(responseChan)
+		return vmExecResponse{}, err
 	}
 
 	select {
 	case res := <-responseChan:
-		return res.evaluated, res.stdout, res.stderr, res.err
+		execResponseChans.put(responseChan)
+		if cacheable {
+			vm.results.put(key, res)
+		}
+		vm.stats.recordExecution(res.err)
+		return res, nil
 	case <-ctx.Done():
-		return "", "", "", ctx.Err()
+		vm.interrupter.interrupt(req.id)
+		vm.stats.recordExecution(ctx.Err())
+		return vmExecResponse{}, contextError(ctx)
 	}
 }
 
 // ParseToValue parses a `janetExpression` containing janet data into a Go value.
+//
+// Only WithArena of `opts` is applied, for converting the value with the memory of an arena.
 func (vm *VM) ParseToValue(
 	ctx context.Context,
 	janetExpression string,
+	opts ...ExecOption,
 ) (
 	value any,
 	err error,
 ) {
-	responseChan := make(chan vmParseResponse, 1)
+	responseChan := parseResponseChans.get()
 	req := vmParseRequest{
+		id:           vm.requestIDs.Add(1),
+		enqueued:     time.Now(),
+		ctx:          ctx,
 		expression:   janetExpression,
+		arena:        newExecOptions(opts).arena,
 		responseChan: responseChan,
 	}
 
-	select {
-	case vm.parseChan <- req:
-		// request sent
-	case <-ctx.Done():
-		return nil, ctx.Err()
+	if err := vm.enqueue(&vm.stats.pendingParse); err != nil {
+		parseResponseChans.put(responseChan)
+		return nil, err
+	}
+	if err := vm.queue.push(vmRequest{kind: requestParse, parse: req}); err != nil {
+		vm.stats.pendingParse.Add(-1)
+		parseResponseChans.put(responseChan)
+		return nil, err
 	}
 
 	select {
 	case res := <-responseChan:
+		parseResponseChans.put(responseChan)
 		return res.value, res.err
 	case <-ctx.Done():
-		return nil, ctx.Err()
+		vm.interrupter.interrupt(req.id)
+		return nil, contextError(ctx)
+	}
+}
+
+// bindingsResult is used to receive the names of bindings from the VM handler.
+type bindingsResult struct {
+	names []string
+	err   error
+}
+
+// Bindings returns the sorted names of all bindings (core ones and user definitions) in the VM's environment.
+func (vm *VM) Bindings(ctx context.Context) (names []string, err error) {
+	res, err := runOnVM(ctx, vm, func(env *C.JanetTable) bindingsResult {
+		if vm.bindingsLister == nil {
+			helper, err := compileHelper(env, bindingsListerSource)
+			if err != nil {
+				return bindingsResult{err: err}
+			}
+			vm.bindingsLister = helper
+		}
+
+		listed, err := pcall(env, vm.bindingsLister)
+		if err != nil {
+			return bindingsResult{err: err}
+		}
+
+		elems := janetIndexed(listed)
+		names := make([]string, 0, len(elems))
+		for _, elem := range elems {
+			names = append(names, janetValueToString(elem))
+		}
+		return bindingsResult{names: names}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.names, res.err
+}
+
+// docResult is used to receive the docstring from the VM handler.
+type docResult struct {
+	doc string
+	err error
+}
+
+// Doc returns the docstring of the binding named `symbol` in the VM's environment.
+//
+// It returns an empty string if there is no such binding or it has no docstring.
+func (vm *VM) Doc(ctx context.Context, symbol string) (doc string, err error) {
+	res, err := runOnVM(ctx, vm, func(env *C.JanetTable) docResult {
+		if vm.docLookup == nil {
+			helper, err := compileHelper(env, docLookupSource)
+			if err != nil {
+				return docResult{err: err}
+			}
+			vm.docLookup = helper
+		}
+
+		looked, err := pcall(env, vm.docLookup, janetString(symbol))
+		if err != nil {
+			return docResult{err: err}
+		}
+		if C.janet_checktype(looked, C.JANET_STRING) == 0 {
+			return docResult{}
+		}
+		return docResult{doc: janetValueToString(looked)}
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return res.doc, res.err
+}
+
+// requestContext returns the context of the request being handled (context.Background if none).
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) requestContext() context.Context {
+	if vm.requestCtx == nil {
+		return context.Background()
 	}
+	return vm.requestCtx
 }
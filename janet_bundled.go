@@ -0,0 +1,202 @@
+// janet_bundled.go
+
+//go:build !system_janet
+
+package janet
+
+// Subsystems of the bundled Janet can be excluded from the binary at compile time
+// with build tags (not applied with `system_janet`):
+//
+//   - janet_no_ev: event loop (`ev/*`, and also `net/*` and threads which depend on it)
+//   - janet_no_net: networking (`net/*`)
+//   - janet_no_processes: subprocesses (`os/spawn`, `os/execute`, ...)
+//   - janet_no_filewatch: file watching (`filewatch/*`)
+//   - janet_no_assembler: assembler (`asm`, `disasm`)
+//   - janet_no_peg: PEG (`peg/*`)
+//   - janet_no_int_types: 64-bit integer types (`int/*`)
+//   - janet_no_dynamic_modules: loading native modules dynamically
+//   - janet_no_docstrings: docstrings
+//   - janet_no_sourcemaps: source maps
+//
+// FFI (`ffi/*`) is excluded by default, and can be included with `janet_ffi` build tag
+// (it should also be enabled for each VM with WithFFI).
+//
+// Enabled features can be checked at runtime with Build.
+
+/*
+#cgo CFLAGS: -I${SRCDIR}/amalgamated
+#cgo !janet_ffi CFLAGS: -DJANET_NO_FFI
+#cgo janet_no_ev CFLAGS: -DJANET_NO_EV
+#cgo janet_no_net CFLAGS: -DJANET_NO_NET
+#cgo janet_no_processes CFLAGS: -DJANET_NO_PROCESSES
+#cgo janet_no_filewatch CFLAGS: -DJANET_NO_FILEWATCH
+#cgo janet_no_assembler CFLAGS: -DJANET_NO_ASSEMBLER
+#cgo janet_no_peg CFLAGS: -DJANET_NO_PEG
+#cgo janet_no_int_types CFLAGS: -DJANET_NO_INT_TYPES
+#cgo janet_no_dynamic_modules CFLAGS: -DJANET_NO_DYNAMIC_MODULES
+#cgo janet_no_docstrings CFLAGS: -DJANET_NO_DOCSTRINGS
+#cgo janet_no_sourcemaps CFLAGS: -DJANET_NO_SOURCEMAPS
+// fatal errors of janet (eg. failed assertions) are handled by `janetCrash` instead of aborting the process
+static _Noreturn void janetCrash(const char *message);
+#define JANET_EXIT(m) janetCrash(m)
+#define JANET_TOP_LEVEL_SIGNAL(m) janetCrash(m)
+
+#include "amalgamated/janet.c"
+
+// where fatal errors of janet jump to on the current thread (NULL for aborting the process)
+static _Thread_local jmp_buf *crashGuard = NULL;
+
+// message of the fatal error of janet on the current thread (empty if none)
+static _Thread_local char crashMessage[256];
+
+static _Noreturn void janetCrash(const char *message) {
+    if (crashGuard == NULL) {
+        fprintf(stderr, "janet internal error: %s\n", message);
+        abort();
+    }
+    snprintf(crashMessage, sizeof(crashMessage), "%s", message[0] != '\0' ? message : "unknown error");
+    longjmp(*crashGuard, 1);
+}
+
+void *setCrashGuard(void *guard) {
+    void *prev = crashGuard;
+    crashGuard = guard;
+    return prev;
+}
+
+const char *getCrashMessage() {
+    return crashMessage[0] != '\0' ? crashMessage : NULL;
+}
+
+const char *getJanetBuildString() {
+    return JANET_BUILD;
+}
+
+int getJanetGCCounters(size_t *allocated, size_t *blocks) {
+    *allocated = janet_vm.next_collection;
+    *blocks = janet_vm.block_count;
+    return 1;
+}
+
+int getCFunctionInfo(JanetCFunction cfun, const char **prefix, const char **name, const char **file, int32_t *line) {
+    JanetCFunRegistry *reg = janet_registry_get(cfun);
+    if (reg == NULL || reg->name == NULL) return 0;
+    *prefix = reg->name_prefix;
+    *name = reg->name;
+    *file = reg->source_file;
+    *line = reg->source_line;
+    return 1;
+}
+
+// marker of the heap for checking resources left open (see leaks.go),
+// with the tasks of the event loop which were active when it was created
+typedef struct {
+    JanetTable *tasks;
+} LeakMarker;
+
+static int leakMarkerMark(void *data, size_t len) {
+    (void) len;
+    LeakMarker *marker = (LeakMarker *)data;
+    if (marker->tasks != NULL) {
+        janet_mark(janet_wrap_table(marker->tasks));
+    }
+    return 0;
+}
+
+static const JanetAbstractType leakMarkerType = {
+    "go/leak-marker",
+    NULL,
+    leakMarkerMark,
+    JANET_ATEND_GCMARK
+};
+
+void *beginLeakCheck() {
+    // objects allocated later are prepended to the heap before the marker
+    LeakMarker *marker = janet_abstract(&leakMarkerType, sizeof(LeakMarker));
+    marker->tasks = NULL;
+    janet_gcroot(janet_wrap_abstract(marker));
+    JanetTable *tasks = janet_table(0);
+#ifdef JANET_EV
+    for (int32_t i = 0; i < janet_vm.active_tasks.capacity; i++) {
+        if (janet_checktype(janet_vm.active_tasks.data[i].key, JANET_FIBER)) {
+            janet_table_put(tasks, janet_vm.active_tasks.data[i].key, janet_wrap_true());
+        }
+    }
+#endif
+    marker->tasks = tasks;
+    return marker;
+}
+
+JanetArray *endLeakCheck(void *marker) {
+    JanetArray *leaks = janet_array(0);
+    JanetGCObject *end = &janet_abstract_head(marker)->gc;
+    for (JanetGCObject *obj = janet_vm.blocks; obj != NULL && obj != end; obj = obj->data.next) {
+        if ((obj->flags & JANET_MEM_TYPEBITS) != JANET_MEMORY_ABSTRACT) continue;
+        JanetAbstractHead *head = (JanetAbstractHead *)obj;
+        if (head->type == &janet_file_type) {
+            JanetFile *file = (JanetFile *)head->data;
+            if (!(file->flags & (JANET_FILE_CLOSED | JANET_FILE_NOT_CLOSEABLE))) {
+                janet_array_push(leaks, janet_wrap_abstract(head->data));
+            }
+        }
+#ifdef JANET_EV
+        else if (head->type == &janet_stream_type) {
+            JanetStream *stream = (JanetStream *)head->data;
+            if (!(stream->flags & JANET_STREAM_CLOSED)) {
+                janet_array_push(leaks, janet_wrap_abstract(head->data));
+            }
+        }
+#endif
+    }
+#ifdef JANET_EV
+    JanetTable *tasks = ((LeakMarker *)marker)->tasks;
+    for (int32_t i = 0; i < janet_vm.active_tasks.capacity; i++) {
+        Janet task = janet_vm.active_tasks.data[i].key;
+        if (janet_checktype(task, JANET_FIBER) && janet_checktype(janet_table_get(tasks, task), JANET_NIL)) {
+            janet_array_push(leaks, task);
+        }
+    }
+#endif
+    janet_gcunroot(janet_wrap_abstract(marker));
+    return leaks;
+}
+
+int cancelJanetFibers(Janet reason) {
+#ifdef JANET_EV
+    // collect fibers first, as cancelling them modifies the tasks table
+    JanetArray *fibers = janet_array(0);
+    // fibers waiting for timeouts (eg. root fibers sleeping with ev/sleep, which are not tracked as tasks)
+    for (size_t i = 0; i < janet_vm.tq_count; i++) {
+        JanetTimeout to = janet_vm.tq[i];
+        if (to.fiber != NULL && to.sched_id == to.fiber->sched_id) {
+            janet_array_push(fibers, janet_wrap_fiber(to.fiber));
+        }
+    }
+    for (int32_t i = 0; i < janet_vm.active_tasks.capacity; i++) {
+        if (janet_checktype(janet_vm.active_tasks.data[i].key, JANET_FIBER)) {
+            janet_array_push(fibers, janet_vm.active_tasks.data[i].key);
+        }
+    }
+    for (int32_t i = 0; i < fibers->count; i++) {
+        JanetFiber *fiber = janet_unwrap_fiber(fibers->data[i]);
+        if (janet_fiber_can_resume(fiber)) {
+            janet_cancel(fiber, reason);
+        }
+    }
+    return 1;
+#else
+    (void) reason;
+    return 0;
+#endif
+}
+*/
+import "C"
+
+// whether fibers waiting in the event loop can be cancelled when interrupted
+const cancelsEventFibers = true
+
+// whether fatal errors of the runtime are recovered (see crash.go)
+const recoversCrashes = true
+
+// whether resources left open by executions are checked (see leaks.go)
+const checksLeaks = true
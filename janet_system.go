@@ -0,0 +1,63 @@
+// janet_system.go
+
+//go:build system_janet
+
+package janet
+
+/*
+#cgo pkg-config: janet
+#include "janet.h"
+
+const char *getJanetBuildString() {
+    return JANET_BUILD;
+}
+
+// counters of the gc are internal to libjanet
+int getJanetGCCounters(size_t *allocated, size_t *blocks) {
+    *allocated = 0;
+    *blocks = 0;
+    return 0;
+}
+
+// registry of c functions is internal to libjanet
+int getCFunctionInfo(JanetCFunction cfun, const char **prefix, const char **name, const char **file, int32_t *line) {
+    (void) cfun; (void) prefix; (void) name; (void) file; (void) line;
+    return 0;
+}
+
+// fatal errors of libjanet cannot be handled, so they abort the process
+void *setCrashGuard(void *guard) {
+    (void) guard;
+    return NULL;
+}
+
+const char *getCrashMessage() {
+    return NULL;
+}
+
+// heap and tasks of the event loop are internal to libjanet, so leaks are not checked
+void *beginLeakCheck() {
+    return NULL;
+}
+
+JanetArray *endLeakCheck(void *marker) {
+    (void) marker;
+    return janet_array(0);
+}
+
+// fibers of the event loop are internal to libjanet
+int cancelJanetFibers(Janet reason) {
+    (void) reason;
+    return 0;
+}
+*/
+import "C"
+
+// whether fibers waiting in the event loop can be cancelled when interrupted
+const cancelsEventFibers = false
+
+// whether fatal errors of the runtime are recovered (see crash.go)
+const recoversCrashes = false
+
+// whether resources left open by executions are checked (see leaks.go)
+const checksLeaks = false
@@ -0,0 +1,133 @@
+// fetch.go
+
+package janetdeps
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Fetcher fetches the files of dependencies.
+type Fetcher interface {
+	// Fetch fetches the files of `dep` into the directory `dst`, which exists and is empty.
+	Fetch(ctx context.Context, dep Dependency, dst string) error
+}
+
+// HTTPFetcher fetches dependencies as gzipped tarballs over http, in pure go (without git).
+type HTTPFetcher struct {
+	Client *http.Client // http client (http.DefaultClient if nil)
+
+	// ArchiveURL returns the url of the tarball for a dependency (DefaultArchiveURL if nil)
+	ArchiveURL func(dep Dependency) (string, error)
+}
+
+// DefaultArchiveURL returns the url of the tarball for `dep` hosted on GitHub-like services,
+// eg. "https://github.com/janet-lang/spork/archive/v1.0.0.tar.gz" for
+// `{:url "https://github.com/janet-lang/spork.git" :tag "v1.0.0"}`.
+func DefaultArchiveURL(dep Dependency) (string, error) {
+	u, err := url.Parse(dep.URL)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported url of dependency: %s", dep.URL)
+	}
+
+	ref := dep.Tag
+	if ref == "" {
+		ref = "HEAD"
+	}
+	u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), ".git") + "/archive/" + url.PathEscape(ref) + ".tar.gz"
+	return u.String(), nil
+}
+
+// Fetch downloads the tarball of `dep` and extracts it into `dst`,
+// stripping the top-level directory of the archive.
+func (f *HTTPFetcher) Fetch(ctx context.Context, dep Dependency, dst string) error {
+	archiveURL := f.ArchiveURL
+	if archiveURL == nil {
+		archiveURL = DefaultArchiveURL
+	}
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	u, err := archiveURL(dep)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch %s: %s", u, res.Status)
+	}
+
+	gz, err := gzip.NewReader(res.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read archive %s: %w", u, err)
+	}
+	defer gz.Close()
+
+	return extractTar(tar.NewReader(gz), dst)
+}
+
+// extractTar extracts regular files and directories from `reader` into `dst`,
+// stripping the top-level directory.
+func extractTar(reader *tar.Reader, dst string) error {
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		_, name, ok := strings.Cut(header.Name, "/")
+		if !ok || name == "" {
+			continue
+		}
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("unsafe path in archive: %s", header.Name)
+		}
+		path := filepath.Join(dst, filepath.FromSlash(name))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				return err
+			}
+			file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(file, reader)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
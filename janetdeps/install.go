@@ -0,0 +1,190 @@
+// install.go
+
+package janetdeps
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/meinside/janet-go"
+)
+
+// default max duration of reading `project.janet` of each dependency
+const defaultReadTimeout = 10 * time.Second
+
+// Installer installs dependencies into a vendor directory.
+type Installer struct {
+	VendorDir   string        // directory where sources of dependencies are installed
+	Fetcher     Fetcher       // fetcher of dependencies (HTTPFetcher if nil)
+	ReadTimeout time.Duration // max duration of reading `project.janet` of each fetched dependency (default: 10s)
+}
+
+// Installed is a dependency installed into the vendor directory.
+type Installed struct {
+	Dependency
+	Name    string   // name of the dependency's project
+	Files   []string // installed files, relative to the vendor directory (in slash-separated form)
+	Natives []string // names of native modules in the dependency, which were not built
+}
+
+// Install fetches `deps` and their dependencies, and installs their sources
+// (declared with `declare-source` in their `project.janet`) into the vendor directory, in the same layout as jpm.
+//
+// Each repository is installed only once, and the ones earlier in `deps` take precedence over
+// the ones found later, so dependencies read with ReadLockfile are installed as they are locked
+// (and their installed files are checked against the content hashes in the lockfile).
+func (i *Installer) Install(ctx context.Context, deps []Dependency) (installed []Installed, err error) {
+	fetcher := i.Fetcher
+	if fetcher == nil {
+		fetcher = &HTTPFetcher{}
+	}
+	if err := os.MkdirAll(i.VendorDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	queue := append([]Dependency{}, deps...)
+	for len(queue) > 0 {
+		dep := queue[0]
+		queue = queue[1:]
+
+		key := repositoryKey(dep.URL)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		project, inst, err := i.install(ctx, fetcher, dep)
+		if err != nil {
+			return installed, fmt.Errorf("failed to install %s: %w", dep.URL, err)
+		}
+		installed = append(installed, inst)
+		queue = append(queue, project.Dependencies...)
+	}
+
+	return installed, nil
+}
+
+// install fetches `dep` into a temporary directory, and installs its sources into the vendor directory.
+func (i *Installer) install(ctx context.Context, fetcher Fetcher, dep Dependency) (*Project, Installed, error) {
+	inst := Installed{Dependency: dep}
+
+	tmp, err := os.MkdirTemp(i.VendorDir, ".fetch-")
+	if err != nil {
+		return nil, inst, err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := fetcher.Fetch(ctx, dep, tmp); err != nil {
+		return nil, inst, err
+	}
+	// (fetched projects are not trusted, so they are read without being evaluated, and with a bounded time)
+	timeout := i.ReadTimeout
+	if timeout <= 0 {
+		timeout = defaultReadTimeout
+	}
+	readCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	project, err := ReadProject(readCtx, filepath.Join(tmp, "project.janet"))
+	if err != nil {
+		return nil, inst, err
+	}
+
+	inst.Name = project.Name
+	if inst.Name == "" {
+		inst.Name = path.Base(repositoryKey(dep.URL))
+	}
+	inst.Natives = project.Natives
+
+	for _, source := range project.Sources {
+		for _, file := range source.Files {
+			if !filepath.IsLocal(file) || (source.Prefix != "" && !filepath.IsLocal(source.Prefix)) {
+				return nil, inst, fmt.Errorf("unsafe path of source: %s", filepath.Join(source.Prefix, file))
+			}
+			src := filepath.Join(tmp, file)
+			dst := filepath.Join(i.VendorDir, source.Prefix, filepath.Base(file))
+			copied, err := copyTree(src, dst)
+			if err != nil {
+				return nil, inst, err
+			}
+			for _, c := range copied {
+				rel, _ := filepath.Rel(i.VendorDir, c)
+				inst.Files = append(inst.Files, filepath.ToSlash(rel))
+			}
+		}
+	}
+
+	if len(dep.Hashes) > 0 {
+		if err := verifyInstalled(i.VendorDir, dep, inst.Files); err != nil {
+			return nil, inst, err
+		}
+	}
+
+	return project, inst, nil
+}
+
+// verifyInstalled checks `files` installed for a locked `dep` against its content hashes.
+func verifyInstalled(vendorDir string, dep Dependency, files []string) error {
+	for _, file := range files {
+		if _, ok := dep.Hashes[file]; !ok {
+			return fmt.Errorf("installed file is not locked: %s", file)
+		}
+	}
+	if len(files) != len(dep.Hashes) {
+		return fmt.Errorf("%d locked files are not installed", len(dep.Hashes)-len(files))
+	}
+	return Verify(vendorDir, []Dependency{dep})
+}
+
+// Option returns the option which makes VMs import modules installed in `vendorDir`.
+func Option(vendorDir string) janet.Option {
+	return janet.WithSyspath(vendorDir)
+}
+
+// repositoryKey returns the key for identifying the repository at `url`
+// (eg. "github.com/janet-lang/spork" for "https://github.com/janet-lang/spork.git").
+func repositoryKey(url string) string {
+	if _, rest, ok := strings.Cut(url, "://"); ok {
+		url = rest
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+}
+
+// copyTree copies a file or a directory at `src` to `dst`, and returns the copied files.
+func copyTree(src, dst string) (copied []string, err error) {
+	err = filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, data, 0o644); err != nil {
+			return err
+		}
+		copied = append(copied, target)
+		return nil
+	})
+	return copied, err
+}
@@ -0,0 +1,234 @@
+// install_test.go
+
+package janetdeps
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/meinside/janet-go"
+)
+
+// tarball creates a gzipped tarball of `files` under a top-level directory, like the ones of GitHub.
+func tarball(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: "repo-main/" + name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("Failed to write tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("Failed to write tar content: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("Failed to close gzip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// newTestServer starts a http server which serves tarballs of test repositories:
+// `acme/mylib` (tagged `v1.0.0`) which depends on `acme/helper`,
+// `acme/endless` whose project does not terminate if evaluated, and `acme/malformed` whose project cannot be read.
+func newTestServer(t *testing.T) *httptest.Server {
+	archives := map[string][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if archive, ok := archives[r.URL.Path]; ok {
+			_, _ = w.Write(archive)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+
+	archives["/acme/mylib/archive/v1.0.0.tar.gz"] = tarball(t, map[string]string{
+		"project.janet": `(declare-project
+  :name "mylib"
+  :dependencies [{:url "` + server.URL + `/acme/helper.git"}])
+(declare-source :source ["mylib.janet"])
+(declare-native :name "mylib-native" :source ["native.c"])
+(task "extra" [] (print "not run"))`,
+		"mylib.janet": `(import helper/strings) (defn greet [name] (strings/shout (string "hello, " name)))`,
+		"native.c":    `/* not built */`,
+	})
+	archives["/acme/helper/archive/HEAD.tar.gz"] = tarball(t, map[string]string{
+		"project.janet":       `(declare-project :name "helper") (declare-source :prefix "helper" :source ["src/strings.janet"])`,
+		"src/strings.janet":   `(defn shout [s] (string/ascii-upper s))`,
+		"src/unrelated.janet": `(error "not installed")`,
+	})
+	archives["/acme/endless/archive/HEAD.tar.gz"] = tarball(t, map[string]string{
+		"project.janet": `(declare-project :name "endless") (forever)`,
+	})
+	archives["/acme/malformed/archive/HEAD.tar.gz"] = tarball(t, map[string]string{
+		"project.janet": `(declare-project :name (string "mal" "formed"))`,
+	})
+
+	return server
+}
+
+// TestInstall tests installing dependencies and importing them.
+func TestInstall(t *testing.T) {
+	ctx := context.TODO()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	// project which depends on them
+	dir := t.TempDir()
+	projectFile := filepath.Join(dir, "project.janet")
+	if err := os.WriteFile(projectFile, []byte(`(declare-project
+  :name "app"
+  :dependencies [{:url "`+server.URL+`/acme/mylib" :tag "v1.0.0"}])`), 0o644); err != nil {
+		t.Fatalf("Failed to write project: %v", err)
+	}
+
+	project, err := ReadProject(ctx, projectFile)
+	if err != nil {
+		t.Fatalf("Failed to read project: %v", err)
+	}
+	if project.Name != "app" || !reflect.DeepEqual(project.Dependencies, []Dependency{{URL: server.URL + "/acme/mylib", Tag: "v1.0.0"}}) {
+		t.Errorf("Unexpected project: %+v", project)
+	}
+
+	vendor := filepath.Join(dir, "vendor")
+	installer := &Installer{VendorDir: vendor}
+	installed, err := installer.Install(ctx, project.Dependencies)
+	if err != nil {
+		t.Fatalf("Failed to install dependencies: %v", err)
+	}
+	if len(installed) != 2 {
+		t.Fatalf("Expected 2 installed dependencies, got: %+v", installed)
+	}
+	if installed[0].Name != "mylib" || !reflect.DeepEqual(installed[0].Files, []string{"mylib.janet"}) || !reflect.DeepEqual(installed[0].Natives, []string{"mylib-native"}) {
+		t.Errorf("Unexpected installed dependency: %+v", installed[0])
+	}
+	if installed[1].Name != "helper" || !reflect.DeepEqual(installed[1].Files, []string{"helper/strings.janet"}) {
+		t.Errorf("Unexpected installed dependency: %+v", installed[1])
+	}
+	if entries, _ := os.ReadDir(vendor); len(entries) != 2 {
+		t.Errorf("Expected only installed files in vendor directory, got: %v", entries)
+	}
+
+	// importing installed modules
+	vm, err := janet.NewVM(Option(vendor))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+	evaluated, _, _, err := vm.Execute(ctx, `(import mylib) (mylib/greet "janet")`)
+	if err != nil || evaluated != "HELLO, JANET" {
+		t.Errorf("Expected result from installed modules, got: %s (%v)", evaluated, err)
+	}
+
+	// missing dependencies
+	if _, err := installer.Install(ctx, []Dependency{{URL: server.URL + "/acme/missing"}}); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected error for missing dependencies, got: %v", err)
+	}
+
+	// projects which are not evaluated
+	installer.ReadTimeout = 100 * time.Millisecond
+	if installed, err := installer.Install(ctx, []Dependency{{URL: server.URL + "/acme/endless"}}); err != nil || len(installed) != 1 || installed[0].Name != "endless" {
+		t.Errorf("Expected endless project to be read without evaluating it, got: %+v (%v)", installed, err)
+	}
+	if _, err := installer.Install(ctx, []Dependency{{URL: server.URL + "/acme/malformed"}}); err == nil || !strings.Contains(err.Error(), "only literal") {
+		t.Errorf("Expected error for malformed project, got: %v", err)
+	}
+}
+
+// TestReadProject tests that projects are read without evaluating them.
+func TestReadProject(t *testing.T) {
+	ctx := context.TODO()
+
+	dir := t.TempDir()
+	evaluated := filepath.Join(dir, "evaluated")
+	projectFile := filepath.Join(dir, "project.janet")
+	if err := os.WriteFile(projectFile, []byte(`# comment
+(import ./missing)
+(spit "`+evaluated+`" "")
+(declare-project
+  :name "untrusted"
+  :dependencies ["https://example.com/dep" {:url "https://example.com/other" :tag "v1"}])
+(declare-source :prefix "untrusted" :source @["init.janet"])
+(task "build" [] (os/exit 1))
+(os/exit 1)`), 0o644); err != nil {
+		t.Fatalf("Failed to write project: %v", err)
+	}
+
+	project, err := ReadProject(ctx, projectFile)
+	if err != nil {
+		t.Fatalf("Failed to read project: %v", err)
+	}
+	expected := &Project{
+		Name:         "untrusted",
+		Dependencies: []Dependency{{URL: "https://example.com/dep"}, {URL: "https://example.com/other", Tag: "v1"}},
+		Sources:      []Source{{Files: []string{"init.janet"}, Prefix: "untrusted"}},
+	}
+	if !reflect.DeepEqual(project, expected) {
+		t.Errorf("Expected %+v, got: %+v", expected, project)
+	}
+	if _, err := os.Stat(evaluated); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected project not to be evaluated, got: %v", err)
+	}
+
+	// unparsable projects
+	if err := os.WriteFile(projectFile, []byte(`(declare-project :name "unclosed"`), 0o644); err != nil {
+		t.Fatalf("Failed to write project: %v", err)
+	}
+	if _, err := ReadProject(ctx, projectFile); err == nil {
+		t.Errorf("Expected error for unparsable project")
+	}
+}
+
+// TestReadLockfile tests reading jpm's lockfiles.
+func TestReadLockfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lockfile.jdn")
+	if err := os.WriteFile(path, []byte(`@[{:url "https://github.com/janet-lang/spork.git" :tag "abc123" :type :git}
+  {:repo "https://example.com/other" :tag "v2"}]`), 0o644); err != nil {
+		t.Fatalf("Failed to write lockfile: %v", err)
+	}
+
+	deps, err := ReadLockfile(context.TODO(), path)
+	if err != nil {
+		t.Fatalf("Failed to read lockfile: %v", err)
+	}
+	expected := []Dependency{
+		{URL: "https://github.com/janet-lang/spork.git", Tag: "abc123"},
+		{URL: "https://example.com/other", Tag: "v2"},
+	}
+	if !reflect.DeepEqual(deps, expected) {
+		t.Errorf("Expected %+v, got: %+v", expected, deps)
+	}
+}
+
+// TestDefaultArchiveURL tests urls of tarballs.
+func TestDefaultArchiveURL(t *testing.T) {
+	tests := []struct {
+		dep      Dependency
+		expected string
+	}{
+		{Dependency{URL: "https://github.com/janet-lang/spork.git", Tag: "v1.0.0"}, "https://github.com/janet-lang/spork/archive/v1.0.0.tar.gz"},
+		{Dependency{URL: "https://github.com/janet-lang/spork/"}, "https://github.com/janet-lang/spork/archive/HEAD.tar.gz"},
+	}
+	for _, test := range tests {
+		if u, err := DefaultArchiveURL(test.dep); err != nil || u != test.expected {
+			t.Errorf("Expected %s for %+v, got: %s (%v)", test.expected, test.dep, u, err)
+		}
+	}
+	if _, err := DefaultArchiveURL(Dependency{URL: "git@github.com:janet-lang/spork.git"}); err == nil {
+		t.Errorf("Expected error for ssh urls")
+	}
+}
@@ -0,0 +1,129 @@
+// lock.go
+
+package janetdeps
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/meinside/janet-go"
+)
+
+// Lock returns the installed dependencies with content hashes of their files in `vendorDir`,
+// which can be written with WriteLockfile.
+func Lock(vendorDir string, installed []Installed) (locked []Dependency, err error) {
+	for _, inst := range installed {
+		dep := Dependency{URL: inst.URL, Tag: inst.Tag, Hashes: map[string]string{}}
+		for _, file := range inst.Files {
+			if dep.Hashes[file], err = hashFile(filepath.Join(vendorDir, filepath.FromSlash(file))); err != nil {
+				return nil, err
+			}
+		}
+		locked = append(locked, dep)
+	}
+	return locked, nil
+}
+
+// WriteLockfile writes `locked` dependencies to a lockfile at `path`, in the format of jpm's lockfile
+// (with content hashes of the installed files in `:files`), which can be read with ReadLockfile.
+func WriteLockfile(path string, locked []Dependency) error {
+	var sb strings.Builder
+	sb.WriteString("@[")
+	for i, dep := range locked {
+		if i > 0 {
+			sb.WriteString("\n  ")
+		}
+		fmt.Fprintf(&sb, "{:url %s :tag %s :type :git", strconv.Quote(dep.URL), strconv.Quote(dep.Tag))
+		if len(dep.Hashes) > 0 {
+			sb.WriteString("\n   :files {")
+			for j, file := range slices.Sorted(maps.Keys(dep.Hashes)) {
+				if j > 0 {
+					sb.WriteString("\n           ")
+				}
+				fmt.Fprintf(&sb, "%s %s", strconv.Quote(file), strconv.Quote(dep.Hashes[file]))
+			}
+			sb.WriteString("}")
+		}
+		sb.WriteString("}")
+	}
+	sb.WriteString("]\n")
+
+	return os.WriteFile(path, []byte(sb.String()), 0o644)
+}
+
+// Verify checks the files of `locked` dependencies in `vendorDir` against their content hashes.
+func Verify(vendorDir string, locked []Dependency) error {
+	var errs []error
+	for _, dep := range locked {
+		for file, hash := range dep.Hashes {
+			if err := verifyFile(filepath.Join(vendorDir, filepath.FromSlash(file)), hash); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Verifier returns the option which makes VMs verify module files in `vendorDir` against
+// the content hashes of `locked` dependencies when they are loaded, so that modified
+// (or unlocked) files in the vendor directory fail to be imported.
+//
+// Module files outside `vendorDir` are not verified.
+func Verifier(vendorDir string, locked []Dependency) janet.Option {
+	hashes := map[string]string{}
+	for _, dep := range locked {
+		for file, hash := range dep.Hashes {
+			hashes[file] = hash
+		}
+	}
+	vendor, vendorErr := filepath.Abs(vendorDir)
+
+	return janet.WithModuleVerifier(func(path string) error {
+		if vendorErr != nil {
+			return vendorErr
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(vendor, abs)
+		if err != nil || !filepath.IsLocal(rel) {
+			return nil // not in the vendor directory
+		}
+		hash, ok := hashes[filepath.ToSlash(rel)]
+		if !ok {
+			return fmt.Errorf("module file is not locked: %s", path)
+		}
+		return verifyFile(path, hash)
+	})
+}
+
+// hashFile returns the content hash of the file at `path` (eg. "sha256:...").
+func hashFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// verifyFile checks the content of the file at `path` against `hash`.
+func verifyFile(path, hash string) error {
+	actual, err := hashFile(path)
+	if err != nil {
+		return err
+	}
+	if actual != hash {
+		return fmt.Errorf("hash mismatch of %s: expected %s, got %s", path, hash, actual)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// lock_test.go
+
+package janetdeps
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/meinside/janet-go"
+)
+
+// TestLockfile tests generating lockfiles and verifying installed files against them.
+func TestLockfile(t *testing.T) {
+	ctx := context.TODO()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	dir := t.TempDir()
+	vendor := filepath.Join(dir, "vendor")
+	installer := &Installer{VendorDir: vendor}
+	installed, err := installer.Install(ctx, []Dependency{{URL: server.URL + "/acme/mylib", Tag: "v1.0.0"}})
+	if err != nil {
+		t.Fatalf("Failed to install dependencies: %v", err)
+	}
+
+	// generate a lockfile, and read it back
+	locked, err := Lock(vendor, installed)
+	if err != nil {
+		t.Fatalf("Failed to lock dependencies: %v", err)
+	}
+	lockfile := filepath.Join(dir, "lockfile.jdn")
+	if err := WriteLockfile(lockfile, locked); err != nil {
+		t.Fatalf("Failed to write lockfile: %v", err)
+	}
+	read, err := ReadLockfile(ctx, lockfile)
+	if err != nil {
+		t.Fatalf("Failed to read lockfile: %v", err)
+	}
+	if len(read) != 2 || read[0].Tag != "v1.0.0" || read[1].URL != server.URL+"/acme/helper.git" ||
+		!strings.HasPrefix(read[0].Hashes["mylib.janet"], "sha256:") || read[1].Hashes["helper/strings.janet"] != locked[1].Hashes["helper/strings.janet"] {
+		t.Fatalf("Unexpected locked dependencies: %+v", read)
+	}
+	if err := Verify(vendor, read); err != nil {
+		t.Errorf("Expected installed files verified, got: %v", err)
+	}
+
+	// installing locked dependencies again
+	if err := os.RemoveAll(vendor); err != nil {
+		t.Fatalf("Failed to remove vendor directory: %v", err)
+	}
+	if _, err := installer.Install(ctx, read); err != nil {
+		t.Fatalf("Failed to install locked dependencies: %v", err)
+	}
+
+	// loading verified modules
+	vm, err := janet.NewVM(Option(vendor), Verifier(vendor, read))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+	if evaluated, _, _, err := vm.Execute(ctx, `(import mylib) (mylib/greet "janet")`); err != nil || evaluated != "HELLO, JANET" {
+		t.Errorf("Expected result from verified modules, got: %s (%v)", evaluated, err)
+	}
+
+	// modified files fail to be loaded
+	if err := os.WriteFile(filepath.Join(vendor, "helper", "strings.janet"), []byte(`(defn shout [s] "tampered")`), 0o644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	if err := Verify(vendor, read); err == nil || !strings.Contains(err.Error(), "hash mismatch") {
+		t.Errorf("Expected hash mismatch, got: %v", err)
+	}
+	vm2, err := janet.NewVM(Option(vendor), Verifier(vendor, read)) // without modules cached
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm2.Close()
+	if _, _, _, err := vm2.Execute(ctx, `(import helper/strings)`); err == nil || !strings.Contains(err.Error(), "hash mismatch") {
+		t.Errorf("Expected hash mismatch on loading modified file, got: %v", err)
+	}
+
+	// unlocked files in the vendor directory fail to be loaded
+	if err := os.WriteFile(filepath.Join(vendor, "extra.janet"), []byte(`(def x 1)`), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, _, _, err := vm.Execute(ctx, `(import extra)`); err == nil || !strings.Contains(err.Error(), "not locked") {
+		t.Errorf("Expected error on loading unlocked file, got: %v", err)
+	}
+
+	// installing dependencies which differ from the lockfile
+	read[1].Hashes["helper/strings.janet"] = "sha256:0000"
+	if _, err := installer.Install(ctx, read); err == nil || !strings.Contains(err.Error(), "hash mismatch") {
+		t.Errorf("Expected hash mismatch on installing, got: %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+// project.go
+
+// Package janetdeps installs dependencies of janet projects (declared in `project.janet`, or locked in jpm's lockfile)
+// into a vendor directory without jpm, so that embedded projects can import them in VMs
+// created with janet.WithSyspath.
+package janetdeps
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/meinside/janet-go"
+)
+
+// Dependency is a dependency of a janet project.
+type Dependency struct {
+	URL string // url of the repository (eg. "https://github.com/janet-lang/spork")
+	Tag string // tag, branch, or commit to be fetched (the default branch if empty)
+
+	// content hashes of installed files (eg. "sha256:..."), keyed by their paths relative to the vendor directory
+	// (only in dependencies read from lockfiles generated with WriteLockfile)
+	Hashes map[string]string
+}
+
+// Source is a declaration of janet sources to be installed (`declare-source`).
+type Source struct {
+	Files  []string // files or directories relative to the project
+	Prefix string   // directory in the vendor directory where the files are installed
+}
+
+// Project is a janet project declared in `project.janet`.
+type Project struct {
+	Name         string
+	Dependencies []Dependency
+	Sources      []Source
+	Natives      []string // names of native modules (`declare-native`), which are not built
+}
+
+// janet source which reads jpm's declarations in `project-source` without evaluating it,
+// by parsing its top-level forms and collecting the literal arguments of `declare-project`, `declare-source`, and `declare-native`.
+const projectReader = `(defn- literal? [x]
+  (case (type x)
+    :symbol false
+    :tuple (and (= (tuple/type x) :brackets) (all literal? x))
+    :array (all literal? x)
+    :struct (all literal? (kvs x))
+    :table (all literal? (kvs x))
+    true))
+
+(def project @{:sources @[] :natives @[]})
+(def parser (parser/new))
+(parser/consume parser project-source)
+(parser/eof parser)
+(when-let [err (parser/error parser)]
+  (error err))
+(while (parser/has-more parser)
+  (def form (parser/produce parser))
+  (when (and (tuple? form) (= (tuple/type form) :parens)
+             (index-of (first form) ['declare-project 'declare-source 'declare-native]))
+    (def [name & args] form)
+    (unless (and (even? (length args)) (all literal? args))
+      (errorf "only literal keys and values are read from %s" name))
+    (def opts (table ;args))
+    (case name
+      'declare-project (eachp [k v] opts (put project k v))
+      'declare-source (array/push (project :sources) opts)
+      'declare-native (array/push (project :natives) (opts :name)))))
+project`
+
+// ReadProject reads the declarations of a janet project from `project.janet` file at `path`.
+//
+// The file is not evaluated, as the ones of fetched dependencies are not trusted:
+// its top-level forms are only parsed, and the literal arguments of jpm's `declare-project`, `declare-source`,
+// and `declare-native` are collected from them (other forms, eg. tasks or definitions, are ignored,
+// and declarations with computed values, eg. `(declare-source :source (os/dir "src"))`, fail to be read).
+func ReadProject(ctx context.Context, path string) (*Project, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	vm, err := janet.NewVM()
+	if err != nil {
+		return nil, err
+	}
+	defer vm.Close()
+
+	if err := vm.Define(ctx, "project-source", source); err != nil {
+		return nil, err
+	}
+	declared, _, _, err := vm.EvalValue(ctx, projectReader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	project, ok := declared.(map[any]any)
+	if !ok {
+		return nil, fmt.Errorf("unexpected declarations in %s: %T", path, declared)
+	}
+	return parseProject(project)
+}
+
+// parseProject converts the declarations collected from `project.janet`.
+func parseProject(declared map[any]any) (*Project, error) {
+	project := &Project{}
+	project.Name, _ = declared[":name"].(string)
+
+	deps, err := asList(declared[":dependencies"])
+	if err != nil {
+		return nil, fmt.Errorf("dependencies: %w", err)
+	}
+	if project.Dependencies, err = parseDependencies(deps); err != nil {
+		return nil, err
+	}
+
+	sources, _ := declared[":sources"].([]any)
+	for _, declaration := range sources {
+		opts, ok := declaration.(map[any]any)
+		if !ok {
+			continue
+		}
+		files, err := asList(opts[":source"])
+		if err != nil {
+			return nil, fmt.Errorf("source: %w", err)
+		}
+		source := Source{}
+		for _, file := range files {
+			if path, ok := file.(string); ok {
+				source.Files = append(source.Files, path)
+			}
+		}
+		source.Prefix, _ = opts[":prefix"].(string)
+		project.Sources = append(project.Sources, source)
+	}
+
+	natives, _ := declared[":natives"].([]any)
+	for _, native := range natives {
+		if name, ok := native.(string); ok {
+			project.Natives = append(project.Natives, name)
+		}
+	}
+
+	return project, nil
+}
+
+// ReadLockfile reads dependencies locked in jpm's lockfile (eg. `lockfile.jdn`) at `path`.
+func ReadLockfile(ctx context.Context, path string) ([]Dependency, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	vm, err := janet.NewVM()
+	if err != nil {
+		return nil, err
+	}
+	defer vm.Close()
+
+	locked, err := vm.ParseToValue(ctx, string(source))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	deps, err := asList(locked)
+	if err != nil {
+		return nil, fmt.Errorf("lockfile: %w", err)
+	}
+	return parseDependencies(deps)
+}
+
+// parseDependencies converts dependencies, which are urls or dictionaries (eg. `{:url "..." :tag "v1.0"}`).
+func parseDependencies(deps []any) (parsed []Dependency, err error) {
+	for i, dep := range deps {
+		switch d := dep.(type) {
+		case string:
+			parsed = append(parsed, Dependency{URL: d})
+		case map[any]any:
+			url, _ := d[":url"].(string)
+			if url == "" {
+				url, _ = d[":repo"].(string)
+			}
+			if url == "" {
+				return nil, fmt.Errorf("dependency #%d has no url", i)
+			}
+			tag, _ := d[":tag"].(string)
+			var hashes map[string]string
+			if files, ok := d[":files"].(map[any]any); ok {
+				hashes = map[string]string{}
+				for file, hash := range files {
+					f, _ := file.(string)
+					h, _ := hash.(string)
+					hashes[f] = h
+				}
+			}
+			parsed = append(parsed, Dependency{URL: url, Tag: tag, Hashes: hashes})
+		default:
+			return nil, fmt.Errorf("dependency #%d has unexpected type %T", i, dep)
+		}
+	}
+	return parsed, nil
+}
+
+// asList converts an optional value, or a list of values into a list.
+func asList(value any) ([]any, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		return v, nil
+	case string, map[any]any:
+		return []any{v}, nil
+	}
+	return nil, fmt.Errorf("unexpected type %T", value)
+}
@@ -0,0 +1,571 @@
+// server.go
+
+// Package janetlsp provides a minimal LSP (Language Server Protocol) server for janet,
+// backed by an embedded janet VM, so that completions, docs, and diagnostics
+// exactly match the VM's environment.
+package janetlsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/meinside/janet-go"
+)
+
+// Options is the options for the LSP server.
+type Options struct {
+	// options for diagnostics (core symbols default to the VM's bindings)
+	Lint janet.LintOptions
+
+	// options for document formatting
+	Format janet.FormatOptions
+}
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// LSP diagnostic severities
+const (
+	severityError       = 1
+	severityWarning     = 2
+	severityInformation = 3
+)
+
+// LSP completion item kind for variables
+const completionKindVariable = 6
+
+// LSP message type for errors (of `window/logMessage`)
+const messageTypeError = 1
+
+// max size of a message body from the client
+const maxContentLength = 64 << 20
+
+// request is a JSON-RPC request (or notification, when ID is nil) from the client.
+type request struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+}
+
+// response is a successful JSON-RPC response to the client.
+type response struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  any              `json:"result"`
+}
+
+// errorResponse is a failed JSON-RPC response to the client.
+type errorResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Error   responseError    `json:"error"`
+}
+
+// responseError is the error of a failed JSON-RPC response.
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// notification is a JSON-RPC notification to the client.
+type notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+// position is a zero-based position in a document (with UTF-16 based characters).
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// lspRange is a range in a document.
+type lspRange struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+// textDocumentPositionParams is the params of position-based requests.
+type textDocumentPositionParams struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position position `json:"position"`
+}
+
+// server serves a single LSP connection.
+type server struct {
+	vm   *janet.VM
+	opts Options
+
+	reader *bufio.Reader
+	writer io.Writer
+	mu     sync.Mutex // for writer
+
+	docs     map[string]string // document uri => text
+	shutdown bool
+}
+
+// stdio combines stdin and stdout into an io.ReadWriter.
+type stdio struct {
+	io.Reader
+	io.Writer
+}
+
+// ServeStdio serves the LSP on stdin and stdout until the client exits.
+func ServeStdio(ctx context.Context, vm *janet.VM, opts Options) error {
+	return Serve(ctx, vm, stdio{os.Stdin, os.Stdout}, opts)
+}
+
+// ServeTCP listens on the TCP network address `addr` and serves the LSP on each accepted connection,
+// until `ctx` is done.
+func ServeTCP(ctx context.Context, vm *janet.VM, addr string, opts Options) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		go func() {
+			defer conn.Close()
+			_ = Serve(ctx, vm, conn, opts)
+		}()
+	}
+}
+
+// Serve serves the LSP on `rw` until the client exits, `rw` is closed, or `ctx` is done.
+func Serve(ctx context.Context, vm *janet.VM, rw io.ReadWriter, opts Options) error {
+	s := &server{
+		vm:     vm,
+		opts:   opts,
+		reader: bufio.NewReader(rw),
+		writer: rw,
+		docs:   map[string]string{},
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		body, err := s.read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			if err := s.replyError(nil, codeParseError, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if req.Method == "exit" {
+			return nil
+		}
+		if err := s.handle(ctx, req); err != nil {
+			return err
+		}
+	}
+}
+
+// read reads the body of the next message.
+func (s *server) read() ([]byte, error) {
+	length := -1
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if name, value, found := strings.Cut(line, ":"); found && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("invalid content length: %w", err)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("missing content length")
+	}
+	if length > maxContentLength {
+		return nil, fmt.Errorf("content length %d exceeds %d bytes", length, maxContentLength)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.reader, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// write writes a message.
+func (s *server) write(message any) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := fmt.Fprintf(s.writer, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = s.writer.Write(body)
+	return err
+}
+
+// reply writes a successful response.
+func (s *server) reply(id *json.RawMessage, result any) error {
+	return s.write(response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+// replyError writes a failed response.
+func (s *server) replyError(id *json.RawMessage, code int, message string) error {
+	return s.write(errorResponse{JSONRPC: "2.0", ID: id, Error: responseError{Code: code, Message: message}})
+}
+
+// notify writes a notification.
+func (s *server) notify(method string, params any) error {
+	return s.write(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// handle handles a request or notification.
+func (s *server) handle(ctx context.Context, req request) error {
+	var result any
+	var err error
+
+	if s.shutdown {
+		if req.ID != nil {
+			return s.replyError(req.ID, codeInvalidRequest, "server is shut down")
+		}
+		return nil
+	}
+
+	switch req.Method {
+	case "initialize":
+		result = map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":           1, // full
+				"completionProvider":         map[string]any{},
+				"hoverProvider":              true,
+				"documentFormattingProvider": true,
+			},
+			"serverInfo": map[string]any{
+				"name":    "janetlsp",
+				"version": janet.Version(),
+			},
+		}
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			s.docs[params.TextDocument.URI] = params.TextDocument.Text
+			err = s.publishDiagnostics(ctx, params.TextDocument.URI)
+		}
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err = json.Unmarshal(req.Params, &params); err == nil && len(params.ContentChanges) > 0 {
+			s.docs[params.TextDocument.URI] = params.ContentChanges[len(params.ContentChanges)-1].Text
+			err = s.publishDiagnostics(ctx, params.TextDocument.URI)
+		}
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			delete(s.docs, params.TextDocument.URI)
+			err = s.notify("textDocument/publishDiagnostics", map[string]any{
+				"uri":         params.TextDocument.URI,
+				"diagnostics": []any{},
+			})
+		}
+	case "textDocument/completion":
+		var params textDocumentPositionParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			result, err = s.completion(ctx, params)
+		}
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			result, err = s.hover(ctx, params)
+		}
+	case "textDocument/formatting":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			result = s.formatting(params.TextDocument.URI)
+		}
+	default:
+		if req.ID != nil {
+			return s.replyError(req.ID, codeMethodNotFound, fmt.Sprintf("method not found: %s", req.Method))
+		}
+		return nil // ignore unknown notifications
+	}
+
+	if req.ID == nil {
+		// (notifications have no responses, so their errors are logged to the client instead of stopping the server)
+		if err != nil {
+			return s.notify("window/logMessage", map[string]any{
+				"type":    messageTypeError,
+				"message": fmt.Sprintf("%s: %s", req.Method, err),
+			})
+		}
+		return nil
+	}
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+			return s.replyError(req.ID, codeInvalidParams, err.Error())
+		}
+		return s.replyError(req.ID, codeInternalError, err.Error())
+	}
+	return s.reply(req.ID, result)
+}
+
+// publishDiagnostics lints and flychecks the document, and publishes its diagnostics.
+func (s *server) publishDiagnostics(ctx context.Context, uri string) error {
+	text := s.docs[uri]
+
+	lintOpts := s.opts.Lint
+	if len(lintOpts.CoreSymbols) == 0 {
+		bindings, err := s.vm.Bindings(ctx)
+		if err != nil {
+			return err
+		}
+		lintOpts.CoreSymbols = bindings
+	}
+	found := janet.Lint(text, lintOpts)
+
+	// flycheck only when the document is syntactically valid (otherwise, the same errors will be reported twice)
+	if !slices.ContainsFunc(found, func(d janet.Diagnostic) bool { return d.Rule == janet.LintRuleSyntax }) {
+		checked, err := s.vm.Flycheck(ctx, text)
+		if err != nil {
+			return err
+		}
+		found = append(found, checked...)
+	}
+
+	diagnostics := make([]map[string]any, 0, len(found))
+	for _, d := range found {
+		severity := severityInformation
+		switch d.Severity {
+		case janet.SeverityError:
+			severity = severityError
+		case janet.SeverityWarning:
+			severity = severityWarning
+		}
+		diagnostics = append(diagnostics, map[string]any{
+			"range": lspRange{
+				Start: toLSPPosition(text, d.Start),
+				End:   toLSPPosition(text, d.End),
+			},
+			"severity": severity,
+			"code":     d.Rule,
+			"source":   "janet",
+			"message":  d.Message,
+		})
+	}
+
+	return s.notify("textDocument/publishDiagnostics", map[string]any{
+		"uri":         uri,
+		"diagnostics": diagnostics,
+	})
+}
+
+// completion returns completion items for the symbol prefix at the position.
+func (s *server) completion(ctx context.Context, params textDocumentPositionParams) (any, error) {
+	text := s.docs[params.TextDocument.URI]
+	offset := fromLSPPosition(text, params.Position)
+
+	start := offset
+	for start > 0 && isSymbolChar(text[start-1]) {
+		start--
+	}
+	prefix := text[start:offset]
+
+	// bindings in the VM, and symbols in the document
+	candidates, err := s.vm.Bindings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tokens, _ := janet.Tokenize(text)
+	for _, tok := range tokens {
+		if tok.Kind == janet.TokenSymbol && tok.End.Offset != offset {
+			candidates = append(candidates, tok.Text)
+		}
+	}
+	slices.Sort(candidates)
+	candidates = slices.Compact(candidates)
+
+	items := []map[string]any{}
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			items = append(items, map[string]any{
+				"label": candidate,
+				"kind":  completionKindVariable,
+			})
+		}
+	}
+	return items, nil
+}
+
+// hover returns the docstring of the symbol at the position.
+func (s *server) hover(ctx context.Context, params textDocumentPositionParams) (any, error) {
+	text := s.docs[params.TextDocument.URI]
+	offset := fromLSPPosition(text, params.Position)
+
+	tokens, _ := janet.Tokenize(text)
+	for _, tok := range tokens {
+		if tok.Kind != janet.TokenSymbol || offset < tok.Start.Offset || offset > tok.End.Offset {
+			continue
+		}
+
+		doc, err := s.vm.Doc(ctx, tok.Text)
+		if err != nil || doc == "" {
+			return nil, err
+		}
+		return map[string]any{
+			"contents": map[string]any{
+				"kind":  "plaintext",
+				"value": doc,
+			},
+			"range": lspRange{
+				Start: toLSPPosition(text, tok.Start),
+				End:   toLSPPosition(text, tok.End),
+			},
+		}, nil
+	}
+	return nil, nil
+}
+
+// formatting returns text edits which format the whole document.
+func (s *server) formatting(uri string) any {
+	text := s.docs[uri]
+
+	formatted, err := janet.Format(text, s.opts.Format)
+	if err != nil || formatted == text {
+		return []any{}
+	}
+
+	lines := strings.Split(text, "\n")
+	return []map[string]any{
+		{
+			"range": lspRange{
+				Start: position{},
+				End: position{
+					Line:      len(lines) - 1,
+					Character: utf16Length(lines[len(lines)-1]),
+				},
+			},
+			"newText": formatted,
+		},
+	}
+}
+
+// isSymbolChar returns whether `c` can be a part of janet symbols.
+func isSymbolChar(c byte) bool {
+	return c >= 0x80 ||
+		(c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9') ||
+		strings.IndexByte("!$%&*+-./:<=>?@^_", c) >= 0
+}
+
+// utf16Length returns the length of `str` in UTF-16 code units.
+func utf16Length(str string) int {
+	length := 0
+	for _, r := range str {
+		length += utf16.RuneLen(r)
+	}
+	return length
+}
+
+// toLSPPosition converts a janet source position to a LSP position.
+func toLSPPosition(text string, pos janet.Position) position {
+	lineStart := min(max(pos.Offset-(pos.Column-1), 0), len(text))
+	offset := min(max(pos.Offset, lineStart), len(text))
+	return position{
+		Line:      max(pos.Line-1, 0),
+		Character: utf16Length(text[lineStart:offset]),
+	}
+}
+
+// fromLSPPosition converts a LSP position to a byte offset in `text`.
+func fromLSPPosition(text string, pos position) int {
+	offset := 0
+	for line := 0; line < pos.Line; line++ {
+		idx := strings.IndexByte(text[offset:], '\n')
+		if idx < 0 {
+			return len(text)
+		}
+		offset += idx + 1
+	}
+
+	for units := 0; units < pos.Character && offset < len(text) && text[offset] != '\n'; {
+		r, size := utf8.DecodeRuneInString(text[offset:])
+		units += utf16.RuneLen(r)
+		offset += size
+	}
+	return offset
+}
@@ -0,0 +1,197 @@
+// server_test.go
+
+package janetlsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/meinside/janet-go"
+)
+
+// testClient is a LSP client for testing.
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+	nextID int
+}
+
+// send sends a request (or a notification when `notify` is true).
+func (c *testClient) send(method string, params any, notify bool) {
+	message := map[string]any{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+	}
+	if !notify {
+		c.nextID++
+		message["id"] = c.nextID
+	}
+
+	body, _ := json.Marshal(message)
+	if _, err := fmt.Fprintf(c.conn, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		c.t.Fatalf("Failed to send '%s': %v", method, err)
+	}
+}
+
+// receive receives the next message.
+func (c *testClient) receive() map[string]any {
+	length := 0
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			c.t.Fatalf("Failed to read header: %v", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if value, found := strings.CutPrefix(line, "Content-Length: "); found {
+			length, _ = strconv.Atoi(value)
+		}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, body); err != nil {
+		c.t.Fatalf("Failed to read body: %v", err)
+	}
+	var message map[string]any
+	if err := json.Unmarshal(body, &message); err != nil {
+		c.t.Fatalf("Failed to unmarshal message: %v", err)
+	}
+	return message
+}
+
+// TestServe tests the Serve function.
+func TestServe(t *testing.T) {
+	vm, err := janet.SharedVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	serverConn, clientConn := net.Pipe()
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(context.TODO(), vm, serverConn, Options{})
+	}()
+
+	c := &testClient{t: t, conn: clientConn, reader: bufio.NewReader(clientConn)}
+	uri := "file:///test.janet"
+
+	// initialize
+	c.send("initialize", map[string]any{"capabilities": map[string]any{}}, false)
+	res := c.receive()
+	if caps, ok := res["result"].(map[string]any)["capabilities"].(map[string]any); !ok || caps["hoverProvider"] != true {
+		t.Errorf("Unexpected initialize result: %v", res)
+	}
+	c.send("initialized", map[string]any{}, true)
+
+	// open a document, and receive diagnostics
+	c.send("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{
+			"uri":        uri,
+			"languageId": "janet",
+			"version":    1,
+			"text":       "(defn f [x]\n(def unused 1)\n(ma x))\n(no-such-symbol)",
+		},
+	}, true)
+	res = c.receive()
+	if res["method"] != "textDocument/publishDiagnostics" {
+		t.Fatalf("Expected diagnostics, got: %v", res)
+	}
+	var messages []string
+	for _, d := range res["params"].(map[string]any)["diagnostics"].([]any) {
+		messages = append(messages, d.(map[string]any)["message"].(string))
+	}
+	if joined := strings.Join(messages, "\n"); !strings.Contains(joined, "`unused` is never used") || !strings.Contains(joined, "unknown symbol ma") {
+		t.Errorf("Unexpected diagnostics: %v", messages)
+	}
+
+	// completion
+	c.send("textDocument/completion", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": 2, "character": 3},
+	}, false)
+	res = c.receive()
+	var labels []string
+	for _, item := range res["result"].([]any) {
+		labels = append(labels, item.(map[string]any)["label"].(string))
+	}
+	if !strings.Contains(strings.Join(labels, " "), "map") || strings.Contains(strings.Join(labels, " "), "print") {
+		t.Errorf("Unexpected completion items: %v", labels)
+	}
+
+	// hover
+	c.send("textDocument/hover", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": 0, "character": 2},
+	}, false)
+	res = c.receive()
+	if value := res["result"].(map[string]any)["contents"].(map[string]any)["value"].(string); !strings.HasPrefix(value, "(defn name & more)") {
+		t.Errorf("Unexpected hover result: %v", value)
+	}
+
+	// formatting
+	c.send("textDocument/formatting", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"options":      map[string]any{"tabSize": 2, "insertSpaces": true},
+	}, false)
+	res = c.receive()
+	if edits := res["result"].([]any); len(edits) != 1 || edits[0].(map[string]any)["newText"] != "(defn f [x]\n  (def unused 1)\n  (ma x))\n(no-such-symbol)\n" {
+		t.Errorf("Unexpected formatting result: %v", res["result"])
+	}
+
+	// unknown method
+	c.send("textDocument/unknown", map[string]any{}, false)
+	if res = c.receive(); res["error"] == nil {
+		t.Errorf("Expected an error for unknown method, got: %v", res)
+	}
+
+	// failed notifications are logged
+	c.send("textDocument/didOpen", map[string]any{"textDocument": "malformed"}, true)
+	if res = c.receive(); res["method"] != "window/logMessage" || !strings.Contains(res["params"].(map[string]any)["message"].(string), "textDocument/didOpen") {
+		t.Errorf("Expected a logged error for failed notification, got: %v", res)
+	}
+
+	// shutdown and exit
+	c.send("shutdown", nil, false)
+	if res = c.receive(); res["result"] != nil || res["error"] != nil {
+		t.Errorf("Unexpected shutdown result: %v", res)
+	}
+	c.send("exit", nil, true)
+	if err := <-served; err != nil {
+		t.Errorf("Serve returned error: %v", err)
+	}
+}
+
+// TestServeLargeMessage tests rejecting messages larger than the limit.
+func TestServeLargeMessage(t *testing.T) {
+	vm, err := janet.SharedVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(context.TODO(), vm, serverConn, Options{})
+	}()
+
+	if _, err := fmt.Fprintf(clientConn, "Content-Length: %d\r\n\r\n", maxContentLength+1); err != nil {
+		t.Fatalf("Failed to send header: %v", err)
+	}
+	if err := <-served; err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("Expected error for large message, got: %v", err)
+	}
+}
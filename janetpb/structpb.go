@@ -0,0 +1,143 @@
+// structpb.go
+
+// Package janetpb converts go values parsed from janet (eg. with janet.VM.ParseToValue)
+// to the well-known types of protobuf (google.protobuf.Struct and google.protobuf.Value) and back,
+// so that results of scripts can be passed to gRPC APIs without detouring through JSON.
+package janetpb
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/meinside/janet-go"
+)
+
+// ToValue converts a go value parsed from janet to a protobuf value.
+//
+//   - nil => null
+//   - bool => bool
+//   - float64, int64, uint64 => number (64-bit integers beyond 2^53 lose precision, and NaN and infinities fail)
+//   - string => string (keywords keep their leading colons)
+//   - []byte => string (base64-encoded, like protojson)
+//   - []any => list
+//   - map[string]any, map[any]any, *janet.OrderedMap => struct (keys should be strings, eg. with janet.MapKeysTrimmed)
+//
+// Other values (eg. *janet.GoValue) fail with an error.
+func ToValue(value any) (*structpb.Value, error) {
+	return toValue(value, "value")
+}
+
+// ToStruct converts a go value parsed from a janet table or struct to a protobuf struct (see ToValue).
+func ToStruct(value any) (*structpb.Struct, error) {
+	return toStruct(value, "value")
+}
+
+// FromValue converts a protobuf value to a go value which can be passed to janet (eg. as an argument of janet.VM.Apply).
+//
+//   - null => nil
+//   - bool => bool
+//   - number => float64
+//   - string => string
+//   - list => []any (array)
+//   - struct => map[string]any (table with string keys)
+func FromValue(value *structpb.Value) any {
+	return value.AsInterface()
+}
+
+// FromStruct converts a protobuf struct to a go map which can be passed to janet (see FromValue).
+func FromStruct(s *structpb.Struct) map[string]any {
+	return s.AsMap()
+}
+
+// toValue converts `value` at `path` (used in error messages) to a protobuf value.
+func toValue(value any, path string) (*structpb.Value, error) {
+	switch v := value.(type) {
+	case nil:
+		return structpb.NewNullValue(), nil
+	case bool:
+		return structpb.NewBoolValue(v), nil
+	case float64:
+		return toNumber(v, path)
+	case int64:
+		return toNumber(float64(v), path)
+	case uint64:
+		return toNumber(float64(v), path)
+	case string:
+		if !utf8.ValidString(v) {
+			return nil, fmt.Errorf("%s: cannot convert string %q which is not valid UTF-8", path, v)
+		}
+		return structpb.NewStringValue(v), nil
+	case []byte:
+		return structpb.NewStringValue(base64.StdEncoding.EncodeToString(v)), nil
+	case []any:
+		list := &structpb.ListValue{Values: make([]*structpb.Value, len(v))}
+		for i, elem := range v {
+			converted, err := toValue(elem, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			list.Values[i] = converted
+		}
+		return structpb.NewListValue(list), nil
+	case map[string]any, map[any]any, *janet.OrderedMap:
+		s, err := toStruct(v, path)
+		if err != nil {
+			return nil, err
+		}
+		return structpb.NewStructValue(s), nil
+	}
+	return nil, fmt.Errorf("%s: cannot convert %T to a protobuf value", path, value)
+}
+
+// toNumber converts a number at `path` to a protobuf value.
+func toNumber(n float64, path string) (*structpb.Value, error) {
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return nil, fmt.Errorf("%s: cannot convert %v to a protobuf value", path, n)
+	}
+	return structpb.NewNumberValue(n), nil
+}
+
+// toStruct converts a map at `path` (used in error messages) to a protobuf struct.
+func toStruct(value any, path string) (*structpb.Struct, error) {
+	s := &structpb.Struct{Fields: map[string]*structpb.Value{}}
+	put := func(key, val any) error {
+		name, ok := key.(string)
+		if !ok {
+			return fmt.Errorf("%s: cannot convert key %v (%T) to a field name", path, key, key)
+		}
+		converted, err := toValue(val, fmt.Sprintf("%s[%q]", path, name))
+		if err != nil {
+			return err
+		}
+		s.Fields[name] = converted
+		return nil
+	}
+
+	switch m := value.(type) {
+	case map[string]any:
+		for key, val := range m {
+			if err := put(key, val); err != nil {
+				return nil, err
+			}
+		}
+	case map[any]any:
+		for key, val := range m {
+			if err := put(key, val); err != nil {
+				return nil, err
+			}
+		}
+	case *janet.OrderedMap:
+		for _, entry := range m.Entries() {
+			if err := put(entry.Key, entry.Value); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%s: cannot convert %T to a protobuf struct", path, value)
+	}
+	return s, nil
+}
@@ -0,0 +1,89 @@
+// structpb_test.go
+
+package janetpb
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/meinside/janet-go"
+)
+
+// TestStruct tests converting parsed values to protobuf structs and back.
+func TestStruct(t *testing.T) {
+	vm, err := janet.NewVM(janet.WithMapKeys(janet.MapKeysTrimmed), janet.WithInvalidUTF8(janet.InvalidUTF8Bytes))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	big := `(int/s64 7)`
+	if !janet.Build().IntTypes {
+		big = `7` // (`int/*` is not available)
+	}
+	parsed, err := vm.ParseToValue(ctx, `{:name "janet" :tags ["a" :b nil] :stars 42 :big `+big+` :meta {:ok true} :raw @"\xff"}`)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	s, err := ToStruct(parsed)
+	if err != nil {
+		t.Fatalf("Failed to convert to a struct: %v", err)
+	}
+
+	expected, _ := structpb.NewStruct(map[string]any{
+		"name":  "janet",
+		"tags":  []any{"a", ":b", nil},
+		"stars": 42,
+		"big":   7,
+		"meta":  map[string]any{"ok": true},
+		"raw":   "/w==",
+	})
+	if !proto.Equal(s, expected) {
+		t.Errorf("Expected %v, got %v", expected, s)
+	}
+
+	// and back to janet
+	name, err := vm.Apply(ctx, "get-in", FromStruct(s), []any{"meta", "ok"})
+	if err != nil {
+		t.Fatalf("Failed to apply: %v", err)
+	}
+	if name != true {
+		t.Errorf("Expected true, got %v", name)
+	}
+	value, err := ToValue([]any{1.0, "x"})
+	if err != nil {
+		t.Fatalf("Failed to convert to a value: %v", err)
+	}
+	length, err := vm.Apply(ctx, "length", FromValue(value))
+	if err != nil {
+		t.Fatalf("Failed to apply: %v", err)
+	}
+	if length != 2.0 {
+		t.Errorf("Expected 2, got %v", length)
+	}
+
+	// values which cannot be converted
+	for _, tc := range []struct {
+		value any
+		err   string
+	}{
+		{map[any]any{1.0: "one"}, "cannot convert key 1"},
+		{[]any{math.NaN()}, "value[0]: cannot convert NaN"},
+		{map[string]any{"s": "\xff"}, `value["s"]: cannot convert string`},
+		{vm.Wrap(struct{}{}), "cannot convert *janet.GoValue"},
+	} {
+		if _, err := ToValue(tc.value); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("Expected an error with %q, got %v", tc.err, err)
+		}
+	}
+	if _, err := ToStruct([]any{}); err == nil {
+		t.Errorf("Expected an error for converting a slice to a struct")
+	}
+}
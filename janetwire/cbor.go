@@ -0,0 +1,363 @@
+// cbor.go
+
+package janetwire
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// major types of CBOR
+const (
+	cborUint   byte = 0 << 5
+	cborNegint byte = 1 << 5
+	cborBytes  byte = 2 << 5
+	cborText   byte = 3 << 5
+	cborArray  byte = 4 << 5
+	cborMap    byte = 5 << 5
+	cborTag    byte = 6 << 5
+	cborSimple byte = 7 << 5
+)
+
+// additional information of CBOR for indefinite lengths
+const cborIndefinite = 31
+
+// MarshalCBOR encodes a go value converted from janet into CBOR (RFC 8949).
+//
+//   - nil => null
+//   - bool => false, true
+//   - float64, float32 => double-precision float, single-precision float
+//   - int64 (eg. `int/s64`), uint64 (eg. `int/u64`), and other integers => unsigned or negative integer (in the smallest form)
+//   - string => text string
+//   - []byte (eg. buffers converted with janet.InvalidUTF8Bytes) => byte string
+//   - []any => array
+//   - map[any]any, map[string]any, *janet.OrderedMap => map (in the order of *janet.OrderedMap, or sorted by keys)
+//
+// Other values (eg. *janet.GoValue) fail with an error.
+func MarshalCBOR(value any) ([]byte, error) {
+	return appendCBOR(nil, value)
+}
+
+// UnmarshalCBOR decodes CBOR into a go value which can be passed to janet.
+//
+//   - null, undefined => nil
+//   - false, true => bool
+//   - half-, single-, and double-precision floats => float64
+//   - unsigned and negative integers => int64 (or uint64 for the ones beyond int64)
+//   - text string => string
+//   - byte string => []byte (buffer)
+//   - array => []any (array)
+//   - map => map[any]any (table)
+//
+// Tags are skipped (their contents are decoded as they are), and integers beyond int64 and uint64 fail with an error.
+func UnmarshalCBOR(data []byte) (any, error) {
+	return decode(data, decodeCBOR)
+}
+
+// appendCBOR appends `value` encoded into CBOR to `b`.
+func appendCBOR(b []byte, value any) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(b, cborSimple|22), nil
+	case bool:
+		if v {
+			return append(b, cborSimple|21), nil
+		}
+		return append(b, cborSimple|20), nil
+	case string:
+		return append(appendCBORHead(b, cborText, uint64(len(v))), v...), nil
+	case []byte:
+		return append(appendCBORHead(b, cborBytes, uint64(len(v))), v...), nil
+	case []any:
+		b = appendCBORHead(b, cborArray, uint64(len(v)))
+		for _, elem := range v {
+			var err error
+			if b, err = appendCBOR(b, elem); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+
+	if n := toNumber(value); n.isNumber {
+		switch n.kind {
+		case reflect.Float32:
+			return binary.BigEndian.AppendUint32(append(b, cborSimple|26), math.Float32bits(float32(n.f))), nil
+		case reflect.Float64:
+			return binary.BigEndian.AppendUint64(append(b, cborSimple|27), math.Float64bits(n.f)), nil
+		case reflect.Uint64:
+			return appendCBORHead(b, cborUint, n.u), nil
+		}
+		if n.i >= 0 {
+			return appendCBORHead(b, cborUint, uint64(n.i)), nil
+		}
+		return appendCBORHead(b, cborNegint, uint64(-1-n.i)), nil
+	}
+
+	if kvs, ok := entries(value); ok {
+		b = appendCBORHead(b, cborMap, uint64(len(kvs)))
+		for _, kv := range kvs {
+			var err error
+			if b, err = appendCBOR(b, kv.Key); err != nil {
+				return nil, err
+			}
+			if b, err = appendCBOR(b, kv.Value); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("cannot encode %T into CBOR", value)
+}
+
+// appendCBORHead appends the head of a data item of `major` type with its argument `n` in the smallest form.
+func appendCBORHead(b []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(b, major|27), n)
+}
+
+// decodeCBOR decodes the next CBOR data item.
+func decodeCBOR(r *reader) (any, error) {
+	initial, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	major, info := initial&0xe0, initial&0x1f
+
+	if major == cborSimple {
+		return decodeCBORSimple(r, info)
+	}
+
+	// (indefinite lengths are only for strings and collections)
+	if info == cborIndefinite {
+		switch major {
+		case cborBytes, cborText:
+			return decodeCBORChunks(r, major)
+		case cborArray:
+			return decodeCBORArray(r, 0, true)
+		case cborMap:
+			return decodeCBORMap(r, 0, true)
+		}
+		return nil, fmt.Errorf("invalid CBOR initial byte 0x%02x", initial)
+	}
+	n, err := decodeCBORArgument(r, info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborUint:
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+	case cborNegint:
+		if n > math.MaxInt64 {
+			return nil, fmt.Errorf("negative integer -1-%d out of range", n)
+		}
+		return -1 - int64(n), nil
+	case cborBytes, cborText:
+		str, err := r.next(n)
+		if err != nil {
+			return nil, err
+		}
+		if major == cborText {
+			return string(str), nil
+		}
+		return append([]byte{}, str...), nil
+	case cborArray:
+		return decodeCBORArray(r, n, false)
+	case cborMap:
+		return decodeCBORMap(r, n, false)
+	}
+
+	// tags
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	defer r.leave()
+	return decodeCBOR(r)
+}
+
+// decodeCBORArgument decodes the argument of a data item with its additional information `info`.
+func decodeCBORArgument(r *reader, info byte) (uint64, error) {
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info <= 27:
+		return r.uint(1 << (info - 24))
+	}
+	return 0, fmt.Errorf("invalid CBOR additional information %d", info)
+}
+
+// decodeCBORSimple decodes a simple value or a float with its additional information `info`.
+func decodeCBORSimple(r *reader, info byte) (any, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		bits, err := r.uint(2)
+		return halfToFloat64(uint16(bits)), err
+	case 26:
+		bits, err := r.uint(4)
+		return float64(math.Float32frombits(uint32(bits))), err
+	case 27:
+		bits, err := r.uint(8)
+		return math.Float64frombits(bits), err
+	case cborIndefinite:
+		return nil, fmt.Errorf("unexpected CBOR break")
+	}
+	return nil, fmt.Errorf("unsupported CBOR simple value %d", info)
+}
+
+// halfToFloat64 converts a half-precision float to float64.
+func halfToFloat64(bits uint16) float64 {
+	exp := int(bits>>10) & 0x1f
+	mant := float64(bits & 0x3ff)
+
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if bits&0x8000 != 0 {
+		return -f
+	}
+	return f
+}
+
+// isCBORBreak checks (and skips) the break code of indefinite lengths.
+func isCBORBreak(r *reader) (bool, error) {
+	if r.pos >= len(r.data) {
+		return false, errTruncated
+	}
+	if r.data[r.pos] == cborSimple|cborIndefinite {
+		r.pos++
+		return true, nil
+	}
+	return false, nil
+}
+
+// decodeCBORChunks decodes a string of `major` type with an indefinite length.
+func decodeCBORChunks(r *reader, major byte) (any, error) {
+	var str []byte
+	for {
+		if done, err := isCBORBreak(r); err != nil {
+			return nil, err
+		} else if done {
+			break
+		}
+
+		initial, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		if initial&0xe0 != major || initial&0x1f == cborIndefinite {
+			return nil, fmt.Errorf("invalid chunk of CBOR string 0x%02x", initial)
+		}
+		n, err := decodeCBORArgument(r, initial&0x1f)
+		if err != nil {
+			return nil, err
+		}
+		chunk, err := r.next(n)
+		if err != nil {
+			return nil, err
+		}
+		str = append(str, chunk...)
+	}
+
+	if major == cborText {
+		return string(str), nil
+	}
+	if str == nil {
+		str = []byte{}
+	}
+	return str, nil
+}
+
+// decodeCBORArray decodes an array of `n` elements (or of an indefinite length).
+func decodeCBORArray(r *reader, n uint64, indefinite bool) (any, error) {
+	count, err := r.count(n, 1)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	defer r.leave()
+
+	array := make([]any, 0, count)
+	for i := 0; indefinite || i < count; i++ {
+		if indefinite {
+			if done, err := isCBORBreak(r); err != nil {
+				return nil, err
+			} else if done {
+				break
+			}
+		}
+		elem, err := decodeCBOR(r)
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, elem)
+	}
+	return array, nil
+}
+
+// decodeCBORMap decodes a map of `n` entries (or of an indefinite length).
+func decodeCBORMap(r *reader, n uint64, indefinite bool) (any, error) {
+	count, err := r.count(n, 2)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	defer r.leave()
+
+	m := make(map[any]any, count)
+	for i := 0; indefinite || i < count; i++ {
+		if indefinite {
+			if done, err := isCBORBreak(r); err != nil {
+				return nil, err
+			} else if done {
+				break
+			}
+		}
+		key, err := decodeCBOR(r)
+		if err != nil {
+			return nil, err
+		}
+		value, err := decodeCBOR(r)
+		if err != nil {
+			return nil, err
+		}
+		if err := putKey(m, key, value); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
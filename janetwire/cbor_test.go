@@ -0,0 +1,131 @@
+// cbor_test.go
+
+package janetwire
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/meinside/janet-go"
+)
+
+// TestCBOR tests encoding converted values into CBOR and back.
+func TestCBOR(t *testing.T) {
+	vm, err := janet.NewVM(janet.WithInvalidUTF8(janet.InvalidUTF8Bytes))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	ints, signed, unsigned := `(int/s64 "-9223372036854775808") (int/u64 "18446744073709551615")`, any(int64(math.MinInt64)), any(uint64(math.MaxUint64))
+	if !janet.Build().IntTypes {
+		ints, signed, unsigned = `-9223372036854775808 18446744073709551615`, float64(math.MinInt64), float64(math.MaxUint64) // (`int/*` is not available)
+	}
+	parsed, err := vm.ParseToValue(ctx, `[nil false -2.5 "str" `+ints+` @"\xff" {"k" @[]}]`)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	encoded, err := MarshalCBOR(parsed)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	decoded, err := UnmarshalCBOR(encoded)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	expected := []any{nil, false, -2.5, "str", signed, unsigned, []byte{0xff}, map[any]any{"k": []any{}}}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, decoded)
+	}
+
+	// and back to janet (as int/s64)
+	if janet.Build().IntTypes {
+		result, err := vm.Apply(ctx, "string", decoded.([]any)[4])
+		if err != nil {
+			t.Fatalf("Failed to apply: %v", err)
+		}
+		if result != "-9223372036854775808" {
+			t.Errorf("Expected -9223372036854775808, got %v", result)
+		}
+	}
+
+	// encodings (from the examples of RFC 8949)
+	for _, tc := range []struct {
+		value    any
+		expected []byte
+	}{
+		{int64(0), []byte{0x00}},
+		{int64(24), []byte{0x18, 0x18}},
+		{uint64(1000000), []byte{0x1a, 0x00, 0x0f, 0x42, 0x40}},
+		{int64(-1), []byte{0x20}},
+		{int64(-1000), []byte{0x39, 0x03, 0xe7}},
+		{1.1, []byte{0xfb, 0x3f, 0xf1, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}},
+		{true, []byte{0xf5}},
+		{nil, []byte{0xf6}},
+		{[]byte{1, 2, 3, 4}, []byte{0x44, 0x01, 0x02, 0x03, 0x04}},
+		{"IETF", []byte{0x64, 'I', 'E', 'T', 'F'}},
+		{[]any{int64(1), []any{int64(2), int64(3)}}, []byte{0x82, 0x01, 0x82, 0x02, 0x03}},
+		{map[any]any{"b": int64(2), "a": int64(1)}, []byte{0xa2, 0x61, 'a', 0x01, 0x61, 'b', 0x02}},
+	} {
+		encoded, err := MarshalCBOR(tc.value)
+		if err != nil {
+			t.Fatalf("Failed to encode %#v: %v", tc.value, err)
+		}
+		if !bytes.Equal(encoded, tc.expected) {
+			t.Errorf("Expected % x for %#v, got % x", tc.expected, tc.value, encoded)
+		}
+	}
+
+	// decodings of the forms which are not encoded (from the examples of RFC 8949)
+	for _, tc := range []struct {
+		data     []byte
+		expected any
+	}{
+		{[]byte{0xf9, 0x3c, 0x00}, 1.0},
+		{[]byte{0xf9, 0xc4, 0x00}, -4.0},
+		{[]byte{0xf9, 0x7c, 0x00}, math.Inf(1)},
+		{[]byte{0xf9, 0x00, 0x01}, 5.960464477539063e-8},
+		{[]byte{0xf7}, nil},
+		{[]byte{0xc1, 0x1a, 0x51, 0x4b, 0x67, 0xb0}, int64(1363896240)},
+		{[]byte{0x5f, 0x42, 0x01, 0x02, 0x43, 0x03, 0x04, 0x05, 0xff}, []byte{1, 2, 3, 4, 5}},
+		{[]byte{0x7f, 0x65, 's', 't', 'r', 'e', 'a', 0x64, 'm', 'i', 'n', 'g', 0xff}, "streaming"},
+		{[]byte{0x9f, 0x01, 0x82, 0x02, 0x03, 0x9f, 0x04, 0x05, 0xff, 0xff}, []any{int64(1), []any{int64(2), int64(3)}, []any{int64(4), int64(5)}}},
+		{[]byte{0xbf, 0x61, 'a', 0x01, 0xff}, map[any]any{"a": int64(1)}},
+	} {
+		decoded, err := UnmarshalCBOR(tc.data)
+		if err != nil {
+			t.Fatalf("Failed to decode % x: %v", tc.data, err)
+		}
+		if !reflect.DeepEqual(decoded, tc.expected) {
+			t.Errorf("Expected %#v for % x, got %#v", tc.expected, tc.data, decoded)
+		}
+	}
+
+	// values and data which cannot be converted
+	if _, err := MarshalCBOR(vm.Wrap(struct{}{})); err == nil || !strings.Contains(err.Error(), "cannot encode *janet.GoValue") {
+		t.Errorf("Expected an error for encoding a go value, got %v", err)
+	}
+	for _, tc := range []struct {
+		data []byte
+		err  string
+	}{
+		{[]byte{0x9f, 0x01}, "unexpected end of data"},
+		{[]byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, "unexpected end of data"},
+		{[]byte{0x3b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, "out of range"},
+		{[]byte{0x01, 0x02}, "1 trailing bytes"},
+		{[]byte{0xa1, 0x80, 0x01}, "cannot decode key of type []interface {}"},
+		{[]byte{0x5f, 0x61, 'a', 0xff}, "invalid chunk"},
+		{[]byte{0xff}, "unexpected CBOR break"},
+		{append(bytes.Repeat([]byte{0x81}, maxDepth+1), 0x01), "nested too deeply"},
+	} {
+		if _, err := UnmarshalCBOR(tc.data); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("Expected an error with %q for % x, got %v", tc.err, tc.data, err)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+// msgpack.go
+
+package janetwire
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// MarshalMsgPack encodes a go value converted from janet into MessagePack.
+//
+//   - nil => nil
+//   - bool => bool
+//   - float64, float32 => float 64, float 32
+//   - int64 (eg. `int/s64`) and other integers => int (in the smallest form)
+//   - uint64 (eg. `int/u64`) and other unsigned integers => uint (in the smallest form)
+//   - string => str
+//   - []byte (eg. buffers converted with janet.InvalidUTF8Bytes) => bin
+//   - []any => array
+//   - map[any]any, map[string]any, *janet.OrderedMap => map (in the order of *janet.OrderedMap, or sorted by keys)
+//
+// Other values (eg. *janet.GoValue) fail with an error.
+func MarshalMsgPack(value any) ([]byte, error) {
+	return appendMsgPack(nil, value)
+}
+
+// UnmarshalMsgPack decodes MessagePack into a go value which can be passed to janet.
+//
+//   - nil => nil
+//   - bool => bool
+//   - float 32, float 64 => float64
+//   - int, uint => int64 (or uint64 for the ones beyond int64)
+//   - str => string
+//   - bin => []byte (buffer)
+//   - array => []any (array)
+//   - map => map[any]any (table)
+//
+// Extension types (eg. timestamps) fail with an error.
+func UnmarshalMsgPack(data []byte) (any, error) {
+	return decode(data, decodeMsgPack)
+}
+
+// appendMsgPack appends `value` encoded into MessagePack to `b`.
+func appendMsgPack(b []byte, value any) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if v {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case string:
+		return append(appendMsgPackLength(b, len(v), 0xa0, 32, 0xd9, 0xda, 0xdb), v...), nil
+	case []byte:
+		return append(appendMsgPackLength(b, len(v), 0, 0, 0xc4, 0xc5, 0xc6), v...), nil
+	case []any:
+		b = appendMsgPackLength(b, len(v), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, elem := range v {
+			var err error
+			if b, err = appendMsgPack(b, elem); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+
+	if n := toNumber(value); n.isNumber {
+		switch n.kind {
+		case reflect.Float32:
+			return binary.BigEndian.AppendUint32(append(b, 0xca), math.Float32bits(float32(n.f))), nil
+		case reflect.Float64:
+			return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(n.f)), nil
+		case reflect.Uint64:
+			return appendMsgPackUint(b, n.u), nil
+		}
+		if n.i >= 0 {
+			return appendMsgPackUint(b, uint64(n.i)), nil
+		}
+		return appendMsgPackInt(b, n.i), nil
+	}
+
+	if kvs, ok := entries(value); ok {
+		b = appendMsgPackLength(b, len(kvs), 0x80, 16, 0, 0xde, 0xdf)
+		for _, kv := range kvs {
+			var err error
+			if b, err = appendMsgPack(b, kv.Key); err != nil {
+				return nil, err
+			}
+			if b, err = appendMsgPack(b, kv.Value); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("cannot encode %T into MessagePack", value)
+}
+
+// appendMsgPackLength appends the header of a str, bin, array, or map of `n` elements:
+// `fix` | n if n < `fixMax`, or one of the codes of 8-bit (`c8`, 0 if none), 16-bit (`c16`), and 32-bit (`c32`) lengths.
+func appendMsgPackLength(b []byte, n int, fix byte, fixMax int, c8, c16, c32 byte) []byte {
+	switch {
+	case n < fixMax:
+		return append(b, fix|byte(n))
+	case c8 != 0 && n <= math.MaxUint8:
+		return append(b, c8, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, c16), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, c32), uint32(n))
+}
+
+// appendMsgPackUint appends a non-negative integer in its smallest form.
+func appendMsgPackUint(b []byte, u uint64) []byte {
+	switch {
+	case u <= 0x7f:
+		return append(b, byte(u))
+	case u <= math.MaxUint8:
+		return append(b, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(u))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xcf), u)
+}
+
+// appendMsgPackInt appends a negative integer in its smallest form.
+func appendMsgPackInt(b []byte, i int64) []byte {
+	switch {
+	case i >= -32:
+		return append(b, byte(i))
+	case i >= math.MinInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(i))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(i))
+}
+
+// decodeMsgPack decodes the next MessagePack value.
+func decodeMsgPack(r *reader) (any, error) {
+	code, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case code <= 0x7f:
+		return int64(code), nil
+	case code >= 0xe0:
+		return int64(int8(code)), nil
+	case code&0xe0 == 0xa0:
+		return decodeMsgPackString(r, uint64(code&0x1f))
+	case code&0xf0 == 0x90:
+		return decodeMsgPackArray(r, uint64(code&0x0f))
+	case code&0xf0 == 0x80:
+		return decodeMsgPackMap(r, uint64(code&0x0f))
+	}
+
+	switch code {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xca:
+		bits, err := r.uint(4)
+		return float64(math.Float32frombits(uint32(bits))), err
+	case 0xcb:
+		bits, err := r.uint(8)
+		return math.Float64frombits(bits), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := r.uint(1 << (code - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if u > math.MaxInt64 {
+			return u, nil
+		}
+		return int64(u), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (code - 0xd0)
+		u, err := r.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		shift := 64 - 8*size
+		return int64(u<<shift) >> shift, nil // (sign-extended)
+	case 0xd9, 0xda, 0xdb:
+		n, err := r.uint(1 << (code - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return decodeMsgPackString(r, n)
+	case 0xc4, 0xc5, 0xc6:
+		n, err := r.uint(1 << (code - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		bin, err := r.next(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, bin...), nil
+	case 0xdc, 0xdd:
+		n, err := r.uint(2 << (code - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return decodeMsgPackArray(r, n)
+	case 0xde, 0xdf:
+		n, err := r.uint(2 << (code - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return decodeMsgPackMap(r, n)
+	}
+	return nil, fmt.Errorf("unsupported MessagePack code 0x%02x", code)
+}
+
+// decodeMsgPackString decodes a str of `n` bytes.
+func decodeMsgPackString(r *reader, n uint64) (any, error) {
+	str, err := r.next(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(str), nil
+}
+
+// decodeMsgPackArray decodes an array of `n` elements.
+func decodeMsgPackArray(r *reader, n uint64) (any, error) {
+	count, err := r.count(n, 1)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	defer r.leave()
+
+	array := make([]any, count)
+	for i := range array {
+		if array[i], err = decodeMsgPack(r); err != nil {
+			return nil, err
+		}
+	}
+	return array, nil
+}
+
+// decodeMsgPackMap decodes a map of `n` entries.
+func decodeMsgPackMap(r *reader, n uint64) (any, error) {
+	count, err := r.count(n, 2)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	defer r.leave()
+
+	m := make(map[any]any, count)
+	for range count {
+		key, err := decodeMsgPack(r)
+		if err != nil {
+			return nil, err
+		}
+		value, err := decodeMsgPack(r)
+		if err != nil {
+			return nil, err
+		}
+		if err := putKey(m, key, value); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,123 @@
+// msgpack_test.go
+
+package janetwire
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/meinside/janet-go"
+)
+
+// TestMsgPack tests encoding converted values into MessagePack and back.
+func TestMsgPack(t *testing.T) {
+	vm, err := janet.NewVM(janet.WithInvalidUTF8(janet.InvalidUTF8Bytes))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	ints, signed, unsigned := `(int/s64 -7) (int/u64 "18446744073709551615")`, any(int64(-7)), any(uint64(math.MaxUint64))
+	if !janet.Build().IntTypes {
+		ints, signed, unsigned = `-7 18446744073709551615`, -7.0, float64(math.MaxUint64) // (`int/*` is not available)
+	}
+	parsed, err := vm.ParseToValue(ctx, `[nil true 1.5 "str" `+ints+` @"\xff\x00" {:a [1 2]}]`)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	encoded, err := MarshalMsgPack(parsed)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	decoded, err := UnmarshalMsgPack(encoded)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	expected := []any{nil, true, 1.5, "str", signed, unsigned, []byte{0xff, 0x00}, map[any]any{":a": []any{1.0, 2.0}}}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, decoded)
+	}
+
+	// and back to janet
+	typ, err := vm.Apply(ctx, "type", decoded.([]any)[6])
+	if err != nil {
+		t.Fatalf("Failed to apply: %v", err)
+	}
+	if typ != ":buffer" {
+		t.Errorf("Expected :buffer, got %v", typ)
+	}
+
+	// encodings in the smallest forms
+	for _, tc := range []struct {
+		value    any
+		expected []byte
+	}{
+		{int64(127), []byte{0x7f}},
+		{int64(-32), []byte{0xe0}},
+		{int64(-33), []byte{0xd0, 0xdf}},
+		{uint64(256), []byte{0xcd, 0x01, 0x00}},
+		{int64(math.MinInt64), []byte{0xd3, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+		{float32(1), []byte{0xca, 0x3f, 0x80, 0x00, 0x00}},
+		{"abc", []byte{0xa3, 'a', 'b', 'c'}},
+		{strings.Repeat("x", 32), append([]byte{0xd9, 32}, strings.Repeat("x", 32)...)},
+		{[]byte{1}, []byte{0xc4, 0x01, 0x01}},
+		{map[string]any{"b": false, "a": nil}, []byte{0x82, 0xa1, 'a', 0xc0, 0xa1, 'b', 0xc2}},
+	} {
+		encoded, err := MarshalMsgPack(tc.value)
+		if err != nil {
+			t.Fatalf("Failed to encode %#v: %v", tc.value, err)
+		}
+		if !bytes.Equal(encoded, tc.expected) {
+			t.Errorf("Expected % x for %#v, got % x", tc.expected, tc.value, encoded)
+		}
+	}
+
+	// large collections and ordered maps
+	large := make([]any, 70000)
+	for i := range large {
+		large[i] = int64(i)
+	}
+	ordered := janet.NewOrderedMap()
+	ordered.Set(":z", large)
+	ordered.Set(":a", strings.Repeat("y", 300))
+	encoded, err = MarshalMsgPack(ordered)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	if !bytes.HasPrefix(encoded, []byte{0x82, 0xa2, ':', 'z', 0xdd}) {
+		t.Errorf("Expected entries in their order, got % x", encoded[:8])
+	}
+	decoded, err = UnmarshalMsgPack(encoded)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, ordered.Map()) {
+		t.Errorf("Expected the ordered map to be decoded")
+	}
+
+	// values and data which cannot be converted
+	if _, err := MarshalMsgPack(vm.Wrap(struct{}{})); err == nil || !strings.Contains(err.Error(), "cannot encode *janet.GoValue") {
+		t.Errorf("Expected an error for encoding a go value, got %v", err)
+	}
+	for _, tc := range []struct {
+		data []byte
+		err  string
+	}{
+		{[]byte{0x92, 0x01}, "unexpected end of data"},
+		{[]byte{0xdd, 0xff, 0xff, 0xff, 0xff}, "unexpected end of data"},
+		{[]byte{0x01, 0x02}, "1 trailing bytes"},
+		{[]byte{0x81, 0x91, 0x01, 0x01}, "cannot decode key of type []interface {}"},
+		{[]byte{0xd4, 0x01, 0x01}, "unsupported MessagePack code 0xd4"},
+		{append(bytes.Repeat([]byte{0x91}, maxDepth+1), 0x01), "nested too deeply"},
+	} {
+		if _, err := UnmarshalMsgPack(tc.data); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("Expected an error with %q for % x, got %v", tc.err, tc.data, err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// wire.go
+
+// Package janetwire encodes go values converted from janet (eg. with janet.VM.ParseToValue)
+// into compact binary formats (MessagePack and CBOR), and decodes them back to go values which can be passed to janet,
+// for transporting results of scripts between services.
+//
+// Unlike JSON, binary buffers ([]byte) and integers (int64 and uint64, eg. of `int/s64` and `int/u64`)
+// are kept distinct from strings and numbers (float64).
+package janetwire
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/meinside/janet-go"
+)
+
+// max nesting depth of decoded collections
+const maxDepth = 1024
+
+// errTruncated is returned when the data ends in the middle of a value.
+var errTruncated = errors.New("unexpected end of data")
+
+// number is a number of go, normalized for encoding.
+type number struct {
+	kind     reflect.Kind // reflect.Int64, reflect.Uint64, reflect.Float32, or reflect.Float64
+	i        int64
+	u        uint64
+	f        float64
+	isNumber bool
+}
+
+// toNumber normalizes `value` if it is a go number.
+func toNumber(value any) number {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return number{kind: reflect.Int64, i: rv.Int(), isNumber: true}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return number{kind: reflect.Uint64, u: rv.Uint(), isNumber: true}
+	case reflect.Float32:
+		return number{kind: reflect.Float32, f: rv.Float(), isNumber: true}
+	case reflect.Float64:
+		return number{kind: reflect.Float64, f: rv.Float(), isNumber: true}
+	}
+	return number{}
+}
+
+// entries returns the entries of a go map converted from janet, or false if `value` is not one.
+//
+// Entries of *janet.OrderedMap are in its order, and the ones of other maps are sorted by their keys
+// (so that encoded data are deterministic).
+func entries(value any) ([]janet.Entry, bool) {
+	var result []janet.Entry
+	switch m := value.(type) {
+	case *janet.OrderedMap:
+		return m.Entries(), true
+	case map[any]any:
+		for key, val := range m {
+			result = append(result, janet.Entry{Key: key, Value: val})
+		}
+	case map[string]any:
+		for key, val := range m {
+			result = append(result, janet.Entry{Key: key, Value: val})
+		}
+	default:
+		return nil, false
+	}
+	slices.SortFunc(result, func(a, b janet.Entry) int {
+		return cmp.Compare(fmt.Sprintf("%T %v", a.Key, a.Key), fmt.Sprintf("%T %v", b.Key, b.Key))
+	})
+	return result, true
+}
+
+// reader reads encoded data.
+type reader struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+// byte reads the next byte.
+func (r *reader) byte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, errTruncated
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+// next reads the next `n` bytes.
+func (r *reader) next(n uint64) ([]byte, error) {
+	if n > uint64(len(r.data)-r.pos) {
+		return nil, errTruncated
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+// uint reads the next big-endian unsigned integer of `size` bytes (1, 2, 4, or 8).
+func (r *reader) uint(size int) (uint64, error) {
+	b, err := r.next(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// count checks the number of elements (`n`, each of which takes `size` bytes at least) of a collection
+// against the rest of the data, not to allocate huge collections for malicious data.
+func (r *reader) count(n uint64, size uint64) (int, error) {
+	if n > uint64(len(r.data)) || n*size > uint64(len(r.data)-r.pos) {
+		return 0, errTruncated
+	}
+	return int(n), nil
+}
+
+// enter enters a collection, checking its nesting depth.
+func (r *reader) enter() error {
+	r.depth++
+	if r.depth > maxDepth {
+		return fmt.Errorf("nested too deeply (max %d)", maxDepth)
+	}
+	return nil
+}
+
+// leave leaves a collection.
+func (r *reader) leave() {
+	r.depth--
+}
+
+// putKey puts `key` and `value` into a decoded map, failing with keys which cannot be go map keys.
+func putKey(m map[any]any, key, value any) error {
+	if key != nil && !reflect.TypeOf(key).Comparable() {
+		return fmt.Errorf("cannot decode key of type %T", key)
+	}
+	m[key] = value
+	return nil
+}
+
+// decode decodes the whole `data` with `decodeValue`, failing with trailing bytes.
+func decode(data []byte, decodeValue func(r *reader) (any, error)) (any, error) {
+	r := &reader{data: data}
+	value, err := decodeValue(r)
+	if err != nil {
+		return nil, fmt.Errorf("at offset %d: %w", r.pos, err)
+	}
+	if r.pos != len(data) {
+		return nil, fmt.Errorf("at offset %d: %d trailing bytes", r.pos, len(data)-r.pos)
+	}
+	return value, nil
+}
@@ -0,0 +1,92 @@
+// leaks.go
+
+package janet
+
+/*
+#include "janet.h"
+
+// defined in janet_bundled.go or janet_system.go
+void *beginLeakCheck();
+JanetArray *endLeakCheck(void *marker);
+
+// returns the kind of a leaked resource
+static const char *leakKind(Janet leak) {
+    if (janet_checktype(leak, JANET_FIBER)) return "task";
+    if (janet_checkabstract(leak, &janet_file_type)) return "file";
+    return "stream";
+}
+
+// closes leaked files and streams, and cancels leaked tasks (which are cleaned up in the event loop)
+static void closeLeaks(JanetArray *leaks) {
+    janet_gcroot(janet_wrap_array(leaks));
+    for (int32_t i = 0; i < leaks->count; i++) {
+        Janet leak = leaks->data[i];
+        if (janet_checkabstract(leak, &janet_file_type)) {
+            janet_file_close((JanetFile *)janet_unwrap_abstract(leak));
+        }
+#ifdef JANET_EV
+        else if (janet_checkabstract(leak, &janet_stream_type)) {
+            janet_stream_close((JanetStream *)janet_unwrap_abstract(leak));
+        } else if (janet_checktype(leak, JANET_FIBER)) {
+            JanetFiber *fiber = janet_unwrap_fiber(leak);
+            if (janet_fiber_can_resume(fiber)) {
+                janet_cancel(fiber, janet_cstringv("leaked task"));
+            }
+        }
+#endif
+    }
+#ifdef JANET_EV
+    janet_loop();
+#endif
+    janet_gcunroot(janet_wrap_array(leaks));
+}
+*/
+import "C"
+
+// LeakPolicy is the policy for resources (files, streams, and tasks of the event loop)
+// which are opened by executions and left open when they complete.
+type LeakPolicy int
+
+// LeakPolicy constants
+const (
+	LeaksIgnored  LeakPolicy = iota // not checked
+	LeaksReported                   // reported to the handler
+	LeaksClosed                     // closed (tasks are cancelled), and reported to the handler
+)
+
+// Leak is a resource which was opened by an execution and left open when it completed.
+type Leak struct {
+	Kind  string // "file", "stream", or "task"
+	Value string // string representation of the resource (eg. "<core/file 0x...>")
+}
+
+// checkLeaks runs `fn` which evaluates janet `expression`,
+// and checks resources which were opened and left open in it, with the policy of the VM (see WithLeakCheck).
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) checkLeaks(expression string, fn func()) {
+	if vm.options.leakPolicy == LeaksIgnored {
+		fn()
+		return
+	}
+
+	marker := C.beginLeakCheck()
+	fn()
+	found := C.endLeakCheck(marker)
+	if found.count == 0 {
+		return
+	}
+
+	leaks := make([]Leak, 0, int(found.count))
+	for _, leak := range janetIndexed(C.janet_wrap_array(found)) {
+		leaks = append(leaks, Leak{
+			Kind:  C.GoString(C.leakKind(leak)),
+			Value: janetValueToString(leak),
+		})
+	}
+	if vm.options.leakPolicy == LeaksClosed {
+		C.closeLeaks(found)
+	}
+	if vm.options.leakHandler != nil {
+		vm.options.leakHandler(expression, leaks)
+	}
+}
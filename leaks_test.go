@@ -0,0 +1,70 @@
+// leaks_test.go
+
+package janet
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// TestLeakCheck tests checking resources left open by executions.
+func TestLeakCheck(t *testing.T) {
+	if !checksLeaks {
+		t.Skip("leaks are not checked with system janet")
+	}
+
+	path := filepath.Join(t.TempDir(), "leak.txt")
+
+	for _, tc := range []struct {
+		policy LeakPolicy
+		closed bool
+	}{
+		{LeaksReported, false},
+		{LeaksClosed, true},
+	} {
+		var reported []Leak
+		vm, err := NewVM(WithLeakCheck(tc.policy, func(expression string, leaks []Leak) {
+			reported = append(reported, leaks...)
+		}))
+		if err != nil {
+			t.Fatalf("Failed to create Janet VM: %v", err)
+		}
+
+		ctx := context.TODO()
+
+		// closed resources are not leaks
+		if _, _, _, err := vm.Execute(ctx, `(with [f (file/open "`+path+`" :w)] (file/write f "ok"))`); err != nil {
+			t.Fatalf("Failed to execute: %v", err)
+		}
+		if len(reported) != 0 {
+			t.Errorf("Expected no leaks, got %v", reported)
+		}
+
+		// (streams are opened with `os/open` only with the event loop)
+		expression, streams := `(def f (file/open "`+path+`")) nil`, 0
+		if Build().EV {
+			expression, streams = `(def f (file/open "`+path+`")) (def s (os/open "`+path+`")) nil`, 1
+		}
+		if _, _, _, err := vm.Execute(ctx, expression); err != nil {
+			t.Fatalf("Failed to execute: %v", err)
+		}
+		kinds := map[string]int{}
+		for _, leak := range reported {
+			kinds[leak.Kind]++
+		}
+		if kinds["file"] != 1 || kinds["stream"] != streams {
+			t.Errorf("Expected a leaked file and %d stream(s), got %v", streams, reported)
+		}
+
+		evaluated, _, _, err := vm.Execute(ctx, `(try (do (file/read f :all) :open) ([_] :closed))`)
+		if err != nil {
+			t.Fatalf("Failed to execute: %v", err)
+		}
+		if closed := evaluated == ":closed"; closed != tc.closed {
+			t.Errorf("Expected file to be closed: %v, got %s", tc.closed, evaluated)
+		}
+
+		vm.Close()
+	}
+}
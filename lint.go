@@ -0,0 +1,484 @@
+// lint.go
+
+package janet
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Severity is the severity of lint diagnostics.
+type Severity int
+
+// severities of lint diagnostics
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// names of lint rules
+const (
+	LintRuleSyntax             = "syntax"               // source cannot be parsed
+	LintRuleUnusedBinding      = "unused-binding"       // local bindings which are never referenced
+	LintRuleUnusedParameter    = "unused-parameter"     // function parameters which are never referenced
+	LintRuleShadowedCoreSymbol = "shadowed-core-symbol" // bindings which shadow core symbols
+	LintRuleArity              = "arity"                // calls with wrong number of arguments
+	LintRuleDeprecated         = "deprecated"           // references to deprecated symbols
+)
+
+// default severities of lint rules
+var defaultLintSeverities = map[string]Severity{
+	LintRuleSyntax:             SeverityError,
+	LintRuleUnusedBinding:      SeverityWarning,
+	LintRuleUnusedParameter:    SeverityInfo,
+	LintRuleShadowedCoreSymbol: SeverityWarning,
+	LintRuleArity:              SeverityError,
+	LintRuleDeprecated:         SeverityWarning,
+}
+
+// Diagnostic is a problem found in janet source by Lint.
+type Diagnostic struct {
+	Rule     string
+	Severity Severity
+	Message  string
+	Start    Position
+	End      Position
+}
+
+// String returns the diagnostic in `line:column: severity: message (rule)` format.
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s (%s)", d.Start, d.Severity, d.Message, d.Rule)
+}
+
+// LintOptions is the options for linting janet source.
+type LintOptions struct {
+	// names of rules to be disabled
+	DisabledRules []string
+
+	// severities of rules which override the default ones
+	Severities map[string]Severity
+
+	// symbols which should not be shadowed (eg. the result of VM.Bindings);
+	// shadowed core symbols are not checked when empty
+	CoreSymbols []string
+
+	// deprecated symbols, with messages (eg. suggestions of replacements)
+	Deprecated map[string]string
+}
+
+// Lint checks janet source `src` (without evaluating it) and returns diagnostics sorted by their positions.
+func Lint(src string, opts LintOptions) (diagnostics []Diagnostic) {
+	l := &linter{
+		opts:        opts,
+		coreSymbols: map[string]bool{},
+		arities:     map[string]arity{},
+	}
+	for _, symbol := range opts.CoreSymbols {
+		l.coreSymbols[symbol] = true
+	}
+
+	nodes, err := ParseAST(src)
+	if err != nil {
+		pos := Position{Line: 1, Column: 1}
+		if parseErr, ok := err.(*ParseError); ok {
+			pos = parseErr.Position
+		}
+		l.report(LintRuleSyntax, err.Error(), &Node{Start: pos, End: pos})
+		return l.diagnostics
+	}
+
+	// collect arities of top-level functions first, so that calls before their definitions can be checked
+	for _, node := range nodes {
+		l.collectArity(node)
+	}
+
+	global := &lintScope{bindings: map[string]*lintBinding{}, global: true}
+	for _, node := range nodes {
+		l.walk(node, global)
+	}
+
+	sort.SliceStable(l.diagnostics, func(i, j int) bool {
+		return l.diagnostics[i].Start.Offset < l.diagnostics[j].Start.Offset
+	})
+	return l.diagnostics
+}
+
+// arity is the number of arguments a function accepts.
+type arity struct {
+	min int
+	max int // -1 for variadic functions
+}
+
+// lintBinding is a binding in a lint scope.
+type lintBinding struct {
+	node  *Node
+	param bool
+	used  bool
+}
+
+// lintScope is a lexical scope for linting.
+type lintScope struct {
+	parent   *lintScope
+	bindings map[string]*lintBinding
+	global   bool
+}
+
+// linter checks janet AST nodes.
+type linter struct {
+	opts        LintOptions
+	coreSymbols map[string]bool
+	arities     map[string]arity
+	diagnostics []Diagnostic
+}
+
+// report adds a diagnostic of `rule` for `node` unless the rule is disabled.
+func (l *linter) report(rule, message string, node *Node) {
+	if slices.Contains(l.opts.DisabledRules, rule) {
+		return
+	}
+
+	severity, exists := l.opts.Severities[rule]
+	if !exists {
+		severity = defaultLintSeverities[rule]
+	}
+	l.diagnostics = append(l.diagnostics, Diagnostic{
+		Rule:     rule,
+		Severity: severity,
+		Message:  message,
+		Start:    node.Start,
+		End:      node.End,
+	})
+}
+
+// collectArity collects the arity of a top-level function definition.
+func (l *linter) collectArity(node *Node) {
+	forms := node.Forms()
+	if node.Kind != NodeTuple || len(forms) < 3 || forms[0].Kind != NodeSymbol || forms[1].Kind != NodeSymbol {
+		return
+	}
+	switch forms[0].Text {
+	case "defn", "defn-", "varfn":
+		if params := findParams(forms[2:]); params != nil {
+			l.arities[forms[1].Text] = paramsArity(params)
+		}
+	}
+}
+
+// findParams returns the first bracket tuple (parameters) among `forms`.
+func findParams(forms []*Node) *Node {
+	for _, form := range forms {
+		if form.Kind == NodeBracketTuple {
+			return form
+		}
+	}
+	return nil
+}
+
+// paramsArity returns the arity of function parameters.
+func paramsArity(params *Node) arity {
+	a := arity{}
+	optional := false
+	for _, param := range params.Forms() {
+		switch param.Text {
+		case "&", "&keys", "&named":
+			a.max = -1
+			return a
+		case "&opt":
+			optional = true
+		default:
+			if !optional {
+				a.min++
+			}
+			a.max++
+		}
+	}
+	return a
+}
+
+// declare declares the bindings of a binding pattern (symbols, or destructuring tuples/arrays/structs/tables).
+func (l *linter) declare(pattern *Node, scope *lintScope, param bool) {
+	switch pattern.Kind {
+	case NodeSymbol:
+		switch pattern.Text {
+		case "&", "&opt", "&keys", "&named":
+			return
+		}
+		if l.coreSymbols[pattern.Text] {
+			l.report(LintRuleShadowedCoreSymbol, fmt.Sprintf("`%s` shadows a core symbol", pattern.Text), pattern)
+		}
+		scope.bindings[pattern.Text] = &lintBinding{node: pattern, param: param}
+	case NodeTuple, NodeBracketTuple, NodeArray:
+		for _, form := range pattern.Forms() {
+			l.declare(form, scope, param)
+		}
+	case NodeStruct, NodeTable:
+		forms := pattern.Forms()
+		for i := 1; i < len(forms); i += 2 {
+			l.declare(forms[i], scope, param)
+		}
+	}
+}
+
+// newScope creates a new scope, runs `fn` with it, and reports its unused bindings.
+func (l *linter) newScope(parent *lintScope, fn func(scope *lintScope)) {
+	scope := &lintScope{parent: parent, bindings: map[string]*lintBinding{}}
+	fn(scope)
+	l.reportUnused(scope)
+}
+
+// reportUnused reports unused bindings of a scope (except for the ones starting with `_`).
+func (l *linter) reportUnused(scope *lintScope) {
+	names := make([]string, 0, len(scope.bindings))
+	for name := range scope.bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		binding := scope.bindings[name]
+		if binding.used || strings.HasPrefix(name, "_") {
+			continue
+		}
+		if binding.param {
+			l.report(LintRuleUnusedParameter, fmt.Sprintf("parameter `%s` is never used", name), binding.node)
+		} else {
+			l.report(LintRuleUnusedBinding, fmt.Sprintf("`%s` is never used", name), binding.node)
+		}
+	}
+}
+
+// resolve marks the binding of `symbol` as used, and returns whether it is locally bound.
+func (l *linter) resolve(symbol string, scope *lintScope) bool {
+	for s := scope; s != nil; s = s.parent {
+		if binding, exists := s.bindings[symbol]; exists {
+			binding.used = true
+			return !s.global
+		}
+	}
+	return false
+}
+
+// walkAll walks all `nodes` in `scope`.
+func (l *linter) walkAll(nodes []*Node, scope *lintScope) {
+	for _, node := range nodes {
+		l.walk(node, scope)
+	}
+}
+
+// walk walks a node in `scope`.
+func (l *linter) walk(node *Node, scope *lintScope) {
+	switch node.Kind {
+	case NodeSymbol:
+		if !l.resolve(node.Text, scope) {
+			if message, deprecated := l.opts.Deprecated[node.Text]; deprecated {
+				l.report(LintRuleDeprecated, fmt.Sprintf("`%s` is deprecated: %s", node.Text, message), node)
+			}
+		}
+	case NodeReaderMacro:
+		switch node.Text {
+		case "'":
+			// quoted forms are not evaluated
+		case "~":
+			l.walkQuasiquoted(node.Children[0], scope)
+		default:
+			l.walkAll(node.Children, scope)
+		}
+	case NodeTuple:
+		l.walkTuple(node, scope)
+	default:
+		if node.Kind.IsCollection() {
+			l.walkAll(node.Forms(), scope)
+		}
+	}
+}
+
+// walkQuasiquoted walks only the unquoted parts of a quasiquoted node.
+func (l *linter) walkQuasiquoted(node *Node, scope *lintScope) {
+	switch {
+	case node.Kind == NodeReaderMacro && node.Text == ",":
+		l.walkAll(node.Children, scope)
+	case node.Kind == NodeReaderMacro, node.Kind.IsCollection():
+		for _, child := range node.Forms() {
+			l.walkQuasiquoted(child, scope)
+		}
+	}
+}
+
+// walkTuple walks a tuple, handling special forms and binding macros.
+func (l *linter) walkTuple(node *Node, scope *lintScope) {
+	forms := node.Forms()
+	if len(forms) == 0 {
+		return
+	}
+
+	head := forms[0]
+	if head.Kind != NodeSymbol {
+		l.walkAll(forms, scope)
+		return
+	}
+	if _, local := scope.lookup(head.Text); !local {
+		switch head.Text {
+		case "quote":
+			return
+		case "quasiquote":
+			l.walkQuasiquoted(&Node{Kind: NodeArray, Children: forms[1:]}, scope)
+			return
+		case "def", "def-", "var", "var-", "defglobal", "varglobal":
+			if len(forms) >= 3 {
+				l.walk(forms[len(forms)-1], scope)
+				l.declare(forms[1], scope, false)
+			}
+			l.walk(head, scope)
+			return
+		case "defn", "defn-", "defmacro", "defmacro-", "varfn":
+			if len(forms) >= 3 {
+				if forms[1].Kind == NodeSymbol {
+					l.declare(forms[1], scope, false)
+				}
+				l.walkFunction(forms[2:], scope)
+			}
+			l.walk(head, scope)
+			return
+		case "fn":
+			rest := forms[1:]
+			if len(rest) > 0 && rest[0].Kind == NodeSymbol {
+				rest = rest[1:]
+			}
+			l.walkFunction(rest, scope)
+			return
+		case "let", "if-let", "when-let", "with":
+			if len(forms) >= 2 && forms[1].Kind == NodeBracketTuple {
+				l.newScope(scope, func(inner *lintScope) {
+					bindings := forms[1].Forms()
+					for i := 0; i+1 < len(bindings); i += 2 {
+						l.walk(bindings[i+1], inner)
+						l.declare(bindings[i], inner, false)
+					}
+					if head.Text == "with" && len(bindings) > 2 {
+						l.walkAll(bindings[2:], inner)
+					}
+					l.walkAll(forms[2:], inner)
+				})
+				l.walk(head, scope)
+				return
+			}
+		case "each", "eachk", "eachp", "eachy":
+			if len(forms) >= 3 {
+				l.walk(forms[2], scope)
+				l.newScope(scope, func(inner *lintScope) {
+					l.declare(forms[1], inner, false)
+					l.walkAll(forms[3:], inner)
+				})
+				l.walk(head, scope)
+				return
+			}
+		case "for", "forv":
+			if len(forms) >= 4 {
+				l.walkAll(forms[2:4], scope)
+				l.newScope(scope, func(inner *lintScope) {
+					l.declare(forms[1], inner, false)
+					l.walkAll(forms[4:], inner)
+				})
+				l.walk(head, scope)
+				return
+			}
+		case "loop", "seq", "generate", "catseq", "tabseq":
+			if len(forms) >= 2 && forms[1].Kind == NodeBracketTuple {
+				l.newScope(scope, func(inner *lintScope) {
+					l.walkLoopHead(forms[1].Forms(), inner)
+					l.walkAll(forms[2:], inner)
+				})
+				l.walk(head, scope)
+				return
+			}
+		}
+
+		// calls of top-level functions with known arities
+		if a, exists := l.arities[head.Text]; exists {
+			if args := len(forms) - 1; args < a.min || (a.max >= 0 && args > a.max) {
+				l.report(LintRuleArity, fmt.Sprintf("`%s` expects %s, but called with %d", head.Text, a, args), node)
+			}
+		}
+	}
+
+	l.walkAll(forms, scope)
+}
+
+// walkLoopHead walks the head of `loop` (and similar macros) in `scope`.
+func (l *linter) walkLoopHead(forms []*Node, scope *lintScope) {
+	for i := 0; i+1 < len(forms); {
+		if forms[i].Kind == NodeKeyword { // modifiers (eg. `:when cond`, `:let [x 1]`)
+			if forms[i].Text == ":let" && forms[i+1].Kind == NodeBracketTuple {
+				bindings := forms[i+1].Forms()
+				for j := 0; j+1 < len(bindings); j += 2 {
+					l.walk(bindings[j+1], scope)
+					l.declare(bindings[j], scope, false)
+				}
+			} else {
+				l.walk(forms[i+1], scope)
+			}
+			i += 2
+			continue
+		}
+
+		// `binding :verb object`
+		if i+2 < len(forms) {
+			l.walk(forms[i+2], scope)
+		}
+		l.declare(forms[i], scope, false)
+		i += 3
+	}
+}
+
+// walkFunction walks the parameters and body of a function (with optional docstrings and metadata before them).
+func (l *linter) walkFunction(forms []*Node, scope *lintScope) {
+	for i, form := range forms {
+		if form.Kind != NodeBracketTuple {
+			continue
+		}
+
+		l.newScope(scope, func(inner *lintScope) {
+			l.declare(form, inner, true)
+			l.walkAll(forms[i+1:], inner)
+		})
+		return
+	}
+}
+
+// lookup returns the scope of `symbol`'s binding, and whether it is a local one.
+func (s *lintScope) lookup(symbol string) (*lintScope, bool) {
+	for scope := s; scope != nil; scope = scope.parent {
+		if _, exists := scope.bindings[symbol]; exists {
+			return scope, !scope.global
+		}
+	}
+	return nil, false
+}
+
+// String returns the arity in a human-readable format.
+func (a arity) String() string {
+	switch {
+	case a.max < 0:
+		return fmt.Sprintf("at least %d argument(s)", a.min)
+	case a.min == a.max:
+		return fmt.Sprintf("%d argument(s)", a.min)
+	default:
+		return fmt.Sprintf("%d to %d argument(s)", a.min, a.max)
+	}
+}
@@ -0,0 +1,93 @@
+// lint_test.go
+
+package janet
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+// TestLint tests the Lint function.
+func TestLint(t *testing.T) {
+	src := `(defn add [x y &opt z]
+  (def unused 1)
+  (let [string "shadowed" _ignored 2]
+    (+ x y)))
+
+(add 1)
+(add 1 2 3 4)
+(add 1 2 3)
+(old-func 42)
+(each item [1 2 3] (print "hello"))
+(loop [i :range [0 10] :let [j (* i 2)]] (print j))
+'(quoted unused-func)`
+
+	diagnostics := Lint(src, LintOptions{
+		CoreSymbols: []string{"string", "print"},
+		Deprecated:  map[string]string{"old-func": "use new-func instead"},
+	})
+
+	expected := []string{
+		`1:21: info: parameter ` + "`z`" + ` is never used (unused-parameter)`,
+		`2:8: warning: ` + "`unused`" + ` is never used (unused-binding)`,
+		`3:9: warning: ` + "`string`" + ` shadows a core symbol (shadowed-core-symbol)`,
+		`3:9: warning: ` + "`string`" + ` is never used (unused-binding)`,
+		`6:1: error: ` + "`add`" + ` expects 2 to 3 argument(s), but called with 1 (arity)`,
+		`7:1: error: ` + "`add`" + ` expects 2 to 3 argument(s), but called with 4 (arity)`,
+		`9:2: warning: ` + "`old-func`" + ` is deprecated: use new-func instead (deprecated)`,
+		`10:7: warning: ` + "`item`" + ` is never used (unused-binding)`,
+	}
+	var got []string
+	for _, diagnostic := range diagnostics {
+		got = append(got, diagnostic.String())
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("Expected diagnostics:\n%v\ngot:\n%v", expected, got)
+	}
+
+	// disabled rules and overridden severities
+	diagnostics = Lint(src, LintOptions{
+		DisabledRules: []string{LintRuleUnusedParameter, LintRuleUnusedBinding, LintRuleArity},
+		Severities:    map[string]Severity{LintRuleDeprecated: SeverityError},
+		Deprecated:    map[string]string{"old-func": "use new-func instead"},
+	})
+	if len(diagnostics) != 1 || diagnostics[0].Rule != LintRuleDeprecated || diagnostics[0].Severity != SeverityError {
+		t.Errorf("Expected only one deprecated error, got: %v", diagnostics)
+	}
+
+	// syntax errors
+	diagnostics = Lint(`(defn broken [x]`, LintOptions{})
+	if len(diagnostics) != 1 || diagnostics[0].Rule != LintRuleSyntax || diagnostics[0].Severity != SeverityError {
+		t.Errorf("Expected a syntax error, got: %v", diagnostics)
+	}
+}
+
+// TestBindings tests the Bindings function.
+func TestBindings(t *testing.T) {
+	vm, err := SharedVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	if _, _, _, err := vm.Execute(context.TODO(), `(defn my-lint-test-func [] nil)`); err != nil {
+		t.Fatalf("Failed to define a function: %v", err)
+	}
+
+	names, err := vm.Bindings(context.TODO())
+	if err != nil {
+		t.Fatalf("Failed to list bindings: %v", err)
+	}
+	for _, name := range []string{"print", "string/join", "defn", "my-lint-test-func"} {
+		if !slices.Contains(names, name) {
+			t.Errorf("Expected bindings to contain '%s'", name)
+		}
+	}
+
+	// core symbols from the VM can be used for linting
+	diagnostics := Lint(`(defn f [] (def map 1) map)`, LintOptions{CoreSymbols: names})
+	if len(diagnostics) != 1 || diagnostics[0].Rule != LintRuleShadowedCoreSymbol {
+		t.Errorf("Expected a shadowed core symbol warning, got: %v", diagnostics)
+	}
+}
@@ -0,0 +1,75 @@
+// logging.go
+
+package janet
+
+/*
+#include "janet.h"
+*/
+import "C"
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"unsafe"
+)
+
+// WithLogger makes `log/debug`, `log/info`, `log/warn`, and `log/error` functions available in the VM,
+// which log messages to `logger` with the context of the calling execution, so that logs of scripts
+// land in the application's logging pipeline. For example,
+//
+//	(log/info "user created" {:id 42 :name "alice" :roles ["admin"]})
+//
+// logs "user created" with attributes id=42, name=alice, and roles=[admin].
+// Keywords as keys of fields are logged without their leading colons, and nested structs (or tables) as groups.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *vmOptions) {
+		o.logger = logger
+	}
+}
+
+// handleLog converts janet `fields` (nil if not given) and logs `message` with them at `level`.
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) handleLog(level int, message string, fields unsafe.Pointer) error {
+	ctx := vm.requestContext()
+	if !vm.options.logger.Enabled(ctx, slog.Level(level)) {
+		return nil
+	}
+
+	var attrs []slog.Attr
+	if fields != nil {
+		converted, err := vm.decoder(ctx).decode(*(*C.Janet)(fields))
+		if err != nil {
+			return fmt.Errorf("failed to convert fields: %w", err)
+		}
+		if dict, ok := asMap(converted); ok {
+			attrs = logAttrs(dict)
+		}
+	}
+	vm.options.logger.LogAttrs(ctx, slog.Level(level), message, attrs...)
+	return nil
+}
+
+// logAttrs converts fields converted from janet to log attributes, sorted by their keys.
+func logAttrs(fields map[any]any) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for key, value := range fields {
+		name, ok := key.(string)
+		if ok {
+			name = strings.TrimPrefix(name, ":")
+		} else {
+			name = fmt.Sprint(key)
+		}
+		if group, ok := asMap(value); ok {
+			attrs = append(attrs, slog.Attr{Key: name, Value: slog.GroupValue(logAttrs(group)...)})
+		} else {
+			attrs = append(attrs, slog.Any(name, value))
+		}
+	}
+	slices.SortFunc(attrs, func(a, b slog.Attr) int {
+		return cmp.Compare(a.Key, b.Key)
+	})
+	return attrs
+}
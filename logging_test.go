@@ -0,0 +1,64 @@
+// logging_test.go
+
+package janet
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// TestWithLogger tests the `log/*` functions enabled with WithLogger.
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{} // remove time for comparison
+			}
+			return a
+		},
+	}))
+
+	vm, err := NewVM(WithLogger(logger))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	if _, _, _, err := vm.Execute(ctx, `
+(log/debug "not logged")
+(log/info "user created" {:name "alice" :id 42 :roles ["admin"] :meta {:source "api"}})
+(log/warn :deprecated)
+(log/error "failed" @{"reason" "timeout"})
+`); err != nil {
+		t.Fatalf("Failed to log: %v", err)
+	}
+	expected := `level=INFO msg="user created" id=42 meta.source=api name=alice roles=[admin]
+level=WARN msg=deprecated
+level=ERROR msg=failed reason=timeout
+`
+	if buf.String() != expected {
+		t.Errorf("Expected logs:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	// fields should be a struct or table
+	if _, _, _, err := vm.Execute(ctx, `(log/info "bad" [1 2])`); err == nil || !strings.Contains(err.Error(), "expected") {
+		t.Errorf("Expected error for bad fields, got: %v", err)
+	}
+
+	// not available without the option
+	plain, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer plain.Close()
+	if _, _, _, err := plain.Execute(ctx, `(log/info "hello")`); err == nil {
+		t.Errorf("Expected error without WithLogger")
+	}
+}
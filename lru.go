@@ -0,0 +1,68 @@
+// lru.go
+
+package janet
+
+import (
+	"container/list"
+)
+
+// lru is a cache of up to `size` values, which evicts the least recently used ones first.
+// It is not safe for concurrent use.
+type lru[K comparable, V any] struct {
+	size    int
+	entries map[K]*list.Element // elements of `order`
+	order   *list.List          // *lruEntry, the most recently used first
+}
+
+// lruEntry is a cached value of lru.
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// newLRU returns a new cache of up to `size` values.
+func newLRU[K comparable, V any](size int) *lru[K, V] {
+	return &lru[K, V]{
+		size:    size,
+		entries: map[K]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// get returns the cached value of `key`.
+func (c *lru[K, V]) get(key K) (value V, ok bool) {
+	if elem, exists := c.entries[key]; exists {
+		c.order.MoveToFront(elem)
+		return elem.Value.(*lruEntry[K, V]).value, true
+	}
+	return value, false
+}
+
+// put caches `value` of `key`, evicting the least recently used values if the cache is full.
+func (c *lru[K, V]) put(key K, value V) {
+	if elem, exists := c.entries[key]; exists {
+		elem.Value.(*lruEntry[K, V]).value = value
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry[K, V]).key)
+	}
+}
+
+// remove removes the cached value of `key`.
+func (c *lru[K, V]) remove(key K) {
+	if elem, exists := c.entries[key]; exists {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+}
+
+// clear removes all cached values.
+func (c *lru[K, V]) clear() {
+	clear(c.entries)
+	c.order.Init()
+}
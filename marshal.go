@@ -0,0 +1,56 @@
+// marshal.go
+
+package janet
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Marshaler is implemented by go types which convert themselves to janet values (eg. Money or UUID types),
+// like json.Marshaler.
+//
+// MarshalJanet returns a go value which is converted to janet instead of the receiver
+// (eg. a string, or a map), so it should not return the receiver itself.
+type Marshaler interface {
+	MarshalJanet() (any, error)
+}
+
+// Unmarshaler is implemented by go types which convert themselves from janet values
+// (with VM.ExecuteInto), like json.Unmarshaler.
+//
+// UnmarshalJanet receives the janet value converted to go (in the same way as VM.ParseToValue),
+// and is not called for janet nil, which stores the zero value.
+type Unmarshaler interface {
+	UnmarshalJanet(value any) error
+}
+
+var unmarshalerType = reflect.TypeFor[Unmarshaler]()
+
+// marshal converts `m` to the go value to be converted to janet.
+func marshal(m Marshaler) (any, error) {
+	if rv := reflect.ValueOf(m); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return nil, nil
+	}
+	value, err := m.MarshalJanet()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %T: %w", m, err)
+	}
+	return value, nil
+}
+
+// unmarshal stores `src` into `dst` with its UnmarshalJanet method, if `dst` (or its address) implements Unmarshaler.
+//
+// It returns false if `dst` is not an Unmarshaler.
+func unmarshal(dst reflect.Value, src any) (ok bool, err error) {
+	if dst.Kind() != reflect.Pointer && dst.CanAddr() && dst.Addr().Type().Implements(unmarshalerType) {
+		dst = dst.Addr()
+	} else if dst.Kind() == reflect.Pointer && dst.Type().Implements(unmarshalerType) {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+	} else {
+		return false, nil
+	}
+	return true, dst.Interface().(Unmarshaler).UnmarshalJanet(src)
+}
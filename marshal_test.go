@@ -0,0 +1,81 @@
+// marshal_test.go
+
+package janet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// money is a type which converts itself to and from janet strings.
+type money struct {
+	cents int64
+}
+
+// MarshalJanet converts the money to a string (eg. "12.34").
+func (m money) MarshalJanet() (any, error) {
+	if m.cents < 0 {
+		return nil, errors.New("negative money")
+	}
+	return fmt.Sprintf("%d.%02d", m.cents/100, m.cents%100), nil
+}
+
+// UnmarshalJanet converts a string (eg. "12.34") to the money.
+func (m *money) UnmarshalJanet(value any) error {
+	str, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("expected a string, got %T", value)
+	}
+	var units, cents int64
+	if _, err := fmt.Sscanf(str, "%d.%d", &units, &cents); err != nil {
+		return err
+	}
+	m.cents = units*100 + cents
+	return nil
+}
+
+// TestMarshaler tests types implementing Marshaler and Unmarshaler.
+func TestMarshaler(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	// go => janet
+	if err := vm.Define(ctx, "price", struct {
+		Item  string
+		Price money
+		Tax   *money
+		None  *money
+	}{"book", money{1234}, &money{56}, nil}); err != nil {
+		t.Fatalf("Failed to define: %v", err)
+	}
+	if evaluated, _, _, err := vm.Execute(ctx, `[(price :price) (price :tax) (price :none)]`, WithRender(RenderJDN)); err != nil || evaluated != `("12.34" "0.56" nil)` {
+		t.Errorf("Expected marshaled values, got %s (%v)", evaluated, err)
+	}
+	if err := vm.Define(ctx, "bad", []money{{-1}}); err == nil || !strings.Contains(err.Error(), "negative money") {
+		t.Errorf("Expected error from MarshalJanet, got: %v", err)
+	}
+
+	// janet => go
+	var out struct {
+		Price money
+		Tax   *money
+		Items []money
+	}
+	if err := vm.ExecuteInto(ctx, `{:price "1.50" :tax "0.05" :items ["1.00" "2.00"]}`, &out); err != nil {
+		t.Fatalf("Failed to execute into: %v", err)
+	}
+	if out.Price.cents != 150 || out.Tax == nil || out.Tax.cents != 5 || len(out.Items) != 2 || out.Items[1].cents != 200 {
+		t.Errorf("Expected unmarshaled values, got %+v", out)
+	}
+	if err := vm.ExecuteInto(ctx, `{:price 1.5}`, &out); err == nil || !strings.Contains(err.Error(), "result.price: expected a string") {
+		t.Errorf("Expected error from UnmarshalJanet with its path, got: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+// memo.go
+
+package janet
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// default number of results memoized by Memoize
+const defaultMemoSize = 128
+
+// MemoOptions is the options of Memoize.
+type MemoOptions struct {
+	Size int           // max number of memoized results, the least recently used ones are evicted first (default: 128)
+	TTL  time.Duration // how long results are memoized (forever if 0)
+}
+
+// Memoize wraps `fn` (eg. an expensive lookup of feature flags) for VM.RegisterAsyncFunc,
+// so that calls with the same arguments (compared after converted to go) return its memoized result
+// instead of calling it again, until the result expires after TTL.
+//
+// Errors are not memoized, and concurrent calls with the same arguments wait for the first one
+// instead of calling `fn` at once (which is cancelled only when all of them are done).
+// The wrapped function is safe to be registered in multiple VMs.
+func Memoize(fn AsyncFunc, opts MemoOptions) AsyncFunc {
+	if opts.Size <= 0 {
+		opts.Size = defaultMemoSize
+	}
+	m := &memo{
+		fn:       fn,
+		ttl:      opts.TTL,
+		results:  newLRU[string, memoResult](opts.Size),
+		inflight: map[string]*memoCall{},
+	}
+	return m.call
+}
+
+// memo is a function with memoized results.
+type memo struct {
+	fn  AsyncFunc
+	ttl time.Duration
+
+	mu       sync.Mutex
+	results  *lru[string, memoResult]
+	inflight map[string]*memoCall // calls in progress
+}
+
+// memoResult is a memoized result.
+type memoResult struct {
+	value   any
+	expires time.Time // zero if it does not expire
+}
+
+// memoCall is a call in progress, which other calls with the same arguments wait for.
+type memoCall struct {
+	done  chan struct{}
+	value any
+	err   error
+
+	waiters int                // number of callers waiting for the call (guarded by memo.mu)
+	cancel  context.CancelFunc // cancels the call, when no callers wait for it anymore
+}
+
+// call returns the memoized result for `args`, or calls the function.
+//
+// The function is called with a context detached from the callers' ones (with the values of the first caller),
+// so that a caller which is done does not fail the others waiting for the same call.
+// It is cancelled when all the waiting callers are done.
+func (m *memo) call(ctx context.Context, args ...any) (any, error) {
+	key := memoKey(args)
+
+	m.mu.Lock()
+	if result, ok := m.results.get(key); ok {
+		if result.expires.IsZero() || time.Now().Before(result.expires) {
+			m.mu.Unlock()
+			return result.value, nil
+		}
+		m.results.remove(key)
+	}
+	call, ok := m.inflight[key]
+	if !ok {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &memoCall{done: make(chan struct{}), cancel: cancel}
+		m.inflight[key] = call
+		go m.run(callCtx, key, call, args)
+	}
+	call.waiters++
+	m.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		m.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			// (later calls do not wait for the cancelled one)
+			if m.inflight[key] == call {
+				delete(m.inflight, key)
+			}
+			call.cancel()
+		}
+		m.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// run calls the function for `call`, and memoizes its result if it succeeds.
+func (m *memo) run(ctx context.Context, key string, call *memoCall, args []any) {
+	defer call.cancel()
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				call.err = fmt.Errorf("memoized function panicked: %v", r)
+			}
+		}()
+		call.value, call.err = m.fn(ctx, args...)
+	}()
+
+	m.mu.Lock()
+	if m.inflight[key] == call {
+		delete(m.inflight, key)
+	}
+	if call.err == nil {
+		result := memoResult{value: call.value}
+		if m.ttl > 0 {
+			result.expires = time.Now().Add(m.ttl)
+		}
+		m.results.put(key, result)
+	}
+	m.mu.Unlock()
+
+	close(call.done)
+}
+
+// memoKey returns the key of memoized results for `value` (eg. arguments of a call),
+// which includes the types of values (so that eg. float64(1) and int64(1) are different arguments),
+// and entries of maps in sorted order.
+func memoKey(value any) string {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() != reflect.Interface {
+			break // (eg. []byte)
+		}
+		elems := make([]string, rv.Len())
+		for i := range elems {
+			elems[i] = memoKey(rv.Index(i).Interface())
+		}
+		return fmt.Sprintf("%T{%s}", value, strings.Join(elems, ", "))
+	case reflect.Map:
+		entries := make([]string, 0, rv.Len())
+		for iter := rv.MapRange(); iter.Next(); {
+			entries = append(entries, memoKey(iter.Key().Interface())+": "+memoKey(iter.Value().Interface()))
+		}
+		slices.Sort(entries)
+		return fmt.Sprintf("%T{%s}", value, strings.Join(entries, ", "))
+	}
+	return fmt.Sprintf("%T(%#v)", value, value)
+}
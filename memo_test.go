@@ -0,0 +1,168 @@
+// memo_test.go
+
+package janet
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestMemoize tests memoizing results of async functions.
+func TestMemoize(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	var calls atomic.Int32
+	lookup := func(ctx context.Context, args ...any) (any, error) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		if args[0] == "broken" {
+			return nil, errors.New("backend failed")
+		}
+		return args[0], nil
+	}
+	expectCalls := func(expected int32) {
+		t.Helper()
+		if n := calls.Load(); n != expected {
+			t.Errorf("Expected %d calls, got %d", expected, n)
+		}
+	}
+
+	// results expire after TTL
+	expiring := Memoize(lookup, MemoOptions{TTL: 100 * time.Millisecond})
+	for range 2 {
+		if _, err := expiring(ctx, "c"); err != nil {
+			t.Errorf("Failed to call: %v", err)
+		}
+	}
+	expectCalls(1)
+	time.Sleep(150 * time.Millisecond)
+	if _, err := expiring(ctx, "c"); err != nil {
+		t.Errorf("Failed to call: %v", err)
+	}
+	expectCalls(2)
+
+	// (calls from janet need the event loop)
+	if !Build().EV {
+		return
+	}
+	calls.Store(0)
+	if err := vm.RegisterAsyncFunc(ctx, "flag", Memoize(lookup, MemoOptions{Size: 2})); err != nil {
+		t.Fatalf("Failed to register async function: %v", err)
+	}
+
+	// same arguments (including collections with the same keys and values)
+	for _, src := range []string{`(flag "a")`, `(flag "a")`, `(flag {:x 1 :y 2})`, `(flag @{:y 2 :x 1})`} {
+		if _, _, _, err := vm.Execute(ctx, src); err != nil {
+			t.Errorf("Failed to execute '%s': %v", src, err)
+		}
+	}
+	expectCalls(2)
+
+	// concurrent calls wait for the first one
+	if evaluated, _, _, err := vm.Execute(ctx, `(string/join (ev/gather (flag "b") (flag "b") (flag "b")) ",")`); err != nil || evaluated != "b,b,b" {
+		t.Errorf("Unexpected result of concurrent calls: %s, %v", evaluated, err)
+	}
+	expectCalls(3)
+
+	// least recently used results are evicted ("a" was evicted by "b")
+	if _, _, _, err := vm.Execute(ctx, `(flag "a")`); err != nil {
+		t.Errorf("Failed to execute: %v", err)
+	}
+	expectCalls(4)
+
+	// errors are not memoized
+	for range 2 {
+		if _, _, _, err := vm.Execute(ctx, `(flag "broken")`); err == nil {
+			t.Errorf("Expected error")
+		}
+	}
+	expectCalls(6)
+}
+
+// TestMemoizeSharedCalls tests calls of memoized functions waiting for the same call.
+func TestMemoizeSharedCalls(t *testing.T) {
+	ctx := context.TODO()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	memoized := Memoize(func(ctx context.Context, args ...any) (any, error) {
+		calls.Add(1)
+		select {
+		case <-release:
+			return args[0], nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}, MemoOptions{})
+
+	// the first caller being done does not fail the others
+	timeout, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	first := make(chan error, 1)
+	go func() {
+		_, err := memoized(timeout, "a")
+		first <- err
+	}()
+	second := make(chan any, 1)
+	go func() {
+		for calls.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		value, err := memoized(ctx, "a")
+		if err != nil {
+			value = err
+		}
+		second <- value
+	}()
+	if err := <-first; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded for the first caller, got: %v", err)
+	}
+	close(release)
+	if value := <-second; value != "a" {
+		t.Errorf("Expected result of the shared call, got: %v", value)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected 1 call, got %d", n)
+	}
+
+	// arguments of different types are not the same
+	for _, arg := range []any{float64(1), int64(1), uint64(1), []any{float64(1)}, []any{int64(1)}} {
+		if value, err := memoized(ctx, arg); err != nil || !reflect.DeepEqual(value, arg) {
+			t.Errorf("Expected %#v, got %#v (%v)", arg, value, err)
+		}
+	}
+	if n := calls.Load(); n != 6 {
+		t.Errorf("Expected 6 calls, got %d", n)
+	}
+
+	// calls are cancelled when all the callers are done
+	cancelled := make(chan error, 1)
+	blocking := Memoize(func(ctx context.Context, args ...any) (any, error) {
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return nil, ctx.Err()
+	}, MemoOptions{})
+	timeout, cancel = context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := blocking(timeout, "b"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got: %v", err)
+	}
+	select {
+	case err := <-cancelled:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the call to be cancelled, got: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Timed out waiting for the call to be cancelled")
+	}
+}
@@ -0,0 +1,91 @@
+// modules.go
+
+package janet
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+
+#include "janet.h"
+
+// defined in callbacks.go
+extern char *goVerifyModule(uintptr_t vm, char *path);
+
+// defined in janet_bundled.go or janet_system.go
+void *setCrashGuard(void *guard);
+
+// handle of the VM running on the current thread, if it has a module verifier
+static _Thread_local uintptr_t moduleVerifier = 0;
+
+static void setModuleVerifier(uintptr_t vm) {
+    moduleVerifier = vm;
+}
+
+// (verify-module path), which panics if the module file at `path` should not be loaded
+static Janet verifyModule(int32_t argc, Janet *argv) {
+    janet_fixarity(argc, 1);
+    const char *path = janet_getcstring(argv, 0);
+    if (moduleVerifier != 0) {
+        void *guard = setCrashGuard(NULL); // not to jump over go frames (see crash.go)
+        char *err = goVerifyModule(moduleVerifier, (char *)path);
+        setCrashGuard(guard);
+        if (err != NULL) {
+            Janet message = janet_cstringv(err);
+            free(err);
+            janet_panicv(message);
+        }
+    }
+    return janet_wrap_nil();
+}
+
+static Janet verifyModuleCfun() {
+    return janet_wrap_cfunction(verifyModule);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"runtime/cgo"
+)
+
+// WithModuleVerifier sets the verifier of module files, which is called with the path of a module file
+// before it is loaded with `import` or `require` (eg. for checking its content against a lockfile),
+// and makes the loading fail with the returned error.
+//
+// The verifier is called on the VM handler goroutine, so it should not call methods of the VM.
+func WithModuleVerifier(verify func(path string) error) Option {
+	return func(o *vmOptions) {
+		o.moduleVerifier = verify
+	}
+}
+
+// janet source of the helper function which makes loaders of module files call the verifier first.
+const moduleLoadersWrapperSource = `(fn [verify]
+  (each kind [:source :image :native]
+    (when-let [loader (get module/loaders kind)]
+      (put module/loaders kind (fn [path & args] (verify path) (loader path ;args))))))`
+
+// startModuleVerifier makes module files loaded in `core` verified with the VM's module verifier (if any).
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) startModuleVerifier(core *C.JanetTable) (stop func(), err error) {
+	if vm.options.moduleVerifier == nil {
+		return func() {}, nil
+	}
+
+	wrapper, err := compileHelper(core, moduleLoadersWrapperSource)
+	if err != nil {
+		return nil, err
+	}
+	defer C.janet_gcunroot(C.janet_wrap_function(wrapper))
+	if _, err := pcall(core, wrapper, C.verifyModuleCfun()); err != nil {
+		return nil, errors.New("failed to set module verifier: " + err.Error())
+	}
+
+	handle := cgo.NewHandle(vm)
+	C.setModuleVerifier(C.uintptr_t(handle))
+	return func() {
+		C.setModuleVerifier(0)
+		handle.Delete()
+	}, nil
+}
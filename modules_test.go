@@ -0,0 +1,55 @@
+// modules_test.go
+
+package janet
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestModuleVerifier tests verifying module files before they are loaded.
+func TestModuleVerifier(t *testing.T) {
+	dir := t.TempDir()
+	for name, source := range map[string]string{
+		"trusted.janet":   `(def answer 42)`,
+		"untrusted.janet": `(def answer 0)`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(source), 0o644); err != nil {
+			t.Fatalf("Failed to write module: %v", err)
+		}
+	}
+
+	var verified []string
+	vm, err := NewVM(WithSyspath(dir), WithModuleVerifier(func(path string) error {
+		verified = append(verified, filepath.Base(path))
+		if strings.HasSuffix(path, "untrusted.janet") {
+			return errors.New("untrusted module")
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	if evaluated, _, _, err := vm.Execute(ctx, `(import trusted) trusted/answer`); err != nil || evaluated != "42" {
+		t.Errorf("Expected trusted module loaded, got: %s (%v)", evaluated, err)
+	}
+	_, _, _, err = vm.Execute(ctx, `(import untrusted)`)
+	var janetErr *Error
+	if !errors.As(err, &janetErr) || janetErr.Message != "untrusted module" {
+		t.Fatalf("Expected error from the verifier, got: %v", err)
+	}
+	if len(janetErr.Frames) == 0 || janetErr.Frames[0].Name != "" || !janetErr.Frames[0].CFunction {
+		t.Errorf("Expected the verifier's frame first, got: %+v", janetErr.Frames)
+	}
+	if strings.Join(verified, ",") != "trusted.janet,untrusted.janet" {
+		t.Errorf("Unexpected verified modules: %v", verified)
+	}
+}
@@ -0,0 +1,50 @@
+// notify.go
+
+package janet
+
+import (
+	"context"
+	"os"
+	"os/signal"
+)
+
+// NotifyInterrupt forwards `signals` (os.Interrupt if none) received by the process to `vm`, for CLI hosts
+// which evaluate codes with the returned context (eg. Ctrl-C in a REPL), like the janet binary:
+//
+//   - a signal interrupts the evaluation being handled by the VM (in the same way as timeouts, see README),
+//     which fails with an error while the host keeps running.
+//   - a signal which finds the same evaluation still running (eg. blocked in a system call) as the previous signal,
+//     or no evaluation running again, cancels the returned context (a hard cancel), on which the host should give up or exit.
+//
+// Signals are forwarded until the hard cancel or `stop` is called (which also cancels the context),
+// after which the default behavior of the signals (eg. terminating the process) is restored.
+func NotifyInterrupt(vm *VM, signals ...os.Signal) (ctx context.Context, stop context.CancelFunc) {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+	go func() {
+		defer signal.Stop(received)
+
+		var pressed bool // whether a signal was received before
+		var last uint64  // id of the request interrupted by the previous signal (0 for none)
+		for {
+			select {
+			case <-received:
+				id := vm.interrupter.interruptRunning()
+				if pressed && id == last {
+					cancel()
+					return
+				}
+				pressed, last = true, id
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ctx, cancel
+}
@@ -0,0 +1,80 @@
+// notify_test.go
+
+package janet
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestNotifyInterrupt tests forwarding signals to VMs as interrupts.
+func TestNotifyInterrupt(t *testing.T) {
+	paused := make(chan struct{}, 1)
+	resume := make(chan struct{})
+	vm, err := NewVM(WithDebugHandler(func(event DebugEvent) DebugAction {
+		paused <- struct{}{}
+		<-resume // (blocks the VM, which cannot be interrupted)
+		return DebugResume
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx, stop := NotifyInterrupt(vm, syscall.SIGUSR1)
+	defer stop()
+
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("Failed to find the process: %v", err)
+	}
+	// signals the process while `expression` is being evaluated, and returns the error of the evaluation
+	signalWhile := func(expression string, signals int) error {
+		done := make(chan error, 1)
+		go func() {
+			_, _, _, err := vm.Execute(ctx, expression)
+			done <- err
+		}()
+		time.Sleep(100 * time.Millisecond)
+		for range signals {
+			if err := process.Signal(syscall.SIGUSR1); err != nil {
+				t.Fatalf("Failed to signal: %v", err)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected the evaluation of %s to be stopped", expression)
+			return nil
+		}
+	}
+
+	// the first signal interrupts the evaluation, and the VM is still usable
+	for range 2 {
+		if err := signalWhile(`(while true)`, 1); err == nil {
+			t.Errorf("Expected the evaluation to be interrupted")
+		}
+		if ctx.Err() != nil {
+			t.Fatalf("Expected the context not to be cancelled, got %v", ctx.Err())
+		}
+	}
+	if result, _, _, err := vm.Execute(ctx, `(+ 1 2)`); err != nil || result != "3" {
+		t.Errorf("Expected 3, got %s (%v)", result, err)
+	}
+
+	// the second signal cancels the context when the evaluation is not interrupted
+	err = signalWhile(`(debug)`, 2)
+	<-paused
+	close(resume)
+	if err == nil || ctx.Err() != context.Canceled {
+		t.Errorf("Expected the context to be cancelled, got %v (%v)", ctx.Err(), err)
+	}
+	if result, _, _, err := vm.Execute(context.TODO(), `(+ 1 2)`); err != nil || result != "3" {
+		t.Errorf("Expected 3, got %s (%v)", result, err)
+	}
+}
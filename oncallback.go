@@ -0,0 +1,54 @@
+// oncallback.go
+
+package janet
+
+/*
+#include "janet.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// callbackHandlers is the handlers of callbacks handed off by scripts on a VM.
+type callbackHandlers struct {
+	mu       sync.Mutex
+	handlers map[string]func(callback *Value)
+}
+
+// OnCallback sets the handler of callbacks which scripts hand off with `(host/callback name f)`
+// (where name is a string or a keyword, eg. "done" or `:done`), replacing the previous one (nil for removing it).
+//
+// The handler is called in a new goroutine with a handle of the function (or fiber) `f`,
+// which can be called later from any goroutine with Value.Call, and should be released after use.
+// Handing off a callback without a handler fails with an error in the script.
+func (vm *VM) OnCallback(name string, handler func(callback *Value)) {
+	vm.callbackHandlers.mu.Lock()
+	defer vm.callbackHandlers.mu.Unlock()
+	if handler == nil {
+		delete(vm.callbackHandlers.handlers, name)
+		return
+	}
+	if vm.callbackHandlers.handlers == nil {
+		vm.callbackHandlers.handlers = map[string]func(callback *Value){}
+	}
+	vm.callbackHandlers.handlers[name] = handler
+}
+
+// handleHandOff passes a handle of the janet `value` handed off by a script to the handler of `name`.
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) handleHandOff(name string, value unsafe.Pointer) error {
+	vm.callbackHandlers.mu.Lock()
+	handler := vm.callbackHandlers.handlers[name]
+	vm.callbackHandlers.mu.Unlock()
+	if handler == nil {
+		return fmt.Errorf("no handler of callback: %s", name)
+	}
+
+	callback := vm.newHandle(*(*C.Janet)(value), vm.callerStack())
+	go handler(callback)
+	return nil
+}
@@ -0,0 +1,84 @@
+// oncallback_test.go
+
+package janet
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestOnCallback tests calling callbacks handed off by scripts.
+func TestOnCallback(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	callbacks := make(chan *Value, 2)
+	vm.OnCallback("done", func(callback *Value) {
+		callbacks <- callback
+	})
+	receive := func() *Value {
+		select {
+		case callback := <-callbacks:
+			return callback
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for a callback")
+			return nil
+		}
+	}
+
+	// functions
+	if _, _, _, err := vm.Execute(ctx, `(var total 0) (host/callback :done (fn [n] (+= total n)))`); err != nil {
+		t.Fatalf("Failed to hand off callback: %v", err)
+	}
+	callback := receive()
+	results := make(chan any, 2)
+	for range 2 {
+		go func() {
+			result, err := callback.Call(ctx, 5)
+			if err != nil {
+				t.Errorf("Failed to call callback: %v", err)
+			}
+			results <- result
+		}()
+	}
+	<-results
+	<-results
+	if result, _, _, err := vm.Execute(ctx, `total`); err != nil || result != "10" {
+		t.Errorf("Expected 10, got: %s, %v", result, err)
+	}
+	if err := callback.Release(ctx); err != nil {
+		t.Errorf("Failed to release callback: %v", err)
+	}
+	if _, err := callback.Call(ctx, 1); err == nil {
+		t.Errorf("Expected error from released callback")
+	}
+
+	// fibers
+	if _, _, _, err := vm.Execute(ctx, `(host/callback "done" (fiber/new (fn [x] (def y (yield (* x 2))) (+ x y))))`); err != nil {
+		t.Fatalf("Failed to hand off callback: %v", err)
+	}
+	callback = receive()
+	defer callback.Release(ctx)
+	if result, err := callback.Call(ctx, 3); err != nil || result != float64(6) {
+		t.Errorf("Expected 6 yielded, got: %v, %v", result, err)
+	}
+	if result, err := callback.Call(ctx, 4); err != nil || result != float64(7) {
+		t.Errorf("Expected 7 returned, got: %v, %v", result, err)
+	}
+
+	// without handlers, or with invalid values
+	vm.OnCallback("done", nil)
+	if _, _, _, err := vm.Execute(ctx, `(host/callback :done (fn [] nil))`); err == nil || !strings.Contains(err.Error(), "no handler") {
+		t.Errorf("Expected error without handler, got: %v", err)
+	}
+	if _, _, _, err := vm.Execute(ctx, `(host/callback :done 42)`); err == nil {
+		t.Errorf("Expected error for non-callable value")
+	}
+}
@@ -0,0 +1,477 @@
+// options.go
+
+package janet
+
+/*
+#include "janet.h"
+
+static void callModuleEntry(void *entry, JanetTable *env) {
+    ((void (*)(JanetTable *))entry)(env);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"unsafe"
+)
+
+// Option configures a VM on its creation.
+type Option func(*vmOptions)
+
+// vmOptions is the options of a VM.
+type vmOptions struct {
+	ffi         bool           // whether `ffi/*` functions are available
+	natives     []nativeModule // native modules to be registered
+	cfunctions  []cfunctions   // c functions to be registered
+	nonFinite   NonFinite      // policy for encoding NaN and infinities
+	cycles      CyclePolicy    // policy for decoding values which contain themselves
+	limits      DecodeLimits   // limits for decoding values
+	mapKeys     MapKeys        // policy for decoding keys of tables and structs
+	orderedMaps bool           // whether tables and structs are decoded to *OrderedMap
+	invalidUTF8 InvalidUTF8    // policy for decoding strings which are not valid UTF-8
+	envVars     envVars        // policy for environment variables visible to scripts
+	workdir     string         // working directory of the VM (shared with the process if empty)
+	syspath     string         // path where modules are installed (`(dyn :syspath)`)
+	redefinable bool           // whether top-level definitions are compiled as redefinable ones (`(dyn :redef)`)
+	httpClient  *http.Client   // client for `http/*` functions (not available if nil)
+	clock       Clock          // source of time for scripts (the system's if nil)
+	random      io.Reader      // source of random data for scripts (the system's if nil)
+	logger      *slog.Logger   // logger for `log/*` functions (not available if nil)
+	queueLimit  int            // max number of requests waiting for the VM (unlimited if 0)
+	resultCache int            // max number of cached results of pure expressions (not cached if 0)
+	lazy        bool           // whether the runtime is initialized on the first use of the VM
+	prelude     string         // janet source evaluated in new environments of user codes
+
+	nilCollections NilCollections // policy for encoding nil slices and maps
+	omitZero       bool           // whether zero-valued fields of structs are omitted on encoding
+
+	handleStacks  bool          // whether creation stacks of value handles are recorded
+	handleRelease HandleRelease // policy for value handles which become unreachable without being released
+
+	debugHandler   func(event DebugEvent) DebugAction // handler of debug signals raised by scripts
+	moduleVerifier func(path string) error            // verifier of module files to be loaded
+	incidentSink   func(incident Incident)            // sink of incidents of requests which failed catastrophically
+
+	leakPolicy  LeakPolicy                            // policy for resources left open by executions
+	leakHandler func(expression string, leaks []Leak) // handler of resources left open by executions
+
+	conversionCheck func(mismatch ConversionMismatch) // handler of mismatched conversions (see WithConversionCheck)
+
+	expvar string // name of the VM in the "janet" expvar map (not published if empty)
+
+	httpMaxBody int64 // max size of response bodies read by `http/*` functions (default if 0)
+}
+
+// nativeModule is a native module to be registered on VM creation.
+type nativeModule struct {
+	name  string
+	entry unsafe.Pointer
+}
+
+// cfunctions is a table of c functions to be registered on VM creation.
+type cfunctions struct {
+	prefix string
+	regs   unsafe.Pointer
+}
+
+// WithFFI makes janet's `ffi/*` functions available in the VM.
+//
+// FFI is excluded from the bundled Janet by default, so it also needs to be
+// compiled in with `janet_ffi` build tag (or be enabled in the system libjanet).
+// Without this option, `ffi/*` bindings are removed from the VM's environment
+// even when they are compiled in.
+func WithFFI() Option {
+	return func(o *vmOptions) {
+		o.ffi = true
+	}
+}
+
+// WithNativeModule registers a janet native module, which is compiled into the binary,
+// so that it can be imported with `(import name)` in the VM.
+//
+// `entry` is a pointer to the module's entry function (`void (*)(JanetTable *env)`),
+// which is called with a new module environment (like the one defined with `JANET_MODULE_ENTRY`).
+// (A c function's pointer can be obtained with a c helper, eg. `void *get_entry() { return mymodule_init; }`)
+func WithNativeModule(name string, entry unsafe.Pointer) Option {
+	return func(o *vmOptions) {
+		o.natives = append(o.natives, nativeModule{name: name, entry: entry})
+	}
+}
+
+// WithCFunctions registers c functions into the VM's root environment.
+//
+// `regs` is a pointer to a `JanetReg` array terminated with `{NULL, NULL, NULL}`.
+// Functions are bound as `prefix/name` (eg. "mymodule/greet" with prefix "mymodule"),
+// or with their own names when `prefix` is empty.
+func WithCFunctions(prefix string, regs unsafe.Pointer) Option {
+	return func(o *vmOptions) {
+		o.cfunctions = append(o.cfunctions, cfunctions{prefix: prefix, regs: regs})
+	}
+}
+
+// WithNonFinite sets the policy for encoding go NaN and infinities into janet
+// (default: NonFiniteLiteral).
+func WithNonFinite(policy NonFinite) Option {
+	return func(o *vmOptions) {
+		o.nonFinite = policy
+	}
+}
+
+// WithNilCollections sets the policy for encoding go nil slices and maps into janet
+// (default: NilCollectionsNil).
+func WithNilCollections(policy NilCollections) Option {
+	return func(o *vmOptions) {
+		o.nilCollections = policy
+	}
+}
+
+// WithOmitZero makes the VM omit zero-valued fields (eg. 0, "", nil, or structs of zero values)
+// when encoding go structs into janet structs.
+func WithOmitZero() Option {
+	return func(o *vmOptions) {
+		o.omitZero = true
+	}
+}
+
+// WithCyclePolicy sets the policy for converting janet values which contain themselves
+// (eg. `(do (def t @{}) (put t :self t))`) to go (default: CycleError).
+func WithCyclePolicy(policy CyclePolicy) Option {
+	return func(o *vmOptions) {
+		o.cycles = policy
+	}
+}
+
+// WithDecodeLimits sets the limits for converting janet values to go,
+// so that (possibly untrusted) huge or deeply nested values cannot exhaust memory.
+func WithDecodeLimits(limits DecodeLimits) Option {
+	return func(o *vmOptions) {
+		o.limits = limits
+	}
+}
+
+// WithMapKeys sets the policy for converting keys of janet tables and structs to go (default: MapKeysAny).
+//
+// With MapKeysString or MapKeysTrimmed, tables and structs are converted to map[string]any
+// (eg. for encoding them with encoding/json), and conversions fail on keys which are not strings,
+// symbols, or keywords, or which become duplicates (eg. `{:a 1 "a" 2}` with MapKeysTrimmed).
+func WithMapKeys(policy MapKeys) Option {
+	return func(o *vmOptions) {
+		o.mapKeys = policy
+	}
+}
+
+// WithInvalidUTF8 sets the policy for converting janet strings, buffers, symbols, and keywords which are not valid UTF-8
+// to go (default: InvalidUTF8Kept), so that arbitrary bytes of janet strings are not passed to
+// consumers which expect UTF-8 (eg. encoding/json, which replaces them silently).
+//
+// With InvalidUTF8Bytes, such strings are converted to []byte losslessly (and can be stored into []byte with VM.ExecuteInto).
+func WithInvalidUTF8(policy InvalidUTF8) Option {
+	return func(o *vmOptions) {
+		o.invalidUTF8 = policy
+	}
+}
+
+// WithOrderedMaps makes the VM convert janet tables and structs to *OrderedMap instead of map[any]any,
+// preserving the order of their entries (eg. for re-emitting configurations).
+func WithOrderedMaps() Option {
+	return func(o *vmOptions) {
+		o.orderedMaps = true
+	}
+}
+
+// WithHandleStacks records where value handles are created (see VM.LiveHandles),
+// for finding leaks of handles while debugging.
+func WithHandleStacks() Option {
+	return func(o *vmOptions) {
+		o.handleStacks = true
+	}
+}
+
+// WithHandleRelease sets the policy for value handles which become unreachable
+// without being released (default: HandleReleaseManual).
+func WithHandleRelease(policy HandleRelease) Option {
+	return func(o *vmOptions) {
+		o.handleRelease = policy
+	}
+}
+
+// WithQueueLimit limits the number of requests (eg. executions) waiting for the VM to `limit`,
+// so that further requests fail with ErrQueueFull at once instead of piling up while the VM is busy.
+func WithQueueLimit(limit int) Option {
+	return func(o *vmOptions) {
+		o.queueLimit = limit
+	}
+}
+
+// WithLazyInit defers the initialization of the VM's runtime (its OS thread and janet environment)
+// from its creation to its first use, for hosts which rarely run scripts (eg. CLIs).
+//
+// Errors of the deferred initialization are returned from the first (and any later) requests,
+// or can be checked earlier with VM.Preinit.
+func WithLazyInit() Option {
+	return func(o *vmOptions) {
+		o.lazy = true
+	}
+}
+
+// WithPrelude makes the VM evaluate janet `source` (eg. definitions of helper functions)
+// in its environment when it is created, and again whenever the environment is recreated
+// (with VM.Reset, or after the runtime crashed, see ErrVMCrashed).
+//
+// Creation of the VM fails if the prelude fails to be evaluated.
+func WithPrelude(source string) Option {
+	return func(o *vmOptions) {
+		o.prelude = source
+	}
+}
+
+// WithWorkdir sets the working directory of the VM to `dir`, against which `os/cwd` and relative paths
+// in scripts (and in go functions called from them) are resolved, and which `os/cd` changes
+// without affecting the host process or other VMs.
+//
+// It is only supported on linux, where the VM's thread gets its own working directory.
+func WithWorkdir(dir string) Option {
+	return func(o *vmOptions) {
+		o.workdir = dir
+	}
+}
+
+// WithSyspath sets `(dyn :syspath)` of the VM, where modules installed for the VM (eg. with janetdeps)
+// are searched with `import` (like `JANET_PATH` of janet's CLI).
+func WithSyspath(dir string) Option {
+	return func(o *vmOptions) {
+		o.syspath = dir
+	}
+}
+
+// WithDebugHandler sets the handler of debug signals raised by scripts (eg. with `(debug)`),
+// which inspects the paused fiber and decides whether to resume it or to abort the evaluation.
+//
+// The handler is called on the VM handler goroutine while the script is paused,
+// so it should not call methods of the VM (which would block forever).
+func WithDebugHandler(handler func(event DebugEvent) DebugAction) Option {
+	return func(o *vmOptions) {
+		o.debugHandler = handler
+	}
+}
+
+// WithIncidentSink sets the sink of incidents, which are diagnostic bundles of requests
+// which failed catastrophically (eg. crashed the runtime, or were interrupted when their contexts were done),
+// for reproducing the failures in postmortems (see Incident.String for a human-readable report).
+//
+// The sink is called on the VM handler goroutine,
+// so it should not call methods of the VM (which would block forever).
+func WithIncidentSink(sink func(incident Incident)) Option {
+	return func(o *vmOptions) {
+		o.incidentSink = sink
+	}
+}
+
+// WithLeakCheck makes the VM check resources (files, streams, and tasks of the event loop)
+// which are opened by each execution (eg. VM.Execute) and left open when it completes, for debugging leaky scripts
+// which could exhaust file descriptors, with `policy` (default: LeaksIgnored).
+//
+// Leaks are reported to `handler` (if not nil) with the janet source of the execution.
+// The handler is called on the VM handler goroutine, so it should not call methods of the VM (which would block forever).
+//
+// Leaks are not checked with `system_janet`, as the heap of libjanet cannot be inspected.
+func WithLeakCheck(policy LeakPolicy, handler func(expression string, leaks []Leak)) Option {
+	return func(o *vmOptions) {
+		o.leakPolicy = policy
+		o.leakHandler = handler
+	}
+}
+
+// ExecOption configures an execution (eg. VM.Execute).
+type ExecOption func(*execOptions)
+
+// execOptions is the options of an execution.
+type execOptions struct {
+	render  Render         // style of rendering evaluated values
+	numbers *NumberFormat  // format of numbers in rendered values (janet's default format if nil)
+	stdout  *string        // where to store outputs to stdout (for functions which do not return them)
+	stderr  *string        // where to store outputs to stderr (for functions which do not return them)
+	result  io.Writer      // where to write rendered results in chunks (see WithResultWriter)
+	arena   *Arena         // where converted values are allocated (see WithArena)
+	dyns    map[string]any // dynamic bindings during the execution (names without leading `:`)
+	source  sourceMap      // location of the evaluated source in its original file
+	pure    bool           // whether the result depends only on the expression (see WithResultCache)
+	strict  bool           // whether results are stored into go values strictly (see WithStrict)
+	tempDir bool           // whether a temporary directory is provisioned for the execution (see WithTempDir)
+
+	errorHandle bool // whether errors keep handles of raised values
+	noCapture   bool // whether outputs are not captured (see WithoutCapture)
+}
+
+// newExecOptions returns execution options with `opts` applied.
+func newExecOptions(opts []ExecOption) execOptions {
+	options := execOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// WithRender sets the style of rendering evaluated values (default: RenderDefault).
+func WithRender(style Render) ExecOption {
+	return func(o *execOptions) {
+		o.render = style
+	}
+}
+
+// WithNumberFormat sets the format of numbers in evaluated values rendered with RenderDefault.
+func WithNumberFormat(format NumberFormat) ExecOption {
+	return func(o *execOptions) {
+		o.numbers = &format
+	}
+}
+
+// WithStrict makes VM.ExecuteInto fail on keys which do not match any fields of structs,
+// on fields tagged with `required` (eg. `janet:"name,required"`) which are not given,
+// and on nil stored into values which cannot be nil, instead of silently leaving zero values.
+//
+// Errors tell where they occur in the result (eg. "result.items[0]: missing required key :name").
+func WithStrict() ExecOption {
+	return func(o *execOptions) {
+		o.strict = true
+	}
+}
+
+// WithErrorHandle makes errors raised by janet code (*Error) keep handles of the raised values,
+// which should be released after use.
+func WithErrorHandle() ExecOption {
+	return func(o *execOptions) {
+		o.errorHandle = true
+	}
+}
+
+// WithSourceMap makes evaluated source refer to the file `name` in source maps (eg. of stack traces and errors),
+// with its line numbers shifted by `lineOffset` (the number of lines preceding the source in the file).
+//
+// It is useful for scripts embedded in go code, eg. with `go:embed` or string constants.
+func WithSourceMap(name string, lineOffset int) ExecOption {
+	return func(o *execOptions) {
+		o.source = sourceMap{name: name, lineOffset: max(lineOffset, 0)}
+	}
+}
+
+// WithOutput sets where to store outputs to stdout and stderr (either can be nil)
+// for functions which do not return them (eg. VM.ExecuteInto).
+func WithOutput(stdout, stderr *string) ExecOption {
+	return func(o *execOptions) {
+		o.stdout = stdout
+		o.stderr = stderr
+	}
+}
+
+// WithoutCapture makes the execution skip capturing outputs to stdout and stderr,
+// which saves the setup of output buffers for expressions which never print (eg. pure data or calculations).
+//
+// Outputs of the execution are written to the bindings of the environment (the process's stdout and stderr by default),
+// and empty outputs are returned.
+func WithoutCapture() ExecOption {
+	return func(o *execOptions) {
+		o.noCapture = true
+	}
+}
+
+// janet source which removes `ffi/*` functions from the environment
+// (and from the image dictionaries, so that they cannot be unmarshalled back).
+const ffiRemoverSource = `(do
+  (def env (curenv))
+  (each name (filter |(and (symbol? $) (string/has-prefix? "ffi/" $)) (keys env))
+    (def value (get-in env [name :value]))
+    (put env name nil)
+    (put load-image-dict name nil)
+    (put make-image-dict value nil)))`
+
+// apply applies the options to the janet environment `env` of a newly created VM.
+//
+// Returned `release` function should be called after the VM is deinitialized.
+// This function should only be called from the VM handler goroutine.
+func (o vmOptions) apply(env *C.JanetTable) (release func(), err error) {
+	// c strings which are referenced by the janet registry while the VM is running
+	var cstrings []*C.char
+	release = func() {
+		for _, str := range cstrings {
+			C.free(unsafe.Pointer(str))
+		}
+	}
+
+	if !o.ffi {
+		if err := dostring(env, ffiRemoverSource); err != nil {
+			return release, errors.New("failed to disable ffi: " + err.Error())
+		}
+	}
+
+	if err := o.envVars.apply(env); err != nil {
+		return release, err
+	}
+
+	if o.syspath != "" {
+		C.janet_table_put(env, janetKeyword("syspath"), janetString(o.syspath))
+	}
+	if o.redefinable {
+		C.janet_table_put(env, janetKeyword("redef"), C.janet_wrap_true())
+	}
+
+	for _, cfuns := range o.cfunctions {
+		if cfuns.regs == nil {
+			return release, errors.New("c functions are nil")
+		}
+		prefix := C.CString(cfuns.prefix)
+		cstrings = append(cstrings, prefix)
+		if cfuns.prefix == "" {
+			C.janet_cfuns(env, nil, (*C.JanetReg)(cfuns.regs))
+		} else {
+			C.janet_cfuns_prefix(env, prefix, (*C.JanetReg)(cfuns.regs))
+		}
+	}
+
+	if len(o.natives) > 0 {
+		var cache C.Janet
+		symbol := C.CString("module/cache")
+		C.janet_resolve(env, C.janet_csymbol(symbol), &cache)
+		C.free(unsafe.Pointer(symbol))
+		if C.janet_checktype(cache, C.JANET_TABLE) == 0 {
+			return release, errors.New("module/cache is not available")
+		}
+		for _, native := range o.natives {
+			if native.entry == nil {
+				return release, errors.New("entry of native module '" + native.name + "' is nil")
+			}
+			module := C.janet_table(0)
+			C.callModuleEntry(native.entry, module)
+			C.janet_table_put(C.janet_unwrap_table(cache), janetString(native.name), C.janet_wrap_table(module))
+		}
+	}
+
+	return release, nil
+}
+
+// evalPrelude evaluates the prelude (see WithPrelude) in a new environment `env`, if any.
+// This function should only be called from the VM handler goroutine.
+func (o vmOptions) evalPrelude(env *C.JanetTable) error {
+	if o.prelude == "" {
+		return nil
+	}
+	if err := dostring(env, o.prelude); err != nil {
+		return errors.New("failed to evaluate prelude: " + err.Error())
+	}
+	return nil
+}
+
+// dostring evaluates janet `source` in `env`, discarding its result.
+// This function should only be called from the VM handler goroutine.
+func dostring(env *C.JanetTable, source string) error {
+	var janetResult C.Janet
+
+	if ret := dobytes(env, source, &janetResult, nil); ret != C.JANET_SIGNAL_OK {
+		return errors.New(janetValueToString(janetResult))
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// options_test.go
+
+package janet
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/meinside/janet-go/internal/nativetest"
+)
+
+// TestNewVM tests the NewVM function.
+func TestNewVM(t *testing.T) {
+	shared, err := SharedVM()
+	if err != nil {
+		t.Fatalf("Failed to create shared Janet VM: %v", err)
+	}
+	defer shared.Close()
+
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	if vm == shared {
+		t.Fatalf("Expected a VM independent of the shared one")
+	}
+
+	ctx := context.TODO()
+	if _, _, _, err := vm.Execute(ctx, "(def x 42)"); err != nil {
+		t.Fatalf("Failed to execute: %v", err)
+	}
+	if _, _, _, err := shared.Execute(ctx, "x"); err == nil {
+		t.Errorf("Expected bindings not to be shared between VMs")
+	}
+
+	vm.Close()
+	vm.Close() // closing twice should be harmless
+
+	if again, err := SharedVM(); err != nil || again != shared {
+		t.Errorf("Expected the shared VM to be kept after closing another VM")
+	}
+}
+
+// TestFFIOption tests the WithFFI option.
+func TestFFIOption(t *testing.T) {
+	ctx := context.TODO()
+
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	if _, _, _, err := vm.Execute(ctx, "(ffi/native)"); err == nil || !strings.Contains(err.Error(), "unknown symbol") {
+		t.Errorf("Expected ffi to be unavailable by default, got error: %v", err)
+	}
+	if evaluated, _, _, err := vm.Execute(ctx, "(get load-image-dict 'ffi/native)"); err != nil || evaluated != "nil" {
+		t.Errorf("Expected ffi not to be in the image dictionary, got '%s' (error: %v)", evaluated, err)
+	}
+
+	ffiVM, err := NewVM(WithFFI())
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM with ffi: %v", err)
+	}
+	defer ffiVM.Close()
+
+	if Build().FFI {
+		if evaluated, _, _, err := ffiVM.Execute(ctx, "(cfunction? ffi/native)"); err != nil || evaluated != "true" {
+			t.Errorf("Expected ffi to be available with WithFFI, got '%s' (error: %v)", evaluated, err)
+		}
+	}
+}
+
+// TestNativeModules tests the WithNativeModule and WithCFunctions options.
+func TestNativeModules(t *testing.T) {
+	ctx := context.TODO()
+
+	vm, err := NewVM(
+		WithNativeModule("nativetest", nativetest.Entry()),
+		WithCFunctions("native", nativetest.CFunctions()),
+		WithCFunctions("", nativetest.CFunctions()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(import nativetest) (nativetest/greet "janet")`, "hello, janet"},
+		{`(import nativetest :as n) (n/greet "go")`, "hello, go"},
+		{`(native/greet "prefix")`, "hello, prefix"},
+		{`(greet "root")`, "hello, root"},
+	}
+	for _, test := range tests {
+		if evaluated, _, _, err := vm.Execute(ctx, test.input); err != nil {
+			t.Errorf("Failed to execute '%s': %v", test.input, err)
+		} else if evaluated != test.expected {
+			t.Errorf("Expected '%s' for '%s', got '%s'", test.expected, test.input, evaluated)
+		}
+	}
+
+	if _, err := NewVM(WithNativeModule("nil", nil)); err == nil {
+		t.Errorf("Expected error for a nil module entry")
+	}
+}
+
+// TestLazyInit tests the WithLazyInit option and VM.Preinit.
+func TestLazyInit(t *testing.T) {
+	ctx := context.TODO()
+
+	vm, err := NewVM(WithLazyInit())
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	if evaluated, _, _, err := vm.Execute(ctx, "(+ 1 2)"); err != nil || evaluated != "3" {
+		t.Errorf("Expected VM initialized on its first use, got '%s' (error: %v)", evaluated, err)
+	}
+	if err := vm.Preinit(); err != nil {
+		t.Errorf("Expected no error from an initialized VM, got: %v", err)
+	}
+
+	// initialization fails on the first use, not on creation
+	failing, err := NewVM(WithLazyInit(), WithNativeModule("nil", nil))
+	if err != nil {
+		t.Fatalf("Expected no error on lazy creation, got: %v", err)
+	}
+	defer failing.Close()
+
+	if _, _, _, err := failing.Execute(ctx, "(+ 1 2)"); err == nil {
+		t.Errorf("Expected initialization error on the first use")
+	}
+	if err := failing.Preinit(); err == nil {
+		t.Errorf("Expected initialization error from Preinit")
+	}
+
+	// closed before initialization
+	closed, err := NewVM(WithLazyInit())
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	closed.Close()
+	if err := closed.Preinit(); err != ErrClosed {
+		t.Errorf("Expected ErrClosed, got: %v", err)
+	}
+}
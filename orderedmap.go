@@ -0,0 +1,110 @@
+// orderedmap.go
+
+package janet
+
+// OrderedMap is a janet table or struct converted to go with the order of its entries preserved
+// (the order in which janet iterates them, eg. with `pairs`), returned instead of map[any]any
+// from VMs created with WithOrderedMaps.
+//
+// It is converted back to a janet table, like go maps.
+type OrderedMap struct {
+	entries []Entry
+	index   map[any]int // indices of entries by their keys
+}
+
+// NewOrderedMap returns a new OrderedMap with `entries`, where later entries replace earlier ones with the same keys.
+func NewOrderedMap(entries ...Entry) *OrderedMap {
+	m := newOrderedMap(len(entries))
+	for _, entry := range entries {
+		m.Set(entry.Key, entry.Value)
+	}
+	return m
+}
+
+// newOrderedMap returns a new empty OrderedMap with capacity for `count` entries.
+func newOrderedMap(count int) *OrderedMap {
+	return &OrderedMap{
+		entries: make([]Entry, 0, count),
+		index:   make(map[any]int, count),
+	}
+}
+
+// Len returns the number of entries.
+func (m *OrderedMap) Len() int {
+	return len(m.entries)
+}
+
+// Get returns the value for `key`, and whether the key exists.
+func (m *OrderedMap) Get(key any) (value any, ok bool) {
+	i, ok := m.index[key]
+	if !ok {
+		return nil, false
+	}
+	return m.entries[i].Value, true
+}
+
+// Set sets the value for `key`, keeping its position if the key exists, or appending a new entry.
+func (m *OrderedMap) Set(key, value any) {
+	if i, ok := m.index[key]; ok {
+		m.entries[i].Value = value
+		return
+	}
+	if m.index == nil {
+		m.index = map[any]int{}
+	}
+	m.index[key] = len(m.entries)
+	m.entries = append(m.entries, Entry{Key: key, Value: value})
+}
+
+// Keys returns the keys in order.
+func (m *OrderedMap) Keys() []any {
+	keys := make([]any, len(m.entries))
+	for i, entry := range m.entries {
+		keys[i] = entry.Key
+	}
+	return keys
+}
+
+// Entries returns the entries in order.
+func (m *OrderedMap) Entries() []Entry {
+	return append([]Entry(nil), m.entries...)
+}
+
+// Map returns the entries as a go map, without converting nested OrderedMaps.
+func (m *OrderedMap) Map() map[any]any {
+	result := make(map[any]any, len(m.entries))
+	for _, entry := range m.entries {
+		result[entry.Key] = entry.Value
+	}
+	return result
+}
+
+// asMap returns a converted janet table or struct as a go map,
+// whether it is converted to map[any]any, map[string]any (with WithMapKeys), or *OrderedMap (with WithOrderedMaps).
+func asMap(value any) (map[any]any, bool) {
+	switch v := value.(type) {
+	case map[any]any:
+		return v, true
+	case map[string]any:
+		result := make(map[any]any, len(v))
+		for key, val := range v {
+			result[key] = val
+		}
+		return result, true
+	case *OrderedMap:
+		if v == nil {
+			return nil, false
+		}
+		return v.Map(), true
+	}
+	return nil, false
+}
+
+// keywordField returns the value for keyword `name` (without a leading colon) in a converted janet table or struct,
+// whose keys may have been converted without leading colons (with MapKeysTrimmed).
+func keywordField(m map[any]any, name string) any {
+	if value, ok := m[":"+name]; ok {
+		return value
+	}
+	return m[name]
+}
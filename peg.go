@@ -0,0 +1,224 @@
+// peg.go
+
+package janet
+
+/*
+#include "janet.h"
+*/
+import "C"
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unsafe"
+)
+
+// janet source of the helper function which compiles PEG grammars.
+//
+// It parses the grammar source (without evaluating it), collects keyword tags
+// of tagged captures, and compiles the grammar followed by a position capture
+// (for the end of the match) and a group of backreference for each tag.
+const pegCompilerSource = `(fn [source]
+  (def grammar (parse source))
+  (def tag-indices {'capture 2 '<- 2 'quote 2 'group 2 'accumulate 2 '% 2
+                    'constant 2 'argument 2 'int 2 'int-be 2 'uint 2 'uint-be 2
+                    'backref 2 '-> 2 'position 1 '$ 1 'line 1 'column 1
+                    'replace 3 '/ 3 'cmt 3 'number 3})
+  (def tags @[])
+  (defn walk [x]
+    (case (type x)
+      :tuple (do
+               (when-let [i (get tag-indices (first x))
+                          tag (get x i)]
+                 (when (and (keyword? tag) (not (index-of tag tags)))
+                   (array/push tags tag)))
+               (each y x (walk y)))
+      :array (each y x (walk y))
+      :struct (eachp [_ v] x (walk v))
+      :table (eachp [_ v] x (walk v))))
+  (walk grammar)
+  [(peg/compile ~(* ,grammar ($) ,;(map (fn [tag] ~(group (opt (-> ,tag)))) tags)))
+   (tuple ;tags)])`
+
+// janet source of the helper function which matches compiled PEGs.
+const pegMatcherSource = `(fn [peg text] (peg/match peg text))`
+
+// ErrPEGNotAvailable is returned when PEGs are compiled without janet's PEG (eg. with `janet_no_peg` build tag).
+var ErrPEGNotAvailable = errors.New("peg is not available")
+
+// PEG is a janet PEG (Parsing Expression Grammar) compiled in a VM.
+type PEG struct {
+	vm   *VM
+	peg  C.Janet  // compiled peg (rooted)
+	tags []string // names of tagged captures
+}
+
+// PEGMatch is the result of a successful PEG match.
+type PEGMatch struct {
+	Captures []any          // captured values, converted to go values
+	End      int            // byte offset where the match ended
+	Named    map[string]any // last values of tagged captures, keyed by tag names (without colons)
+}
+
+// pegCompileResult is used to receive the compiled PEG from the VM handler.
+type pegCompileResult struct {
+	peg  C.Janet
+	tags []string
+	err  error
+}
+
+// pegMatchResult is used to receive the match result from the VM handler.
+type pegMatchResult struct {
+	match *PEGMatch
+	err   error
+}
+
+// CompilePEG compiles a janet PEG `grammar` in the VM.
+//
+// `grammar` is the janet source of the grammar data (eg. `(some (capture :d))`
+// or `{:main (* :word (any (* " " :word))) :word (<- :w+ :word)}`),
+// and is read without being evaluated.
+//
+// Keyword tags of tagged captures (eg. `(<- :d+ :year)`) are reported as named captures.
+//
+// Returned PEG should be closed with PEG.Close when it is no longer needed.
+// ErrPEGNotAvailable is returned without janet's PEG.
+func (vm *VM) CompilePEG(
+	ctx context.Context,
+	grammar string,
+) (
+	peg *PEG,
+	err error,
+) {
+	if !Build().PEG {
+		return nil, ErrPEGNotAvailable
+	}
+
+	res, err := runOnVM(ctx, vm, func(env *C.JanetTable) pegCompileResult {
+		if vm.pegCompiler == nil {
+			helper, err := compileHelper(env, pegCompilerSource)
+			if err != nil {
+				return pegCompileResult{err: err}
+			}
+			vm.pegCompiler = helper
+		}
+
+		compiled, err := pcall(env, vm.pegCompiler, janetString(grammar))
+		if err != nil {
+			return pegCompileResult{err: err}
+		}
+
+		elems := janetIndexed(compiled)
+		tags := make([]string, 0, len(janetIndexed(elems[1])))
+		for _, tag := range janetIndexed(elems[1]) {
+			tags = append(tags, strings.TrimPrefix(janetValueToString(tag), ":"))
+		}
+		C.janet_gcroot(elems[0])
+
+		return pegCompileResult{
+			peg:  elems[0],
+			tags: tags,
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.err != nil {
+		return nil, res.err
+	}
+
+	return &PEG{
+		vm:   vm,
+		peg:  res.peg,
+		tags: res.tags,
+	}, nil
+}
+
+// Match matches `input` against the PEG from its beginning.
+//
+// It returns nil (without error) if `input` does not match.
+func (p *PEG) Match(
+	ctx context.Context,
+	input string,
+) (
+	match *PEGMatch,
+	err error,
+) {
+	res, err := runOnVM(ctx, p.vm, func(env *C.JanetTable) pegMatchResult {
+		if p.vm.pegMatcher == nil {
+			helper, err := compileHelper(env, pegMatcherSource)
+			if err != nil {
+				return pegMatchResult{err: err}
+			}
+			p.vm.pegMatcher = helper
+		}
+
+		matched, err := pcall(env, p.vm.pegMatcher, p.peg, janetString(input))
+		if err != nil {
+			return pegMatchResult{err: err}
+		}
+		if C.janet_checktype(matched, C.JANET_NIL) != 0 {
+			return pegMatchResult{}
+		}
+
+		// [captures... end named-groups...]
+		elems := janetIndexed(matched)
+		numCaptures := len(elems) - 1 - len(p.tags)
+		match := &PEGMatch{
+			Captures: make([]any, 0, numCaptures),
+			End:      int(C.janet_unwrap_number(elems[numCaptures])),
+			Named:    map[string]any{},
+		}
+		dec := p.vm.decoder(ctx)
+		for _, capture := range elems[:numCaptures] {
+			value, err := dec.decode(capture)
+			if err != nil {
+				return pegMatchResult{err: err}
+			}
+			match.Captures = append(match.Captures, value)
+		}
+		for i, group := range elems[numCaptures+1:] {
+			if values := janetIndexed(group); len(values) > 0 {
+				value, err := dec.decode(values[len(values)-1])
+				if err != nil {
+					return pegMatchResult{err: err}
+				}
+				match.Named[p.tags[i]] = value
+			}
+		}
+
+		return pegMatchResult{match: match}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.match, res.err
+}
+
+// Close releases the compiled PEG from the VM.
+func (p *PEG) Close(ctx context.Context) error {
+	if p.vm == nil {
+		return errors.New("PEG is already closed")
+	}
+
+	_, err := runOnVM(ctx, p.vm, func(env *C.JanetTable) struct{} {
+		C.janet_gcunroot(p.peg)
+		return struct{}{}
+	})
+	if err == nil {
+		p.vm = nil
+	}
+	return err
+}
+
+// janetIndexed returns the elements of a janet tuple or array.
+func janetIndexed(value C.Janet) []C.Janet {
+	var data *C.Janet
+	var length C.int32_t
+	if C.janet_indexed_view(value, &data, &length) == 0 || length == 0 {
+		return nil
+	}
+	return unsafe.Slice(data, int(length))
+}
@@ -0,0 +1,116 @@
+// peg_test.go
+
+package janet
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestPEG tests the CompilePEG function and PEG matches.
+func TestPEG(t *testing.T) {
+	vm, err := SharedVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	tests := []struct {
+		grammar string
+		input   string
+
+		expectedNil      bool
+		expectedCaptures []any
+		expectedEnd      int
+		expectedNamed    map[string]any
+	}{
+		{
+			grammar:          `(some (capture :d))`,
+			input:            `123abc`,
+			expectedCaptures: []any{"1", "2", "3"},
+			expectedEnd:      3,
+			expectedNamed:    map[string]any{},
+		},
+		{
+			grammar:          `{:main (* :word (any (* " " :word))) :word (<- :w+)}`,
+			input:            `hello peg world`,
+			expectedCaptures: []any{"hello", "peg", "world"},
+			expectedEnd:      15,
+			expectedNamed:    map[string]any{},
+		},
+		{
+			grammar:          `(* (<- 4 :year) "-" (<- 2 :month) "-" (<- 2 :day) ($))`,
+			input:            `2025-11-17`,
+			expectedCaptures: []any{"2025", "11", "17", float64(10)},
+			expectedEnd:      10,
+			expectedNamed: map[string]any{
+				"year":  "2025",
+				"month": "11",
+				"day":   "17",
+			},
+		},
+		{
+			grammar:          `(* "a" (opt (number :d+ nil :n)))`,
+			input:            `a`,
+			expectedCaptures: []any{},
+			expectedEnd:      1,
+			expectedNamed:    map[string]any{},
+		},
+		{
+			grammar:     `(some :d)`,
+			input:       `abc`,
+			expectedNil: true,
+		},
+	}
+
+	// (not available without janet's PEG)
+	if !Build().PEG {
+		if _, err := vm.CompilePEG(context.TODO(), tests[0].grammar); !errors.Is(err, ErrPEGNotAvailable) {
+			t.Errorf("Expected peg not available, got: %v", err)
+		}
+		return
+	}
+
+	for _, test := range tests {
+		peg, err := vm.CompilePEG(context.TODO(), test.grammar)
+		if err != nil {
+			t.Errorf("Failed to compile PEG '%s': %v", test.grammar, err)
+			continue
+		}
+
+		match, err := peg.Match(context.TODO(), test.input)
+		if err != nil {
+			t.Errorf("Failed to match PEG '%s' with '%s': %v", test.grammar, test.input, err)
+		} else if test.expectedNil {
+			if match != nil {
+				t.Errorf("Grammar: %s, input: %s\nExpected no match, got: %+v", test.grammar, test.input, match)
+			}
+		} else if match == nil {
+			t.Errorf("Grammar: %s, input: %s\nExpected a match, got none", test.grammar, test.input)
+		} else {
+			if !reflect.DeepEqual(match.Captures, test.expectedCaptures) {
+				t.Errorf("Grammar: %s, input: %s\nExpected captures: %v, got: %v", test.grammar, test.input, test.expectedCaptures, match.Captures)
+			}
+			if match.End != test.expectedEnd {
+				t.Errorf("Grammar: %s, input: %s\nExpected end: %d, got: %d", test.grammar, test.input, test.expectedEnd, match.End)
+			}
+			if !reflect.DeepEqual(match.Named, test.expectedNamed) {
+				t.Errorf("Grammar: %s, input: %s\nExpected named captures: %v, got: %v", test.grammar, test.input, test.expectedNamed, match.Named)
+			}
+		}
+
+		if err := peg.Close(context.TODO()); err != nil {
+			t.Errorf("Failed to close PEG: %v", err)
+		}
+	}
+
+	// malformed grammar
+	if _, err := vm.CompilePEG(context.TODO(), `(no-such-special "a")`); err == nil {
+		t.Errorf("Should have failed to compile a malformed grammar")
+	} else if !strings.Contains(err.Error(), "no-such-special") {
+		t.Errorf("Expected error about unknown special, got '%s'", err)
+	}
+}
@@ -0,0 +1,33 @@
+// pool.go
+
+package janet
+
+import (
+	"sync"
+)
+
+// chanPool is a pool of response channels, which are reused after their responses are received.
+type chanPool[T any] struct {
+	pool sync.Pool
+}
+
+// get returns a response channel from the pool, or a new one.
+func (p *chanPool[T]) get() chan T {
+	if ch, ok := p.pool.Get().(chan T); ok {
+		return ch
+	}
+	return make(chan T, 1)
+}
+
+// put returns a response channel to the pool.
+//
+// Channels abandoned before receiving their responses (eg. when contexts are done)
+// should not be put back, as the VM handler may still send responses to them.
+func (p *chanPool[T]) put(ch chan T) {
+	p.pool.Put(ch)
+}
+
+var (
+	execResponseChans  chanPool[vmExecResponse]
+	parseResponseChans chanPool[vmParseResponse]
+)
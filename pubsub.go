@@ -0,0 +1,149 @@
+// pubsub.go
+
+package janet
+
+/*
+#include "janet.h"
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"unsafe"
+)
+
+// subscription is a subscriber of a topic, which receives published values in order
+// without blocking the publishing VM.
+type subscription struct {
+	ch chan any
+
+	mu     sync.Mutex
+	queue  []any         // values which are not sent to `ch` yet
+	notify chan struct{} // for waking up the sender
+	done   chan struct{} // closed when unsubscribed
+}
+
+// subscriptions is the subscribers of topics on a VM.
+type subscriptions struct {
+	mu     sync.Mutex
+	topics map[string][]*subscription
+	closed bool
+}
+
+// Subscribe returns a channel which receives values published to `topic` by scripts
+// with `(host/publish topic value)` (where topic is a string or a keyword, eg. "created" or `:created`),
+// converted to go values.
+//
+// Values are queued for each subscriber without blocking scripts, so the channel should be drained
+// until it is unsubscribed with VM.Unsubscribe, or closed on VM.Close.
+func (vm *VM) Subscribe(topic string) <-chan any {
+	sub := &subscription{
+		ch:     make(chan any),
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	vm.subscriptions.mu.Lock()
+	defer vm.subscriptions.mu.Unlock()
+	if vm.subscriptions.closed {
+		close(sub.ch)
+		return sub.ch
+	}
+	if vm.subscriptions.topics == nil {
+		vm.subscriptions.topics = map[string][]*subscription{}
+	}
+	vm.subscriptions.topics[topic] = append(vm.subscriptions.topics[topic], sub)
+
+	go sub.run()
+
+	return sub.ch
+}
+
+// Unsubscribe closes the channel `ch` returned from VM.Subscribe,
+// after which it does not receive values any more.
+func (vm *VM) Unsubscribe(ch <-chan any) {
+	vm.subscriptions.mu.Lock()
+	defer vm.subscriptions.mu.Unlock()
+	for topic, subs := range vm.subscriptions.topics {
+		for i, sub := range subs {
+			if sub.ch == ch {
+				vm.subscriptions.topics[topic] = slices.Delete(subs, i, i+1)
+				close(sub.done)
+				return
+			}
+		}
+	}
+}
+
+// publish queues `value` for the subscribers of `topic`, and returns the number of them.
+func (s *subscriptions) publish(topic string, value any) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subs := s.topics[topic]
+	for _, sub := range subs {
+		sub.mu.Lock()
+		sub.queue = append(sub.queue, value)
+		sub.mu.Unlock()
+		select {
+		case sub.notify <- struct{}{}:
+		default:
+		}
+	}
+	return len(subs)
+}
+
+// close unsubscribes all the subscribers.
+func (s *subscriptions) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, subs := range s.topics {
+		for _, sub := range subs {
+			close(sub.done)
+		}
+	}
+	s.topics = nil
+	s.closed = true
+}
+
+// run sends queued values to the channel until unsubscribed, and closes it.
+func (s *subscription) run() {
+	defer close(s.ch)
+	for {
+		s.mu.Lock()
+		var value any
+		queued := len(s.queue) > 0
+		if queued {
+			value = s.queue[0]
+			s.queue[0] = nil
+			s.queue = s.queue[1:]
+		}
+		s.mu.Unlock()
+
+		if !queued {
+			select {
+			case <-s.notify:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+		select {
+		case s.ch <- value:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// handlePublish converts a published janet `value` and queues it for the subscribers of `topic`.
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) handlePublish(topic string, value unsafe.Pointer) (int, error) {
+	converted, err := vm.decoder(context.Background()).decode(*(*C.Janet)(value))
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert published value: %w", err)
+	}
+	return vm.subscriptions.publish(topic, converted), nil
+}
@@ -0,0 +1,76 @@
+// pubsub_test.go
+
+package janet
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestSubscribe tests receiving values published by scripts.
+func TestSubscribe(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+
+	ctx := context.TODO()
+
+	created := vm.Subscribe("created")
+	created2 := vm.Subscribe("created")
+	deleted := vm.Subscribe("deleted")
+
+	receive := func(ch <-chan any) any {
+		select {
+		case value := <-ch:
+			return value
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for a value")
+			return nil
+		}
+	}
+
+	// published without blocking, and received in order
+	result, _, _, err := vm.Execute(ctx, `(host/publish :created {:id 1})
+(host/publish "created" {:id 2})
+(host/publish :deleted 3)
+(host/publish :unknown 4)`)
+	if err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+	if result != "0" {
+		t.Errorf("Expected no subscribers of unknown topic, got: %s", result)
+	}
+	for _, ch := range []<-chan any{created, created2} {
+		for _, id := range []float64{1, 2} {
+			if value := receive(ch); !reflect.DeepEqual(value, map[any]any{":id": id}) {
+				t.Errorf("Unexpected value: %v", value)
+			}
+		}
+	}
+	if value := receive(deleted); value != float64(3) {
+		t.Errorf("Unexpected value: %v", value)
+	}
+
+	// unsubscribed
+	vm.Unsubscribe(created2)
+	if _, ok := <-created2; ok {
+		t.Errorf("Expected closed channel")
+	}
+	if result, _, _, err := vm.Execute(ctx, `(host/publish :created {:id 3})`); err != nil || result != "1" {
+		t.Errorf("Expected 1 subscriber, got: %s, %v", result, err)
+	}
+	if value := receive(created); !reflect.DeepEqual(value, map[any]any{":id": float64(3)}) {
+		t.Errorf("Unexpected value: %v", value)
+	}
+
+	// closed with the VM
+	vm.Close()
+	for _, ch := range []<-chan any{created, deleted, vm.Subscribe("created")} {
+		if _, ok := <-ch; ok {
+			t.Errorf("Expected closed channel")
+		}
+	}
+}
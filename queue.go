@@ -0,0 +1,170 @@
+// queue.go
+
+package janet
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// initial capacity of request queues
+const requestQueueCapacity = 64
+
+// requestKind is the kind of a queued request.
+type requestKind int
+
+// requestKind constants
+const (
+	requestExec requestKind = iota
+	requestParse
+	requestCall
+)
+
+// vmRequest is a request queued for the VM handler goroutine, whose field of its kind is set.
+//
+// (requests are stored as values, not to allocate them on each request)
+type vmRequest struct {
+	kind  requestKind
+	exec  vmExecRequest
+	parse vmParseRequest
+	call  vmCallRequest
+}
+
+// context returns the context of the request (nil for internal requests).
+func (r *vmRequest) context() context.Context {
+	switch r.kind {
+	case requestExec:
+		return r.exec.ctx
+	case requestParse:
+		return r.parse.ctx
+	}
+	return r.call.ctx
+}
+
+// pending returns the counter of pending requests of the request's kind in `stats`.
+func (r *vmRequest) pending(stats *vmStats) *atomic.Int64 {
+	switch r.kind {
+	case requestExec:
+		return &stats.pendingExec
+	case requestParse:
+		return &stats.pendingParse
+	}
+	return &stats.pendingCall
+}
+
+// reject responds to the request with `err` without handling it.
+func (r *vmRequest) reject(err error) {
+	switch r.kind {
+	case requestExec:
+		r.exec.responseChan <- vmExecResponse{err: err}
+	case requestParse:
+		r.parse.responseChan <- vmParseResponse{err: err}
+	default:
+		if r.call.fail != nil {
+			r.call.fail(err)
+		}
+	}
+}
+
+// requestQueue is a FIFO queue of requests from any goroutines to the VM handler goroutine.
+//
+// Requests are pushed into a ring buffer without waiting for the handler (unlike sending to unbuffered channels),
+// and the handler takes all of the queued requests at once on a wakeup, so that tiny requests
+// are not dominated by handoffs and scheduling of goroutines.
+type requestQueue struct {
+	mu     sync.Mutex
+	buf    []vmRequest // ring buffer of queued requests
+	head   int         // index of the oldest request in `buf`
+	count  int         // number of queued requests
+	closed bool
+
+	wake chan struct{} // signaled (without blocking) when requests are queued
+}
+
+// newRequestQueue returns a new empty queue.
+func newRequestQueue() *requestQueue {
+	return &requestQueue{
+		buf:  make([]vmRequest, requestQueueCapacity),
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// push queues `req`, or returns ErrClosed if the queue is closed.
+func (q *requestQueue) push(req vmRequest) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return ErrClosed
+	}
+	if q.count == len(q.buf) {
+		q.grow()
+	}
+	q.buf[(q.head+q.count)%len(q.buf)] = req
+	q.count++
+	q.mu.Unlock()
+
+	q.signal()
+	return nil
+}
+
+// take appends all of the queued requests (the oldest first) to `batch` and returns it.
+func (q *requestQueue) take(batch []vmRequest) []vmRequest {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for ; q.count > 0; q.count-- {
+		batch = append(batch, q.buf[q.head])
+		q.buf[q.head] = vmRequest{}
+		q.head = (q.head + 1) % len(q.buf)
+	}
+	return batch
+}
+
+// requeue puts `reqs` back to the front of the queue (eg. the rest of a batch which was not handled),
+// or returns false if the queue is closed.
+func (q *requestQueue) requeue(reqs []vmRequest) bool {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return false
+	}
+	for i := len(reqs) - 1; i >= 0; i-- {
+		if q.count == len(q.buf) {
+			q.grow()
+		}
+		q.head = (q.head + len(q.buf) - 1) % len(q.buf)
+		q.buf[q.head] = reqs[i]
+		q.count++
+	}
+	q.mu.Unlock()
+
+	if len(reqs) > 0 {
+		q.signal()
+	}
+	return true
+}
+
+// close closes the queue so that no more requests are queued, and returns the requests left in it.
+func (q *requestQueue) close() []vmRequest {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	return q.take(nil)
+}
+
+// signal wakes up the handler, if it is not signaled yet.
+func (q *requestQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// grow doubles the capacity of the ring buffer. `q.mu` should be locked.
+func (q *requestQueue) grow() {
+	buf := make([]vmRequest, len(q.buf)*2)
+	for i := range q.count {
+		buf[i] = q.buf[(q.head+i)%len(q.buf)]
+	}
+	q.buf, q.head = buf, 0
+}
@@ -0,0 +1,116 @@
+// queue_test.go
+
+package janet
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRequestQueue tests queueing requests to the VM handler goroutine.
+func TestRequestQueue(t *testing.T) {
+	q := newRequestQueue()
+
+	ids := func(reqs []vmRequest) (ids []uint64) {
+		for _, req := range reqs {
+			ids = append(ids, req.call.id)
+		}
+		return ids
+	}
+
+	// more than the initial capacity, wrapping around the ring buffer
+	for id := range uint64(requestQueueCapacity / 2) {
+		if err := q.push(vmRequest{kind: requestCall, call: vmCallRequest{id: id}}); err != nil {
+			t.Fatalf("Failed to push: %v", err)
+		}
+	}
+	q.take(nil)
+	for id := range uint64(requestQueueCapacity * 3) {
+		if err := q.push(vmRequest{kind: requestCall, call: vmCallRequest{id: id}}); err != nil {
+			t.Fatalf("Failed to push: %v", err)
+		}
+	}
+	select {
+	case <-q.wake:
+	default:
+		t.Fatalf("Expected the queue to be signaled")
+	}
+
+	batch := q.take(nil)
+	if len(batch) != requestQueueCapacity*3 {
+		t.Fatalf("Expected %d requests, got %d", requestQueueCapacity*3, len(batch))
+	}
+	for i, id := range ids(batch) {
+		if id != uint64(i) {
+			t.Fatalf("Expected request #%d to be %d, got %d", i, i, id)
+		}
+	}
+
+	// requeued requests come first
+	if err := q.push(vmRequest{kind: requestCall, call: vmCallRequest{id: 100}}); err != nil {
+		t.Fatalf("Failed to push: %v", err)
+	}
+	q.requeue(batch[1:3])
+	if got := ids(q.close()); len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 100 {
+		t.Errorf("Expected requeued requests first, got %v", got)
+	}
+
+	if err := q.push(vmRequest{kind: requestCall}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed after close, got %v", err)
+	}
+}
+
+// TestConcurrentRequests tests handling requests from many goroutines, and closing the VM with queued requests.
+func TestConcurrentRequests(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+
+	ctx := context.TODO()
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				if evaluated, _, _, err := vm.Execute(ctx, `(+ 1 2)`); err != nil || evaluated != "3" {
+					t.Errorf("Expected 3, got %q (%v)", evaluated, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if served := vm.Stats().Served; served != 800 {
+		t.Errorf("Expected 800 served requests, got %d", served)
+	}
+
+	// requests queued behind a slow one fail when the VM is closed
+	go func() {
+		_, _, _, _ = vm.Execute(ctx, `(os/sleep 0.2)`)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	errs := make(chan error, 4)
+	for range 4 {
+		go func() {
+			_, _, _, err := vm.Execute(ctx, `1`)
+			errs <- err
+		}()
+	}
+	for vm.Stats().PendingExec < 4 {
+		runtime.Gosched()
+	}
+	vm.Close()
+	for range 4 {
+		if err := <-errs; !errors.Is(err, ErrClosed) {
+			t.Errorf("Expected ErrClosed for queued requests, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// random.go
+
+package janet
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+
+#include "janet.h"
+
+// defined in callbacks.go
+extern char *goReadRandom(uintptr_t vm, uint8_t *buf, int32_t n);
+
+// defined in janet_bundled.go or janet_system.go
+void *setCrashGuard(void *guard);
+
+// number of random bytes for seeding generators
+#define RANDOM_SEED_BYTES 16
+
+// handle of the VM running on the current thread, if it has a random source
+static _Thread_local uintptr_t randomVM = 0;
+
+// original `math/rng` of janet, which is called with seeds
+static _Thread_local JanetCFunction systemRng = NULL;
+
+static void setRandomVM(uintptr_t vm, JanetCFunction rng) {
+    randomVM = vm;
+    systemRng = rng;
+}
+
+// reads `n` random bytes into `buf` from the random source, panicking on failures
+static void readRandom(uint8_t *buf, int32_t n) {
+    void *guard = setCrashGuard(NULL); // not to jump over go frames (see crash.go)
+    char *err = goReadRandom(randomVM, buf, n);
+    setCrashGuard(guard);
+    if (err != NULL) {
+        Janet message = janet_cstringv(err);
+        free(err);
+        janet_panicv(message);
+    }
+}
+
+// (math/rng &opt seed), which is seeded from the random source if `seed` is not given
+static Janet randomRng(int32_t argc, Janet *argv) {
+    janet_arity(argc, 0, 1);
+    if (argc == 1) return systemRng(argc, argv);
+    JanetBuffer *seed = janet_buffer(RANDOM_SEED_BYTES);
+    janet_buffer_setcount(seed, RANDOM_SEED_BYTES);
+    readRandom(seed->data, RANDOM_SEED_BYTES);
+    Janet args[1] = {janet_wrap_buffer(seed)};
+    return systemRng(1, args);
+}
+
+// (os/cryptorand n &opt buf)
+static Janet randomCryptorand(int32_t argc, Janet *argv) {
+    janet_arity(argc, 1, 2);
+    int32_t n = janet_getinteger(argv, 0);
+    if (n < 0) janet_panic("expected positive integer");
+    JanetBuffer *buffer = argc == 2 ? janet_getbuffer(argv, 1) : janet_buffer(n);
+    int32_t offset = buffer->count;
+    janet_buffer_setcount(buffer, offset + n);
+    readRandom(buffer->data + offset, n);
+    return janet_wrap_buffer(buffer);
+}
+
+static const JanetReg randomCfuns[] = {
+    {"math/rng", randomRng, "(math/rng &opt seed)\n\nCreates a Pseudo-Random number generator, with an optional seed (seeded from the host's random source if not given). The seed should be an unsigned 32 bit integer or a buffer. Do not use this for cryptography. Returns a core/rng abstract type."},
+    {"os/cryptorand", randomCryptorand, "(os/cryptorand n &opt buf)\n\nGet or append `n` bytes of random data provided by the host's random source. Returns a new buffer or `buf`."},
+    {NULL, NULL, NULL},
+};
+
+// registers the functions of the random source in `env`, returning 0 if the original ones are not found
+static int registerRandomCfuns(JanetTable *env, uintptr_t vm) {
+    Janet rng;
+    if (janet_resolve(env, janet_csymbol("math/rng"), &rng) != JANET_BINDING_DEF || !janet_checktype(rng, JANET_CFUNCTION)) {
+        return 0;
+    }
+    setRandomVM(vm, janet_unwrap_cfunction(rng));
+    janet_cfuns(env, NULL, randomCfuns);
+    return 1;
+}
+
+static void unregisterRandom() {
+    setRandomVM(0, NULL);
+}
+
+// seeds the default generator of `math/random` with `seed` of RANDOM_SEED_BYTES
+static void seedDefaultRng(uint8_t *seed) {
+    janet_rng_longseed(janet_default_rng(), seed, RANDOM_SEED_BYTES);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime/cgo"
+	"unsafe"
+)
+
+// WithRandomSource makes scripts get random data from `source` (eg. crypto/rand.Reader, or a recorded stream for replaying)
+// instead of the system, so that hosts control the entropy of scripts and random-dependent scripts can be reproduced.
+//
+// `os/cryptorand` reads from the source, and the generator of `math/random` and the ones created with `(math/rng)`
+// (without seeds) are seeded from it. The generator of `math/random` is seeded on creation and reset of the VM.
+//
+// Scripts fail with an error when the source fails (eg. with io.EOF at the end of a recorded stream).
+func WithRandomSource(source io.Reader) Option {
+	return func(o *vmOptions) {
+		o.random = source
+	}
+}
+
+// startRandom replaces the random functions in `core` with the ones of the VM's random source (if any),
+// and seeds the generator of `math/random` from it.
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) startRandom(core *C.JanetTable) (stop func(), err error) {
+	if vm.options.random == nil {
+		return func() {}, nil
+	}
+
+	handle := cgo.NewHandle(vm)
+	if C.registerRandomCfuns(core, C.uintptr_t(handle)) == 0 {
+		handle.Delete()
+		return nil, errors.New("failed to set random source: random functions not found")
+	}
+	stop = func() {
+		C.unregisterRandom()
+		handle.Delete()
+	}
+
+	if err := vm.seedRandom(); err != nil {
+		stop()
+		return nil, err
+	}
+	return stop, nil
+}
+
+// seedRandom seeds the generator of `math/random` from the VM's random source (if any).
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) seedRandom() error {
+	if vm.options.random == nil {
+		return nil
+	}
+	seed := make([]byte, C.RANDOM_SEED_BYTES)
+	if err := vm.readRandom(seed); err != nil {
+		return err
+	}
+	C.seedDefaultRng((*C.uint8_t)(unsafe.Pointer(&seed[0])))
+	return nil
+}
+
+// readRandom fills `buf` with the data of the VM's random source.
+func (vm *VM) readRandom(buf []byte) error {
+	if _, err := io.ReadFull(vm.options.random, buf); err != nil {
+		return fmt.Errorf("failed to read random data: %w", err)
+	}
+	return nil
+}
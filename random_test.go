@@ -0,0 +1,76 @@
+// random_test.go
+
+package janet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+// TestRandomSource tests getting random data of scripts from a host's random source.
+func TestRandomSource(t *testing.T) {
+	// replays the same stream of random data
+	stream := bytes.Repeat([]byte{1, 2, 3, 4, 5, 6, 7, 8}, 64)
+	run := func() []string {
+		vm, err := NewVM(WithRandomSource(bytes.NewReader(stream)))
+		if err != nil {
+			t.Fatalf("Failed to create Janet VM: %v", err)
+		}
+		defer vm.Close()
+
+		ctx := context.TODO()
+
+		var results []string
+		for _, expression := range []string{
+			`(math/random)`,
+			`(math/rng-int (math/rng) 1000000)`,
+			`(string/format "%j" (os/cryptorand 4))`,
+			`(string/format "%j" (os/cryptorand 2 @"ab"))`,
+		} {
+			evaluated, _, _, err := vm.Execute(ctx, expression)
+			if err != nil {
+				t.Fatalf("Failed to execute %s: %v", expression, err)
+			}
+			results = append(results, evaluated)
+		}
+
+		// reset seeds `math/random` again (from the rest of the stream)
+		if err := vm.Reset(ctx); err != nil {
+			t.Fatalf("Failed to reset: %v", err)
+		}
+		evaluated, _, _, err := vm.Execute(ctx, `(math/random)`)
+		if err != nil {
+			t.Fatalf("Failed to execute after reset: %v", err)
+		}
+		return append(results, evaluated)
+	}
+
+	first, second := run(), run()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("Expected replayed result #%d to be %s, got %s", i, first[i], second[i])
+		}
+	}
+	if first[2] != `@"\x01\x02\x03\x04"` && first[2] != `@"\x05\x06\x07\x08"` {
+		t.Errorf("Expected random bytes from the source, got %s", first[2])
+	}
+	if first[3] != `@"ab\x01\x02"` && first[3] != `@"ab\x05\x06"` {
+		t.Errorf("Expected random bytes to be appended, got %s", first[3])
+	}
+
+	// fails when the source is exhausted
+	vm, err := NewVM(WithRandomSource(bytes.NewReader(make([]byte, 16))))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+	if _, _, _, err := vm.Execute(context.TODO(), `(os/cryptorand 8)`); err == nil {
+		t.Errorf("Expected an error with the exhausted source")
+	}
+	if _, err := NewVM(WithRandomSource(bytes.NewReader(nil))); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected creation to fail with the empty source, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+// redefine.go
+
+package janet
+
+/*
+#include <stdlib.h>
+
+#include "janet.h"
+
+// replaces the value of a binding `entry` with `value`,
+// updating its reference too (for vars, and defs compiled with `:redef`).
+static void replaceBinding(JanetTable *entry, Janet value) {
+    Janet ref = janet_table_get(entry, janet_ckeywordv("ref"));
+    if (janet_checktype(ref, JANET_ARRAY) && janet_unwrap_array(ref)->count > 0) {
+        janet_unwrap_array(ref)->data[0] = value;
+    } else {
+        janet_table_put(entry, janet_ckeywordv("value"), value);
+    }
+}
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"unsafe"
+)
+
+// WithRedefinable makes top-level definitions (`def`, `defn`, ...) compiled as redefinable ones
+// (like `(setdyn :redef true)`), so that functions referring to them see their new values
+// replaced with VM.Redefine.
+//
+// References to redefinable bindings are resolved on each use, so they are slightly slower than the default ones.
+func WithRedefinable() Option {
+	return func(o *vmOptions) {
+		o.redefinable = true
+	}
+}
+
+// Redefine replaces the value of the binding `name` defined in the VM with the value of janet source `src`
+// (eg. `(fn [x] (* x 2))`, or `(defn double [x] (* x 2))`).
+//
+// The source is compiled and evaluated in isolation with `opts` (eg. WithSourceMap), so its definitions are not visible in the VM,
+// and the binding is replaced only if it succeeds, so a failed update leaves the VM as it was.
+// The binding is replaced at once, between other executions on the VM.
+//
+// Functions compiled before refer to the new value if the binding is a `var`, or was defined
+// in a VM created with WithRedefinable, otherwise they keep referring to the old (still valid) value.
+func (vm *VM) Redefine(
+	ctx context.Context,
+	name string,
+	src string,
+	opts ...ExecOption,
+) error {
+	options := newExecOptions(opts)
+
+	res, err := runOnVM(ctx, vm, func(env *C.JanetTable) error {
+		cName := C.CString(name)
+		defer C.free(unsafe.Pointer(cName))
+		entry := C.janet_table_rawget(env, C.janet_wrap_symbol(C.janet_csymbol(cName)))
+		if C.janet_checktype(entry, C.JANET_TABLE) == 0 {
+			return fmt.Errorf("unknown binding: %s", name)
+		}
+
+		// source is evaluated in a temporary environment
+		isolated := C.janet_table(0)
+		isolated.proto = env
+		C.janet_gcroot(C.janet_wrap_table(isolated))
+		defer C.janet_gcunroot(C.janet_wrap_table(isolated))
+
+		var janetResult C.Janet
+		var failure evalFailure
+		var ret C.int
+		if err := vm.withExecDyns(isolated, options, func() {
+			captureOutput(isolated, func() {
+				ret = dobytesAt(isolated, src, options.source, &janetResult, &failure)
+			})
+		}); err != nil {
+			return err
+		}
+		if ret != C.JANET_SIGNAL_OK {
+			return failure.wrap(newError(failure.fiber, janetResult, janetValueToString(janetResult)))
+		}
+
+		C.replaceBinding(C.janet_unwrap_table(entry), janetResult)
+		vm.results.clear()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return res
+}
@@ -0,0 +1,79 @@
+// redefine_test.go
+
+package janet
+
+import (
+	"context"
+	"testing"
+)
+
+// TestRedefine tests replacing bindings of VMs.
+func TestRedefine(t *testing.T) {
+	ctx := context.TODO()
+
+	for _, test := range []struct {
+		opts []Option
+
+		expectedCaller string // result of the caller compiled before the redefinition
+	}{
+		{nil, "2"},
+		{[]Option{WithRedefinable()}, "3"},
+	} {
+		vm, err := NewVM(test.opts...)
+		if err != nil {
+			t.Fatalf("Failed to create Janet VM: %v", err)
+		}
+
+		if _, _, _, err := vm.Execute(ctx, `(defn f [x] (* x 2)) (defn caller [] (f 1))`); err != nil {
+			t.Fatalf("Failed to define functions: %v", err)
+		}
+
+		// failed redefinitions leave bindings as they were
+		if err := vm.Redefine(ctx, "f", `(fn [x] (* x`); err == nil {
+			t.Errorf("Expected error for malformed source")
+		}
+		if err := vm.Redefine(ctx, "f", `(fn [x] (undefined-fn x))`); err == nil {
+			t.Errorf("Expected error for uncompilable source")
+		}
+		if err := vm.Redefine(ctx, "undefined-fn", `(fn [] 0)`); err == nil {
+			t.Errorf("Expected error for unknown binding")
+		}
+		if result, _, _, err := vm.Execute(ctx, `(f 1)`); err != nil || result != "2" {
+			t.Errorf("Expected unchanged binding, got: %s, %v", result, err)
+		}
+
+		// successful redefinition
+		if err := vm.Redefine(ctx, "f", `(defn helper [x] (* x 3)) (defn f [x] (helper x))`); err != nil {
+			t.Errorf("Failed to redefine: %v", err)
+		}
+		if result, _, _, err := vm.Execute(ctx, `(f 1)`); err != nil || result != "3" {
+			t.Errorf("Expected redefined binding, got: %s, %v", result, err)
+		}
+		if result, _, _, err := vm.Execute(ctx, `(caller)`); err != nil || result != test.expectedCaller {
+			t.Errorf("Expected %s from caller, got: %s, %v", test.expectedCaller, result, err)
+		}
+
+		// definitions in the source are isolated
+		if _, _, _, err := vm.Execute(ctx, `(helper 1)`); err == nil {
+			t.Errorf("Expected isolated definition")
+		}
+
+		vm.Close()
+	}
+
+	// vars are always redefinable
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+	if _, _, _, err := vm.Execute(ctx, `(var limit 10) (defn over? [x] (> x limit))`); err != nil {
+		t.Fatalf("Failed to define functions: %v", err)
+	}
+	if err := vm.Redefine(ctx, "limit", `20`); err != nil {
+		t.Errorf("Failed to redefine: %v", err)
+	}
+	if result, _, _, err := vm.Execute(ctx, `(over? 15)`); err != nil || result != "false" {
+		t.Errorf("Expected redefined var, got: %s, %v", result, err)
+	}
+}
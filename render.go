@@ -0,0 +1,123 @@
+// render.go
+
+package janet
+
+/*
+#include "janet.h"
+*/
+import "C"
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// Render is the style of rendering evaluated janet values as strings.
+type Render int
+
+// Render constants
+const (
+	RenderDefault  Render = iota // strings and symbols as they are, keywords with colons, tuples in parentheses, and others like `string`
+	RenderString                 // same as janet's `string` (eg. `hello` for "hello", `<tuple 0x...>` for [1 2])
+	RenderDescribe               // same as janet's `describe` (quoted, eg. `"hello"` for "hello", `:kw` for :kw)
+	RenderJDN                    // strict JDN (janet data notation) which can be parsed back (fails on values like functions)
+)
+
+// janet source of the helper function which renders values as JDN.
+const jdnRendererSource = `(fn [x] (string/format "%j" x))`
+
+// render renders a janet value as a string in the given style.
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) render(
+	env *C.JanetTable,
+	value C.Janet,
+	style Render,
+	numbers *NumberFormat,
+) (string, error) {
+	switch style {
+	case RenderString:
+		return janetBufferString(value, false), nil
+	case RenderDescribe:
+		return janetBufferString(value, true), nil
+	case RenderJDN:
+		rendered, err := vm.renderJDN(env, value)
+		if err != nil {
+			return "", err
+		}
+		return janetValueToString(rendered), nil
+	default:
+		return janetValueToFormattedString(value, numbers), nil
+	}
+}
+
+// renderJDN renders a janet value as a janet string of JDN.
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) renderJDN(env *C.JanetTable, value C.Janet) (C.Janet, error) {
+	if vm.jdnRenderer == nil {
+		helper, err := compileHelper(env, jdnRendererSource)
+		if err != nil {
+			return C.janet_wrap_nil(), err
+		}
+		vm.jdnRenderer = helper
+	}
+	return pcall(env, vm.jdnRenderer, value)
+}
+
+// janetBufferString renders a janet value with `janet_description_b` (if `describe` is true)
+// or `janet_to_string_b`.
+func janetBufferString(value C.Janet, describe bool) string {
+	var buffer C.JanetBuffer
+	C.janet_buffer_init(&buffer, 0)
+	defer C.janet_buffer_deinit(&buffer)
+
+	if describe {
+		C.janet_description_b(&buffer, value)
+	} else {
+		C.janet_to_string_b(&buffer, value)
+	}
+	return C.GoStringN((*C.char)(unsafe.Pointer(buffer.data)), C.int(buffer.count))
+}
+
+// NumberFormat is the format of numbers in rendered results,
+// for results which should not depend on platforms (eg. golden tests).
+type NumberFormat struct {
+	Precision         int  // max number of significant digits (0 for the shortest representation which can be parsed back)
+	AlwaysDecimal     bool // always show decimal points for whole numbers (eg. "1.0" instead of "1")
+	ExponentThreshold int  // use scientific notation for numbers >= 1e(this value) or < 1e-6 (0 for 21, like javascript)
+}
+
+// format formats a number.
+func (f NumberFormat) format(n float64) string {
+	switch {
+	case math.IsNaN(n):
+		return "nan"
+	case math.IsInf(n, 1):
+		return "inf"
+	case math.IsInf(n, -1):
+		return "-inf"
+	}
+
+	if f.Precision > 0 {
+		n, _ = strconv.ParseFloat(strconv.FormatFloat(n, 'g', f.Precision, 64), 64)
+	}
+	threshold := f.ExponentThreshold
+	if threshold <= 0 {
+		threshold = 21
+	}
+
+	// decimal exponent of the number
+	scientific := strconv.FormatFloat(n, 'e', -1, 64)
+	exponent, _ := strconv.Atoi(scientific[strings.IndexByte(scientific, 'e')+1:])
+
+	if n != 0 && (exponent >= threshold || exponent < -6) {
+		return scientific
+	}
+
+	formatted := strconv.FormatFloat(n, 'f', -1, 64)
+	if f.AlwaysDecimal && !strings.Contains(formatted, ".") {
+		formatted += ".0"
+	}
+	return formatted
+}
@@ -0,0 +1,103 @@
+// render_test.go
+
+package janet
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestRender tests rendering styles of evaluated values.
+func TestRender(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	tests := []struct {
+		input string
+		style Render
+
+		expected       string
+		expectedPrefix string
+		expectError    bool
+	}{
+		{input: `"hello"`, style: RenderDefault, expected: `hello`},
+		{input: `"hello"`, style: RenderString, expected: `hello`},
+		{input: `"hello"`, style: RenderDescribe, expected: `"hello"`},
+		{input: `"hello"`, style: RenderJDN, expected: `"hello"`},
+		{input: `:kw`, style: RenderString, expected: `kw`},
+		{input: `:kw`, style: RenderDescribe, expected: `:kw`},
+		{input: `[1 "a"]`, style: RenderDefault, expected: `(1 a)`},
+		{input: `[1 "a"]`, style: RenderString, expectedPrefix: `<tuple 0x`},
+		{input: `[1 "a"]`, style: RenderDescribe, expectedPrefix: `<tuple 0x`},
+		{input: `[1 "a"]`, style: RenderJDN, expected: `(1 "a")`},
+		{input: `@{:a [1 2]}`, style: RenderJDN, expected: `@{:a (1 2)}`},
+		{input: `nil`, style: RenderJDN, expected: `nil`},
+		{input: `print`, style: RenderJDN, expectError: true},
+	}
+	for _, test := range tests {
+		evaluated, _, _, err := vm.Execute(ctx, test.input, WithRender(test.style))
+		if test.expectError {
+			if err == nil {
+				t.Errorf("Expected error for '%s' with style %d, got '%s'", test.input, test.style, evaluated)
+			}
+		} else if err != nil {
+			t.Errorf("Failed to execute '%s' with style %d: %v", test.input, test.style, err)
+		} else if test.expectedPrefix != "" {
+			if !strings.HasPrefix(evaluated, test.expectedPrefix) {
+				t.Errorf("Expected '%s...' for '%s' with style %d, got '%s'", test.expectedPrefix, test.input, test.style, evaluated)
+			}
+		} else if evaluated != test.expected {
+			t.Errorf("Expected '%s' for '%s' with style %d, got '%s'", test.expected, test.input, test.style, evaluated)
+		}
+	}
+
+	// for each form
+	results, _, _, err := vm.ExecuteAllForms(ctx, `"a" :b`, WithRender(RenderJDN))
+	if err != nil || len(results) != 2 || results[0].Evaluated != `"a"` || results[1].Evaluated != `:b` {
+		t.Errorf("Unexpected results of forms rendered as JDN: %+v (error: %v)", results, err)
+	}
+}
+
+// TestNumberFormat tests formatting numbers in rendered values.
+func TestNumberFormat(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	tests := []struct {
+		input  string
+		format NumberFormat
+
+		expected string
+	}{
+		{`1`, NumberFormat{}, `1`},
+		{`1`, NumberFormat{AlwaysDecimal: true}, `1.0`},
+		{`[1 2.5 -3]`, NumberFormat{AlwaysDecimal: true}, `(1.0 2.5 -3.0)`},
+		{`(/ 1 3)`, NumberFormat{}, `0.3333333333333333`},
+		{`(/ 1 3)`, NumberFormat{Precision: 3}, `0.333`},
+		{`(/ 2 3)`, NumberFormat{Precision: 3}, `0.667`},
+		{`1e20`, NumberFormat{}, `100000000000000000000`},
+		{`1e21`, NumberFormat{}, `1e+21`},
+		{`123456`, NumberFormat{ExponentThreshold: 5}, `1.23456e+05`},
+		{`123456`, NumberFormat{ExponentThreshold: 5, Precision: 2}, `1.2e+05`},
+		{`0.0000001`, NumberFormat{}, `1e-07`},
+		{`[math/nan math/inf (- math/inf)]`, NumberFormat{}, `(nan inf -inf)`},
+	}
+	for _, test := range tests {
+		if evaluated, _, _, err := vm.Execute(ctx, test.input, WithNumberFormat(test.format)); err != nil {
+			t.Errorf("Failed to execute '%s': %v", test.input, err)
+		} else if evaluated != test.expected {
+			t.Errorf("Expected '%s' for '%s' with format %+v, got '%s'", test.expected, test.input, test.format, evaluated)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// reset.go
+
+package janet
+
+/*
+#include "janet.h"
+*/
+import "C"
+
+import (
+	"context"
+)
+
+// newEnv creates a new (gc-rooted) environment for user codes, which inherits bindings from `core`.
+//
+// This function should only be called from the VM handler goroutine.
+func newEnv(core *C.JanetTable) *C.JanetTable {
+	env := C.janet_table(0)
+	env.proto = core
+	C.janet_gcroot(C.janet_wrap_table(env))
+	return env
+}
+
+// Reset clears all definitions made on the VM, as if it was newly created with the same options
+// (including the prelude, see WithPrelude, and the seed of `math/random`, see WithRandomSource),
+// without tearing down its OS thread and janet runtime.
+//
+// Value handles created before the reset are still valid,
+// but modules already imported are cached and not loaded again.
+func (vm *VM) Reset(ctx context.Context) error {
+	res, err := runOnVM(ctx, vm, func(env *C.JanetTable) error {
+		vm.env = newEnv(env.proto)
+		C.janet_gcunroot(C.janet_wrap_table(env))
+		vm.results.clear()
+
+		// helpers are compiled again in the new environment
+		for _, helper := range vm.helpers() {
+			if *helper.fn != nil {
+				C.janet_gcunroot(C.janet_wrap_function(*helper.fn))
+				*helper.fn = nil
+			}
+		}
+		if err := vm.seedRandom(); err != nil {
+			return err
+		}
+		return vm.options.evalPrelude(vm.env)
+	})
+	if err != nil {
+		return err
+	}
+	return res
+}
+
+// helper is a janet function used internally, which is compiled on its first use.
+type helper struct {
+	fn     **C.JanetFunction // where the compiled function is stored (only accessed from the VM handler goroutine)
+	source string
+}
+
+// helpers returns the janet functions used internally.
+func (vm *VM) helpers() []helper {
+	return []helper{
+		{&vm.pegCompiler, pegCompilerSource},
+		{&vm.pegMatcher, pegMatcherSource},
+		{&vm.bindingsLister, bindingsListerSource},
+		{&vm.docLookup, docLookupSource},
+		{&vm.flychecker, flycheckerSource},
+		{&vm.jdnRenderer, jdnRendererSource},
+		{&vm.applier, applierSource},
+		{&vm.getter, getterSource},
+		{&vm.debugInspector, debugInspectorSource},
+	}
+}
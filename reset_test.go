@@ -0,0 +1,48 @@
+// reset_test.go
+
+package janet
+
+import (
+	"context"
+	"testing"
+)
+
+// TestReset tests resetting environments of VMs.
+func TestReset(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	if _, _, _, err := vm.Execute(ctx, `(def answer 42) (defn + [& _] "shadowed")`); err != nil {
+		t.Fatalf("Failed to define: %v", err)
+	}
+	if _, err := vm.Apply(ctx, "+", 1, 2); err != nil {
+		t.Fatalf("Failed to apply: %v", err)
+	}
+	handle, err := vm.EvalHandle(ctx, `@[answer]`)
+	if err != nil {
+		t.Fatalf("Failed to evaluate handle: %v", err)
+	}
+	defer handle.Release(ctx)
+
+	if err := vm.Reset(ctx); err != nil {
+		t.Fatalf("Failed to reset: %v", err)
+	}
+
+	if _, _, _, err := vm.Execute(ctx, `answer`); err == nil {
+		t.Errorf("Expected definitions to be cleared after reset")
+	}
+	if result, err := vm.Apply(ctx, "+", 1, 2); err != nil || result != float64(3) {
+		t.Errorf("Expected core bindings to be restored after reset, got %v (%v)", result, err)
+	}
+	if value, err := handle.Get(ctx, 0); err != nil || value != float64(42) {
+		t.Errorf("Expected handles to be valid after reset, got %v (%v)", value, err)
+	}
+	if _, _, _, err := vm.Execute(ctx, `(ffi/context)`); err == nil {
+		t.Errorf("Expected options to be kept after reset")
+	}
+}
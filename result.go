@@ -0,0 +1,89 @@
+// result.go
+
+package janet
+
+/*
+#include "janet.h"
+*/
+import "C"
+
+import (
+	"context"
+	"time"
+)
+
+// Type is the type of a janet value.
+type Type int
+
+// Type constants
+const (
+	TypeNumber    Type = C.JANET_NUMBER
+	TypeNil       Type = C.JANET_NIL
+	TypeBoolean   Type = C.JANET_BOOLEAN
+	TypeFiber     Type = C.JANET_FIBER
+	TypeString    Type = C.JANET_STRING
+	TypeSymbol    Type = C.JANET_SYMBOL
+	TypeKeyword   Type = C.JANET_KEYWORD
+	TypeArray     Type = C.JANET_ARRAY
+	TypeTuple     Type = C.JANET_TUPLE
+	TypeTable     Type = C.JANET_TABLE
+	TypeStruct    Type = C.JANET_STRUCT
+	TypeBuffer    Type = C.JANET_BUFFER
+	TypeFunction  Type = C.JANET_FUNCTION
+	TypeCFunction Type = C.JANET_CFUNCTION
+	TypeAbstract  Type = C.JANET_ABSTRACT
+	TypePointer   Type = C.JANET_POINTER
+)
+
+// String returns the name of the type, same as janet's `type` function (eg. "table").
+func (t Type) String() string {
+	if t < TypeNumber || t > TypePointer {
+		return "unknown"
+	}
+	return C.GoString(C.janet_type_names[t])
+}
+
+// Result is the result of an execution.
+type Result struct {
+	Evaluated string // rendered evaluated value (or the payload of the raised signal)
+	Type      Type   // type of the evaluated value (eg. for telling nil from the string "nil")
+	Signal    Signal // SignalOK, or a yield or a user signal which stopped the execution
+	Stdout    string
+	Stderr    string
+	Timing    Timing // time spent waiting for the VM and running on it
+}
+
+// Timing is the time spent for an execution, for telling the latency of queueing from the one of evaluation.
+type Timing struct {
+	Wait time.Duration // time spent waiting in the VM's queue (eg. while other requests are handled)
+	Run  time.Duration // time spent running on the VM (evaluating, and rendering the result)
+}
+
+// Total returns the total time spent for the execution.
+func (t Timing) Total() time.Duration {
+	return t.Wait + t.Run
+}
+
+// ExecuteResult executes a `janetExpression` and returns the result with the type of the evaluated value.
+//
+// Outputs to stdout and stderr are returned in the result even when the execution fails.
+// Yields and user signals raised by top-level forms are returned in the result, not as errors.
+// Time spent for the execution is also returned in the result (eg. for logging slow scripts).
+func (vm *VM) ExecuteResult(
+	ctx context.Context,
+	janetExpression string,
+	opts ...ExecOption,
+) (Result, error) {
+	res, err := vm.execute(ctx, janetExpression, opts)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Evaluated: res.evaluated,
+		Type:      res.typ,
+		Signal:    res.signal,
+		Stdout:    res.stdout,
+		Stderr:    res.stderr,
+		Timing:    res.timing,
+	}, res.err
+}
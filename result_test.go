@@ -0,0 +1,86 @@
+// result_test.go
+
+package janet
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestExecuteResult tests executions with types of evaluated values.
+func TestExecuteResult(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	tests := []struct {
+		input string
+
+		expectedEvaluated string
+		expectedType      Type
+		expectedTypeName  string
+	}{
+		{`nil`, "nil", TypeNil, "nil"},
+		{`"nil"`, "nil", TypeString, "string"},
+		{`42`, "42", TypeNumber, "number"},
+		{`:kw`, ":kw", TypeKeyword, "keyword"},
+		{`@{}`, "", TypeTable, "table"},
+		{`[1 2]`, "(1 2)", TypeTuple, "tuple"},
+		{`(fiber/new (fn [] 1))`, "", TypeFiber, "fiber"},
+		{`(fn [] 1)`, "", TypeFunction, "function"},
+		{`print`, "", TypeCFunction, "cfunction"},
+	}
+	for _, test := range tests {
+		result, err := vm.ExecuteResult(ctx, test.input)
+		if err != nil {
+			t.Errorf("Failed to execute '%s': %v", test.input, err)
+			continue
+		}
+		if test.expectedEvaluated != "" && result.Evaluated != test.expectedEvaluated {
+			t.Errorf("Expected '%s' for '%s', got '%s'", test.expectedEvaluated, test.input, result.Evaluated)
+		}
+		if result.Type != test.expectedType || result.Type.String() != test.expectedTypeName {
+			t.Errorf("Expected type %s for '%s', got %s", test.expectedTypeName, test.input, result.Type)
+		}
+	}
+
+	if result, err := vm.ExecuteResult(ctx, `(print "out") (error "failed")`); err == nil {
+		t.Errorf("Expected error for failed execution")
+	} else if result.Stdout != "out\n" {
+		t.Errorf("Expected stdout 'out\\n' of failed execution, got '%s'", result.Stdout)
+	}
+}
+
+// TestExecuteResultTiming tests time spent for executions returned in their results.
+func TestExecuteResultTiming(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	// waits for a running execution
+	go func() { _, _ = vm.ExecuteResult(ctx, `(os/sleep 0.3)`) }()
+	time.Sleep(100 * time.Millisecond)
+	result, err := vm.ExecuteResult(ctx, `(os/sleep 0.1)`)
+	if err != nil {
+		t.Fatalf("Failed to execute: %v", err)
+	}
+	if result.Timing.Wait < 100*time.Millisecond || result.Timing.Run < 100*time.Millisecond || result.Timing.Run > result.Timing.Total() {
+		t.Errorf("Unexpected timing: %+v", result.Timing)
+	}
+
+	// failed executions also have timing
+	if result, err := vm.ExecuteResult(ctx, `(os/sleep 0.1) (error "failed")`); err == nil {
+		t.Errorf("Expected error")
+	} else if result.Timing.Run < 100*time.Millisecond {
+		t.Errorf("Unexpected timing of failed execution: %+v", result.Timing)
+	}
+}
@@ -0,0 +1,100 @@
+// resultcache.go
+
+package janet
+
+import (
+	"strings"
+	"sync"
+)
+
+// WithResultCache caches the results of up to `size` pure expressions (executed with WithPure),
+// so that executing the same expressions again (eg. in hot paths of templating) returns the cached results
+// without waiting for the VM. The least recently used results are evicted first.
+//
+// The cache is cleared with VM.Reset and VM.Redefine, and can be cleared with VM.ClearResultCache
+// (eg. after changing definitions which pure expressions refer to).
+func WithResultCache(size int) Option {
+	return func(o *vmOptions) {
+		o.resultCache = size
+	}
+}
+
+// WithPure marks the executed expression as pure, whose result depends only on the expression itself
+// (and definitions which are not changed), so that its result is cached in a VM created with WithResultCache.
+//
+// Only the results of successful executions are cached, and executions with dynamic bindings (WithDyns) are not cached.
+// Results returned from the cache have zero Timing.
+func WithPure() ExecOption {
+	return func(o *execOptions) {
+		o.pure = true
+	}
+}
+
+// resultCacheKey identifies a cached result.
+type resultCacheKey struct {
+	expression string
+	render     Render
+	numbers    NumberFormat
+	formatted  bool // whether numbers are formatted with `numbers`
+}
+
+// resultCache is a LRU cache of the results of pure expressions.
+type resultCache struct {
+	mu      sync.Mutex
+	results *lru[resultCacheKey, vmExecResponse]
+}
+
+// newResultCache returns a new cache of `size` results (nil if `size` is not positive).
+func newResultCache(size int) *resultCache {
+	if size <= 0 {
+		return nil
+	}
+	return &resultCache{results: newLRU[resultCacheKey, vmExecResponse](size)}
+}
+
+// key returns the key of `expression` executed with `options`, and whether its result can be cached.
+func (c *resultCache) key(expression string, options execOptions) (resultCacheKey, bool) {
+	if c == nil || !options.pure || len(options.dyns) > 0 || options.tempDir || options.result != nil {
+		return resultCacheKey{}, false
+	}
+	key := resultCacheKey{expression: expression, render: options.render}
+	if options.numbers != nil {
+		key.numbers, key.formatted = *options.numbers, true
+	}
+	return key, true
+}
+
+// get returns the cached result of `key`.
+func (c *resultCache) get(key resultCacheKey) (vmExecResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.results.get(key)
+}
+
+// put caches `result` of `key`, if it is a successful one.
+func (c *resultCache) put(key resultCacheKey, result vmExecResponse) {
+	if result.err != nil || result.signal != SignalOK {
+		return
+	}
+	result.timing = Timing{}
+	key.expression = strings.Clone(key.expression) // (it may refer to bytes of ExecuteBytes)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results.put(key, result)
+}
+
+// clear removes all cached results.
+func (c *resultCache) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results.clear()
+}
+
+// ClearResultCache removes all the results cached with WithResultCache.
+func (vm *VM) ClearResultCache() {
+	vm.results.clear()
+}
@@ -0,0 +1,82 @@
+// resultcache_test.go
+
+package janet
+
+import (
+	"context"
+	"testing"
+)
+
+// TestResultCache tests caching results of pure expressions.
+func TestResultCache(t *testing.T) {
+	vm, err := NewVM(WithResultCache(2))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	if _, _, _, err := vm.Execute(ctx, `(var calls 0) (defn render [x] (++ calls) (string "<" x ">"))`); err != nil {
+		t.Fatalf("Failed to define function: %v", err)
+	}
+	execute := func(src string, opts ...ExecOption) string {
+		evaluated, _, _, err := vm.Execute(ctx, src, opts...)
+		if err != nil {
+			t.Fatalf("Failed to execute '%s': %v", src, err)
+		}
+		return evaluated
+	}
+	expectCalls := func(expected string) {
+		t.Helper()
+		if calls := execute(`calls`); calls != expected {
+			t.Errorf("Expected %s calls, got %s", expected, calls)
+		}
+	}
+
+	// cached only with WithPure
+	for range 3 {
+		if evaluated := execute(`(render "a")`, WithPure()); evaluated != "<a>" {
+			t.Errorf("Unexpected result: %s", evaluated)
+		}
+	}
+	expectCalls("1")
+	execute(`(render "a")`)
+	expectCalls("2")
+
+	// cached results have zero timing
+	if result, err := vm.ExecuteResult(ctx, `(render "a")`, WithPure()); err != nil || result.Evaluated != "<a>" || result.Timing != (Timing{}) {
+		t.Errorf("Unexpected cached result: %+v, %v", result, err)
+	}
+
+	// results are cached separately for different rendering options
+	if evaluated := execute(`(render "a")`, WithPure(), WithRender(RenderJDN)); evaluated != `"<a>"` {
+		t.Errorf("Unexpected result rendered as jdn: %s", evaluated)
+	}
+	expectCalls("3")
+
+	// least recently used results are evicted
+	execute(`(render "b")`, WithPure())
+	execute(`(render "a")`, WithPure())
+	expectCalls("5")
+
+	// failures are not cached
+	for range 2 {
+		if _, _, _, err := vm.Execute(ctx, `(error (render "c"))`, WithPure()); err == nil {
+			t.Errorf("Expected error")
+		}
+	}
+	expectCalls("7")
+
+	// cleared explicitly, or with redefinitions
+	vm.ClearResultCache()
+	execute(`(render "a")`, WithPure())
+	expectCalls("8")
+	if err := vm.Redefine(ctx, "render", `(fn [x] (++ calls) (string "[" x "]"))`); err != nil {
+		t.Fatalf("Failed to redefine function: %v", err)
+	}
+	if evaluated := execute(`(render "a")`, WithPure()); evaluated != "[a]" {
+		t.Errorf("Expected redefined result, got: %s", evaluated)
+	}
+	expectCalls("9")
+}
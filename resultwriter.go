@@ -0,0 +1,148 @@
+// resultwriter.go
+
+package janet
+
+/*
+#include <stdint.h>
+
+#include "janet.h"
+
+static int32_t resultStringLength(const uint8_t *str) {
+    return janet_string_length(str);
+}
+*/
+import "C"
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// max size of chunks of results written with WithResultWriter
+const resultChunkSize = 64 * 1024
+
+// WithResultWriter makes the execution write its rendered result to `w` in chunks of up to 64 KiB,
+// instead of returning it as a string (an empty evaluated result is returned),
+// so that huge results (eg. multi-megabyte strings) are not built as go strings at once.
+//
+// Strings, symbols, keywords and buffers are written directly from the memory of janet,
+// and tuples of RenderDefault are written element by element.
+// `w` is called on the VM handler goroutine (so it should not call methods of the VM),
+// and chunks passed to it should not be retained after the call, as io.Writer requires.
+//
+// Writing fails the execution with an error (with its outputs), and results are not cached with WithResultCache.
+// Functions which return results of multiple forms (eg. VM.ExecuteAllForms) ignore this option.
+func WithResultWriter(w io.Writer) ExecOption {
+	return func(o *execOptions) {
+		o.result = w
+	}
+}
+
+// chunkWriter splits writes to `w` into chunks of up to resultChunkSize.
+type chunkWriter struct {
+	w io.Writer
+}
+
+// Write writes `p` to the underlying writer in chunks.
+func (c chunkWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		chunk := p[:min(len(p), resultChunkSize)]
+		written, err := c.w.Write(chunk)
+		n += written
+		if err != nil {
+			return n, err
+		}
+		if written < len(chunk) {
+			return n, io.ErrShortWrite
+		}
+		p = p[written:]
+	}
+	return n, nil
+}
+
+// renderTo renders a janet value in the given style (same as VM.render) and writes it to `w` in chunks.
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) renderTo(
+	env *C.JanetTable,
+	value C.Janet,
+	style Render,
+	numbers *NumberFormat,
+	w io.Writer,
+) error {
+	// small pieces (eg. elements of tuples) are gathered into chunks
+	out := bufio.NewWriterSize(chunkWriter{w: w}, resultChunkSize)
+
+	switch style {
+	case RenderString, RenderDescribe:
+		var buffer C.JanetBuffer
+		C.janet_buffer_init(&buffer, 0)
+		defer C.janet_buffer_deinit(&buffer)
+
+		if style == RenderDescribe {
+			C.janet_description_b(&buffer, value)
+		} else {
+			C.janet_to_string_b(&buffer, value)
+		}
+		_, _ = out.Write(janetBytes(buffer.data, buffer.count))
+	case RenderJDN:
+		rendered, err := vm.renderJDN(env, value)
+		if err != nil {
+			return err
+		}
+		writeJanetString(out, C.janet_unwrap_string(rendered))
+	default:
+		writeFormatted(out, value, numbers)
+	}
+
+	// (errors of writes are kept by `out` until flushed)
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("failed to write result: %w", err)
+	}
+	return nil
+}
+
+// writeFormatted writes a janet value formatted in the same way as janetValueToFormattedString.
+func writeFormatted(out *bufio.Writer, value C.Janet, numbers *NumberFormat) {
+	switch C.janet_type(value) {
+	case C.JANET_STRING:
+		writeJanetString(out, C.janet_unwrap_string(value))
+	case C.JANET_SYMBOL:
+		writeJanetString(out, C.janet_unwrap_symbol(value))
+	case C.JANET_KEYWORD:
+		_ = out.WriteByte(':')
+		writeJanetString(out, C.janet_unwrap_keyword(value))
+	case C.JANET_BUFFER:
+		buffer := C.janet_unwrap_buffer(value)
+		_, _ = out.Write(janetBytes(buffer.data, buffer.count))
+	case C.JANET_TUPLE:
+		var data *C.Janet
+		var length C.int32_t
+		C.janet_indexed_view(value, &data, &length)
+
+		_ = out.WriteByte('(')
+		for i, elem := range unsafe.Slice(data, int(length)) {
+			if i > 0 {
+				_ = out.WriteByte(' ')
+			}
+			writeFormatted(out, elem, numbers)
+		}
+		_ = out.WriteByte(')')
+	default:
+		_, _ = out.WriteString(janetValueToFormattedString(value, numbers))
+	}
+}
+
+// writeJanetString writes the bytes of a janet string (or symbol, keyword) without copying them.
+func writeJanetString(out *bufio.Writer, str *C.uint8_t) {
+	_, _ = out.Write(janetBytes(str, C.resultStringLength(str)))
+}
+
+// janetBytes returns the bytes of janet's memory at `data` as a slice, which is valid until janet runs again.
+func janetBytes(data *C.uint8_t, length C.int32_t) []byte {
+	if length <= 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(data)), int(length))
+}
@@ -0,0 +1,102 @@
+// resultwriter_test.go
+
+package janet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// chunkRecorder records chunks written to it.
+type chunkRecorder struct {
+	bytes.Buffer
+	chunks int
+	max    int
+}
+
+func (r *chunkRecorder) Write(p []byte) (int, error) {
+	r.chunks++
+	r.max = max(r.max, len(p))
+	return r.Buffer.Write(p)
+}
+
+// failingWriter fails all writes.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+// TestResultWriter tests writing rendered results to writers in chunks.
+func TestResultWriter(t *testing.T) {
+	vm, err := NewVM(WithResultCache(16))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	// huge strings are written in chunks
+	var w chunkRecorder
+	evaluated, stdout, _, err := vm.Execute(ctx, `(do (print "making") (string/repeat "ab" 100000))`, WithResultWriter(&w))
+	if err != nil {
+		t.Fatalf("Failed to execute: %v", err)
+	}
+	if evaluated != "" || stdout != "making\n" {
+		t.Errorf("Expected an empty result with outputs, got %q and %q", evaluated, stdout)
+	}
+	if w.String() != strings.Repeat("ab", 100000) {
+		t.Errorf("Expected the written string, got %d bytes", w.Len())
+	}
+	if w.chunks != 4 || w.max != resultChunkSize {
+		t.Errorf("Expected 4 chunks of up to %d bytes, got %d chunks of up to %d bytes", resultChunkSize, w.chunks, w.max)
+	}
+
+	// written results are same as the returned ones
+	for _, tc := range []struct {
+		expression string
+		opts       []ExecOption
+	}{
+		{`[:a "b" @"c" 'd [1.5 nil] true]`, nil},
+		{`[1 2]`, []ExecOption{WithNumberFormat(NumberFormat{AlwaysDecimal: true})}},
+		{`"hello"`, []ExecOption{WithRender(RenderDescribe)}},
+		{`@"buf"`, []ExecOption{WithRender(RenderString)}},
+		{`{:a [1 "b"]}`, []ExecOption{WithRender(RenderJDN)}},
+		{`(buffer/new-filled 100000 (chr "x"))`, nil},
+		{`(tuple/slice (range 20000))`, nil},
+	} {
+		t.Log("plain", tc.expression)
+		expected, _, _, err := vm.Execute(ctx, tc.expression, tc.opts...)
+		if err != nil {
+			t.Fatalf("Failed to execute %s: %v", tc.expression, err)
+		}
+		var written bytes.Buffer
+		t.Log("writer", tc.expression)
+		if _, _, _, err := vm.Execute(ctx, tc.expression, append(tc.opts, WithResultWriter(&written))...); err != nil {
+			t.Fatalf("Failed to execute %s with a writer: %v", tc.expression, err)
+		}
+		if written.String() != expected {
+			t.Errorf("Expected %s to be written as %.100q, got %.100q", tc.expression, expected, written.String())
+		}
+	}
+
+	// pure results are written, not cached
+	for range 2 {
+		var written bytes.Buffer
+		if _, _, _, err := vm.Execute(ctx, `(+ 1 2)`, WithPure(), WithResultWriter(&written)); err != nil {
+			t.Fatalf("Failed to execute: %v", err)
+		}
+		if written.String() != "3" {
+			t.Errorf("Expected 3 to be written, got %q", written.String())
+		}
+	}
+
+	// fails with errors of writers
+	if _, _, _, err := vm.Execute(ctx, `"hello"`, WithResultWriter(failingWriter{})); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("Expected an error of the writer, got %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+// runmain.go
+
+package janet
+
+/*
+#include "janet.h"
+*/
+import "C"
+
+import (
+	"context"
+	"maps"
+	"path/filepath"
+	"unsafe"
+)
+
+// MainResult is the result of a script run with VM.RunMain.
+type MainResult struct {
+	Result       // result of the script's `main` function (nil if there is none)
+	ExitCode int // code passed to `os/exit` (0 if not called, or 1 if the script failed)
+}
+
+// janet source which evaluates the script `(dyn :janet-go/main-file)` in a new environment
+// and calls its main function with `(dyn :args)`, like janet's CLI runs scripts.
+//
+// Modules are also searched in the script's directory while running it, and `os/exit` only stops the script
+// (with its code stored in `__janet_go_exit`).
+const mainRunnerSource = `(defn os/exit [&opt code]
+  (put __janet_go_exit :code (case code nil 0 true 0 false 1 code))
+  (error __janet_go_exit))
+(do
+  (def dir (dyn :janet-go/main-dir))
+  (def paths (array/slice module/paths))
+  (array/insert module/paths 0
+                [(string dir "/:all:.jimage") :image]
+                [(string dir "/:all:.janet") :source]
+                [(string dir "/:all:/init.janet") :source])
+  (defer (do (array/clear module/paths) (array/concat module/paths paths))
+    (try
+      (do
+        (def env (make-env (curenv)))
+        (dofile (dyn :janet-go/main-file) :env env)
+        (when-let [main (get-in env ['main :value])]
+          (main ;(dyn :args))))
+      ([err fib]
+        (if (= err __janet_go_exit) nil (propagate err fib))))))`
+
+// RunMain runs the janet script at `path` like janet's CLI (`janet path args...`):
+// the script is evaluated in a new environment inheriting the VM's one (where modules are also searched in the script's directory),
+// and its `main` function is called with `path` and `args` (which are also `(dyn :args)`).
+//
+// Calling `os/exit` in the script stops it with the exit code, without exiting the host process.
+// The script's environment is discarded after the run, so it is not visible to other executions.
+func (vm *VM) RunMain(
+	ctx context.Context,
+	path string,
+	args []string,
+	opts ...ExecOption,
+) (MainResult, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return MainResult{ExitCode: 1}, err
+	}
+
+	options := newExecOptions(opts)
+	options.dyns = maps.Clone(options.dyns)
+	if options.dyns == nil {
+		options.dyns = map[string]any{}
+	}
+	options.dyns["args"] = append([]string{path}, args...)
+	options.dyns["janet-go/main-file"] = abs
+	options.dyns["janet-go/main-dir"] = filepath.Dir(abs)
+
+	type mainResult struct {
+		res      vmExecResponse
+		exitCode int
+	}
+	res, err := runOnVM(ctx, vm, func(env *C.JanetTable) mainResult {
+		// script is run in a temporary environment, with a table for its exit code
+		mainEnv := C.janet_table(1)
+		mainEnv.proto = env
+		C.janet_gcroot(C.janet_wrap_table(mainEnv))
+		defer C.janet_gcunroot(C.janet_wrap_table(mainEnv))
+
+		exit := C.janet_table(1)
+		name := C.CString("__janet_go_exit")
+		defer C.free(unsafe.Pointer(name))
+		C.janet_def(mainEnv, name, C.janet_wrap_table(exit), nil)
+
+		res := vm.evaluate(mainEnv, mainRunnerSource, options)
+
+		exitCode := 0
+		if code := C.janet_table_get(exit, janetKeyword("code")); C.janet_checktype(code, C.JANET_NUMBER) != 0 {
+			exitCode = int(C.janet_unwrap_number(code))
+		} else if res.err != nil {
+			exitCode = 1
+		}
+		return mainResult{res: res, exitCode: exitCode}
+	})
+	if err != nil {
+		return MainResult{ExitCode: 1}, err
+	}
+
+	result := MainResult{
+		Result: Result{
+			Evaluated: res.res.evaluated,
+			Type:      res.res.typ,
+			Signal:    res.res.signal,
+			Stdout:    res.res.stdout,
+			Stderr:    res.res.stderr,
+		},
+		ExitCode: res.exitCode,
+	}
+	return result, res.res.err
+}
@@ -0,0 +1,86 @@
+// runmain_test.go
+
+package janet
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// test running scripts with their main functions
+func TestRunMain(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "lib"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"lib/greet.janet": `(defn greet [name] (string "hello, " name))`,
+		"main.janet": `(import lib/greet)
+(defn main [& args]
+  (print (greet/greet (get args 1)))
+  (length args))`,
+		"exit.janet": `(defn main [& args]
+  (print "exiting")
+  (os/exit 3)
+  (print "unreachable"))`,
+		"noop.janet": `(def x 42)`,
+		"fail.janet": `(defn main [& _] (error "failed"))`,
+	}
+	for name, source := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("failed to create VM: %s", err)
+	}
+	defer vm.Close()
+
+	ctx := context.Background()
+
+	// main is called with the path and arguments, and modules are imported relative to the script
+	if res, err := vm.RunMain(ctx, filepath.Join(dir, "main.janet"), []string{"world", "!"}); err != nil {
+		t.Errorf("failed to run main: %s", err)
+	} else {
+		if res.Stdout != "hello, world\n" {
+			t.Errorf("unexpected stdout: %q", res.Stdout)
+		}
+		if res.Evaluated != "3" || res.ExitCode != 0 {
+			t.Errorf("unexpected result: %q (exit code: %d)", res.Evaluated, res.ExitCode)
+		}
+	}
+
+	// os/exit stops the script with the exit code, without exiting the process
+	if res, err := vm.RunMain(ctx, filepath.Join(dir, "exit.janet"), nil); err != nil {
+		t.Errorf("failed to run main: %s", err)
+	} else if res.ExitCode != 3 || res.Stdout != "exiting\n" {
+		t.Errorf("unexpected result: exit code %d, stdout %q", res.ExitCode, res.Stdout)
+	}
+
+	// scripts without main functions are just evaluated
+	if res, err := vm.RunMain(ctx, filepath.Join(dir, "noop.janet"), nil); err != nil {
+		t.Errorf("failed to run script: %s", err)
+	} else if res.ExitCode != 0 {
+		t.Errorf("unexpected exit code: %d", res.ExitCode)
+	}
+
+	// errors in scripts are returned with exit code 1
+	if res, err := vm.RunMain(ctx, filepath.Join(dir, "fail.janet"), nil); err == nil {
+		t.Errorf("should have failed")
+	} else if res.ExitCode != 1 || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("unexpected result: exit code %d, error %s", res.ExitCode, err)
+	}
+
+	// scripts' definitions and module paths do not leak into the VM
+	if _, _, _, err := vm.Execute(ctx, `x`); err == nil {
+		t.Errorf("script's definition should not be visible")
+	}
+	if res, _, _, err := vm.Execute(ctx, `(some |(and (string? (get $ 0)) (string/has-prefix? "`+dir+`" (get $ 0))) module/paths)`); err != nil || res != "nil" {
+		t.Errorf("module paths should be restored: %s, %v", res, err)
+	}
+}
@@ -0,0 +1,365 @@
+// serialize.go
+
+package janet
+
+/*
+#include <stdlib.h>
+#include <string.h>
+
+#include "janet.h"
+
+// max nesting depth of collections serialized in c (deeper values are converted one by one)
+#define SERIALIZE_MAX_DEPTH 1024
+
+enum {
+    serialNil = 0,
+    serialFalse,
+    serialTrue,
+    serialNumber,
+    serialString,
+    serialSymbol,
+    serialKeyword,
+    serialIndexed,
+    serialDictionary,
+    serialOpaque,
+};
+
+typedef struct {
+    JanetBuffer *buffer;
+    const void *stack[SERIALIZE_MAX_DEPTH]; // collections being serialized (for detecting cycles)
+    int32_t depth;
+
+    // limits of DecodeLimits (0 for no limits), counted in the same way as conversions in go
+    int64_t maxDepth, maxElements, maxBytes;
+    int64_t elements, bytes;
+
+    const int *cancelled; // set when the context of the conversion is done (NULL if it cannot be done)
+} Serializer;
+
+static void setCancelled(int *cancelled) {
+    __atomic_store_n(cancelled, 1, __ATOMIC_RELAXED);
+}
+
+static int isCancelled(Serializer *s) {
+    return s->cancelled != NULL && __atomic_load_n(s->cancelled, __ATOMIC_RELAXED);
+}
+
+// adds `n` elements or bytes to `count`, and returns whether it exceeds `max`
+static int exceeds(int64_t *count, int64_t n, int64_t max) {
+    *count += n;
+    return max > 0 && *count > max;
+}
+
+static void serializeOpaque(Serializer *s, Janet x) {
+    janet_buffer_push_u8(s->buffer, serialOpaque);
+    janet_buffer_push_bytes(s->buffer, (const uint8_t *)&x, sizeof(Janet));
+}
+
+static void serializeLength(Serializer *s, uint8_t tag, int32_t length) {
+    janet_buffer_push_u8(s->buffer, tag);
+    janet_buffer_push_bytes(s->buffer, (const uint8_t *)&length, sizeof(int32_t));
+}
+
+static int isCollection(Janet x) {
+    return janet_checktypes(x, JANET_TFLAG_INDEXED | JANET_TFLAG_DICTIONARY);
+}
+
+// returns 0 on success, or non-zero if the value should be converted one by one
+// (eg. it contains itself, it exceeds the limits, or the conversion is cancelled)
+static int serializeWalk(Serializer *s, Janet x) {
+    if (isCancelled(s)) {
+        return 1;
+    }
+    switch (janet_type(x)) {
+    case JANET_NIL:
+        janet_buffer_push_u8(s->buffer, serialNil);
+        return 0;
+    case JANET_BOOLEAN:
+        janet_buffer_push_u8(s->buffer, janet_unwrap_boolean(x) ? serialTrue : serialFalse);
+        return 0;
+    case JANET_NUMBER: {
+        double number = janet_unwrap_number(x);
+        janet_buffer_push_u8(s->buffer, serialNumber);
+        janet_buffer_push_bytes(s->buffer, (const uint8_t *)&number, sizeof(double));
+        return 0;
+    }
+    case JANET_STRING:
+    case JANET_SYMBOL:
+    case JANET_KEYWORD: {
+        const uint8_t *str = janet_unwrap_string(x);
+        int32_t length = janet_string_length(str);
+        uint8_t tag = janet_checktype(x, JANET_STRING) ? serialString : janet_checktype(x, JANET_SYMBOL) ? serialSymbol : serialKeyword;
+        if (exceeds(&s->bytes, tag == serialKeyword ? length + 1 : length, s->maxBytes)) {
+            return 1;
+        }
+        serializeLength(s, tag, length);
+        janet_buffer_push_bytes(s->buffer, str, length);
+        return 0;
+    }
+    case JANET_TUPLE:
+    case JANET_ARRAY:
+    case JANET_TABLE:
+    case JANET_STRUCT:
+        break;
+    default:
+        serializeOpaque(s, x);
+        return 0;
+    }
+
+    const void *ptr = janet_unwrap_pointer(x);
+    if (s->depth >= SERIALIZE_MAX_DEPTH || (s->maxDepth > 0 && s->depth >= s->maxDepth)) {
+        return 1;
+    }
+    for (int32_t i = 0; i < s->depth; i++) {
+        if (s->stack[i] == ptr) {
+            return 1;
+        }
+    }
+    s->stack[s->depth++] = ptr;
+
+    if (janet_checktypes(x, JANET_TFLAG_INDEXED)) {
+        const Janet *data;
+        int32_t length;
+        janet_indexed_view(x, &data, &length);
+        if (exceeds(&s->elements, length, s->maxElements)) {
+            return 1;
+        }
+        serializeLength(s, serialIndexed, length);
+        for (int32_t i = 0; i < length; i++) {
+            if (serializeWalk(s, data[i])) {
+                return 1;
+            }
+        }
+    } else {
+        const JanetKV *kvs;
+        int32_t length, capacity;
+        janet_dictionary_view(x, &kvs, &length, &capacity);
+        if (exceeds(&s->elements, (int64_t)length * 2, s->maxElements)) {
+            return 1;
+        }
+        serializeLength(s, serialDictionary, length);
+        for (int32_t i = 0; i < capacity; i++) {
+            if (janet_checktype(kvs[i].key, JANET_NIL)) {
+                continue;
+            }
+            // collection keys are converted in go, for falling back to their string representations
+            if (isCollection(kvs[i].key)) {
+                serializeOpaque(s, kvs[i].key);
+            } else if (serializeWalk(s, kvs[i].key)) {
+                return 1;
+            }
+            if (serializeWalk(s, kvs[i].value)) {
+                return 1;
+            }
+        }
+    }
+
+    s->depth--;
+    return 0;
+}
+
+static int serializeValue(JanetBuffer *buffer, Janet x, int64_t maxDepth, int64_t maxElements, int64_t maxBytes, const int *cancelled) {
+    Serializer s;
+    s.buffer = buffer;
+    s.depth = 0;
+    s.maxDepth = maxDepth;
+    s.maxElements = maxElements;
+    s.maxBytes = maxBytes;
+    s.elements = 0;
+    s.bytes = 0;
+    s.cancelled = cancelled;
+    return serializeWalk(&s, x);
+}
+*/
+import "C"
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"unsafe"
+)
+
+// onSerialized is called with the number of bytes of each value serialized by decodeSerialized (for tests).
+var onSerialized func(bytes int)
+
+// serialReader reads values serialized by `serializeValue`.
+type serialReader struct {
+	data []byte
+	pos  int
+}
+
+// next returns the next `n` bytes.
+func (r *serialReader) next(n int) []byte {
+	bytes := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return bytes
+}
+
+// length returns the next length.
+func (r *serialReader) length() int {
+	return int(int32(binary.NativeEndian.Uint32(r.next(4))))
+}
+
+// opaque returns the next janet value which is not serialized.
+func (r *serialReader) opaque() C.Janet {
+	var value C.Janet
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(&value)), unsafe.Sizeof(value)), r.next(int(unsafe.Sizeof(value))))
+	return value
+}
+
+// decodeSerialized converts a janet collection to go by serializing it in c at once,
+// instead of converting its elements one by one with many cgo calls.
+//
+// It returns false when the value cannot be serialized (eg. it contains itself),
+// so that it should be converted one by one.
+// Serialization also stops (before serializing the rest) when the value exceeds the limits of the decoder
+// or its context is done, so that conversions one by one fail without huge memory for the serialized value.
+// This function should only be called from the VM handler goroutine.
+func (d *decoder) decodeSerialized(value C.Janet) (converted any, ok bool, err error) {
+	var buffer C.JanetBuffer
+	C.janet_buffer_init(&buffer, 0)
+	defer C.janet_buffer_deinit(&buffer)
+
+	var cancelled *C.int
+	if d.ctx != nil && d.ctx.Done() != nil {
+		cancelled = (*C.int)(C.calloc(1, C.sizeof_int))
+		defer C.free(unsafe.Pointer(cancelled))
+		done := make(chan struct{})
+		stop := context.AfterFunc(d.ctx, func() {
+			C.setCancelled(cancelled)
+			close(done)
+		})
+		defer func() {
+			if !stop() {
+				<-done // (not to free the flag while it is being set)
+			}
+		}()
+	}
+
+	limits := d.limits
+	if C.serializeValue(&buffer, value, C.int64_t(limits.MaxDepth), C.int64_t(limits.MaxElements), C.int64_t(limits.MaxBytes), cancelled) != 0 {
+		return nil, false, nil
+	}
+	if onSerialized != nil {
+		onSerialized(int(buffer.count))
+	}
+
+	r := &serialReader{data: unsafe.Slice((*byte)(unsafe.Pointer(buffer.data)), int(buffer.count))}
+	converted, _, err = d.readSerialized(r)
+	return converted, true, err
+}
+
+// readSerialized converts the next serialized value to go, in the same way as `decode`.
+//
+// Returned `opaque` is the janet value which was not serialized (if any),
+// for falling back to its string representation.
+func (d *decoder) readSerialized(r *serialReader) (converted any, opaque *C.Janet, err error) {
+	tag := r.next(1)[0]
+	if tag == C.serialOpaque {
+		value := r.opaque()
+		converted, err := d.decodeValue(value)
+		return converted, &value, err
+	}
+
+	d.uncheckedValues++
+	if d.uncheckedValues >= decodeCancelCheckInterval {
+		d.uncheckedValues = 0
+		if d.ctx.Err() != nil {
+			return nil, nil, fmt.Errorf("conversion aborted: %w", contextError(d.ctx))
+		}
+	}
+
+	switch tag {
+	case C.serialNil:
+		return nil, nil, nil
+	case C.serialFalse:
+		return false, nil, nil
+	case C.serialTrue:
+		return true, nil, nil
+	case C.serialNumber:
+		number := math.Float64frombits(binary.NativeEndian.Uint64(r.next(8)))
+		if math.IsNaN(number) {
+			return math.NaN(), nil, nil // normalize NaN payloads
+		}
+		return number, nil, nil
+	case C.serialString, C.serialSymbol:
+		length := r.length()
+		if err := d.addBytes(length); err != nil {
+			return nil, nil, err
+		}
+		kind := "string"
+		if tag == C.serialSymbol {
+			kind = "symbol"
+		}
+		converted, err := d.decodeText(d.text("", r.next(length)), kind)
+		return converted, nil, err
+	case C.serialKeyword:
+		length := r.length()
+		if err := d.addBytes(length + 1); err != nil {
+			return nil, nil, err
+		}
+		converted, err := d.decodeText(d.text(":", r.next(length)), "keyword")
+		return converted, nil, err
+	}
+
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.limits.MaxDepth > 0 && d.depth > d.limits.MaxDepth {
+		return nil, nil, &QuotaError{Quota: "MaxDepth", Limit: d.limits.MaxDepth}
+	}
+
+	count := r.length()
+	if tag == C.serialIndexed {
+		if err := d.addElements(count); err != nil {
+			return nil, nil, err
+		}
+		slice := d.newSlice(count)
+		for i := range slice {
+			if slice[i], _, err = d.readSerialized(r); err != nil {
+				return nil, nil, err
+			}
+		}
+		return slice, nil, nil
+	}
+
+	if err := d.addElements(count * 2); err != nil {
+		return nil, nil, err
+	}
+	result, put := d.newDictionary(count)
+	for range count {
+		kind := serialKeyKind(r.data[r.pos])
+		inKey := d.key
+		d.key = true
+		key, opaqueKey, err := d.readSerialized(r)
+		d.key = inKey
+		if err != nil {
+			return nil, nil, err
+		}
+		val, _, err := d.readSerialized(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		if key != nil && !reflect.TypeOf(key).Comparable() {
+			// collections cannot be go map keys, so use their string representations instead
+			key = janetValueToString(*opaqueKey)
+		}
+		if err := put(key, kind, val); err != nil {
+			return nil, nil, err
+		}
+	}
+	return result, nil, nil
+}
+
+// serialKeyKind returns the kind of a serialized key with `tag`.
+func serialKeyKind(tag byte) keyKind {
+	switch tag {
+	case C.serialString, C.serialSymbol:
+		return keyString
+	case C.serialKeyword:
+		return keyKeyword
+	}
+	return keyOther
+}
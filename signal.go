@@ -0,0 +1,363 @@
+// signal.go
+
+package janet
+
+/*
+#include <setjmp.h>
+#include <stdint.h>
+
+#include "janet.h"
+
+// defined in callbacks.go
+extern int goDebugHook(uintptr_t vm, void *fiber, void *value);
+
+// defined in janet_bundled.go or janet_system.go
+void *setCrashGuard(void *guard);
+
+// handle of the VM running on the current thread, if it has a debug handler
+static _Thread_local uintptr_t debugHook = 0;
+
+static void setDebugHook(uintptr_t vm) {
+    debugHook = vm;
+}
+
+// calls the debug handler for `fiber` paused by a debug signal with `value`,
+// and returns the signal after resuming it (or JANET_SIGNAL_DEBUG when aborted)
+static JanetSignal handleDebug(JanetFiber *fiber, Janet *value) {
+    JanetSignal status = JANET_SIGNAL_DEBUG;
+    while (status == JANET_SIGNAL_DEBUG && debugHook != 0) {
+        // the signal is raised from the innermost child fiber
+        JanetFiber *paused = fiber;
+        while (paused->child != NULL) {
+            paused = paused->child;
+        }
+
+        janet_gcroot(janet_wrap_fiber(fiber));
+        janet_gcroot(*value);
+        void *guard = setCrashGuard(NULL); // not to jump over go frames
+        int resume = goDebugHook(debugHook, paused, value);
+        setCrashGuard(guard);
+        janet_gcunroot(*value);
+        janet_gcunroot(janet_wrap_fiber(fiber));
+        if (!resume) {
+            *value = janet_cstringv("aborted by debug handler");
+            break;
+        }
+        status = janet_continue(fiber, janet_wrap_nil(), value);
+    }
+    return status;
+}
+
+// runs the event loop until the top-level form's `fiber` suspended by events finishes
+// (like janet's `run-context`, so that the next form sees its effects), and returns its signal
+// with its result stored into `out` (JANET_SIGNAL_EVENT if it is still suspended when the loop is done)
+static int waitForm(JanetFiber *fiber, Janet *out) {
+#ifdef JANET_EV
+    janet_gcroot(janet_wrap_fiber(fiber));
+    while (!janet_loop_done()) {
+        JanetFiberStatus status = janet_fiber_status(fiber);
+        if (status == JANET_STATUS_DEAD || status == JANET_STATUS_ERROR) break;
+        JanetFiber *interrupted = janet_loop1();
+        if (interrupted != NULL) {
+            janet_schedule(interrupted, janet_wrap_nil());
+        }
+    }
+    janet_gcunroot(janet_wrap_fiber(fiber));
+#endif
+    switch (janet_fiber_status(fiber)) {
+    case JANET_STATUS_DEAD:
+        *out = fiber->last_value;
+        return JANET_SIGNAL_OK;
+    case JANET_STATUS_ERROR:
+        *out = fiber->last_value;
+        return JANET_SIGNAL_ERROR;
+    default:
+        return JANET_SIGNAL_EVENT;
+    }
+}
+
+// prints `message` (if not nil) and the stack trace of `fiber` (if not nil) raising `err`, for `reportError`
+static Janet cfunReportError(int32_t argc, Janet *argv) {
+    (void) argc;
+    if (janet_checktype(argv[0], JANET_STRING)) {
+        janet_eprintf("%s", (const char *)janet_unwrap_string(argv[0]));
+    }
+    if (janet_checktype(argv[1], JANET_FIBER)) {
+        janet_stacktrace_ext(janet_unwrap_fiber(argv[1]), argv[2], "");
+    }
+    return janet_wrap_nil();
+}
+
+// prints `message` (if any) and the stack trace of `fiber` (if any) raising `err` to the error output of `env`,
+// as janet prints errors outside fibers.
+//
+// They are printed in a fiber running in `env`, because the dynamic bindings outside fibers are not marked
+// by the gc of janet, so outputs cannot be captured with them (nothing is printed if an interrupt is pending).
+void reportError(JanetTable *env, JanetString where, JanetString message, JanetFiber *fiber, Janet err) {
+    Janet quoted[2] = {janet_csymbolv("quote"), err};
+    Janet call[4] = {
+        janet_wrap_cfunction(cfunReportError),
+        message ? janet_wrap_string(message) : janet_wrap_nil(),
+        fiber ? janet_wrap_fiber(fiber) : janet_wrap_nil(),
+        janet_wrap_tuple(janet_tuple_n(quoted, 2)),
+    };
+    JanetCompileResult cres = janet_compile(janet_wrap_tuple(janet_tuple_n(call, 4)), env, where);
+    if (cres.status != JANET_COMPILE_OK) return;
+
+    JanetFiber *reporter = janet_fiber(janet_thunk(cres.funcdef), 64, 0, NULL);
+    reporter->env = env;
+    Janet ret;
+    janet_continue(reporter, janet_wrap_nil(), &ret);
+}
+
+// evaluates janet source in the same way as `janet_dobytes`, but returns the signal of the evaluation
+// (eg. JANET_SIGNAL_YIELD when a top-level form yields), instead of folding it into error flags.
+//
+// Forms suspended by events (eg. with `ev/sleep`, or async functions) are waited for in the event loop
+// before the next form is evaluated.
+// Evaluation stops at the first form which raises a signal (other than events),
+// and the payload of the signal is stored into `out` (and the fiber which raised an error into `errFiber`,
+// and whether the source failed to be parsed or compiled into `compileFailed`).
+//
+// Compiled functions and errors refer to `sourcePath` ("<unknown>" if NULL), with line numbers shifted by `lineOffset`.
+static int evalBytes(JanetTable *env, const uint8_t *bytes, int32_t len, const char *sourcePath, int32_t lineOffset, Janet *out, JanetFiber **errFiber, int *compileFailed) {
+    if (sourcePath == NULL) sourcePath = "<unknown>";
+    int signal = JANET_SIGNAL_OK, done = 0, compileError = 0;
+    int32_t index = 0;
+    Janet ret = janet_wrap_nil();
+    JanetFiber *fiber = NULL, *failed = NULL;
+
+    // source path is needed for compiling functions with source maps
+    const uint8_t *where = janet_cstring(sourcePath);
+    janet_gcroot(janet_wrap_string(where));
+
+    JanetParser *parser = janet_abstract(&janet_parser_type, sizeof(JanetParser));
+    janet_parser_init(parser);
+    parser->line += lineOffset;
+    janet_gcroot(janet_wrap_abstract(parser));
+
+    while (!done) {
+        // evaluate parsed values
+        while (!done && janet_parser_has_more(parser)) {
+            Janet form = janet_parser_produce(parser);
+            JanetCompileResult cres = janet_compile(form, env, where);
+            if (cres.status == JANET_COMPILE_OK) {
+                JanetFunction *f = janet_thunk(cres.funcdef);
+                fiber = janet_fiber(f, 64, 0, NULL);
+                fiber->env = env;
+                JanetSignal status = janet_continue(fiber, janet_wrap_nil(), &ret);
+                if (status == JANET_SIGNAL_DEBUG) {
+                    status = handleDebug(fiber, &ret);
+                }
+                if (status == JANET_SIGNAL_EVENT) {
+                    status = waitForm(fiber, &ret);
+                    if (status == JANET_SIGNAL_ERROR) {
+                        // (stack trace is printed by the event loop, outside the environment)
+                        signal = status;
+                        failed = fiber;
+                        done = 1;
+                    }
+                } else if (status != JANET_SIGNAL_OK) {
+                    if (status == JANET_SIGNAL_ERROR || status == JANET_SIGNAL_DEBUG || status == JANET_SIGNAL_INTERRUPT) {
+                        reportError(env, where, NULL, fiber, ret);
+                        failed = fiber;
+                    }
+                    signal = status;
+                    done = 1;
+                }
+            } else {
+                int32_t line = (int32_t) parser->line;
+                int32_t col = (int32_t) parser->column;
+                if ((cres.error_mapping.line > 0) && (cres.error_mapping.column > 0)) {
+                    line = cres.error_mapping.line;
+                    col = cres.error_mapping.column;
+                }
+                JanetString ctx = janet_formatc("%s:%d:%d: compile error", sourcePath, line, col);
+                JanetString errstr = janet_formatc("%s: %s", (const char *)ctx, (const char *)cres.error);
+                ret = janet_wrap_string(errstr);
+                if (cres.macrofiber) {
+                    reportError(env, where, ctx, cres.macrofiber, ret);
+                    failed = cres.macrofiber;
+                } else {
+                    reportError(env, where, janet_formatc("%s\n", (const char *)errstr), NULL, ret);
+                }
+                signal = JANET_SIGNAL_ERROR;
+                compileError = 1;
+                done = 1;
+            }
+        }
+        if (done) break;
+
+        // dispatch based on parse state
+        switch (janet_parser_status(parser)) {
+        case JANET_PARSE_DEAD:
+            done = 1;
+            break;
+        case JANET_PARSE_ERROR: {
+            JanetString errstr = janet_formatc("%s:%d:%d: parse error: %s",
+                                               sourcePath, (int32_t) parser->line, (int32_t) parser->column,
+                                               janet_parser_error(parser));
+            ret = janet_wrap_string(errstr);
+            reportError(env, where, janet_formatc("%s\n", (const char *)errstr), NULL, ret);
+            signal = JANET_SIGNAL_ERROR;
+            compileError = 1;
+            done = 1;
+            break;
+        }
+        case JANET_PARSE_ROOT:
+        case JANET_PARSE_PENDING:
+            if (index >= len) {
+                janet_parser_eof(parser);
+            } else {
+                janet_parser_consume(parser, bytes[index++]);
+            }
+            break;
+        }
+    }
+
+    janet_gcunroot(janet_wrap_abstract(parser));
+    janet_gcunroot(janet_wrap_string(where));
+#ifdef JANET_EV
+    // run the event loop (evaluations are never nested in other fibers)
+    if (fiber) {
+        janet_gcroot(janet_wrap_fiber(fiber));
+    }
+    if (failed) {
+        janet_gcroot(janet_wrap_fiber(failed));
+    }
+    if (signal != JANET_SIGNAL_OK) {
+        janet_gcroot(ret); // (eg. messages of compile errors)
+    }
+    janet_loop();
+    if (signal != JANET_SIGNAL_OK) {
+        janet_gcunroot(ret);
+    }
+    if (failed) {
+        janet_gcunroot(janet_wrap_fiber(failed));
+    }
+    if (fiber) {
+        janet_gcunroot(janet_wrap_fiber(fiber));
+        if (signal == JANET_SIGNAL_OK) {
+            ret = fiber->last_value;
+        }
+    }
+#endif
+    if (out) *out = ret;
+    if (errFiber) *errFiber = failed;
+    if (compileFailed) *compileFailed = compileError;
+    return signal;
+}
+
+// evaluates janet source in the same way as `evalBytes`, but returns JANET_SIGNAL_ERROR
+// when the janet runtime fails fatally, instead of aborting the process (see crash.go)
+static int guardedEvalBytes(JanetTable *env, const uint8_t *bytes, int32_t len, const char *sourcePath, int32_t lineOffset, Janet *out, JanetFiber **errFiber, int *compileFailed) {
+    jmp_buf guard;
+    void *prev = setCrashGuard(&guard);
+    if (setjmp(guard)) {
+        setCrashGuard(prev);
+        return JANET_SIGNAL_ERROR;
+    }
+    int signal = evalBytes(env, bytes, len, sourcePath, lineOffset, out, errFiber, compileFailed);
+    setCrashGuard(prev);
+    return signal;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"runtime/cgo"
+	"unsafe"
+)
+
+// Signal is a signal raised by a janet fiber.
+type Signal int
+
+// Signal constants
+//
+// User signals 8 and 9 are used by janet internally (for interrupts and the event loop).
+const (
+	SignalOK    Signal = C.JANET_SIGNAL_OK
+	SignalError Signal = C.JANET_SIGNAL_ERROR
+	SignalDebug Signal = C.JANET_SIGNAL_DEBUG
+	SignalYield Signal = C.JANET_SIGNAL_YIELD
+	SignalUser0 Signal = C.JANET_SIGNAL_USER0
+	SignalUser1 Signal = C.JANET_SIGNAL_USER1
+	SignalUser2 Signal = C.JANET_SIGNAL_USER2
+	SignalUser3 Signal = C.JANET_SIGNAL_USER3
+	SignalUser4 Signal = C.JANET_SIGNAL_USER4
+	SignalUser5 Signal = C.JANET_SIGNAL_USER5
+	SignalUser6 Signal = C.JANET_SIGNAL_USER6
+	SignalUser7 Signal = C.JANET_SIGNAL_USER7
+)
+
+// String returns the name of the signal, same as janet's `fiber/status` of signaled fibers (eg. "yield").
+func (s Signal) String() string {
+	if s < SignalOK || s > C.JANET_SIGNAL_USER9 {
+		return "unknown"
+	}
+	return C.GoString(C.janet_signal_names[s])
+}
+
+// isValueSignal returns whether `s` passes a value to the host (a yield, or a user signal).
+func (s Signal) isValueSignal() bool {
+	return s >= SignalYield && s <= SignalUser7
+}
+
+// RaisedSignal is returned from Execute when a top-level form raises
+// a yield or a user signal (which are not errors) and stops the execution.
+//
+// ExecuteResult returns them in Result without errors.
+type RaisedSignal struct {
+	Signal Signal
+	Value  string // rendered payload of the signal
+}
+
+// Error returns the signal and its payload.
+func (e *RaisedSignal) Error() string {
+	return fmt.Sprintf("unhandled %s signal: %s", e.Signal, e.Value)
+}
+
+// dobytes evaluates janet `source` in `env` and stores the result (or the payload of a raised signal) into `out`,
+// passing the bytes of `source` to janet without copying them, and returns the signal.
+//
+// How it failed (if any) is stored into `failure`, whose fiber is not rooted
+// and should be inspected before running any other janet code.
+// This function should only be called from the VM handler goroutine.
+func dobytes(env *C.JanetTable, source string, out *C.Janet, failure *evalFailure) C.int {
+	return dobytesAt(env, source, sourceMap{}, out, failure)
+}
+
+// sourceMap is the location of evaluated janet source in its original file.
+type sourceMap struct {
+	name       string // name of the file ("<unknown>" if empty)
+	lineOffset int    // number of lines preceding the source in the file
+}
+
+// dobytesAt evaluates janet `source` in the same way as dobytes,
+// but compiles it with source maps pointing at `location`.
+//
+// It panics with *vmCrash if the janet runtime fails fatally (see recoverCrash).
+// This function should only be called from the VM handler goroutine.
+func dobytesAt(env *C.JanetTable, source string, location sourceMap, out *C.Janet, failure *evalFailure) C.int {
+	var name *C.char
+	if location.name != "" {
+		name = C.CString(location.name)
+		defer C.free(unsafe.Pointer(name))
+	}
+	var errFiber *C.JanetFiber
+	var compileFailed C.int
+	ret := C.guardedEvalBytes(env, (*C.uint8_t)(unsafe.Pointer(unsafe.StringData(source))), C.int32_t(len(source)), name, C.int32_t(location.lineOffset), out, &errFiber, &compileFailed)
+	checkCrash()
+	if failure != nil {
+		*failure = evalFailure{fiber: errFiber, compile: compileFailed != 0}
+	}
+	return ret
+}
+
+// setDebugHook sets the VM (with a debug handler) running on the current thread,
+// whose handler is called for debug signals raised by evaluations (0 for none).
+// This function should only be called from the VM handler goroutine.
+func setDebugHook(vm cgo.Handle) {
+	C.setDebugHook(C.uintptr_t(vm))
+}
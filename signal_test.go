@@ -0,0 +1,74 @@
+// signal_test.go
+
+package janet
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestSignals tests yields and user signals raised by executions.
+func TestSignals(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	tests := []struct {
+		input string
+
+		expectedSignal    Signal
+		expectedName      string
+		expectedEvaluated string
+		expectedType      Type
+	}{
+		{`(+ 1 2)`, SignalOK, "ok", "3", TypeNumber},
+		{`(yield :progress)`, SignalYield, "yield", ":progress", TypeKeyword},
+		{`(signal 0 [1 2])`, SignalUser0, "user0", "(1 2)", TypeTuple},
+		{`(signal :user7 "done")`, SignalUser7, "user7", "done", TypeString},
+		{`(def x 1) (yield x) (def y 2)`, SignalYield, "yield", "1", TypeNumber},
+	}
+	for _, test := range tests {
+		result, err := vm.ExecuteResult(ctx, test.input)
+		if err != nil {
+			t.Errorf("Failed to execute '%s': %v", test.input, err)
+			continue
+		}
+		if result.Signal != test.expectedSignal || result.Signal.String() != test.expectedName ||
+			result.Evaluated != test.expectedEvaluated || result.Type != test.expectedType {
+			t.Errorf("Unexpected result of '%s': %+v", test.input, result)
+		}
+
+		_, _, _, err = vm.Execute(ctx, test.input)
+		var raised *RaisedSignal
+		if test.expectedSignal == SignalOK {
+			if err != nil {
+				t.Errorf("Expected no error from '%s', got: %v", test.input, err)
+			}
+		} else if !errors.As(err, &raised) || raised.Signal != test.expectedSignal || raised.Value != test.expectedEvaluated {
+			t.Errorf("Expected raised signal from '%s', got: %v", test.input, err)
+		}
+	}
+
+	// execution stops at the signal
+	if _, _, _, err := vm.Execute(ctx, `y`); err == nil {
+		t.Errorf("Expected forms after the signal not to be evaluated")
+	}
+
+	// errors are not signals
+	if _, err := vm.ExecuteResult(ctx, `(error "failed")`); err == nil || errors.As(err, new(*RaisedSignal)) {
+		t.Errorf("Expected plain error, got: %v", err)
+	}
+
+	// errors raised after forms are suspended by events
+	if Build().EV {
+		if _, _, _, err := vm.Execute(ctx, `(do (ev/sleep 0) (error "late")) (+ 1 2)`); err == nil || !strings.Contains(err.Error(), "late") {
+			t.Errorf("Expected error raised in the event loop, got: %v", err)
+		}
+	}
+}
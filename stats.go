@@ -0,0 +1,145 @@
+// stats.go
+
+package janet
+
+/*
+#include "janet.h"
+
+int getJanetGCCounters(size_t *allocated, size_t *blocks);
+*/
+import "C"
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// number of recent requests whose queue waits are kept for percentiles
+const statsWindow = 1024
+
+// Stats is the statistics of requests handled by a VM.
+type Stats struct {
+	PendingExec  int // execution requests waiting to be handled (eg. VM.Execute)
+	PendingParse int // parse requests waiting to be handled (eg. VM.ParseToValue)
+	PendingCall  int // other requests waiting to be handled (eg. VM.Apply)
+
+	Served  uint64 // number of requests handled
+	Crashes uint64 // number of times the janet runtime crashed and was replaced (see ErrVMCrashed)
+
+	Executions  uint64 // number of executions (eg. VM.Execute), including the ones returned from the result cache
+	Errors      uint64 // number of executions which failed (including the ones whose contexts were done)
+	CacheHits   uint64 // number of executions returned from the result cache (see WithResultCache)
+	CacheMisses uint64 // number of cacheable executions which were not in the result cache
+
+	HeapBlocks    uint64 // number of blocks in the heap of janet (sampled after each request, 0 with system janet)
+	HeapAllocated uint64 // number of bytes allocated by janet since its last collection (sampled in the same way)
+	Collections   uint64 // number of garbage collections of janet observed between requests (at least)
+
+	AvgWait time.Duration // average time requests waited before being handled
+	P50Wait time.Duration // percentiles of waits of recent requests
+	P90Wait time.Duration
+	P99Wait time.Duration
+
+	Uptime       time.Duration // time since the VM was created
+	BusyFraction float64       // fraction of the uptime spent handling requests
+}
+
+// vmStats collects the statistics of a VM.
+type vmStats struct {
+	pendingExec  atomic.Int64
+	pendingParse atomic.Int64
+	pendingCall  atomic.Int64
+	crashes      atomic.Uint64
+
+	executions  atomic.Uint64
+	errors      atomic.Uint64
+	cacheHits   atomic.Uint64
+	cacheMisses atomic.Uint64
+
+	heapBlocks    atomic.Uint64
+	heapAllocated atomic.Uint64
+	collections   atomic.Uint64
+
+	mu      sync.Mutex
+	started time.Time
+	served  uint64
+	waited  time.Duration // total
+	busy    time.Duration // total
+	waits   [statsWindow]time.Duration
+}
+
+// pending returns the number of requests waiting for the VM handler goroutine.
+func (s *vmStats) pending() int64 {
+	return s.pendingExec.Load() + s.pendingParse.Load() + s.pendingCall.Load()
+}
+
+// record records a handled request which waited for `wait` and was handled for `busy`.
+func (s *vmStats) record(wait, busy time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.waits[s.served%statsWindow] = wait
+	s.served++
+	s.waited += wait
+	s.busy += busy
+}
+
+// recordExecution records an execution which failed with `err` (if any).
+func (s *vmStats) recordExecution(err error) {
+	s.executions.Add(1)
+	if err != nil {
+		s.errors.Add(1)
+	}
+}
+
+// sampleHeap samples the counters of the janet heap, counting a collection when the bytes allocated since the last one decreased.
+// This function should only be called from the VM handler goroutine.
+func (s *vmStats) sampleHeap() {
+	var allocated, blocks C.size_t
+	if C.getJanetGCCounters(&allocated, &blocks) == 0 {
+		return
+	}
+	if uint64(allocated) < s.heapAllocated.Swap(uint64(allocated)) {
+		s.collections.Add(1)
+	}
+	s.heapBlocks.Store(uint64(blocks))
+}
+
+// Stats returns the statistics of requests handled by the VM so far.
+func (vm *VM) Stats() Stats {
+	s := &vm.stats
+
+	s.mu.Lock()
+	stats := Stats{
+		Served: s.served,
+		Uptime: time.Since(s.started),
+	}
+	waits := slices.Clone(s.waits[:min(s.served, statsWindow)])
+	waited, busy := s.waited, s.busy
+	s.mu.Unlock()
+
+	stats.PendingExec = int(s.pendingExec.Load())
+	stats.PendingParse = int(s.pendingParse.Load())
+	stats.PendingCall = int(s.pendingCall.Load())
+	stats.Crashes = s.crashes.Load()
+	stats.Executions, stats.Errors = s.executions.Load(), s.errors.Load()
+	stats.CacheHits, stats.CacheMisses = s.cacheHits.Load(), s.cacheMisses.Load()
+	stats.HeapBlocks, stats.HeapAllocated = s.heapBlocks.Load(), s.heapAllocated.Load()
+	stats.Collections = s.collections.Load()
+	if stats.Served > 0 {
+		stats.AvgWait = waited / time.Duration(stats.Served)
+	}
+	if len(waits) > 0 {
+		slices.Sort(waits)
+		percentile := func(p int) time.Duration {
+			return waits[(len(waits)-1)*p/100]
+		}
+		stats.P50Wait, stats.P90Wait, stats.P99Wait = percentile(50), percentile(90), percentile(99)
+	}
+	if stats.Uptime > 0 {
+		stats.BusyFraction = float64(busy) / float64(stats.Uptime)
+	}
+	return stats
+}
@@ -0,0 +1,62 @@
+// stats_test.go
+
+package janet
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+// TestStats tests statistics of requests handled by a VM.
+func TestStats(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	if stats := vm.Stats(); stats.Served != 0 {
+		t.Errorf("Expected no requests served on a new VM, got %d", stats.Served)
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			if _, _, _, err := vm.Execute(ctx, `(+ 1 2)`); err != nil {
+				t.Errorf("Failed to execute: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := vm.ParseToValue(ctx, `[1 2 3]`); err != nil {
+				t.Errorf("Failed to parse: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := vm.Apply(ctx, "+", 1, 2); err != nil {
+				t.Errorf("Failed to apply: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := vm.Stats()
+	if stats.Served != 30 {
+		t.Errorf("Expected 30 requests served, got %d", stats.Served)
+	}
+	if stats.PendingExec != 0 || stats.PendingParse != 0 || stats.PendingCall != 0 {
+		t.Errorf("Expected no pending requests, got %+v", stats)
+	}
+	if stats.P50Wait > stats.P90Wait || stats.P90Wait > stats.P99Wait {
+		t.Errorf("Expected ordered percentiles, got %+v", stats)
+	}
+	if stats.BusyFraction <= 0 || stats.BusyFraction > 1 {
+		t.Errorf("Expected busy fraction in (0, 1], got %v", stats.BusyFraction)
+	}
+}
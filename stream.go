@@ -0,0 +1,181 @@
+// stream.go
+
+package janet
+
+/*
+#include <stdio.h>
+#include <unistd.h>
+#include "janet.h"
+
+// returns nil if the file could not be opened
+static Janet makeJanetFile(int fd, int writable) {
+    FILE *f = fdopen(fd, writable ? "wb" : "rb");
+    if (f == NULL) {
+        close(fd);
+        return janet_wrap_nil();
+    }
+    return janet_makefile(f, (writable ? JANET_FILE_WRITE : JANET_FILE_READ) | JANET_FILE_BINARY);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// Stream is a go io.Reader or io.Writer wrapped with VM.WrapReader or VM.WrapWriter,
+// which is passed into janet (eg. with VM.Define) as a janet file (`core/file`),
+// so that scripts can use it with `file/read`, `file/write`, or `(with-dyns [:out ...] ...)`.
+//
+// Data flows through an OS pipe, copied by a goroutine.
+// Streams which are not passed into janet (or whose janet files are not read or closed) should be closed with Close.
+type Stream struct {
+	mu       sync.Mutex
+	file     *os.File // janet's end of the pipe (nil after passed into janet)
+	host     *os.File // go's end of the pipe, copied from or to by the goroutine
+	writable bool
+	closed   bool // whether Close is called
+
+	done chan struct{} // closed when copying is finished
+	err  error         // error from copying
+}
+
+// WrapReader wraps `r` as a readable janet file.
+//
+// Reading from the file in janet blocks until data is read from `r`, and it reaches EOF when `r` does.
+func (vm *VM) WrapReader(r io.Reader) (*Stream, error) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &Stream{
+		file: pr,
+		host: pw,
+		done: make(chan struct{}),
+	}
+	go func() {
+		_, err := io.Copy(pw, r)
+		if errors.Is(err, syscall.EPIPE) {
+			err = nil // janet closed the file before reading all
+		}
+		stream.finish(err)
+	}()
+
+	return stream, nil
+}
+
+// WrapWriter wraps `w` as a writable janet file.
+//
+// Data written to the file in janet is copied to `w` when it is flushed (with `file/flush`)
+// or closed (with `file/close`, or when garbage-collected).
+func (vm *VM) WrapWriter(w io.Writer) (*Stream, error) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &Stream{
+		file:     pw,
+		host:     pr,
+		writable: true,
+		done:     make(chan struct{}),
+	}
+	go func() {
+		_, err := io.Copy(w, pr)
+		stream.finish(err)
+	}()
+
+	return stream, nil
+}
+
+// finish closes go's end of the pipe after copying is finished with `err`, and marks the stream as done.
+func (s *Stream) finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		err = nil // (copying was stopped by Close, which closed go's end)
+	} else {
+		err = errors.Join(err, s.host.Close())
+	}
+	s.err = err
+	close(s.done)
+}
+
+// Wait waits for copying to be finished and returns its error.
+//
+// For a writer, it returns after the janet file is closed and all data is copied to the io.Writer.
+// For a reader, it returns after the io.Reader reaches EOF (or the janet file is closed).
+// For both, it also returns after the stream is closed with Close.
+func (s *Stream) Wait() error {
+	<-s.done
+	return s.err
+}
+
+// Close stops copying, and closes both ends of the pipe which are not passed into janet.
+//
+// Data not copied yet is discarded, and the janet file (if passed) reaches EOF or fails to be written.
+// It does not wait for copying to be finished (see Wait), as the goroutine reading from
+// the io.Reader of VM.WrapReader returns only after the read returns.
+func (s *Stream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	var err error
+	select {
+	case <-s.done:
+	default:
+		err = s.host.Close() // unblocks the goroutine
+	}
+	if s.file != nil {
+		err = errors.Join(err, s.file.Close())
+		s.file = nil
+	}
+	return err
+}
+
+// toJanet creates a janet file from the stream.
+// A stream can be passed into janet only once.
+// This function should only be called from the VM handler goroutine.
+func (s *Stream) toJanet() (C.Janet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return C.janet_wrap_nil(), errors.New("stream is closed")
+	}
+	if s.file == nil {
+		return C.janet_wrap_nil(), errors.New("stream is already passed into janet")
+	}
+
+	// janet's FILE will own a duplicated file descriptor
+	fd, err := syscall.Dup(int(s.file.Fd()))
+	if err != nil {
+		return C.janet_wrap_nil(), err
+	}
+	if err := s.file.Close(); err != nil {
+		syscall.Close(fd)
+		return C.janet_wrap_nil(), err
+	}
+	s.file = nil
+
+	writable := C.int(0)
+	if s.writable {
+		writable = 1
+	}
+	file := C.makeJanetFile(C.int(fd), writable)
+	if C.janet_checktype(file, C.JANET_NIL) != 0 {
+		return file, errors.New("failed to open stream as a janet file")
+	}
+	return file, nil
+}
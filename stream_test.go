@@ -0,0 +1,135 @@
+// stream_test.go
+
+package janet
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestStreams tests the WrapReader and WrapWriter functions.
+func TestStreams(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	// reader
+	in, err := vm.WrapReader(strings.NewReader("first line\nsecond line\n"))
+	if err != nil {
+		t.Fatalf("Failed to wrap reader: %v", err)
+	}
+	if err := vm.Define(ctx, "in", in); err != nil {
+		t.Fatalf("Failed to define reader: %v", err)
+	}
+	if evaluated, _, _, err := vm.Execute(ctx, `(string (file/read in :line) "|" (file/read in :all))`); err != nil {
+		t.Errorf("Failed to read from stream: %v", err)
+	} else if evaluated != "first line\n|second line\n" {
+		t.Errorf("Unexpected data read from stream: '%s'", evaluated)
+	}
+	if err := in.Wait(); err != nil {
+		t.Errorf("Failed to copy from reader: %v", err)
+	}
+
+	// writer
+	var buf bytes.Buffer
+	out, err := vm.WrapWriter(&buf)
+	if err != nil {
+		t.Fatalf("Failed to wrap writer: %v", err)
+	}
+	if err := vm.Define(ctx, "out", out); err != nil {
+		t.Fatalf("Failed to define writer: %v", err)
+	}
+	if _, _, _, err := vm.Execute(ctx, `(file/write out "written ") (with-dyns [:out out] (print "printed")) (file/close out)`); err != nil {
+		t.Errorf("Failed to write to stream: %v", err)
+	}
+	if err := out.Wait(); err != nil {
+		t.Errorf("Failed to copy to writer: %v", err)
+	}
+	if buf.String() != "written printed\n" {
+		t.Errorf("Unexpected data written to stream: '%s'", buf.String())
+	}
+
+	// a stream can be passed into janet only once
+	if err := vm.Define(ctx, "again", out); err == nil {
+		t.Errorf("Expected error when passing a stream twice")
+	}
+}
+
+// TestStreamClose tests closing streams which are not passed into janet, or not closed in janet.
+func TestStreamClose(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	// waits for copying of `stream` to be finished (or times out)
+	wait := func(name string, stream *Stream) {
+		t.Helper()
+
+		waited := make(chan error, 1)
+		go func() { waited <- stream.Wait() }()
+		select {
+		case err := <-waited:
+			if err != nil {
+				t.Errorf("Expected %s to finish without error, got: %v", name, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("Expected %s to finish after closed, but it is still copying", name)
+		}
+	}
+
+	// never passed into janet (data larger than the buffer of the pipe blocks the copying)
+	in, err := vm.WrapReader(strings.NewReader(strings.Repeat("x", 1<<20)))
+	if err != nil {
+		t.Fatalf("Failed to wrap reader: %v", err)
+	}
+	if err := in.Close(); err != nil {
+		t.Errorf("Failed to close reader: %v", err)
+	}
+	wait("reader", in)
+	if err := vm.Define(ctx, "in", in); err == nil {
+		t.Errorf("Expected error when passing a closed stream")
+	}
+
+	var buf bytes.Buffer
+	out, err := vm.WrapWriter(&buf)
+	if err != nil {
+		t.Fatalf("Failed to wrap writer: %v", err)
+	}
+	if err := out.Close(); err != nil {
+		t.Errorf("Failed to close writer: %v", err)
+	}
+	wait("writer", out)
+
+	// passed into janet, but not closed there
+	out, err = vm.WrapWriter(&buf)
+	if err != nil {
+		t.Fatalf("Failed to wrap writer: %v", err)
+	}
+	if err := vm.Define(ctx, "out", out); err != nil {
+		t.Fatalf("Failed to define writer: %v", err)
+	}
+	if _, _, _, err := vm.Execute(ctx, `(file/write out "unflushed")`); err != nil {
+		t.Errorf("Failed to write to stream: %v", err)
+	}
+	if err := out.Close(); err != nil {
+		t.Errorf("Failed to close writer: %v", err)
+	}
+	wait("passed writer", out)
+	if _, _, _, err := vm.Execute(ctx, `(file/write out (string/repeat "x" 100000)) (file/flush out)`); err == nil {
+		t.Errorf("Expected error when writing to a closed stream")
+	}
+	if err := out.Close(); err != nil {
+		t.Errorf("Expected closing twice to succeed, got: %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+// structfields.go
+
+package janet
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// structField is an exported field of a go struct, converted to and from an entry of a janet struct.
+//
+// Fields are named with `janet` struct tags like encoding/json (eg. `janet:"name,omitempty"`, or `janet:"-"` for skipping,
+// and `janet:",required"` for fields which should be given on decoding with WithStrict),
+// or with their go names in kebab-case (eg. `MaxDepth` => `:max-depth`) by default.
+// Fields of embedded structs without names in their tags are inlined into the outer struct.
+type structField struct {
+	name      string // name of the keyword key (without a leading colon)
+	goName    string // name of the go field
+	tagged    bool   // whether the name is given with a struct tag
+	index     []int  // index sequence for reflect.Value.FieldByIndex
+	omitEmpty bool   // whether the field is omitted when it is empty
+	required  bool   // whether the field should be given on decoding with WithStrict
+}
+
+// cache of fields of struct types (reflect.Type => []structField)
+var structFieldsCache sync.Map
+
+// structFields returns the fields of struct type `typ`, in the order of their declarations.
+func structFields(typ reflect.Type) []structField {
+	if cached, ok := structFieldsCache.Load(typ); ok {
+		return cached.([]structField)
+	}
+
+	var fields []structField
+	depths := map[string]int{} // nesting depths of fields by their names (shallower fields win)
+	var collect func(typ reflect.Type, index []int, visited map[reflect.Type]bool)
+	collect = func(typ reflect.Type, index []int, visited map[reflect.Type]bool) {
+		if visited[typ] {
+			return
+		}
+		visited[typ] = true
+		defer delete(visited, typ)
+
+		for i := range typ.NumField() {
+			f := typ.Field(i)
+			tag := f.Tag.Get("janet")
+			if tag == "-" {
+				continue
+			}
+			name, options, _ := strings.Cut(tag, ",")
+
+			fieldIndex := append(append([]int(nil), index...), i)
+			if f.Anonymous && name == "" {
+				embedded := f.Type
+				if embedded.Kind() == reflect.Pointer {
+					if !f.IsExported() {
+						continue // cannot be allocated on decoding
+					}
+					embedded = embedded.Elem()
+				}
+				if embedded.Kind() == reflect.Struct {
+					collect(embedded, fieldIndex, visited)
+					continue
+				}
+			}
+			if !f.IsExported() {
+				continue
+			}
+
+			field := structField{
+				name:      name,
+				goName:    f.Name,
+				tagged:    name != "",
+				index:     fieldIndex,
+				omitEmpty: hasTagOption(options, "omitempty"),
+				required:  hasTagOption(options, "required"),
+			}
+			if !field.tagged {
+				field.name = kebabCase(f.Name)
+			}
+			if depth, exists := depths[field.name]; exists {
+				if depth <= len(index) {
+					continue
+				}
+				fields = slices.DeleteFunc(fields, func(f structField) bool { return f.name == field.name })
+			}
+			depths[field.name] = len(index)
+			fields = append(fields, field)
+		}
+	}
+	collect(typ, nil, map[reflect.Type]bool{})
+
+	structFieldsCache.Store(typ, fields)
+	return fields
+}
+
+// hasTagOption returns whether comma-separated `options` of a struct tag contain `option`.
+func hasTagOption(options, option string) bool {
+	for opt := range strings.SplitSeq(options, ",") {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupField returns the field of `fields` for a key `name` (without a leading colon),
+// matching it with their names exactly, or case-insensitively (also with go names of untagged fields).
+func lookupField(fields []structField, name string) (structField, bool) {
+	for _, field := range fields {
+		if field.name == name {
+			return field, true
+		}
+	}
+	for _, field := range fields {
+		if strings.EqualFold(field.name, name) || (!field.tagged && strings.EqualFold(field.goName, name)) {
+			return field, true
+		}
+	}
+	return structField{}, false
+}
+
+// isEmptyValue returns whether `value` is empty for `omitempty`
+// (a zero value, or an empty slice, map, or string).
+func isEmptyValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String:
+		return value.Len() == 0
+	}
+	return value.IsZero()
+}
+
+// kebabCase converts a go field name to kebab-case (eg. `MaxDepth` => `max-depth`, `HTTPClient` => `http-client`).
+func kebabCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+				b.WriteByte('-')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,86 @@
+// structfields_test.go
+
+package janet
+
+import (
+	"context"
+	"testing"
+)
+
+// TestKebabCase tests converting go field names to kebab-case.
+func TestKebabCase(t *testing.T) {
+	for name, expected := range map[string]string{
+		"Name":       "name",
+		"MaxDepth":   "max-depth",
+		"ID":         "id",
+		"UserID":     "user-id",
+		"HTTPClient": "http-client",
+		"Field1":     "field1",
+		"V2Config":   "v2-config",
+	} {
+		if converted := kebabCase(name); converted != expected {
+			t.Errorf("Expected '%s' for '%s', got '%s'", expected, name, converted)
+		}
+	}
+}
+
+// Base is a struct embedded in other structs.
+type Base struct {
+	ID      int
+	Created string `janet:"created-at"`
+}
+
+// Meta is a struct embedded with a name.
+type Meta struct {
+	Owner string
+}
+
+// TestStructTags tests conversions of structs with `janet` struct tags and embedded structs.
+func TestStructTags(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	type record struct {
+		*Base
+		Meta      `janet:"meta"`
+		MaxDepth  int
+		Title     string   `janet:"name"`
+		Tags      []string `janet:",omitempty"`
+		Secret    string   `janet:"-"`
+		Dash      string   `janet:"-,"`
+		ID        string   // shadows the embedded one
+		unexposed int
+	}
+
+	// go => janet
+	if err := vm.Define(ctx, "r", record{
+		Base:     &Base{ID: 1, Created: "today"},
+		Meta:     Meta{Owner: "janet"},
+		MaxDepth: 3,
+		Title:    "title",
+		Secret:   "secret",
+		Dash:     "dash",
+		ID:       "outer",
+	}); err != nil {
+		t.Fatalf("Failed to define: %v", err)
+	}
+	expected := `@[3 "title" nil nil "dash" "outer" "today" "janet" 6]`
+	if evaluated, _, _, err := vm.Execute(ctx, `(array/concat (map |(get r $) [:max-depth :name :tags :secret :- :id :created-at]) [((r :meta) :owner) (length r)])`, WithRender(RenderJDN)); err != nil || evaluated != expected {
+		t.Errorf("Expected %s, got %s (%v)", expected, evaluated, err)
+	}
+
+	// janet => go
+	var decoded record
+	if err := vm.ExecuteInto(ctx, `{:maxdepth 5 :name "decoded" :tags ["a"] :secret "x" :created-at "now" :meta {:owner "go"} :id "id"}`, &decoded); err != nil {
+		t.Fatalf("Failed to execute into: %v", err)
+	}
+	if decoded.MaxDepth != 5 || decoded.Title != "decoded" || len(decoded.Tags) != 1 || decoded.Secret != "" ||
+		decoded.Base == nil || decoded.Created != "now" || decoded.Base.ID != 0 || decoded.ID != "id" || decoded.Owner != "go" {
+		t.Errorf("Expected values stored by their names, got %+v (base: %+v)", decoded, decoded.Base)
+	}
+}
@@ -0,0 +1,47 @@
+// tempdir.go
+
+package janet
+
+/*
+#include "janet.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"maps"
+	"os"
+)
+
+// WithTempDir provisions a new temporary directory for the execution as scratch space of the script,
+// whose path is bound to the dynamic binding `:tmpdir` (eg. `(string (dyn :tmpdir) "/out.txt")`).
+//
+// The directory (and everything in it) is removed when the execution completes,
+// so scripts do not litter the host filesystem.
+func WithTempDir() ExecOption {
+	return func(o *execOptions) {
+		o.tempDir = true
+	}
+}
+
+// withExecDyns runs `fn` with the dynamic bindings of the execution with `options` bound in `env`,
+// including the temporary directory of the execution (see WithTempDir) which is removed after `fn` returns.
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) withExecDyns(env *C.JanetTable, options execOptions, fn func()) error {
+	if !options.tempDir {
+		return vm.withDyns(env, options.dyns, fn)
+	}
+
+	dir, err := os.MkdirTemp("", "janet-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	dyns := maps.Clone(options.dyns)
+	if dyns == nil {
+		dyns = map[string]any{}
+	}
+	dyns["tmpdir"] = dir
+	return vm.withDyns(env, dyns, fn)
+}
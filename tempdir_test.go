@@ -0,0 +1,45 @@
+// tempdir_test.go
+
+package janet
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestTempDir tests temporary directories of executions.
+func TestTempDir(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	dir, _, _, err := vm.Execute(ctx, `(def dir (dyn :tmpdir))
+(spit (string dir "/scratch.txt") "data")
+(assert (= (string (slurp (string dir "/scratch.txt"))) "data"))
+dir`, WithTempDir())
+	if err != nil {
+		t.Fatalf("Failed to execute with a temporary directory: %v", err)
+	}
+	if !filepath.IsAbs(dir) {
+		t.Errorf("Expected an absolute path of the temporary directory, got %q", dir)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Expected the temporary directory to be removed, got %v", err)
+	}
+
+	// not visible to other executions
+	if evaluated, _, _, err := vm.Execute(ctx, `(dyn :tmpdir)`); err != nil || evaluated != "nil" {
+		t.Errorf("Expected no temporary directory, got %q (%v)", evaluated, err)
+	}
+
+	// with other dynamic bindings
+	if evaluated, _, _, err := vm.Execute(ctx, `(and (dyn :tmpdir) (dyn :answer))`, WithTempDir(), WithDyns(map[string]any{"answer": 42})); err != nil || evaluated != "42" {
+		t.Errorf("Expected both bindings, got %q (%v)", evaluated, err)
+	}
+}
@@ -0,0 +1,126 @@
+// timeconv.go
+
+package janet
+
+/*
+#include "janet.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"math"
+	"time"
+	"unsafe"
+)
+
+// Date is a time.Time which is encoded as an `os/date` struct in janet,
+// with its fields in the time's location (eg. `Date(t.UTC())` for `(os/date)`,
+// or `Date(t.Local())` for `(os/date (os/time) true)`).
+//
+// (time.Time itself is encoded as an epoch number in seconds, like `(os/time)`)
+type Date time.Time
+
+// dateToJanet converts a date to an `os/date` struct.
+// This function should only be called from the VM handler goroutine.
+func dateToJanet(date Date) C.Janet {
+	t := time.Time(date)
+
+	fields := []struct {
+		key   string
+		value C.Janet
+	}{
+		{"seconds", C.janet_wrap_number(C.double(t.Second()))},
+		{"minutes", C.janet_wrap_number(C.double(t.Minute()))},
+		{"hours", C.janet_wrap_number(C.double(t.Hour()))},
+		{"month-day", C.janet_wrap_number(C.double(t.Day() - 1))},
+		{"month", C.janet_wrap_number(C.double(t.Month() - 1))},
+		{"year", C.janet_wrap_number(C.double(t.Year()))},
+		{"week-day", C.janet_wrap_number(C.double(t.Weekday()))},
+		{"year-day", C.janet_wrap_number(C.double(t.YearDay() - 1))},
+		{"dst", C.janet_wrap_boolean(C.int(boolToInt(t.IsDST())))},
+	}
+
+	st := C.janet_struct_begin(C.int32_t(len(fields)))
+	for _, field := range fields {
+		key := C.CString(field.key)
+		C.janet_struct_put(st, C.janet_wrap_keyword(C.janet_ckeyword(key)), field.value)
+		C.free(unsafe.Pointer(key))
+	}
+	return C.janet_wrap_struct(C.janet_struct_end(st))
+}
+
+// DecodeTime converts a janet time value (which was converted to go) to time.Time.
+//
+// `value` can be an epoch number in seconds (eg. from `(os/time)` or `(os/clock :realtime)`),
+// or an `os/date` struct, whose fields are interpreted in `loc` (UTC if nil).
+func DecodeTime(value any, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	switch v := value.(type) {
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return time.Time{}, fmt.Errorf("invalid epoch seconds: %v", v)
+		}
+		sec, frac := math.Modf(v)
+		return time.Unix(int64(sec), int64(frac*1e9)).In(loc), nil
+	case int64:
+		return time.Unix(v, 0).In(loc), nil
+	case *OrderedMap, map[string]any:
+		if m, ok := asMap(v); ok {
+			return DecodeTime(m, loc)
+		}
+	case map[any]any:
+		field := func(key string) (int, error) {
+			n, ok := keywordField(v, key).(float64)
+			if !ok {
+				return 0, fmt.Errorf("missing or invalid field :%s in os/date struct", key)
+			}
+			return int(n), nil
+		}
+
+		var values [6]int
+		for i, key := range []string{"year", "month", "month-day", "hours", "minutes", "seconds"} {
+			n, err := field(key)
+			if err != nil {
+				return time.Time{}, err
+			}
+			values[i] = n
+		}
+		return time.Date(values[0], time.Month(values[1]+1), values[2]+1, values[3], values[4], values[5], 0, loc), nil
+	}
+
+	return time.Time{}, fmt.Errorf("cannot decode value of type %T as time", value)
+}
+
+// DecodeDuration converts a janet duration in seconds (which was converted to go) to time.Duration.
+//
+// `value` can also be a string accepted by time.ParseDuration (eg. "1m30s").
+func DecodeDuration(value any) (time.Duration, error) {
+	switch v := value.(type) {
+	case int64:
+		return time.Duration(v) * time.Second, nil
+	case string:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("cannot decode value %q as duration: %w", v, err)
+		}
+		return d, nil
+	}
+
+	seconds, ok := value.(float64)
+	if !ok || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return 0, fmt.Errorf("cannot decode value %v as duration", value)
+	}
+	return time.Duration(math.Round(seconds * float64(time.Second))), nil
+}
+
+// boolToInt converts a bool to 1 or 0.
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,83 @@
+// timeconv_test.go
+
+package janet
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestTimeConversions tests conversions of time.Time and time.Duration.
+func TestTimeConversions(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	tm := time.Date(2024, time.March, 15, 12, 30, 45, 0, time.UTC)
+
+	// time.Time => epoch seconds => os/date struct => time.Time
+	if err := vm.Define(ctx, "t", tm); err != nil {
+		t.Fatalf("Failed to define time: %v", err)
+	}
+	if value, err := vm.ParseToValue(ctx, "t"); err != nil {
+		t.Errorf("Failed to evaluate time: %v", err)
+	} else if value != float64(tm.Unix()) {
+		t.Errorf("Expected epoch seconds %d, got %v", tm.Unix(), value)
+	}
+	date, err := vm.ParseToValue(ctx, "(os/date t)")
+	if err != nil {
+		t.Fatalf("Failed to evaluate os/date: %v", err)
+	}
+	if decoded, err := DecodeTime(date, nil); err != nil {
+		t.Errorf("Failed to decode os/date struct: %v", err)
+	} else if !decoded.Equal(tm) {
+		t.Errorf("Expected %v, got %v", tm, decoded)
+	}
+
+	// os/date struct interpreted in another location
+	zone := time.FixedZone("UTC+9", 9*60*60)
+	if decoded, err := DecodeTime(date, zone); err != nil {
+		t.Errorf("Failed to decode os/date struct: %v", err)
+	} else if expected := time.Date(2024, time.March, 15, 12, 30, 45, 0, zone); !decoded.Equal(expected) {
+		t.Errorf("Expected %v, got %v", expected, decoded)
+	}
+
+	// Date => os/date struct
+	if err := vm.Define(ctx, "d", Date(tm.In(zone))); err != nil {
+		t.Fatalf("Failed to define date: %v", err)
+	}
+	if value, err := vm.ParseToValue(ctx, "[(d :hours) (d :month) (d :month-day) (d :week-day) (d :year-day)]"); err != nil {
+		t.Errorf("Failed to evaluate date: %v", err)
+	} else if fields := value.([]any); fields[0] != float64(21) || fields[1] != float64(2) || fields[2] != float64(14) || fields[3] != float64(5) || fields[4] != float64(74) {
+		t.Errorf("Unexpected date fields: %v", fields)
+	}
+
+	// epoch seconds => time.Time
+	now, err := vm.ParseToValue(ctx, "(os/time)")
+	if err != nil {
+		t.Fatalf("Failed to evaluate os/time: %v", err)
+	}
+	if decoded, err := DecodeTime(now, nil); err != nil {
+		t.Errorf("Failed to decode epoch seconds: %v", err)
+	} else if diff := time.Since(decoded); diff < -time.Second || diff > 2*time.Second {
+		t.Errorf("Unexpected time decoded from os/time: %v", decoded)
+	}
+	if _, err := DecodeTime("not a time", nil); err == nil {
+		t.Errorf("Expected error for an invalid time value")
+	}
+
+	// time.Duration => seconds => time.Duration
+	if err := vm.Define(ctx, "dur", 1500*time.Millisecond); err != nil {
+		t.Fatalf("Failed to define duration: %v", err)
+	}
+	if value, err := vm.ParseToValue(ctx, "(* dur 2)"); err != nil {
+		t.Errorf("Failed to evaluate duration: %v", err)
+	} else if decoded, err := DecodeDuration(value); err != nil || decoded != 3*time.Second {
+		t.Errorf("Expected 3s, got %v (error: %v)", decoded, err)
+	}
+}
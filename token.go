@@ -0,0 +1,257 @@
+// token.go
+
+package janet
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// TokenKind is the kind of a janet source token.
+type TokenKind int
+
+// kinds of janet source tokens
+const (
+	tokenEOF         TokenKind = iota
+	TokenOpen                  // opening delimiters: (, [, {, @(, @[, or @{
+	TokenClose                 // closing delimiters: ), ], or }
+	TokenReaderMacro           // reader macros: ', ~, ,, ;, or |
+	TokenComment               // # comment
+	TokenString                // "string" or @"buffer"
+	TokenLongString            // `long string` or @`long buffer`
+	TokenNumber                // numbers
+	TokenKeyword               // :keywords
+	TokenSymbol                // symbols
+	TokenNil                   // nil
+	TokenBoolean               // true or false
+)
+
+// names of token kinds
+var tokenKindNames = map[TokenKind]string{
+	tokenEOF:         "eof",
+	TokenOpen:        "open",
+	TokenClose:       "close",
+	TokenReaderMacro: "reader-macro",
+	TokenComment:     "comment",
+	TokenString:      "string",
+	TokenLongString:  "long-string",
+	TokenNumber:      "number",
+	TokenKeyword:     "keyword",
+	TokenSymbol:      "symbol",
+	TokenNil:         "nil",
+	TokenBoolean:     "boolean",
+}
+
+// String returns the name of the token kind.
+func (k TokenKind) String() string {
+	if name, exists := tokenKindNames[k]; exists {
+		return name
+	}
+	return fmt.Sprintf("TokenKind(%d)", int(k))
+}
+
+// Token is a token of janet source.
+type Token struct {
+	Kind  TokenKind
+	Text  string   // source text of the token
+	Start Position // position of the first byte of the token
+	End   Position // position right after the last byte of the token
+}
+
+// Tokenize splits janet source `src` into tokens (without parsing or evaluating them),
+// which is useful for syntax highlighting.
+//
+// Whitespaces are not included in the tokens, but can be found between their spans.
+//
+// When `src` has a lexical error (eg. an unterminated string),
+// it returns the tokens before the error along with the error.
+func Tokenize(src string) (tokens []Token, err error) {
+	l := newLexer(src)
+
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return tokens, err
+		}
+		if tok.Kind == tokenEOF {
+			return tokens, nil
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+// lexer splits janet source into tokens.
+type lexer struct {
+	src    string
+	offset int
+	line   int
+	column int
+
+	peeked *Token
+}
+
+// newLexer returns a new lexer for `src`.
+func newLexer(src string) *lexer {
+	return &lexer{
+		src:    src,
+		line:   1,
+		column: 1,
+	}
+}
+
+// pos returns the current position of the lexer.
+func (l *lexer) pos() Position {
+	return Position{Offset: l.offset, Line: l.line, Column: l.column}
+}
+
+// advance moves the lexer forward by `n` bytes.
+func (l *lexer) advance(n int) {
+	for i := 0; i < n && l.offset < len(l.src); i++ {
+		if l.src[l.offset] == '\n' {
+			l.line++
+			l.column = 1
+		} else {
+			l.column++
+		}
+		l.offset++
+	}
+}
+
+// peek returns the next token without consuming it.
+func (l *lexer) peek() (Token, error) {
+	if l.peeked == nil {
+		tok, err := l.lex()
+		if err != nil {
+			return tok, err
+		}
+		l.peeked = &tok
+	}
+	return *l.peeked, nil
+}
+
+// next consumes and returns the next token.
+func (l *lexer) next() (Token, error) {
+	if l.peeked != nil {
+		tok := *l.peeked
+		l.peeked = nil
+		return tok, nil
+	}
+	return l.lex()
+}
+
+// isWhitespace returns whether `c` is a whitespace character in janet.
+func isWhitespace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', 0, '\v', '\f':
+		return true
+	}
+	return false
+}
+
+// isSymbolChar returns whether `c` can be a part of janet symbols, keywords, or numbers.
+func isSymbolChar(c byte) bool {
+	return c >= 0x80 ||
+		(c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9') ||
+		strings.IndexByte("!$%&*+-./:<=>?@^_", c) >= 0
+}
+
+// lex scans the next token from the source.
+func (l *lexer) lex() (tok Token, err error) {
+	// skip whitespaces
+	for l.offset < len(l.src) && isWhitespace(l.src[l.offset]) {
+		l.advance(1)
+	}
+
+	start := l.pos()
+	emit := func(kind TokenKind, length int) (Token, error) {
+		l.advance(length)
+		return Token{
+			Kind:  kind,
+			Text:  l.src[start.Offset:l.offset],
+			Start: start,
+			End:   l.pos(),
+		}, nil
+	}
+
+	if l.offset >= len(l.src) {
+		return Token{Kind: tokenEOF, Start: start, End: start}, nil
+	}
+
+	rest := l.src[l.offset:]
+	c := rest[0]
+	switch {
+	case c == '#':
+		length := strings.IndexByte(rest, '\n')
+		if length < 0 {
+			length = len(rest)
+		}
+		return emit(TokenComment, length)
+	case c == '(' || c == '[' || c == '{':
+		return emit(TokenOpen, 1)
+	case c == ')' || c == ']' || c == '}':
+		return emit(TokenClose, 1)
+	case c == '\'' || c == '~' || c == ',' || c == ';' || c == '|':
+		return emit(TokenReaderMacro, 1)
+	case c == '@' && len(rest) > 1 && strings.IndexByte("([{", rest[1]) >= 0:
+		return emit(TokenOpen, 2)
+	case c == '"' || (c == '@' && len(rest) > 1 && rest[1] == '"'):
+		prefix := 1
+		if c == '@' {
+			prefix = 2
+		}
+		for i := prefix; i < len(rest); i++ {
+			switch rest[i] {
+			case '\\':
+				i++
+			case '"':
+				return emit(TokenString, i+1)
+			}
+		}
+		return Token{}, &ParseError{Position: start, Message: "unexpected end of source, \" opened at " + start.String()}
+	case c == '`' || (c == '@' && len(rest) > 1 && rest[1] == '`'):
+		prefix := 0
+		if c == '@' {
+			prefix = 1
+		}
+		delim := len(rest[prefix:]) - len(strings.TrimLeft(rest[prefix:], "`"))
+		closing := strings.Index(rest[prefix+delim:], strings.Repeat("`", delim))
+		if closing < 0 {
+			return Token{}, &ParseError{Position: start, Message: "unexpected end of source, ` opened at " + start.String()}
+		}
+		return emit(TokenLongString, prefix+delim+closing+delim)
+	case isSymbolChar(c):
+		length := 1
+		for length < len(rest) && isSymbolChar(rest[length]) {
+			length++
+		}
+		text := rest[:length]
+
+		switch {
+		case text[0] == ':':
+			if !utf8.ValidString(text[1:]) {
+				return Token{}, &ParseError{Position: start, Message: "invalid utf-8 in keyword"}
+			}
+			return emit(TokenKeyword, length)
+		case text == "nil":
+			return emit(TokenNil, length)
+		case text == "true" || text == "false":
+			return emit(TokenBoolean, length)
+		case strings.IndexByte("0123456789-+.", text[0]) >= 0:
+			if _, ok := scanNumber(text); ok {
+				return emit(TokenNumber, length)
+			}
+			if text[0] >= '0' && text[0] <= '9' {
+				return Token{}, &ParseError{Position: start, Message: "symbol literal cannot start with a digit"}
+			}
+		}
+		if !utf8.ValidString(text) {
+			return Token{}, &ParseError{Position: start, Message: "invalid utf-8 in symbol"}
+		}
+		return emit(TokenSymbol, length)
+	}
+
+	return Token{}, &ParseError{Position: start, Message: fmt.Sprintf("unexpected character %q", c)}
+}
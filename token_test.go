@@ -0,0 +1,81 @@
+// token_test.go
+
+package janet
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestTokenize tests the Tokenize function.
+func TestTokenize(t *testing.T) {
+	src := `(def x @{:a 1}) # comment
+'[nil true "str"]`
+
+	tokens, err := Tokenize(src)
+	if err != nil {
+		t.Fatalf("Failed to tokenize: %v", err)
+	}
+
+	expected := []struct {
+		kind TokenKind
+		text string
+	}{
+		{TokenOpen, "("},
+		{TokenSymbol, "def"},
+		{TokenSymbol, "x"},
+		{TokenOpen, "@{"},
+		{TokenKeyword, ":a"},
+		{TokenNumber, "1"},
+		{TokenClose, "}"},
+		{TokenClose, ")"},
+		{TokenComment, "# comment"},
+		{TokenReaderMacro, "'"},
+		{TokenOpen, "["},
+		{TokenNil, "nil"},
+		{TokenBoolean, "true"},
+		{TokenString, `"str"`},
+		{TokenClose, "]"},
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("Expected %d tokens, got %d: %+v", len(expected), len(tokens), tokens)
+	}
+	for i, tok := range tokens {
+		if tok.Kind != expected[i].kind || tok.Text != expected[i].text {
+			t.Errorf("Expected token #%d to be %s '%s', got %s '%s'", i, expected[i].kind, expected[i].text, tok.Kind, tok.Text)
+		}
+		if src[tok.Start.Offset:tok.End.Offset] != tok.Text {
+			t.Errorf("Span of token #%d (%d-%d) does not match its text '%s'", i, tok.Start.Offset, tok.End.Offset, tok.Text)
+		}
+	}
+
+	// positions
+	if comment := tokens[8]; comment.Start != (Position{Offset: 16, Line: 1, Column: 17}) {
+		t.Errorf("Unexpected position of comment: %+v", comment.Start)
+	}
+	if str := tokens[13]; str.Start != (Position{Offset: 37, Line: 2, Column: 12}) || str.End != (Position{Offset: 42, Line: 2, Column: 17}) {
+		t.Errorf("Unexpected span of string: %+v - %+v", str.Start, str.End)
+	}
+
+	// unbalanced delimiters are not errors for tokenizing
+	if tokens, err := Tokenize(`(+ 1`); err != nil || len(tokens) != 3 {
+		t.Errorf("Expected 3 tokens without error, got %d tokens and error: %v", len(tokens), err)
+	}
+}
+
+// TestTokenizeErrors tests errors from the Tokenize function.
+func TestTokenizeErrors(t *testing.T) {
+	tokens, err := Tokenize(`(print "unterminated`)
+	if err == nil || !strings.Contains(err.Error(), "unexpected end of source") {
+		t.Errorf("Expected error about unterminated string, got: %v", err)
+	}
+
+	var texts []string
+	for _, tok := range tokens {
+		texts = append(texts, tok.Text)
+	}
+	if !reflect.DeepEqual(texts, []string{"(", "print"}) {
+		t.Errorf("Expected tokens before the error, got: %v", texts)
+	}
+}
@@ -0,0 +1,405 @@
+// value.go
+
+package janet
+
+/*
+#include <stdlib.h>
+
+#include "janet.h"
+*/
+import "C"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// janet function which gets a value from a data structure
+const getterSource = `(fn [ds key] (get ds key))`
+
+// Value is a handle of a janet value (eg. a table or an abstract value) kept in a VM.
+//
+// The value is rooted so that it is not garbage collected, until the handle is released with Release
+// (or all of its roots are removed with Unroot).
+type Value struct {
+	vm *VM
+	*handle
+}
+
+// handle is the state of a value handle, which is kept by the VM until it is released
+// (separated from Value, so that unreachable Values can be detected with cleanups).
+type handle struct {
+	value C.Janet
+	typ   Type
+	stack string // where the handle was created (only with WithHandleStacks)
+
+	roots int // number of roots (released when 0), only accessed from the VM handler goroutine
+}
+
+// HandleRelease is the policy for value handles which become unreachable without being released.
+type HandleRelease int
+
+// HandleRelease constants
+const (
+	HandleReleaseManual HandleRelease = iota // they are kept rooted until the VM is closed
+	HandleReleaseAuto                        // they are released on the VM goroutine after go collects them
+	HandleReleaseStrict                      // the program panics (with where they were created, if recorded) after go collects them, for finding leaks while debugging
+)
+
+// newHandle roots `value` and returns a new handle of it,
+// with the `stack` of the caller where it is created (see VM.callerStack).
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) newHandle(value C.Janet, stack string) *Value {
+	C.janet_gcroot(value)
+	h := &handle{
+		value: value,
+		typ:   Type(C.janet_type(value)),
+		stack: stack,
+		roots: 1,
+	}
+	if vm.handles == nil {
+		vm.handles = map[*handle]struct{}{}
+	}
+	vm.handles[h] = struct{}{}
+	vm.liveRoots.Add(1)
+
+	v := &Value{vm: vm, handle: h}
+	if vm.options.handleRelease != HandleReleaseManual {
+		runtime.AddCleanup(v, vm.releaseUnreachable, h)
+	}
+	return v
+}
+
+// releaseUnreachable releases the handle `h` whose Value became unreachable, on the VM goroutine.
+func (vm *VM) releaseUnreachable(h *handle) {
+	req := vmCallRequest{
+		enqueued: time.Now(),
+		fn: func(env *C.JanetTable) {
+			if h.roots <= 0 {
+				return
+			}
+			if vm.options.handleRelease == HandleReleaseStrict {
+				panic(fmt.Sprintf("janet: value handle (%s) was not released before being garbage collected%s", h.typ, stackSuffix(h.stack)))
+			}
+			vm.unroot(h, h.roots)
+		},
+	}
+
+	// (queued without blocking the cleanup goroutine)
+	vm.stats.pendingCall.Add(1)
+	if err := vm.queue.push(vmRequest{kind: requestCall, call: req}); err != nil {
+		vm.stats.pendingCall.Add(-1)
+	}
+}
+
+// stackSuffix returns `stack` formatted as a suffix of messages, if any.
+func stackSuffix(stack string) string {
+	if stack == "" {
+		return ""
+	}
+	return ", created at:\n" + stack
+}
+
+// callerStack returns the stack of the calling goroutine if creation stacks of handles are recorded.
+func (vm *VM) callerStack() string {
+	if vm.options.handleStacks {
+		return string(debug.Stack())
+	}
+	return ""
+}
+
+// EvalHandle executes a `janetExpression` and returns a handle of the evaluated value.
+func (vm *VM) EvalHandle(
+	ctx context.Context,
+	janetExpression string,
+) (*Value, error) {
+	type handleResult struct {
+		handle *Value
+		err    error
+	}
+	stack := vm.callerStack()
+	res, err := runOnVM(ctx, vm, func(env *C.JanetTable) handleResult {
+		var janetResult C.Janet
+
+		var failure evalFailure
+		if ret := dobytes(env, janetExpression, &janetResult, &failure); ret != C.JANET_SIGNAL_OK {
+			return handleResult{err: failure.wrap(newError(failure.fiber, janetResult, janetValueToString(janetResult)))}
+		}
+		return handleResult{handle: vm.newHandle(janetResult, stack)}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.handle, res.err
+}
+
+// Type returns the type of the value.
+func (v *Value) Type() Type {
+	return v.typ
+}
+
+// Get returns the value of `key` (converted to janet) in the value, converted to a go value.
+func (v *Value) Get(ctx context.Context, key any) (any, error) {
+	return v.run(ctx, func(env *C.JanetTable) (C.Janet, error) {
+		if v.vm.getter == nil {
+			helper, err := compileHelper(env, getterSource)
+			if err != nil {
+				return C.janet_wrap_nil(), err
+			}
+			v.vm.getter = helper
+		}
+		converted, err := v.vm.encoder().encode(key)
+		if err != nil {
+			return C.janet_wrap_nil(), fmt.Errorf("failed to convert key: %w", err)
+		}
+		return pcall(env, v.vm.getter, v.value, converted)
+	})
+}
+
+// Invoke calls the `method` (eg. ":area" or "area") of the value with `args`,
+// in the same way as `(:area value ...args)` in janet, and returns its result converted to a go value.
+func (v *Value) Invoke(ctx context.Context, method string, args ...any) (any, error) {
+	return v.run(ctx, func(env *C.JanetTable) (C.Janet, error) {
+		return v.vm.call(env, janetKeyword(strings.TrimPrefix(method, ":")), append([]any{v}, args...))
+	})
+}
+
+// Call calls the value (eg. a function) with `args`, and returns its result converted to a go value.
+// Fibers are resumed with the first argument (if any) instead, returning the value they yield or return.
+//
+// It can be called from any goroutine, eg. for calling callbacks handed off by scripts (see VM.OnCallback).
+func (v *Value) Call(ctx context.Context, args ...any) (any, error) {
+	return v.run(ctx, func(env *C.JanetTable) (C.Janet, error) {
+		if v.typ != TypeFiber {
+			return v.vm.call(env, v.value, args)
+		}
+		if len(args) > 1 {
+			return C.janet_wrap_nil(), errors.New("fibers are resumed with at most one argument")
+		}
+		cName := C.CString("resume")
+		defer C.free(unsafe.Pointer(cName))
+		var resume C.Janet
+		C.janet_resolve(env, C.janet_csymbol(cName), &resume)
+		return v.vm.call(env, resume, append([]any{v}, args...))
+	})
+}
+
+// Entry is an element of a janet collection, yielded by Value.Iter.
+type Entry struct {
+	Key   any // index (int) for tuples and arrays, converted key for tables and structs
+	Value any
+}
+
+// number of elements converted at once by Value.Iter
+const iterPageSize = 1024
+
+// iterPage is a page of converted elements.
+type iterPage struct {
+	entries []Entry
+	next    int // position of the next page (-1 if there are no more elements)
+	err     error
+}
+
+// Iter returns an iterator over the elements of a tuple, array, table, or struct value,
+// which converts elements on demand (in pages) on the VM goroutine, instead of converting
+// the whole collection at once.
+//
+// Iteration stops after yielding an error. Modifying the collection during iteration
+// may skip or repeat elements.
+func (v *Value) Iter(ctx context.Context) iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		for position := 0; position >= 0; {
+			page, err := runOnVM(ctx, v.vm, func(env *C.JanetTable) iterPage {
+				return v.page(ctx, position)
+			})
+			if err == nil {
+				err = page.err
+			}
+			if err != nil {
+				yield(Entry{}, err)
+				return
+			}
+			for _, entry := range page.entries {
+				if !yield(entry, nil) {
+					return
+				}
+			}
+			position = page.next
+		}
+	}
+}
+
+// page converts the elements of the collection from `position`.
+// This function should only be called from the VM handler goroutine.
+func (v *Value) page(ctx context.Context, position int) iterPage {
+	if v.roots <= 0 {
+		return iterPage{err: errReleased}
+	}
+
+	entries := make([]Entry, 0, iterPageSize)
+	switch v.typ {
+	case TypeTuple, TypeArray:
+		elems := janetIndexed(v.value)
+		for ; position < len(elems) && len(entries) < iterPageSize; position++ {
+			converted, err := v.vm.decoder(ctx).decode(elems[position])
+			if err != nil {
+				return iterPage{err: err}
+			}
+			entries = append(entries, Entry{Key: position, Value: converted})
+		}
+		if position >= len(elems) {
+			position = -1
+		}
+	case TypeTable, TypeStruct:
+		var data *C.JanetKV
+		var length, capacity C.int32_t
+		C.janet_dictionary_view(v.value, &data, &length, &capacity)
+		kvs := unsafe.Slice(data, int(capacity))
+		for ; position < len(kvs) && len(entries) < iterPageSize; position++ {
+			kv := kvs[position]
+			if C.janet_checktype(kv.key, C.JANET_NIL) != 0 {
+				continue
+			}
+			key, err := v.vm.decoder(ctx).decode(kv.key)
+			if err != nil {
+				return iterPage{err: err}
+			}
+			value, err := v.vm.decoder(ctx).decode(kv.value)
+			if err != nil {
+				return iterPage{err: err}
+			}
+			entries = append(entries, Entry{Key: key, Value: value})
+		}
+		if position >= len(kvs) {
+			position = -1
+		}
+	default:
+		return iterPage{err: fmt.Errorf("cannot iterate over %s", v.typ)}
+	}
+	return iterPage{entries: entries, next: position}
+}
+
+// Root adds a root to the value, so that it is kept alive until the root is removed with Unroot
+// (eg. by another owner of the handle).
+func (v *Value) Root(ctx context.Context) error {
+	res, err := runOnVM(ctx, v.vm, func(env *C.JanetTable) error {
+		if v.roots <= 0 {
+			return errReleased
+		}
+		C.janet_gcroot(v.value)
+		v.roots++
+		v.vm.liveRoots.Add(1)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return res
+}
+
+// Unroot removes a root of the value (added on creation or with Root).
+// The handle is released when all of its roots are removed.
+func (v *Value) Unroot(ctx context.Context) error {
+	res, err := runOnVM(ctx, v.vm, func(env *C.JanetTable) error {
+		if v.roots <= 0 {
+			return errReleased
+		}
+		v.vm.unroot(v.handle, 1)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return res
+}
+
+// Release removes all roots of the value so that it can be garbage collected.
+// The handle cannot be used after it is released.
+func (v *Value) Release(ctx context.Context) error {
+	_, err := runOnVM(ctx, v.vm, func(env *C.JanetTable) bool {
+		v.vm.unroot(v.handle, v.roots)
+		return true
+	})
+	return err
+}
+
+// unroot removes `n` roots of the handle `h`, and releases it when no roots remain.
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) unroot(h *handle, n int) {
+	for range n {
+		C.janet_gcunroot(h.value)
+	}
+	h.roots -= n
+	vm.liveRoots.Add(-int64(n))
+	if h.roots <= 0 {
+		delete(vm.handles, h)
+	}
+}
+
+// HandleInfo describes a live value handle, for diagnosing leaks of handles.
+type HandleInfo struct {
+	Type  Type
+	Roots int
+	Stack string // where the handle was created (only with WithHandleStacks)
+}
+
+// LiveRoots returns the number of roots held by the live value handles of the VM.
+func (vm *VM) LiveRoots() int {
+	return int(vm.liveRoots.Load())
+}
+
+// LiveHandles returns the descriptions of the live (not released) value handles of the VM.
+func (vm *VM) LiveHandles(ctx context.Context) ([]HandleInfo, error) {
+	return runOnVM(ctx, vm, func(env *C.JanetTable) []HandleInfo {
+		infos := make([]HandleInfo, 0, len(vm.handles))
+		for h := range vm.handles {
+			infos = append(infos, HandleInfo{
+				Type:  h.typ,
+				Roots: h.roots,
+				Stack: h.stack,
+			})
+		}
+		return infos
+	})
+}
+
+// run runs `fn` on the VM goroutine and converts its result to a go value.
+func (v *Value) run(ctx context.Context, fn func(env *C.JanetTable) (C.Janet, error)) (any, error) {
+	res, err := runOnVM(ctx, v.vm, func(env *C.JanetTable) valueResult {
+		if v.roots <= 0 {
+			return valueResult{err: errReleased}
+		}
+		janetResult, err := fn(env)
+		if err != nil {
+			return valueResult{err: err}
+		}
+		value, err := v.vm.decoder(ctx).decode(janetResult)
+		return valueResult{value: value, err: err}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.value, res.err
+}
+
+// errReleased is returned when a released handle is used.
+var errReleased = errors.New("value handle is already released")
+
+// toJanet returns the janet value of the handle, for passing it to the VM `vm`.
+// This function should only be called from the VM handler goroutine.
+func (v *Value) toJanet(vm *VM) (C.Janet, error) {
+	if v.vm != vm {
+		return C.janet_wrap_nil(), errors.New("value handle belongs to another VM")
+	}
+	if v.roots <= 0 {
+		return C.janet_wrap_nil(), errReleased
+	}
+	return v.value, nil
+}
@@ -0,0 +1,277 @@
+// value_test.go
+
+package janet
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestValueHandles tests handles of janet values.
+func TestValueHandles(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	rect, err := vm.EvalHandle(ctx, `
+(def Shape @{:describe (fn [self] (string "shape with area " (:area self)))})
+(table/setproto @{:w 3 :h 4 "name" "rect"
+                  :area (fn [self] (* (self :w) (self :h)))
+                  :scale (fn [self k] (put self :w (* k (self :w))) (put self :h (* k (self :h))) self)}
+                Shape)`)
+	if err != nil {
+		t.Fatalf("Failed to evaluate handle: %v", err)
+	}
+	if rect.Type() != TypeTable {
+		t.Errorf("Expected table handle, got %s", rect.Type())
+	}
+
+	// force garbage collection, the rooted value should survive
+	if _, _, _, err := vm.Execute(ctx, `(gccollect)`); err != nil {
+		t.Fatalf("Failed to collect garbage: %v", err)
+	}
+
+	if name, err := rect.Get(ctx, "name"); err != nil || name != "rect" {
+		t.Errorf("Expected name 'rect', got %v (%v)", name, err)
+	}
+	if area, err := rect.Invoke(ctx, ":area"); err != nil || area != float64(12) {
+		t.Errorf("Expected area 12, got %v (%v)", area, err)
+	}
+	if _, err := rect.Invoke(ctx, "scale", 2); err != nil {
+		t.Errorf("Failed to invoke method with arguments: %v", err)
+	}
+	if description, err := rect.Invoke(ctx, ":describe"); err != nil || description != "shape with area 48" {
+		t.Errorf("Expected description from prototype, got %v (%v)", description, err)
+	}
+	if _, err := rect.Invoke(ctx, ":no-such-method"); err == nil {
+		t.Errorf("Expected error for unknown method")
+	}
+
+	// handles can be passed as arguments
+	if length, err := vm.Apply(ctx, "length", rect); err != nil || length != float64(5) {
+		t.Errorf("Expected length 5 of handle, got %v (%v)", length, err)
+	}
+
+	// abstract values (`int/*` is not available without int types)
+	if Build().IntTypes {
+		number, err := vm.EvalHandle(ctx, `(int/s64 5)`)
+		if err != nil {
+			t.Fatalf("Failed to evaluate abstract handle: %v", err)
+		}
+		if number.Type() != TypeAbstract {
+			t.Errorf("Expected abstract handle, got %s", number.Type())
+		}
+		if sum, err := number.Invoke(ctx, "+", 1); err != nil || sum != int64(6) {
+			t.Errorf("Expected sum 6, got %v (%v)", sum, err)
+		}
+	}
+
+	// released handles
+	if err := rect.Release(ctx); err != nil {
+		t.Errorf("Failed to release handle: %v", err)
+	}
+	if err := rect.Release(ctx); err != nil {
+		t.Errorf("Failed to release handle twice: %v", err)
+	}
+	if _, err := rect.Get(ctx, "name"); err == nil || !strings.Contains(err.Error(), "released") {
+		t.Errorf("Expected error for released handle, got: %v", err)
+	}
+	if _, err := vm.Apply(ctx, "length", rect); err == nil || !strings.Contains(err.Error(), "released") {
+		t.Errorf("Expected error for passing released handle, got: %v", err)
+	}
+
+	// handles of other VMs
+	other, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer other.Close()
+	list, err := vm.EvalHandle(ctx, `@[1 2]`)
+	if err != nil {
+		t.Fatalf("Failed to evaluate handle: %v", err)
+	}
+	if _, err := other.Apply(ctx, "type", list); err == nil || !strings.Contains(err.Error(), "another VM") {
+		t.Errorf("Expected error for handle of another VM, got: %v", err)
+	}
+}
+
+// TestValueIter tests iterations over janet collections.
+func TestValueIter(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	// large arrays are converted in pages
+	numbers, err := vm.EvalHandle(ctx, `(range 3000)`)
+	if err != nil {
+		t.Fatalf("Failed to evaluate handle: %v", err)
+	}
+	count := 0
+	for entry, err := range numbers.Iter(ctx) {
+		if err != nil {
+			t.Fatalf("Failed to iterate: %v", err)
+		}
+		if entry.Key != count || entry.Value != float64(count) {
+			t.Fatalf("Unexpected entry #%d: %+v", count, entry)
+		}
+		count++
+	}
+	if count != 3000 {
+		t.Errorf("Expected 3000 elements, got %d", count)
+	}
+
+	// stop early
+	count = 0
+	for range numbers.Iter(ctx) {
+		if count++; count == 10 {
+			break
+		}
+	}
+
+	// tables and structs
+	for _, expr := range []string{`(tabseq [i :range [0 2000]] i (* i 2))`, `(struct ;(mapcat |[$ (* $ 2)] (range 2000)))`} {
+		table, err := vm.EvalHandle(ctx, expr)
+		if err != nil {
+			t.Fatalf("Failed to evaluate handle: %v", err)
+		}
+		sum := 0.0
+		count := 0
+		for entry, err := range table.Iter(ctx) {
+			if err != nil {
+				t.Fatalf("Failed to iterate over '%s': %v", expr, err)
+			}
+			if entry.Value != entry.Key.(float64)*2 {
+				t.Fatalf("Unexpected entry of '%s': %+v", expr, entry)
+			}
+			sum += entry.Key.(float64)
+			count++
+		}
+		if count != 2000 || sum != 1999*1000 {
+			t.Errorf("Expected 2000 entries of '%s', got %d (sum: %v)", expr, count, sum)
+		}
+	}
+
+	// not iterable
+	number, err := vm.EvalHandle(ctx, `42`)
+	if err != nil {
+		t.Fatalf("Failed to evaluate handle: %v", err)
+	}
+	for _, err := range number.Iter(ctx) {
+		if err == nil || !strings.Contains(err.Error(), "cannot iterate") {
+			t.Errorf("Expected error for iterating over a number, got: %v", err)
+		}
+	}
+}
+
+// TestValueRoots tests roots of value handles and diagnostics of their leaks.
+func TestValueRoots(t *testing.T) {
+	vm, err := NewVM(WithHandleStacks())
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	table, err := vm.EvalHandle(ctx, `@{"a" 1}`)
+	if err != nil {
+		t.Fatalf("Failed to evaluate handle: %v", err)
+	}
+	leaked, err := vm.EvalHandle(ctx, `@[1 2 3]`)
+	if err != nil {
+		t.Fatalf("Failed to evaluate handle: %v", err)
+	}
+	if roots := vm.LiveRoots(); roots != 2 {
+		t.Errorf("Expected 2 live roots, got %d", roots)
+	}
+
+	// add a root for another owner
+	if err := table.Root(ctx); err != nil {
+		t.Fatalf("Failed to root handle: %v", err)
+	}
+	if err := table.Unroot(ctx); err != nil {
+		t.Fatalf("Failed to unroot handle: %v", err)
+	}
+	if _, _, _, err := vm.Execute(ctx, `(gccollect)`); err != nil {
+		t.Fatalf("Failed to collect garbage: %v", err)
+	}
+	if a, err := table.Get(ctx, "a"); err != nil || a != float64(1) {
+		t.Errorf("Expected handle to be usable with remaining root, got %v (%v)", a, err)
+	}
+
+	// remove the last root
+	if err := table.Unroot(ctx); err != nil {
+		t.Fatalf("Failed to unroot handle: %v", err)
+	}
+	if err := table.Unroot(ctx); err == nil || !strings.Contains(err.Error(), "released") {
+		t.Errorf("Expected error for unrooting released handle, got: %v", err)
+	}
+	if err := table.Root(ctx); err == nil || !strings.Contains(err.Error(), "released") {
+		t.Errorf("Expected error for rooting released handle, got: %v", err)
+	}
+
+	// diagnostics
+	if roots := vm.LiveRoots(); roots != 1 {
+		t.Errorf("Expected 1 live root, got %d", roots)
+	}
+	handles, err := vm.LiveHandles(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list live handles: %v", err)
+	}
+	if len(handles) != 1 || handles[0].Type != TypeArray || handles[0].Roots != 1 || !strings.Contains(handles[0].Stack, "TestValueRoots") {
+		t.Errorf("Expected the leaked array handle with its creation stack, got: %+v", handles)
+	}
+
+	if err := leaked.Release(ctx); err != nil {
+		t.Fatalf("Failed to release handle: %v", err)
+	}
+	if roots := vm.LiveRoots(); roots != 0 {
+		t.Errorf("Expected no live roots, got %d", roots)
+	}
+}
+
+// TestValueAutoRelease tests releases of unreachable value handles.
+func TestValueAutoRelease(t *testing.T) {
+	vm, err := NewVM(WithHandleRelease(HandleReleaseAuto))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	for range 10 {
+		if _, err := vm.EvalHandle(ctx, `@{:unreachable true}`); err != nil {
+			t.Fatalf("Failed to evaluate handle: %v", err)
+		}
+	}
+	kept, err := vm.EvalHandle(ctx, `@[:kept]`)
+	if err != nil {
+		t.Fatalf("Failed to evaluate handle: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for vm.LiveRoots() > 1 && time.Now().Before(deadline) {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if roots := vm.LiveRoots(); roots != 1 {
+		t.Errorf("Expected unreachable handles to be released, got %d live roots", roots)
+	}
+
+	if length, err := vm.Apply(ctx, "length", kept); err != nil || length != float64(1) {
+		t.Errorf("Expected kept handle to be usable, got %v (%v)", length, err)
+	}
+	runtime.KeepAlive(kept)
+}
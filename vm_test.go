@@ -4,8 +4,12 @@ package janet
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"reflect"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -17,6 +21,46 @@ func TestVersion(t *testing.T) {
 	}
 }
 
+// TestBuild tests the Build function.
+func TestBuild(t *testing.T) {
+	info := Build()
+	if info.JanetVersion != Version() {
+		t.Errorf("Expected janet version '%s', got '%s'", Version(), info.JanetVersion)
+	}
+	if info.JanetBuild == "" {
+		t.Errorf("Failed to get janet build")
+	}
+
+	// (features match the functions built in, which are excluded with build tags like `janet_no_peg`)
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+	for _, feature := range []struct {
+		name     string
+		enabled  bool
+		function string
+	}{
+		{"ev", info.EV, "ev/sleep"},
+		{"net", info.Net, "net/connect"},
+		{"processes", info.Processes, "os/spawn"},
+		{"peg", info.PEG, "peg/match"},
+		{"int-types", info.IntTypes, "int/s64"},
+	} {
+		evaluated, _, _, err := vm.Execute(context.TODO(), fmt.Sprintf(`(cfunction? %s)`, feature.function))
+		if err != nil || evaluated != fmt.Sprint(feature.enabled) {
+			t.Errorf("Expected '%s' to be %v as %s is built in or not, got %s (%v)", feature.name, feature.enabled, feature.function, evaluated, err)
+		}
+		if features := info.Features(); slices.Contains(features, feature.name) != feature.enabled {
+			t.Errorf("Expected '%s' in features to be %v, got %v", feature.name, feature.enabled, features)
+		}
+	}
+	if str := info.String(); !strings.HasPrefix(str, "janet "+info.JanetVersion+"-"+info.JanetBuild) {
+		t.Errorf("Unexpected build string: '%s'", str)
+	}
+}
+
 // TestExecutions tests the Execute function.
 func TestExecutions(t *testing.T) {
 	vm, err := SharedVM()
@@ -119,7 +163,7 @@ func TestExecutions(t *testing.T) {
 		{
 			input: `(error "intentional")`,
 			expectedStderr: `error: intentional
-  in thunk pc=1
+  in thunk [<unknown>] on line 1, column 1
 `,
 			expectedErrPattern: "intentional",
 		},
@@ -152,6 +196,36 @@ func TestExecutions(t *testing.T) {
 	}
 }
 
+// TestExecuteBytes tests the ExecuteBytes function.
+func TestExecuteBytes(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	if evaluated, _, _, err := vm.ExecuteBytes(ctx, []byte(`(string/join ["a" "b"] "-")`)); err != nil {
+		t.Errorf("Failed to execute bytes: %v", err)
+	} else if evaluated != "a-b" {
+		t.Errorf("Expected 'a-b', got '%s'", evaluated)
+	}
+
+	// sources are not truncated at NULs
+	if evaluated, _, _, err := vm.ExecuteBytes(ctx, []byte("(length \"a\x00b\")")); err != nil {
+		t.Errorf("Failed to execute bytes with NUL: %v", err)
+	} else if evaluated != "3" {
+		t.Errorf("Expected '3', got '%s'", evaluated)
+	}
+
+	if evaluated, _, _, err := vm.ExecuteBytes(ctx, nil); err != nil {
+		t.Errorf("Failed to execute empty bytes: %v", err)
+	} else if evaluated != "nil" {
+		t.Errorf("Expected 'nil' for empty source, got '%s'", evaluated)
+	}
+}
+
 // TestTimedoutExecutions tests the Execute function which times out.
 func TestTimedoutExecutions(t *testing.T) {
 	vm, err := SharedVM()
@@ -164,7 +238,7 @@ func TestTimedoutExecutions(t *testing.T) {
 	timedoutCtx, cancel := context.WithTimeout(context.TODO(), 1*time.Second)
 	defer cancel()
 	if _, _, _, err := vm.Execute(timedoutCtx, `(os/sleep 3)`); err != nil {
-		if !strings.Contains(err.Error(), `context deadline exceeded`) {
+		if !strings.Contains(err.Error(), `context deadline exceeded`) || !errors.Is(err, ErrTimeout) {
 			t.Errorf("Expected timeout error, got '%s'", err)
 		}
 	} else {
@@ -253,3 +327,139 @@ func TestParseJanetString(t *testing.T) {
 		}
 	}
 }
+
+// TestClose tests calls on closed VMs.
+func TestClose(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+
+	ctx := context.TODO()
+
+	// close concurrently with executions
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, _, err := vm.Execute(ctx, `(+ 1 2)`); err != nil && !errors.Is(err, ErrClosed) {
+				t.Errorf("Expected success or ErrClosed, got: %v", err)
+			}
+		}()
+	}
+	vm.Close()
+	wg.Wait()
+	vm.Close() // should be idempotent
+
+	if _, _, _, err := vm.Execute(ctx, `(+ 1 2)`); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed from Execute, got: %v", err)
+	}
+	if _, err := vm.ParseToValue(ctx, `[1 2]`); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed from ParseToValue, got: %v", err)
+	}
+	if _, err := vm.Apply(ctx, "+", 1, 2); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed from Apply, got: %v", err)
+	}
+
+	// closing the shared VM does not affect the next shared VM
+	shared, err := SharedVM()
+	if err != nil {
+		t.Fatalf("Failed to create shared VM: %v", err)
+	}
+	shared.Close()
+	renewed, err := SharedVM()
+	if err != nil {
+		t.Fatalf("Failed to create shared VM: %v", err)
+	}
+	if renewed == shared {
+		t.Errorf("Expected a new shared VM after closing the previous one")
+	}
+	if _, _, _, err := renewed.Execute(ctx, `(+ 1 2)`); err != nil {
+		t.Errorf("Failed to execute on renewed shared VM: %v", err)
+	}
+}
+
+// TestOutputIsolation tests that outputs are captured per VM, even when VMs run concurrently.
+func TestOutputIsolation(t *testing.T) {
+	ctx := context.TODO()
+
+	var wg sync.WaitGroup
+	for i := range 4 {
+		vm, err := NewVM()
+		if err != nil {
+			t.Fatalf("Failed to create Janet VM: %v", err)
+		}
+		defer vm.Close()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := range 50 {
+				expected := fmt.Sprintf("vm%d-%d", i, j)
+				_, stdout, stderr, err := vm.Execute(ctx, fmt.Sprintf(`(print "%[1]s") (eprin "%[1]s")`, expected))
+				if err != nil {
+					t.Errorf("Failed to execute: %v", err)
+					return
+				}
+				if stdout != expected+"\n" || stderr != expected {
+					t.Errorf("Expected outputs of '%s', got stdout: '%s', stderr: '%s'", expected, stdout, stderr)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// TestOutputCaptureWithCollection tests that outputs are captured after the gc of janet collects garbages
+// (the bindings of outputs should stay reachable while capturing, in every build).
+func TestOutputCaptureWithCollection(t *testing.T) {
+	ctx := context.TODO()
+
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	for i := range 3 {
+		if evaluated, _, _, err := vm.Execute(ctx, `(do (def a @[]) (for i 0 100000 (array/push a @"x")) (length a))`); err != nil || evaluated != "100000" {
+			t.Fatalf("Expected 100000, got '%s' (%v)", evaluated, err)
+		}
+		if _, _, _, err := vm.Execute(ctx, `(gccollect)`, WithDyns(map[string]any{"garbage": "value"})); err != nil {
+			t.Fatalf("Failed to collect garbages: %v", err)
+		}
+
+		expected := fmt.Sprintf("collected %d", i)
+		if _, stdout, stderr, err := vm.Execute(ctx, fmt.Sprintf(`(print "%[1]s") (eprin "%[1]s")`, expected)); err != nil || stdout != expected+"\n" || stderr != expected {
+			t.Errorf("Expected outputs of '%s', got stdout: '%s', stderr: '%s' (%v)", expected, stdout, stderr, err)
+		}
+		if _, _, stderr, err := vm.Execute(ctx, `(+ 1`); err == nil || !strings.Contains(stderr, "parse error") {
+			t.Errorf("Expected parse error in stderr, got '%s' (%v)", stderr, err)
+		}
+	}
+}
+
+// TestWithoutCapture tests executions which skip capturing outputs.
+func TestWithoutCapture(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	if evaluated, stdout, _, err := vm.Execute(ctx, `(buffer? (dyn :out))`, WithoutCapture()); err != nil || evaluated != "false" || stdout != "" {
+		t.Errorf("Expected outputs not to be captured, got %q, %q (%v)", evaluated, stdout, err)
+	}
+	var captured bool
+	if err := vm.ExecuteInto(ctx, `(buffer? (dyn :out))`, &captured); err != nil || !captured {
+		t.Errorf("Expected outputs to be captured by default, got %v (%v)", captured, err)
+	}
+	if results, _, _, err := vm.ExecuteAllForms(ctx, `(+ 1 2) (* 2 3)`, WithoutCapture()); err != nil || len(results) != 2 {
+		t.Errorf("Expected results of forms, got %v (%v)", results, err)
+	}
+}
@@ -0,0 +1,407 @@
+// vmpool.go
+
+package janet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// PoolOptions is the options of a Pool.
+type PoolOptions struct {
+	Min int // number of VMs created on start, and kept while shrinking (default: runtime.GOMAXPROCS(0))
+	Max int // max number of VMs (default: Min, for a fixed size pool)
+
+	ScaleUpWait time.Duration // VMs are added when acquiring waits longer than this (default: 10ms)
+	IdleTTL     time.Duration // VMs idle longer than this are closed, down to Min (0 for never)
+
+	VMOptions []Option // options for creating VMs
+
+	Retry RetryPolicy // policy for retrying idempotent requests (see Pool.DoIdempotent)
+
+	OnScale func(event ScaleEvent) // called after the pool is scaled up or down, ie. Size reflects it already (should not block)
+}
+
+// RetryPolicy is the policy for retrying idempotent requests of a Pool (see Pool.DoIdempotent),
+// which fail because their VMs are closed (eg. restarted) while running them.
+type RetryPolicy struct {
+	MaxAttempts int           // max number of attempts, including the first one (no retries if <= 1)
+	Backoff     time.Duration // wait before the first retry, doubled on each retry (default: 10ms)
+	MaxBackoff  time.Duration // max wait between retries (default: 1s)
+}
+
+// ScaleEvent is a scaling decision of a Pool.
+type ScaleEvent struct {
+	Size   int    // number of VMs after scaling
+	Delta  int    // 1 for a VM added, -1 for a VM removed
+	Reason string // why the pool was scaled (eg. "wait exceeded 10ms")
+}
+
+// Pool is a pool of independent VMs for running scripts concurrently.
+//
+// Each VM has its own environment, so definitions made on one VM are not visible to the others.
+type Pool struct {
+	options PoolOptions
+
+	mu     sync.Mutex
+	size   int // number of VMs (including the ones being used)
+	closed bool
+
+	idle chan *pooledVM // VMs not being used
+	used map[*VM]*pooledVM
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// pooledVM is a VM in a pool.
+type pooledVM struct {
+	vm       *VM
+	lastUsed time.Time
+}
+
+// ErrPoolClosed is returned when a closed pool is used.
+var ErrPoolClosed = errors.New("pool is closed")
+
+// NewPool creates a new pool of VMs with `opts`.
+func NewPool(opts PoolOptions) (*Pool, error) {
+	if opts.Min <= 0 {
+		opts.Min = runtime.GOMAXPROCS(0)
+	}
+	if opts.Max < opts.Min {
+		opts.Max = opts.Min
+	}
+	if opts.ScaleUpWait <= 0 {
+		opts.ScaleUpWait = 10 * time.Millisecond
+	}
+	if opts.Retry.Backoff <= 0 {
+		opts.Retry.Backoff = 10 * time.Millisecond
+	}
+	if opts.Retry.MaxBackoff <= 0 {
+		opts.Retry.MaxBackoff = time.Second
+	}
+
+	p := &Pool{
+		options: opts,
+		idle:    make(chan *pooledVM, opts.Max),
+		used:    map[*VM]*pooledVM{},
+		done:    make(chan struct{}),
+	}
+	for range opts.Min {
+		vm, err := NewVM(opts.VMOptions...)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.size++
+		p.idle <- &pooledVM{vm: vm, lastUsed: time.Now()}
+	}
+
+	if opts.IdleTTL > 0 && opts.Max > opts.Min {
+		p.wg.Add(1)
+		go p.shrink()
+	}
+
+	return p, nil
+}
+
+// Acquire returns a VM from the pool, which should be returned with Release after use.
+//
+// When no VMs are idle for ScaleUpWait, a new VM is added to the pool (up to Max).
+// VMs closed while being used are removed from the pool, and replaced in the same way.
+func (p *Pool) Acquire(ctx context.Context) (*VM, error) {
+	select {
+	case pvm := <-p.idle:
+		if vm, err := p.use(pvm); vm != nil || err != nil {
+			return vm, err
+		}
+	default:
+	}
+
+	timer := time.NewTimer(p.options.ScaleUpWait)
+	defer timer.Stop()
+	for {
+		select {
+		case pvm := <-p.idle:
+			if vm, err := p.use(pvm); vm != nil || err != nil {
+				return vm, err
+			}
+		case <-timer.C:
+			if pvm, err := p.grow(); err != nil {
+				return nil, err
+			} else if pvm != nil {
+				return p.use(pvm)
+			}
+			// (retry later, as VMs closed while being used may be removed from the pool meanwhile)
+			timer.Reset(p.options.ScaleUpWait)
+		case <-p.done:
+			return nil, ErrPoolClosed
+		case <-ctx.Done():
+			return nil, contextError(ctx)
+		}
+	}
+}
+
+// Release returns a VM acquired with Acquire to the pool.
+func (p *Pool) Release(vm *VM) {
+	p.mu.Lock()
+	pvm, exists := p.used[vm]
+	if !exists {
+		p.mu.Unlock()
+		return
+	}
+	delete(p.used, vm)
+	if p.closed {
+		p.mu.Unlock()
+		vm.Close()
+		return
+	}
+	if vm.closed() {
+		p.remove()
+		return
+	}
+	pvm.lastUsed = time.Now()
+	p.idle <- pvm // never blocks, as its capacity is the max size
+	p.mu.Unlock()
+}
+
+// Do runs `fn` with a VM acquired from the pool, and releases the VM after it returns.
+func (p *Pool) Do(ctx context.Context, fn func(vm *VM) error) error {
+	vm, err := p.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer p.Release(vm)
+
+	return fn(vm)
+}
+
+// DoIdempotent runs `fn` with a VM acquired from the pool like Do, but retries it on another VM
+// with the pool's RetryPolicy when it fails because the VM is closed (eg. restarted) while running it.
+//
+// `fn` should be safe to run more than once (eg. evaluating pure expressions),
+// as it may have been partially run on the closed VM.
+func (p *Pool) DoIdempotent(ctx context.Context, fn func(vm *VM) error) (err error) {
+	backoff := p.options.Retry.Backoff
+	for attempt := 1; ; attempt++ {
+		err = p.Do(ctx, fn)
+		if err == nil || !isTransient(err) || attempt >= p.options.Retry.MaxAttempts {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-p.done:
+			timer.Stop()
+			return err
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+		backoff = min(backoff*2, p.options.Retry.MaxBackoff)
+	}
+}
+
+// isTransient returns whether `err` is caused by a VM closed while running a request,
+// which may succeed on another VM.
+func isTransient(err error) bool {
+	return errors.Is(err, ErrClosed)
+}
+
+// Size returns the number of VMs in the pool (including the ones being used).
+func (p *Pool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.size
+}
+
+// Close closes the idle VMs of the pool, and the ones being used when they are released.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.done)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	for {
+		select {
+		case pvm := <-p.idle:
+			pvm.vm.Close()
+		default:
+			return
+		}
+	}
+}
+
+// use marks `pvm` as being used, and returns its VM.
+//
+// It returns nil without an error if the VM is closed, removing it from the pool.
+func (p *Pool) use(pvm *pooledVM) (*VM, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		pvm.vm.Close()
+		return nil, ErrPoolClosed
+	}
+	if pvm.vm.closed() {
+		p.remove()
+		return nil, nil
+	}
+	p.used[pvm.vm] = pvm
+	p.mu.Unlock()
+	return pvm.vm, nil
+}
+
+// remove removes a closed VM from the pool, unlocking the pool which should be locked by the caller.
+func (p *Pool) remove() {
+	p.size--
+	size := p.size
+	p.mu.Unlock()
+
+	p.notify(ScaleEvent{Size: size, Delta: -1, Reason: "vm closed"})
+}
+
+// grow adds a new VM to the pool, and returns it (nil if the pool is already at its max size).
+func (p *Pool) grow() (*pooledVM, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	if p.size >= p.options.Max {
+		p.mu.Unlock()
+		return nil, nil
+	}
+	p.size++ // reserve a slot while creating a VM
+	p.mu.Unlock()
+
+	vm, err := NewVM(p.options.VMOptions...)
+	p.mu.Lock()
+	if err != nil {
+		p.size--
+		p.mu.Unlock()
+		return nil, err
+	}
+	size := p.size
+	p.mu.Unlock()
+
+	p.notify(ScaleEvent{Size: size, Delta: 1, Reason: "wait exceeded " + p.options.ScaleUpWait.String()})
+	return &pooledVM{vm: vm, lastUsed: time.Now()}, nil
+}
+
+// shrink closes VMs idle longer than IdleTTL periodically, until the pool is closed.
+func (p *Pool) shrink() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(max(p.options.IdleTTL/2, time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.done:
+			return
+		}
+
+		// check each idle VM once
+		for range len(p.idle) {
+			var pvm *pooledVM
+			select {
+			case pvm = <-p.idle:
+			default:
+			}
+			if pvm == nil {
+				break
+			}
+
+			p.mu.Lock()
+			expired := time.Since(pvm.lastUsed) > p.options.IdleTTL && p.size > p.options.Min
+			if expired {
+				p.size--
+			} else {
+				p.idle <- pvm
+			}
+			size := p.size
+			p.mu.Unlock()
+
+			if expired {
+				pvm.vm.Close()
+				p.notify(ScaleEvent{Size: size, Delta: -1, Reason: "idle longer than " + p.options.IdleTTL.String()})
+			}
+		}
+	}
+}
+
+// notify calls the scaling hook, if any.
+func (p *Pool) notify(event ScaleEvent) {
+	if p.options.OnScale != nil {
+		p.options.OnScale(event)
+	}
+}
+
+// ForEach calls `fn` with each of `inputs` concurrently on VMs acquired from `pool`,
+// with at most the pool's max size of calls running at once.
+//
+// All inputs are processed even when some of them fail,
+// and the errors are returned joined together with the indices of their inputs.
+func ForEach[T any](
+	ctx context.Context,
+	pool *Pool,
+	inputs []T,
+	fn func(ctx context.Context, vm *VM, item T) error,
+) error {
+	indices := make(chan int)
+	errs := make([]error, len(inputs))
+
+	var wg sync.WaitGroup
+	for range min(pool.options.Max, len(inputs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				errs[i] = pool.Do(ctx, func(vm *VM) error {
+					return fn(ctx, vm, inputs[i])
+				})
+				if errs[i] != nil {
+					errs[i] = fmt.Errorf("input #%d: %w", i, errs[i])
+				}
+			}
+		}()
+	}
+	for i := range inputs {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// ParseToValues parses each of `janetExpressions` into a go value (in the same way as VM.ParseToValue)
+// concurrently on VMs of the pool, and returns the values in the same order.
+//
+// Values of failed expressions are nil, and the errors are returned joined together.
+func (p *Pool) ParseToValues(
+	ctx context.Context,
+	janetExpressions []string,
+) (values []any, err error) {
+	values = make([]any, len(janetExpressions))
+	indices := make([]int, len(janetExpressions))
+	for i := range indices {
+		indices[i] = i
+	}
+
+	err = ForEach(ctx, p, indices, func(ctx context.Context, vm *VM, i int) (err error) {
+		values[i], err = vm.ParseToValue(ctx, janetExpressions[i])
+		return err
+	})
+	return values, err
+}
@@ -0,0 +1,302 @@
+// vmpool_test.go
+
+package janet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestPool tests pools of VMs.
+func TestPool(t *testing.T) {
+	ctx := context.TODO()
+
+	pool, err := NewPool(PoolOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create pool: %v", err)
+	}
+	if size := pool.Size(); size != runtime.GOMAXPROCS(0) {
+		t.Errorf("Expected pool of GOMAXPROCS VMs, got %d", size)
+	}
+
+	if err := pool.Do(ctx, func(vm *VM) error {
+		evaluated, _, _, err := vm.Execute(ctx, `(+ 1 2)`)
+		if err == nil && evaluated != "3" {
+			err = errors.New("unexpected result: " + evaluated)
+		}
+		return err
+	}); err != nil {
+		t.Errorf("Failed to execute on pool: %v", err)
+	}
+
+	pool.Close()
+	if _, err := pool.Acquire(ctx); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Expected error for closed pool, got: %v", err)
+	}
+}
+
+// TestPoolAutoscale tests scaling pools of VMs up and down.
+func TestPoolAutoscale(t *testing.T) {
+	ctx := context.TODO()
+
+	var mu sync.Mutex
+	var events []ScaleEvent
+	pool, err := NewPool(PoolOptions{
+		Min:         1,
+		Max:         3,
+		ScaleUpWait: time.Millisecond,
+		IdleTTL:     50 * time.Millisecond,
+		OnScale: func(event ScaleEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, event)
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create pool: %v", err)
+	}
+	defer pool.Close()
+
+	// scale up while all VMs are busy
+	var vms []*VM
+	for range 4 {
+		acquireCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		vm, err := pool.Acquire(acquireCtx)
+		cancel()
+		if err != nil {
+			if len(vms) != 3 || !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Expected to acquire up to 3 VMs, failed with %d: %v", len(vms), err)
+			}
+			break
+		}
+		vms = append(vms, vm)
+	}
+	if size := pool.Size(); size != 3 {
+		t.Errorf("Expected pool of 3 VMs, got %d", size)
+	}
+	for _, vm := range vms {
+		pool.Release(vm)
+	}
+
+	// counts scale events (which are notified after the size changes)
+	count := func() (ups, downs int) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, event := range events {
+			if event.Delta > 0 {
+				ups++
+			} else {
+				downs++
+			}
+		}
+		return ups, downs
+	}
+
+	// scale down after idle TTL
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, downs := count(); downs >= 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if size := pool.Size(); size != 1 {
+		t.Errorf("Expected pool to shrink to 1 VM, got %d", size)
+	}
+	if ups, downs := count(); ups != 2 || downs != 2 {
+		mu.Lock()
+		defer mu.Unlock()
+		t.Errorf("Expected 2 scale-ups and 2 scale-downs, got: %+v", events)
+	}
+}
+
+// TestForEach tests processing inputs concurrently on a pool.
+func TestForEach(t *testing.T) {
+	ctx := context.TODO()
+
+	pool, err := NewPool(PoolOptions{Min: 2})
+	if err != nil {
+		t.Fatalf("Failed to create pool: %v", err)
+	}
+	defer pool.Close()
+
+	inputs := []string{`(+ 1 2)`, `(* 2 3)`, `(error "oops")`, `(- 10 1)`, `(undefined-fn)`}
+	results := make([]string, len(inputs))
+	err = ForEach(ctx, pool, inputs, func(ctx context.Context, vm *VM, expr string) error {
+		evaluated, _, _, err := vm.Execute(ctx, expr)
+		if err != nil {
+			return err
+		}
+		for i := range inputs {
+			if inputs[i] == expr {
+				results[i] = evaluated
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		t.Fatalf("Expected errors for failing inputs")
+	}
+	for _, expected := range []string{"input #2:", "input #4:"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to contain '%s', got: %v", expected, err)
+		}
+	}
+	if expected := []string{"3", "6", "", "9", ""}; !slices.Equal(results, expected) {
+		t.Errorf("Expected results %v, got %v", expected, results)
+	}
+}
+
+// TestPoolParseToValues tests parsing expressions concurrently on a pool.
+func TestPoolParseToValues(t *testing.T) {
+	ctx := context.TODO()
+
+	pool, err := NewPool(PoolOptions{Min: 2})
+	if err != nil {
+		t.Fatalf("Failed to create pool: %v", err)
+	}
+	defer pool.Close()
+
+	var exprs []string
+	for i := range 100 {
+		exprs = append(exprs, fmt.Sprintf(`{:id %d :tags ["a" "b"]}`, i))
+	}
+	exprs = append(exprs, `{:malformed`)
+
+	values, err := pool.ParseToValues(ctx, exprs)
+	if err == nil || !strings.Contains(err.Error(), "input #100:") {
+		t.Errorf("Expected error for the malformed input, got: %v", err)
+	}
+	for i, value := range values[:100] {
+		if m, ok := value.(map[any]any); !ok || m[":id"] != float64(i) {
+			t.Errorf("Unexpected value #%d: %v", i, value)
+		}
+	}
+	if values[100] != nil {
+		t.Errorf("Expected nil for the malformed input, got: %v", values[100])
+	}
+}
+
+// TestPoolRetry tests retrying idempotent requests on VMs closed while running them.
+func TestPoolRetry(t *testing.T) {
+	ctx := context.TODO()
+
+	var mu sync.Mutex
+	var reasons []string
+	pool, err := NewPool(PoolOptions{
+		Min:         1,
+		ScaleUpWait: time.Millisecond,
+		Retry:       RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond},
+		OnScale: func(event ScaleEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			reasons = append(reasons, event.Reason)
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create pool: %v", err)
+	}
+	defer pool.Close()
+
+	// closed VMs are replaced, and requests are retried on the new ones
+	attempts := 0
+	err = pool.DoIdempotent(ctx, func(vm *VM) error {
+		attempts++
+		if attempts == 1 {
+			vm.Close() // eg. restarted while running the request
+		}
+		_, _, _, err := vm.Execute(ctx, `(+ 1 2)`)
+		return err
+	})
+	if err != nil || attempts != 2 {
+		t.Errorf("Expected success on the second attempt, got %d attempts (%v)", attempts, err)
+	}
+	if size := pool.Size(); size != 1 {
+		t.Errorf("Expected the closed VM replaced, got %d VMs", size)
+	}
+	mu.Lock()
+	if !slices.Contains(reasons, "vm closed") {
+		t.Errorf("Expected scale event for the closed VM, got: %v", reasons)
+	}
+	mu.Unlock()
+
+	// attempts are limited
+	attempts = 0
+	err = pool.DoIdempotent(ctx, func(vm *VM) error {
+		attempts++
+		vm.Close()
+		_, _, _, err := vm.Execute(ctx, `(+ 1 2)`)
+		return err
+	})
+	if !errors.Is(err, ErrClosed) || attempts != 3 {
+		t.Errorf("Expected ErrClosed after 3 attempts, got %d attempts (%v)", attempts, err)
+	}
+
+	// other errors are not retried, nor requests with Do
+	attempts = 0
+	if err := pool.DoIdempotent(ctx, func(vm *VM) error {
+		attempts++
+		_, _, _, err := vm.Execute(ctx, `(error "failed")`)
+		return err
+	}); err == nil || attempts != 1 {
+		t.Errorf("Expected no retries for script errors, got %d attempts (%v)", attempts, err)
+	}
+	attempts = 0
+	if err := pool.Do(ctx, func(vm *VM) error {
+		attempts++
+		vm.Close()
+		_, _, _, err := vm.Execute(ctx, `(+ 1 2)`)
+		return err
+	}); !errors.Is(err, ErrClosed) || attempts != 1 {
+		t.Errorf("Expected no retries with Do, got %d attempts (%v)", attempts, err)
+	}
+}
+
+// TestPoolReplaceWhileWaiting tests acquiring VMs while waiting for a VM which is closed while being used.
+func TestPoolReplaceWhileWaiting(t *testing.T) {
+	ctx := context.TODO()
+
+	pool, err := NewPool(PoolOptions{
+		Min:         1,
+		Max:         1,
+		ScaleUpWait: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create pool: %v", err)
+	}
+	defer pool.Close()
+
+	vm, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Failed to acquire VM: %v", err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() {
+		acquireCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		waited, err := pool.Acquire(acquireCtx)
+		if err == nil {
+			pool.Release(waited)
+		}
+		acquired <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond) // (wait longer than ScaleUpWait at max size)
+	vm.Close()
+	pool.Release(vm)
+
+	if err := <-acquired; err != nil {
+		t.Errorf("Expected a replaced VM for the waiting caller, got: %v", err)
+	}
+	if size := pool.Size(); size != 1 {
+		t.Errorf("Expected pool of 1 VM, got %d", size)
+	}
+}
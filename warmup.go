@@ -0,0 +1,61 @@
+// warmup.go
+
+package janet
+
+/*
+#include "janet.h"
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+)
+
+// janet source of the helper function which loads a module into the module cache
+const moduleRequirerSource = `(fn [spec] (require spec) nil)`
+
+// Warmup prepares the VM for its first user-facing requests (eg. on startup of a server),
+// so that they do not pay for loading modules and compiling internal helpers.
+//
+// Modules `specs` (eg. "spork/json", or "./lib/util", as `import` accepts) are loaded into the module cache
+// without binding anything in the VM, so that later `(import ...)` of them only bind their definitions.
+// Helper functions used internally (eg. by Apply, CompilePEG, or WithRender(RenderJDN)) are also compiled.
+//
+// Loaded modules are kept after VM.Reset, but the helpers are compiled again on their first use after it.
+func (vm *VM) Warmup(
+	ctx context.Context,
+	specs []string,
+) error {
+	res, err := runOnVM(ctx, vm, func(env *C.JanetTable) error {
+		for _, helper := range vm.helpers() {
+			if *helper.fn != nil {
+				continue
+			}
+			compiled, err := compileHelper(env, helper.source)
+			if err != nil {
+				return fmt.Errorf("failed to compile helper: %w", err)
+			}
+			*helper.fn = compiled
+		}
+
+		if len(specs) == 0 {
+			return nil
+		}
+		requirer, err := compileHelper(env, moduleRequirerSource)
+		if err != nil {
+			return err
+		}
+		defer C.janet_gcunroot(C.janet_wrap_function(requirer))
+		for _, spec := range specs {
+			if _, err := pcall(env, requirer, janetString(spec)); err != nil {
+				return fmt.Errorf("failed to load module %s: %w", spec, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return res
+}
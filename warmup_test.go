@@ -0,0 +1,61 @@
+// warmup_test.go
+
+package janet
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestWarmup tests preloading modules and compiling helpers.
+func TestWarmup(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "preloaded.janet"), []byte(`(def answer 42)`), 0o644); err != nil {
+		t.Fatalf("Failed to write module: %v", err)
+	}
+
+	var loaded []string
+	vm, err := NewVM(WithSyspath(dir), WithModuleVerifier(func(path string) error {
+		loaded = append(loaded, filepath.Base(path))
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	if err := vm.Warmup(ctx, []string{"preloaded"}); err != nil {
+		t.Fatalf("Failed to warm up: %v", err)
+	}
+	if strings.Join(loaded, ",") != "preloaded.janet" {
+		t.Errorf("Expected module loaded on warmup, got: %v", loaded)
+	}
+	for _, helper := range vm.helpers() {
+		if *helper.fn == nil {
+			t.Errorf("Expected helper compiled on warmup: %s", helper.source)
+		}
+	}
+
+	// nothing is bound on warmup
+	if _, _, _, err := vm.Execute(ctx, `preloaded/answer`); err == nil {
+		t.Errorf("Expected no bindings from warmup")
+	}
+
+	// cached module is imported without loading it again
+	if evaluated, _, _, err := vm.Execute(ctx, `(import preloaded) preloaded/answer`); err != nil || evaluated != "42" {
+		t.Errorf("Expected preloaded module imported, got: %s (%v)", evaluated, err)
+	}
+	if len(loaded) != 1 {
+		t.Errorf("Expected module not loaded again, got: %v", loaded)
+	}
+
+	// unknown module
+	if err := vm.Warmup(ctx, []string{"nonexistent"}); err == nil || !strings.Contains(err.Error(), "nonexistent") {
+		t.Errorf("Expected error naming the module, got: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+// watch.go
+
+package janet
+
+/*
+#include <stdlib.h>
+
+#include "janet.h"
+
+// returns the reference of the var bound to `name` in `env` (NULL if it is not a var).
+static JanetArray *varRef(JanetTable *env, const char *name) {
+    Janet entry = janet_table_rawget(env, janet_csymbolv(name));
+    if (!janet_checktype(entry, JANET_TABLE)) return NULL;
+    Janet ref = janet_table_get(janet_unwrap_table(entry), janet_ckeywordv("ref"));
+    if (!janet_checktype(ref, JANET_ARRAY) || janet_unwrap_array(ref)->count < 1) return NULL;
+    return janet_unwrap_array(ref);
+}
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"unsafe"
+)
+
+// varWatcher is a watcher of a janet var (only accessed from the VM handler goroutine).
+type varWatcher struct {
+	ctx  context.Context
+	name *C.char  // name of the var
+	last C.Janet  // last seen value of the var (gc-rooted)
+	ch   chan any // channel for sending new values
+}
+
+// WatchVar returns a channel which receives the new value (converted to a go value) of the var `name`
+// (defined with `var`, or with `def` in a VM created with WithRedefinable) whenever it is set by janet code.
+//
+// Values are checked after each execution on the VM, so a var set multiple times in an execution
+// is notified once with its last value. The channel keeps only the latest value which is not received yet,
+// and values which fail to be converted are not sent.
+//
+// The channel is closed when `ctx` is done, or the VM is closed.
+func (vm *VM) WatchVar(
+	ctx context.Context,
+	name string,
+) (<-chan any, error) {
+	watcher := &varWatcher{
+		ctx: ctx,
+		ch:  make(chan any, 1),
+	}
+
+	res, err := runOnVM(ctx, vm, func(env *C.JanetTable) error {
+		watcher.name = C.CString(name)
+		ref := C.varRef(env, watcher.name)
+		if ref == nil {
+			C.free(unsafe.Pointer(watcher.name))
+			return fmt.Errorf("not a var: %s", name)
+		}
+		watcher.last = *ref.data
+		C.janet_gcroot(watcher.last)
+		vm.watchers = append(vm.watchers, watcher)
+		return nil
+	})
+	if err == nil {
+		err = res
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	context.AfterFunc(ctx, func() {
+		_, _ = runOnVM(context.Background(), vm, func(env *C.JanetTable) struct{} {
+			vm.removeWatcher(watcher)
+			return struct{}{}
+		})
+	})
+
+	return watcher.ch, nil
+}
+
+// notifyWatchers sends values of watched vars which were changed since the last check.
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) notifyWatchers(env *C.JanetTable) {
+	for _, watcher := range vm.watchers {
+		ref := C.varRef(env, watcher.name)
+		if ref == nil || C.janet_equals(*ref.data, watcher.last) != 0 {
+			continue
+		}
+		C.janet_gcunroot(watcher.last)
+		watcher.last = *ref.data
+		C.janet_gcroot(watcher.last)
+
+		value, err := vm.decoder(watcher.ctx).decode(watcher.last)
+		if err != nil {
+			continue
+		}
+		// replace the value which is not received yet
+		select {
+		case <-watcher.ch:
+		default:
+		}
+		watcher.ch <- value
+	}
+}
+
+// removeWatcher removes `watcher` and closes its channel (if it is not removed yet).
+// This function should only be called from the VM handler goroutine.
+func (vm *VM) removeWatcher(watcher *varWatcher) {
+	index := slices.Index(vm.watchers, watcher)
+	if index < 0 {
+		return
+	}
+	vm.watchers = slices.Delete(vm.watchers, index, index+1)
+
+	C.janet_gcunroot(watcher.last)
+	C.free(unsafe.Pointer(watcher.name))
+	close(watcher.ch)
+}
@@ -0,0 +1,95 @@
+// watch_test.go
+
+package janet
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestWatchVar tests watching vars from go.
+func TestWatchVar(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	if _, _, _, err := vm.Execute(ctx, `(var config {:level 1}) (def constant 1)`); err != nil {
+		t.Fatalf("Failed to define vars: %v", err)
+	}
+	if _, err := vm.WatchVar(ctx, "constant"); err == nil {
+		t.Errorf("Expected error for watching a def")
+	}
+	if _, err := vm.WatchVar(ctx, "undefined"); err == nil {
+		t.Errorf("Expected error for watching an undefined binding")
+	}
+
+	changes, err := vm.WatchVar(ctx, "config")
+	if err != nil {
+		t.Fatalf("Failed to watch var: %v", err)
+	}
+
+	receive := func() any {
+		select {
+		case value := <-changes:
+			return value
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for a change")
+			return nil
+		}
+	}
+
+	// set in an execution
+	if _, _, _, err := vm.Execute(ctx, `(set config {:level 2})`); err != nil {
+		t.Fatalf("Failed to set var: %v", err)
+	}
+	if value := receive(); !reflect.DeepEqual(value, map[any]any{":level": float64(2)}) {
+		t.Errorf("Unexpected value: %v", value)
+	}
+
+	// not notified when unchanged
+	if _, _, _, err := vm.Execute(ctx, `(set config {:level 2})`); err != nil {
+		t.Fatalf("Failed to set var: %v", err)
+	}
+	select {
+	case value := <-changes:
+		t.Errorf("Unexpected notification: %v", value)
+	default:
+	}
+
+	// only the latest value is kept
+	for _, level := range []string{"3", "4"} {
+		if _, _, _, err := vm.Execute(ctx, `(set config {:level `+level+`})`); err != nil {
+			t.Fatalf("Failed to set var: %v", err)
+		}
+	}
+	if value := receive(); !reflect.DeepEqual(value, map[any]any{":level": float64(4)}) {
+		t.Errorf("Unexpected value: %v", value)
+	}
+
+	// closed when the context is done
+	cancel()
+	select {
+	case _, ok := <-changes:
+		if ok {
+			t.Errorf("Expected closed channel")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Timed out waiting for the channel to be closed")
+	}
+
+	// closed when the VM is closed
+	changes, err = vm.WatchVar(context.TODO(), "config")
+	if err != nil {
+		t.Fatalf("Failed to watch var: %v", err)
+	}
+	vm.Close()
+	if _, ok := <-changes; ok {
+		t.Errorf("Expected closed channel")
+	}
+}
@@ -0,0 +1,24 @@
+// workdir_linux.go
+
+//go:build linux
+
+package janet
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// enterWorkdir makes the current thread have its own working directory, and changes it to `dir`.
+//
+// When `isolated` is true, the thread's working directory is no longer shared with the process,
+// so the thread should not be unlocked (and be terminated with its goroutine).
+func enterWorkdir(dir string) (isolated bool, err error) {
+	if err := syscall.Unshare(syscall.CLONE_FS); err != nil {
+		return false, fmt.Errorf("failed to isolate working directory: %w", err)
+	}
+	if err := syscall.Chdir(dir); err != nil {
+		return true, fmt.Errorf("failed to change working directory to '%s': %w", dir, err)
+	}
+	return true, nil
+}
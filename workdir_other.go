@@ -0,0 +1,14 @@
+// workdir_other.go
+
+//go:build !linux
+
+package janet
+
+import (
+	"errors"
+)
+
+// enterWorkdir is not supported on this platform, as the working directory cannot be isolated from the process.
+func enterWorkdir(dir string) (isolated bool, err error) {
+	return false, errors.New("working directory of VMs is only supported on linux")
+}
@@ -0,0 +1,68 @@
+// workdir_test.go
+
+package janet
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// TestWorkdir tests working directories of VMs.
+func TestWorkdir(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		if _, err := NewVM(WithWorkdir(t.TempDir())); err == nil {
+			t.Errorf("Expected error for working directory on %s", runtime.GOOS)
+		}
+		return
+	}
+
+	ctx := context.TODO()
+
+	hostWd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+
+	dirs := []string{t.TempDir(), t.TempDir()}
+	vms := make([]*VM, len(dirs))
+	for i, dir := range dirs {
+		if err := os.WriteFile(filepath.Join(dir, "name.txt"), []byte(dir), 0o644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if vms[i], err = NewVM(WithWorkdir(dir)); err != nil {
+			t.Fatalf("Failed to create Janet VM: %v", err)
+		}
+		defer vms[i].Close()
+	}
+
+	for i, vm := range vms {
+		if evaluated, _, _, err := vm.Execute(ctx, `(os/cwd)`); err != nil || evaluated != dirs[i] {
+			t.Errorf("Expected cwd '%s', got: %s (%v)", dirs[i], evaluated, err)
+		}
+		if evaluated, _, _, err := vm.Execute(ctx, `(slurp "name.txt")`); err != nil || evaluated != dirs[i] {
+			t.Errorf("Expected relative path resolved in '%s', got: %s (%v)", dirs[i], evaluated, err)
+		}
+	}
+
+	// changing directories in a VM
+	if _, _, _, err := vms[0].Execute(ctx, `(os/mkdir "sub") (os/cd "sub")`); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	if evaluated, _, _, err := vms[0].Execute(ctx, `(os/cwd)`); err != nil || evaluated != filepath.Join(dirs[0], "sub") {
+		t.Errorf("Expected changed cwd, got: %s (%v)", evaluated, err)
+	}
+	if evaluated, _, _, err := vms[1].Execute(ctx, `(os/cwd)`); err != nil || evaluated != dirs[1] {
+		t.Errorf("Expected cwd of other VMs unchanged, got: %s (%v)", evaluated, err)
+	}
+	if wd, err := os.Getwd(); err != nil || wd != hostWd {
+		t.Errorf("Expected host's cwd unchanged, got: %s (%v)", wd, err)
+	}
+
+	// directories which do not exist
+	if _, err := NewVM(WithWorkdir(filepath.Join(dirs[0], "nonexistent"))); err == nil {
+		t.Errorf("Expected error for nonexistent working directory")
+	}
+}
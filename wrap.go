@@ -0,0 +1,89 @@
+// wrap.go
+
+package janet
+
+/*
+#include "janet.h"
+
+// defined in callbacks.go
+extern void goDeleteHandle(uintptr_t handle);
+
+static int goValueGC(void *data, size_t len) {
+    (void)len;
+    goDeleteHandle(*(uintptr_t *)data);
+    return 0;
+}
+
+static const JanetAbstractType goValueType = {
+    "go/value",
+    goValueGC,
+    JANET_ATEND_GCMARK
+};
+
+static Janet wrapGoHandle(uintptr_t handle) {
+    uintptr_t *data = janet_abstract(&goValueType, sizeof(uintptr_t));
+    *data = handle;
+    return janet_wrap_abstract(data);
+}
+
+// returns 0 if `value` is not a wrapped go value
+static uintptr_t unwrapGoHandle(Janet value) {
+    uintptr_t *data = janet_checkabstract(value, &goValueType);
+    return data ? *data : 0;
+}
+*/
+import "C"
+
+import (
+	"runtime/cgo"
+	"sync/atomic"
+)
+
+// number of go values which are currently referenced from janet
+var liveGoValues atomic.Int64
+
+// GoValue is a go value wrapped with VM.Wrap, which is stored in janet
+// as an opaque abstract value (of type `go/value`).
+//
+// Scripts can carry it through their data structures, and when it is converted
+// back to go (eg. with VM.ParseToValue), the same *GoValue is returned.
+type GoValue struct {
+	value any
+}
+
+// Wrap wraps a go `value` (eg. a database handle, or a session)
+// so that it can be passed into the VM (eg. with VM.Define) as an opaque janet value.
+//
+// The go value is kept alive while it is referenced from janet,
+// and is released when janet garbage-collects it.
+func (vm *VM) Wrap(value any) *GoValue {
+	return &GoValue{value: value}
+}
+
+// Unwrap returns the go value wrapped in `value` (a *GoValue converted from janet).
+//
+// `ok` is false if `value` is not a wrapped go value.
+func (vm *VM) Unwrap(value any) (unwrapped any, ok bool) {
+	if wrapped, ok := value.(*GoValue); ok && wrapped != nil {
+		return wrapped.value, true
+	}
+	return nil, false
+}
+
+// wrapGoValue creates a janet abstract value which references `wrapped`.
+// This function should only be called from the VM handler goroutine.
+func wrapGoValue(wrapped *GoValue) C.Janet {
+	liveGoValues.Add(1)
+	return C.wrapGoHandle(C.uintptr_t(cgo.NewHandle(wrapped)))
+}
+
+// unwrapGoValue returns the *GoValue referenced by a janet abstract value,
+// or nil if `value` is not a wrapped go value.
+// This function should only be called from the VM handler goroutine.
+func unwrapGoValue(value C.Janet) *GoValue {
+	handle := C.unwrapGoHandle(value)
+	if handle == 0 {
+		return nil
+	}
+	return cgo.Handle(handle).Value().(*GoValue)
+}
@@ -0,0 +1,64 @@
+// wrap_test.go
+
+package janet
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestWrap tests the Wrap and Unwrap functions.
+func TestWrap(t *testing.T) {
+	vm, err := NewVM()
+	if err != nil {
+		t.Fatalf("Failed to create Janet VM: %v", err)
+	}
+	defer vm.Close()
+
+	ctx := context.TODO()
+
+	type session struct{ id int }
+	sess := &session{id: 42}
+
+	live := liveGoValues.Load()
+
+	wrapped := vm.Wrap(sess)
+	if err := vm.Define(ctx, "sess", wrapped); err != nil {
+		t.Fatalf("Failed to define wrapped value: %v", err)
+	}
+
+	// carried through janet data structures
+	if evaluated, _, _, err := vm.Execute(ctx, "(type sess)"); err != nil || evaluated != ":go/value" {
+		t.Errorf("Expected type :go/value, got '%s' (error: %v)", evaluated, err)
+	}
+	value, err := vm.ParseToValue(ctx, "(get @{:sessions @[sess]} :sessions)")
+	if err != nil {
+		t.Fatalf("Failed to parse value: %v", err)
+	}
+	sessions, ok := value.([]any)
+	if !ok || len(sessions) != 1 || sessions[0] != wrapped {
+		t.Fatalf("Expected the wrapped value, got %#v", value)
+	}
+	if unwrapped, ok := vm.Unwrap(sessions[0]); !ok || unwrapped != sess {
+		t.Errorf("Expected the original go value, got %#v", unwrapped)
+	}
+	if _, ok := vm.Unwrap("not wrapped"); ok {
+		t.Errorf("Expected non-wrapped value not to be unwrapped")
+	}
+
+	// released when garbage-collected in janet
+	if _, _, _, err := vm.Execute(ctx, "(put (curenv) 'sess nil) (gccollect)"); err != nil {
+		t.Fatalf("Failed to collect garbage: %v", err)
+	}
+	for range 10 {
+		if liveGoValues.Load() == live {
+			break
+		}
+		_, _, _, _ = vm.Execute(ctx, "(gccollect)")
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := liveGoValues.Load(); n != live {
+		t.Errorf("Expected wrapped values to be released, %d remaining", n-live)
+	}
+}